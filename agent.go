@@ -1,43 +1,124 @@
 package gopheract
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/openai/openai-go/v2"
 )
 
 // Base interface for the ReactAgent
 type ReActAgent interface {
-	BuildChatHistory() any
+	BuildChatHistory() (any, error)
 	BuildSystemPrompt() (*ChatMessage, error)
 	Think() (string, error)
 	Act() (*Action, error)
 	Observe() (string, error)
 	Run(string, func(string), func(Action), func(any), func(string), func(string)) error
+	RunEvents(string, func(AgentEvent)) error
 }
 
+// Error returned by `Run` when the model did not stop within the maximum number of steps of the agent
+var ErrMaxStepsReached = errors.New("the run reached the maximum number of steps")
+
+// Error returned by `RunEventsWithImages` when images are attached to the prompt of a model known not to accept them
+var ErrImagesNotSupported = errors.New("the model does not accept images")
+
 // Struct type that implements the ReActAgent interface for OpenAI
 type OpenAIReActAgent struct {
-	Llm                  *OpenAILLM
-	ChatHistory          []*ChatMessage
+	// Optional name of the agent, identifying it among nested runs to detect cycles (e.g. agents transferring the conversation back and forth)
+	Name string
+	Llm  *OpenAILLM
+	// Store holding the chat history of the agent
+	Memory HistoryStore
+	// Identifier of the session whose history the agent works on
+	SessionID            string
 	SystemPromptTemplate *template.Template
-	Tools                []Tool
+	// Optional working directory of the session, stated in the system prompt so that the model knows where its relative paths and commands are resolved
+	WorkingDir string
+	Tools      []Tool
+	// Optional cache for tool results, shared across runs
+	ToolCache *ToolCache
+	// Whether to re-execute the calls of read-only tools identical to ones already executed in the same run, instead of reusing their result
+	DisableDuplicateDetection bool
+	// Names of the tools without side effects, whose calls identical to one already executed in the same run reuse its result. Any other tool is always executed, and clears the results recorded so far since it might have changed them (e.g. writing a file that was read before)
+	ReadOnlyTools []string
+	// Whether to execute the tool calls of a `tool_calls` action concurrently
+	ParallelToolCalls bool
+	// Maximum number of think-act-observe iterations of a run, after which it fails with ErrMaxStepsReached (zero means no limit)
+	MaxSteps int
+	// Optional policy fitting the chat history into the context window of the model before each LLM call
+	ContextPolicy *ContextWindowPolicy
+	// Optional provider of the context window of the model (defaults to DefaultModels)
+	Models ModelInfoProvider
+	// Optional semantic memory: relevant memories are recalled into the prompt at Think time, and observations and answers are stored into it
+	LongTermMemory *LongTermMemory
+	// Optional blackboard shared with the other agents of an orchestration: its entries are added to the prompt at Think time
+	Blackboard *Blackboard
+	// Optional knowledge base the agent searches with the SearchKnowledgeBase tool, which is added to its tools
+	KnowledgeBase *KnowledgeBase
+	// Optional store of durable facts about the user, injected into the system prompt
+	Profile ProfileStore
+	// Whether to extract durable facts into the profile at the end of each run
+	ExtractFacts bool
+	// Whether to replace the tool results repeating an earlier result verbatim with a reference to it, to save tokens
+	DedupeToolResults bool
+	// Optional redactor applied to the messages before they are sent to the LLM provider
+	Redactor *Redactor
+	// System reminders re-injected periodically during runs
+	Reminders []Reminder
+	// Optional structured logger (nothing is logged if nil)
+	Logger *slog.Logger
+	// Optional audit log recording every tool invocation
+	Audit AuditLog
+	// Optional Prometheus metrics recording the runs and tool calls of the agent
+	Metrics *Metrics
+	// Optional callback receiving the report of every run (tokens by phase, tool calls, estimated cost and wall time) when it ends
+	OnRunReport func(*RunReport)
+	// Optional recorder writing the trace of every run to a JSON file
+	Tracer *TraceRecorder
+	// trace of the current run, if a tracer is set
+	trace *Trace
+	// Optional exporters receiving the events of every run when it ends
+	EventExporters []EventExporter
+	// Optional notifier POSTing the start, completion and failure of every run to webhooks
+	Notifier *WebhookNotifier
+	// Optional callback asked to approve every tool call before it is executed: rejected calls are not executed, and the rejection is reported to the model as their result
+	ApproveToolCall func(callID string, toolCall ToolCall) (bool, error)
+	// Optional verification stage checking the final answers before they are returned, and sending them back for rework when they are rejected
+	Verifier *Verifier
+	// Optional guardrails checking the prompts, the arguments of the tool calls and the final answers of the runs
+	Guardrails *Guardrails
+	// Optional settings for compacting the chat history, automatically when it exceeds a threshold or by calling `Compact`
+	Compaction *CompactionPolicy
+	// Optional limits of the runs nested within the runs of the agent, when it is not itself running nested in another run (defaults to DefaultRunLimits)
+	Limits *RunLimits
+	// whether the runs continue a conversation transferred from another agent with an AgentRef, whose request is already in the history
+	continued bool
+	// scope of the placeholders of the redactor during a run
+	redactionScope string
 }
 
 // Helper method that builds the system prompt from the base template provided when defininig the OpenAIReactAgent.
 //
-// This methods loads the tool name, description and parameters into the system prompt as a clean markdown table, returning the system prompt as a ChatMessage.
+// This methods loads the tool name, description, parameters and cost/latency annotations into the system prompt as a clean markdown table, returning the system prompt as a ChatMessage.
+// If the agent has a working directory, it is stated in the system prompt, and if it has a profile, the known facts about the user are appended to it.
 func (o *OpenAIReActAgent) BuildSystemPrompt() (*ChatMessage, error) {
-	toolStr := "| Name | Description | Parameters |\n|-------|-------|-------|\n"
-	for _, tool := range o.Tools {
+	toolStr := "| Name | Description | Parameters | Cost and latency |\n|-------|-------|-------|-------|\n"
+	for _, tool := range o.tools() {
+		metadata := tool.GetMetadata()
 		paramDesc := []string{}
-		for _, param := range tool.GetMetadata().ParametersMetadata {
+		for _, param := range metadata.ParametersMetadata {
 			paramDesc = append(paramDesc, param.ToString())
 		}
-		toolStr += fmt.Sprintf("| %s | %s | %s |\n", tool.GetMetadata().Name, tool.GetMetadata().Description, strings.Join(paramDesc, " - "))
+		toolStr += fmt.Sprintf("| %s | %s | %s | %s |\n", metadata.Name, metadata.Description, strings.Join(paramDesc, " - "), metadata.CostToString())
 	}
 	toolStr += "\n\n"
 	var buf strings.Builder
@@ -46,74 +127,153 @@ func (o *OpenAIReActAgent) BuildSystemPrompt() (*ChatMessage, error) {
 		return nil, err
 	}
 	sysPrompt := buf.String()
+	if o.WorkingDir != "" {
+		sysPrompt += fmt.Sprintf("\n\n## Working directory\n\nYou are working in %s: relative file paths are resolved against it, and commands run in it.", o.WorkingDir)
+	}
+	if o.Profile != nil {
+		facts, err := o.Profile.Facts()
+		if err != nil {
+			return nil, err
+		}
+		sysPrompt += profilePromptSection(facts)
+	}
 	return NewChatMessage("system", sysPrompt), nil
 }
 
+// Helper method that returns the chat history of the current session from the agent's memory
+func (o *OpenAIReActAgent) History() ([]*ChatMessage, error) {
+	if o.Memory == nil {
+		return nil, errors.New("no memory configured for the agent")
+	}
+	return o.Memory.List(o.SessionID)
+}
+
+// Helper method that appends messages to the chat history of the current session
+func (o *OpenAIReActAgent) AppendHistory(messages ...*ChatMessage) error {
+	if o.Memory == nil {
+		return errors.New("no memory configured for the agent")
+	}
+	return o.Memory.Append(o.SessionID, messages...)
+}
+
 // Helper method that converts the chat history of the OpenAIReActAgent (slice of ChatMessage) into valid message types for the OpenAI SDK.
-func (o *OpenAIReActAgent) BuildChatHistory() any {
-	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(o.ChatHistory))
-	for _, message := range o.ChatHistory {
+//
+// If a context policy is set, the history is first fitted into the context window of the model; then repeated tool results are deduplicated if `DedupeToolResults` is set, and sensitive data is redacted if a redactor is set (the messages in the memory are left untouched).
+func (o *OpenAIReActAgent) BuildChatHistory() (any, error) {
+	history, err := o.History()
+	if err != nil {
+		return nil, err
+	}
+	if o.ContextPolicy != nil {
+		history = o.ContextPolicy.Fit(history)
+	}
+	// deduplicating after fitting guarantees that the referenced results are still in the history
+	if o.DedupeToolResults {
+		history = DedupeToolResults(history)
+	}
+	history = o.redact(history)
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(history))
+	for _, message := range history {
 		switch message.Role {
 		case "system":
 			messages = append(messages, openai.SystemMessage(message.Content))
 		case "assistant":
 			messages = append(messages, openai.AssistantMessage(message.Content))
 		default:
-			messages = append(messages, openai.UserMessage(message.Content))
+			if len(message.Images) == 0 {
+				messages = append(messages, openai.UserMessage(message.Content))
+				continue
+			}
+			parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(message.Content)}
+			for _, image := range message.Images {
+				parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: image.ImageURL()}))
+			}
+			messages = append(messages, openai.UserMessage(parts))
 		}
 	}
-	return messages
+	return messages, nil
 }
 
 // Method that implements the thinking part of the ReAct agent process, leveraging the `Thought` struct type for structured generation of a thinking response based on the previous chat history.
+//
+// If the agent has a long-term memory, the memories relevant to the latest user request are added to the chat history sent to the LLM (but not stored in it), and so are the entries of its blackboard, if it has one.
 func (o *OpenAIReActAgent) Think() (string, error) {
-	chatHistory := o.BuildChatHistory()
+	chatHistory, err := o.BuildChatHistory()
+	if err != nil {
+		return "", err
+	}
 	typedChatHistory, ok := chatHistory.([]openai.ChatCompletionMessageParamUnion)
 	if !ok {
 		return "", errors.New("error while generating the chat history: unexpected typing")
 	}
+	memories, err := o.recallMemories()
+	if err != nil {
+		return "", err
+	}
+	if memories != nil {
+		typedChatHistory = append(typedChatHistory, openai.SystemMessage(o.redact([]*ChatMessage{memories})[0].Content))
+	}
+	if board := o.blackboardMessage(); board != nil {
+		typedChatHistory = append(typedChatHistory, openai.SystemMessage(o.redact([]*ChatMessage{board})[0].Content))
+	}
+	start := time.Now()
 	response, err := OpenAILLMStructuredPredict[Thought](o.Llm, typedChatHistory, "thought", "Thoughts about the action to perform next, based on current chat history")
 	if err != nil {
 		return "", err
 	}
+	o.trace.addCompletion(MessagePhaseThought, typedChatHistory, response, time.Since(start))
 	typedResponse, ok := response.(Thought)
 	if !ok {
 		return "", errors.New("error while generating the response: unexpected structured output")
 	}
-	o.ChatHistory = append(o.ChatHistory, NewChatMessage("assistant", typedResponse.Thought))
+	if err := o.AppendHistory(NewPhaseMessage("assistant", MessagePhaseThought, "", typedResponse.Thought)); err != nil {
+		return "", err
+	}
 	return typedResponse.Thought, nil
 }
 
 // Method that implements the observation part of the ReAct agent process, leveraging the `Observation` struct type for structured generation of an observational response based on the previous chat history.
 func (o *OpenAIReActAgent) Observe() (string, error) {
-	chatHistory := o.BuildChatHistory()
+	chatHistory, err := o.BuildChatHistory()
+	if err != nil {
+		return "", err
+	}
 	typedChatHistory, ok := chatHistory.([]openai.ChatCompletionMessageParamUnion)
 	if !ok {
 		return "", errors.New("error while generating the chat history: unexpected typing")
 	}
+	start := time.Now()
 	response, err := OpenAILLMStructuredPredict[Observation](o.Llm, typedChatHistory, "observation", "Observation about the current state of the task, based on chat history")
 	if err != nil {
 		return "", err
 	}
+	o.trace.addCompletion(MessagePhaseObservation, typedChatHistory, response, time.Since(start))
 	typedResponse, ok := response.(Observation)
 	if !ok {
 		return "", errors.New("error while generating the response: unexpected structured output")
 	}
-	o.ChatHistory = append(o.ChatHistory, NewChatMessage("assistant", typedResponse.Observation))
+	if err := o.AppendHistory(NewPhaseMessage("assistant", MessagePhaseObservation, "", typedResponse.Observation)); err != nil {
+		return "", err
+	}
 	return typedResponse.Observation, nil
 }
 
 // Method that implements the action part of the ReAct agent process, leveraging the `Action` struct type for structured generation of an action-oriented response based on the previous chat history.
 func (o *OpenAIReActAgent) Act() (*Action, error) {
-	chatHistory := o.BuildChatHistory()
+	chatHistory, err := o.BuildChatHistory()
+	if err != nil {
+		return nil, err
+	}
 	typedChatHistory, ok := chatHistory.([]openai.ChatCompletionMessageParamUnion)
 	if !ok {
 		return nil, errors.New("error while generating the chat history: unexpected typing")
 	}
-	response, err := OpenAILLMStructuredPredict[Action](o.Llm, typedChatHistory, "action", "Action to take, based on the chat history. Choose within _done (accompanied with a stop reason), if you think the conversation should stop, or tool_call (accompanied by a tool call) if you think the conversation should continue and you need more input from available tooling.")
+	start := time.Now()
+	response, err := OpenAILLMStructuredPredict[Action](o.Llm, typedChatHistory, "action", "Action to take, based on the chat history. Choose within _done (accompanied with a stop reason), if you think the conversation should stop, tool_call (accompanied by a tool call) if you think the conversation should continue and you need more input from available tooling, or tool_calls (accompanied by several tool calls) if you need input from several independent tools at once.")
 	if err != nil {
 		return nil, err
 	}
+	o.trace.addCompletion(MessagePhaseAction, typedChatHistory, response, time.Since(start))
 	typedResponse, ok := response.(Action)
 	if !ok {
 		return nil, errors.New("error while generating the response: unexpected structured output")
@@ -121,54 +281,544 @@ func (o *OpenAIReActAgent) Act() (*Action, error) {
 	return &typedResponse, nil
 }
 
+// Helper method returning the logger of the agent, annotated with the session (a logger discarding everything if none is set)
+func (o *OpenAIReActAgent) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger.With("session", o.SessionID)
+}
+
+// Helper method that executes a tool, going through the tool cache if one is configured
+func (o *OpenAIReActAgent) executeTool(tool Tool, args map[string]any) (any, error) {
+	if o.ToolCache != nil {
+		return o.ToolCache.Execute(tool, args)
+	}
+	return tool.Execute(args)
+}
+
+// Private struct type representing the outcome of a tool call within a run
+type toolCallResult struct {
+	found  bool
+	result any
+	// whether the result was reused from an identical call in the same run
+	reused bool
+	// duration of the execution of the tool
+	duration time.Duration
+	// messages reporting the tool call and its result in the chat history
+	messages []*ChatMessage
+	// violations of the guardrails found in the arguments of the tool call
+	violations []GuardrailEvent
+}
+
+// Helper method that executes a tool call requested by the model, returning the tool result along with the messages reporting the call and its result in the chat history.
+//
+// Calls of read-only tools identical to a previous one in the same run are not executed again (nor approved again), and calls to unknown tools are skipped.
+func (o *OpenAIReActAgent) callTool(toolCall *ToolCall, callID string, calls *runToolCalls) (toolCallResult, error) {
+	for _, tool := range o.tools() {
+		if tool.GetMetadata().Name == toolCall.Name {
+			args, err := toolCall.ArgsToMap()
+			if err != nil {
+				return toolCallResult{}, err
+			}
+			serializedArgs, err := json.Marshal(args)
+			if err != nil {
+				return toolCallResult{}, err
+			}
+			actionMsg := NewPhaseMessage("assistant", MessagePhaseAction, callID, fmt.Sprintf(actionMessageFormat, toolCall.Name, serializedArgs))
+			checked, violations, err := o.checkToolArgs(toolCall, callID, string(serializedArgs))
+			if errors.Is(err, ErrGuardrailBlocked) {
+				// guardrails and approval callbacks can return the error themselves, without a violation
+				reason, guardrail := err.Error(), ""
+				if len(violations) > 0 {
+					reason, guardrail = violations[len(violations)-1].reason(), violations[len(violations)-1].Guardrail
+				}
+				result := fmt.Sprintf("The call to %s was blocked by a guardrail, so it was not executed: %s", toolCall.Name, reason)
+				o.logger().Info("tool call blocked", "tool", toolCall.Name, "call_id", callID, "guardrail", guardrail)
+				o.trace.add(TraceEntry{Kind: TraceEntryToolCall, Phase: MessagePhaseTool, CallID: callID, Tool: toolCall.Name, Args: args, Result: result})
+				if err := o.audit(callID, toolCall.Name, args, "blocked", nil, 0); err != nil {
+					return toolCallResult{}, err
+				}
+				return toolCallResult{
+					found:      true,
+					result:     result,
+					messages:   []*ChatMessage{actionMsg, NewPhaseMessage("user", MessagePhaseTool, callID, fmt.Sprintf("Tool call result from %s: %s", tool.GetMetadata().Name, result))},
+					violations: violations,
+				}, nil
+			} else if err != nil {
+				return toolCallResult{}, err
+			}
+			if checked != string(serializedArgs) {
+				// the arguments rewritten by the guardrails replace the ones of the model
+				args = map[string]any{}
+				if err := json.Unmarshal([]byte(checked), &args); err != nil {
+					return toolCallResult{}, fmt.Errorf("the guardrails rewrote the arguments of %s into invalid JSON: %w", toolCall.Name, err)
+				}
+			}
+			// the values redacted from the history are put back, since the tool runs locally
+			args = o.restoreArgs(toolCall.Name, args)
+			if restored, err := json.Marshal(args); err == nil && string(restored) != string(serializedArgs) {
+				toolCall = &ToolCall{Name: toolCall.Name, Args: []ToolCallArgs{{ParameterValue: string(restored)}}}
+			}
+			if result, ok := calls.lookup(tool, args); ok {
+				o.logger().Debug("tool call reused", "tool", toolCall.Name, "call_id", callID)
+				o.trace.add(TraceEntry{Kind: TraceEntryToolCall, Phase: MessagePhaseTool, CallID: callID, Tool: toolCall.Name, Args: args, Result: result, Reused: true})
+				if err := o.audit(callID, toolCall.Name, args, "reused", nil, 0); err != nil {
+					return toolCallResult{}, err
+				}
+				return toolCallResult{
+					found:      true,
+					result:     result,
+					reused:     true,
+					violations: violations,
+					messages:   []*ChatMessage{actionMsg, NewPhaseMessage("user", MessagePhaseTool, callID, fmt.Sprintf("Tool call result from %s (identical to a previous call in this run, so it was not executed again): %v", tool.GetMetadata().Name, result))},
+				}, nil
+			}
+			if o.ApproveToolCall != nil {
+				approved, err := o.ApproveToolCall(callID, *toolCall)
+				if err != nil {
+					return toolCallResult{}, err
+				}
+				if !approved {
+					result := fmt.Sprintf("The call to %s was rejected by the user, so it was not executed", toolCall.Name)
+					o.logger().Info("tool call rejected", "tool", toolCall.Name, "call_id", callID)
+					o.trace.add(TraceEntry{Kind: TraceEntryToolCall, Phase: MessagePhaseTool, CallID: callID, Tool: toolCall.Name, Args: args, Result: result})
+					if err := o.audit(callID, toolCall.Name, args, "rejected", nil, 0); err != nil {
+						return toolCallResult{}, err
+					}
+					return toolCallResult{
+						found:      true,
+						result:     result,
+						messages:   []*ChatMessage{actionMsg, NewPhaseMessage("user", MessagePhaseTool, callID, fmt.Sprintf("Tool call result from %s: %s", tool.GetMetadata().Name, result))},
+						violations: violations,
+					}, nil
+				}
+			}
+			start := time.Now()
+			result, err := o.executeTool(tool, args)
+			duration := time.Since(start)
+			if citable, ok := result.(CitableResult); ok && err == nil {
+				result = calls.cite(citable, toolCall.Name, callID)
+			}
+			o.Metrics.toolCall(toolCall.Name, duration, err)
+			entry := TraceEntry{Kind: TraceEntryToolCall, Phase: MessagePhaseTool, Duration: Duration(duration), CallID: callID, Tool: toolCall.Name, Args: args, Result: result}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			o.trace.add(entry)
+			if err != nil {
+				o.logger().Warn("tool call failed", "tool", toolCall.Name, "call_id", callID, "duration", duration, "error", err)
+			} else {
+				o.logger().Debug("tool call", "tool", toolCall.Name, "call_id", callID, "duration", duration)
+			}
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			if auditErr := o.audit(callID, toolCall.Name, args, status, err, duration); auditErr != nil {
+				return toolCallResult{}, auditErr
+			}
+			if recErr := o.recordToolCall(calls.runID, callID, toolCall.Name, args, result, err); recErr != nil {
+				return toolCallResult{}, recErr
+			}
+			if err != nil {
+				return toolCallResult{}, err
+			}
+			calls.record(tool, args, result)
+			return toolCallResult{
+				found:      true,
+				result:     result,
+				duration:   duration,
+				violations: violations,
+				messages:   []*ChatMessage{actionMsg, NewPhaseMessage("user", MessagePhaseTool, callID, fmt.Sprintf("Tool call result from %s: %v", tool.GetMetadata().Name, result))},
+			}, nil
+		}
+	}
+	return toolCallResult{}, nil
+}
+
+// Helper method that runs the guardrails on the arguments of a tool call, logging their violations
+func (o *OpenAIReActAgent) checkToolArgs(toolCall *ToolCall, callID, args string) (string, []GuardrailEvent, error) {
+	ctx := o.Llm.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	checked, violations, err := o.Guardrails.check(ctx, GuardrailEvent{Stage: GuardrailStageToolArgs, Content: args, CallID: callID, Tool: toolCall.Name})
+	for _, violation := range violations {
+		o.logger().Info("guardrail violation", "stage", violation.Stage, "guardrail", violation.Guardrail, "action", violation.Verdict.Action, "call_id", callID)
+	}
+	return checked, violations, err
+}
+
+// Helper method that records a tool call, if the memory of the agent implements RunRecorder
+func (o *OpenAIReActAgent) recordToolCall(runID, callID, name string, args map[string]any, result any, callErr error) error {
+	recorder, ok := o.Memory.(RunRecorder)
+	if !ok || runID == "" {
+		return nil
+	}
+	record := ToolCallRecord{ID: callID, Name: name, Args: args, Result: result, CreatedAt: time.Now().UTC()}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+	return recorder.RecordToolCall(runID, record)
+}
+
+// Helper method that executes a batch of tool calls, concurrently if `ParallelToolCalls` is set, returning their results in the same order as the calls.
+func (o *OpenAIReActAgent) callTools(toolCalls []ToolCall, callIDs []string, calls *runToolCalls) ([]toolCallResult, error) {
+	results := make([]toolCallResult, len(toolCalls))
+	if !o.ParallelToolCalls {
+		for i := range toolCalls {
+			res, err := o.callTool(&toolCalls[i], callIDs[i], calls)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = res
+		}
+		return results, nil
+	}
+	errs := make([]error, len(toolCalls))
+	var wg sync.WaitGroup
+	for i := range toolCalls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = o.callTool(&toolCalls[i], callIDs[i], calls)
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 // Method that implements the Think -> Act -> Observe loop for a ReActAgent.
 //
-// Apart from the user prompt, this method also needs callback functions to communicate the execution of the loop steps (thoughts, actions, observations, tool call results and stopping) to the external environment.
+// Apart from the user prompt, this method also needs callback functions to communicate the execution of the loop steps (thoughts, actions, observations, tool call results and stopping) to the external environment. It is a shorthand for `RunEvents` with a `CallbackHandler`.
 func (o *OpenAIReActAgent) Run(prompt string, thoughtCallback func(string), actionCallback func(Action), toolEndCallback func(any), observationCallback func(string), stopCallback func(string)) error {
+	return o.RunEvents(prompt, CallbackHandler(thoughtCallback, actionCallback, toolEndCallback, observationCallback, stopCallback))
+}
+
+// Method that implements the Think -> Act -> Observe loop for a ReActAgent, sending everything that happens during the run (thoughts, actions, tool calls, observations, token usage, stopping and errors) to the handler as a single stream of events.
+//
+// If the memory of the agent implements RunRecorder, the run and the tool calls executed within it are recorded as well; if a tracer is set, the full trace of the run is written to a file.
+func (o *OpenAIReActAgent) RunEvents(prompt string, handler func(AgentEvent)) error {
+	return o.RunEventsWithImages(prompt, nil, handler)
+}
+
+// Method that runs the agent like `RunEvents`, attaching images to the user prompt. It fails if the model is known not to accept images.
+func (o *OpenAIReActAgent) RunEventsWithImages(prompt string, images []ImageContent, handler func(AgentEvent)) error {
+	return o.RunEventsContext(context.Background(), prompt, images, handler)
+}
+
+// Method that runs the agent like `RunEventsWithImages`, until the context is cancelled: the LLM request in flight is aborted and no further step is taken (a tool call in progress runs to completion), and the run fails with the error of the context.
+func (o *OpenAIReActAgent) RunEventsContext(ctx context.Context, prompt string, images []ImageContent, handler func(AgentEvent)) (err error) {
+	logger := o.logger()
+	runStart := time.Now()
+	steps := 0
+	phase := ""
+	logger.Info("run started", "prompt_length", len(prompt))
+	o.Metrics.runStarted()
+	var report *RunReport
+	if o.OnRunReport != nil {
+		report = newRunReport(o.SessionID, o.Llm.Model)
+	}
+	runID := fmt.Sprintf("run_%d", runStart.UnixNano())
+	var events []AgentEvent
+	emit := func(event AgentEvent) {
+		report.record(event)
+		if len(o.EventExporters) > 0 {
+			events = append(events, event)
+		}
+		handler(event)
+	}
+	info := func() EventInfo {
+		return EventInfo{Time: time.Now().UTC(), Step: steps}
+	}
+	// registered first so that it runs last, once the error event has been emitted
+	defer func() {
+		for _, exporter := range o.EventExporters {
+			// failing to export the events does not fail the run
+			if exportErr := exporter.ExportEvents(context.Background(), o.SessionID, runID, events); exportErr != nil {
+				logger.Warn("failed to export the events", "error", exportErr)
+			}
+		}
+	}()
+	defer func() {
+		o.Metrics.runCompleted(steps, err)
+		if err != nil {
+			logger.Error("run failed", "steps", steps, "duration", time.Since(runStart), "error", err)
+			emit(ErrorEvent{EventInfo: info(), Err: err})
+		} else {
+			logger.Info("run completed", "steps", steps, "duration", time.Since(runStart))
+		}
+		if report != nil {
+			o.finishRunReport(report, time.Since(runStart), err)
+		}
+	}()
+	// the context of the run carries its place among nested runs, which the runs started by its tools inherit through the context of the LLM
+	ctx, err = o.enterRun(ctx)
+	if err != nil {
+		return err
+	}
+	// the placeholders of the redacted values only stand for them within the run
+	defer o.beginRedactionScope(o.SessionID + "/" + runID)()
+	// the LLM requests of the run are reported as usage events, accounted to the current phase
+	llm := o.Llm
+	runLlm := *llm
+	runLlm.ctx = ctx
+	runLlm.OnUsage = func(usage TokenUsage) {
+		o.trace.addUsage(phase, usage)
+		emit(UsageEvent{EventInfo: info(), Phase: phase, Usage: usage})
+		if llm.OnUsage != nil {
+			llm.OnUsage(usage)
+		}
+	}
+	o.Llm = &runLlm
+	defer func() { o.Llm = llm }()
+	if !o.continued {
+		checked, violations, guardErr := o.Guardrails.check(ctx, GuardrailEvent{Stage: GuardrailStagePrompt, Content: prompt})
+		for _, violation := range violations {
+			logger.Info("guardrail violation", "stage", violation.Stage, "guardrail", violation.Guardrail, "action", violation.Verdict.Action)
+			violation.EventInfo = info()
+			emit(violation)
+		}
+		if guardErr != nil {
+			return guardErr
+		}
+		prompt = checked
+	}
+	calls := &runToolCalls{
+		disabled: o.DisableDuplicateDetection,
+		readOnly: o.ReadOnlyTools,
+		results:  map[string]any{},
+	}
+	if recorder, ok := o.Memory.(RunRecorder); ok {
+		runID, recErr := recorder.StartRun(o.SessionID, prompt)
+		if recErr != nil {
+			return recErr
+		}
+		calls.runID = runID
+		defer func() {
+			err = errors.Join(err, recorder.FinishRun(runID, err))
+		}()
+	}
+	if o.Tracer != nil {
+		o.trace = o.Tracer.Start(o.SessionID, o.Llm.Model, prompt)
+		runID = o.trace.ID
+		defer func() {
+			trace := o.trace
+			o.trace = nil
+			path, traceErr := o.Tracer.Finish(trace, err)
+			if traceErr == nil {
+				logger.Debug("trace written", "path", path)
+			}
+			err = errors.Join(err, traceErr)
+			// failing to export a trace does not fail the run
+			if exportErr := o.Tracer.Export(context.Background(), trace); exportErr != nil {
+				logger.Warn("failed to export the trace", "error", exportErr)
+			}
+		}()
+	}
+	// the identifiers of the tool calls are prefixed with the one of the run, so that they stay unique within the session
+	calls.prefix = runID
+	answer := ""
+	// answers sent back for rework by the verifier
+	reworks := 0
+	if o.Notifier != nil {
+		o.notify(logger, WebhookPayload{Type: WebhookRunStarted, RunID: runID, Text: "Run started", Data: map[string]any{"prompt": prompt}})
+		defer func() {
+			data := map[string]any{"steps": steps, "duration_ms": time.Since(runStart).Milliseconds()}
+			if err != nil {
+				data["error"] = err.Error()
+				o.notify(logger, WebhookPayload{Type: WebhookRunFailed, RunID: runID, Text: "Run failed: " + err.Error(), Data: data})
+				return
+			}
+			data["answer"] = answer
+			o.notify(logger, WebhookPayload{Type: WebhookRunCompleted, RunID: runID, Text: fmt.Sprintf("Run completed in %d steps", steps), Data: data})
+		}()
+	}
+	if len(images) > 0 {
+		if info, ok := o.modelInfo(); ok && !info.Vision {
+			return fmt.Errorf("%w: %s", ErrImagesNotSupported, o.Llm.Model)
+		}
+	}
 	sysMsg, err := o.BuildSystemPrompt()
 	if err != nil {
 		return err
 	}
-	o.ChatHistory = append(o.ChatHistory, sysMsg)
-	o.ChatHistory = append(o.ChatHistory, NewChatMessage("user", prompt))
-	for {
+	sysMsg.Phase = MessagePhasePrompt
+	if o.continued {
+		// the system prompt of the agent taking over replaces the one of the previous agent, and the request stays the same
+		if err := o.AppendHistory(sysMsg); err != nil {
+			return err
+		}
+	} else {
+		userMsg := NewPhaseMessage("user", MessagePhasePrompt, "", prompt)
+		userMsg.Images = images
+		if err := o.AppendHistory(sysMsg, userMsg); err != nil {
+			return err
+		}
+	}
+	for step := 1; ; step++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if o.MaxSteps > 0 && step > o.MaxSteps {
+			return fmt.Errorf("%w (%d)", ErrMaxStepsReached, o.MaxSteps)
+		}
+		steps = step
+		emit(ProgressEvent{EventInfo: info(), MaxSteps: o.MaxSteps, Elapsed: time.Since(runStart)})
+		o.trace.setStep(step)
+		phase = "compaction"
+		if err := o.autoCompact(); err != nil {
+			return err
+		}
+		if step == 1 && len(o.Reminders) > 0 {
+			history, err := o.History()
+			if err != nil {
+				return err
+			}
+			if reminders := dueReminders(o.Reminders, history); len(reminders) > 0 {
+				if err := o.AppendHistory(reminders...); err != nil {
+					return err
+				}
+			}
+		}
+		phase = string(MessagePhaseThought)
+		phaseStart := time.Now()
 		thought, err := o.Think()
 		if err != nil {
 			return err
 		}
-		thoughtCallback(thought)
+		phaseDuration := time.Since(phaseStart)
+		logger.Debug("thought", "step", step, "duration", phaseDuration)
+		emit(ThoughtEvent{EventInfo: info(), Thought: thought, Duration: phaseDuration})
+		phase = string(MessagePhaseAction)
+		phaseStart = time.Now()
 		action, err := o.Act()
 		if err != nil {
 			return err
 		}
+		phaseDuration = time.Since(phaseStart)
+		logger.Debug("action", "step", step, "type", action.ActionType, "duration", phaseDuration)
+		emit(ActionEvent{EventInfo: info(), Action: *action, Duration: phaseDuration})
+		if action.ActionType == "_done" && o.Verifier != nil {
+			phase = string(MessagePhaseVerification)
+			verdict, err := o.verify(prompt, action.StopReason.Reason)
+			if err != nil {
+				return err
+			}
+			rework := !verdict.Approved && reworks < o.Verifier.maxReworks()
+			logger.Debug("answer verified", "step", step, "approved", verdict.Approved, "rework", rework)
+			emit(VerificationEvent{EventInfo: info(), Answer: action.StopReason.Reason, Verdict: verdict, Rework: rework})
+			if rework {
+				reworks++
+				if err := o.AppendHistory(reworkMessage(action.StopReason.Reason, verdict)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
 		if action.ActionType == "_done" {
-			stopCallback(action.StopReason.Reason)
-			break
-		} else if action.ActionType == "tool_call" {
-			actionCallback(*action)
-			for _, tool := range o.Tools {
-				if tool.GetMetadata().Name == action.ToolCall.Name {
-					args, err := action.ToolCall.ArgsToMap()
-					if err != nil {
-						return err
-					}
-					result, err := tool.Execute(args)
-					if err != nil {
-						return err
-					}
-					o.ChatHistory = append(o.ChatHistory, NewChatMessage("user", fmt.Sprintf("Tool call result from %s: %v", tool.GetMetadata().Name, result)))
-					toolEndCallback(result)
-					break
+			checked, violations, err := o.Guardrails.check(ctx, GuardrailEvent{Stage: GuardrailStageAnswer, Content: action.StopReason.Reason})
+			for _, violation := range violations {
+				logger.Info("guardrail violation", "stage", violation.Stage, "guardrail", violation.Guardrail, "action", violation.Verdict.Action)
+				violation.EventInfo = info()
+				emit(violation)
+			}
+			if err != nil {
+				return err
+			}
+			// the values redacted from the history are put back in the answer returned to the user
+			action.StopReason.Reason = o.restore(checked)
+			if o.trace != nil {
+				o.trace.Answer = action.StopReason.Reason
+			}
+			answer = action.StopReason.Reason
+			emit(StopEvent{EventInfo: info(), Reason: answer, Citations: calls.cited(answer)})
+			if err := o.remember(fmt.Sprintf("Request: %s\nAnswer: %s", prompt, action.StopReason.Reason)); err != nil {
+				return err
+			}
+			if o.Profile != nil && o.ExtractFacts {
+				phase = "facts"
+				history, err := o.History()
+				if err != nil {
+					return err
+				}
+				if err := o.extractFacts(currentRunMessages(history)); err != nil {
+					return err
 				}
 			}
-		} else {
+			break
+		}
+		var toolCalls []ToolCall
+		switch action.ActionType {
+		case "tool_call":
+			toolCalls = []ToolCall{*action.ToolCall}
+		case "tool_calls":
+			toolCalls = action.ToolCalls
+		default:
 			return fmt.Errorf("unsupported action type: %s", action.ActionType)
 		}
+		callIDs := make([]string, 0, len(toolCalls))
+		for _, toolCall := range toolCalls {
+			callIDs = append(callIDs, calls.nextID())
+			emit(ToolStartEvent{EventInfo: info(), CallID: callIDs[len(callIDs)-1], ToolCall: toolCall})
+		}
+		results, err := o.callTools(toolCalls, callIDs, calls)
+		if err != nil {
+			return err
+		}
+		messages := []*ChatMessage{}
+		for i, res := range results {
+			if res.found {
+				messages = append(messages, res.messages...)
+				for _, violation := range res.violations {
+					violation.EventInfo = info()
+					emit(violation)
+				}
+				emit(ToolEndEvent{EventInfo: info(), CallID: callIDs[i], Tool: toolCalls[i].Name, Result: res.result, Reused: res.reused, Duration: res.duration})
+			}
+		}
+		if len(messages) > 0 {
+			if err := o.AppendHistory(messages...); err != nil {
+				return err
+			}
+		}
+		// the conversation was transferred to another agent, which takes over from here
+		if handoff, ok := findHandoff(results); ok {
+			logger.Info("conversation handed off", "step", step, "agent", handoff.Agent)
+			emit(HandoffEvent{EventInfo: info(), Handoff: handoff})
+			break
+		}
+		// a tool referenced the agent continuing the conversation, which takes over the run from here
+		if ref, ok := findAgentRef(results); ok {
+			logger.Info("conversation transferred", "step", step, "agent", ref.Name)
+			emit(TransferEvent{EventInfo: info(), Agent: ref.Name, Reason: ref.Reason})
+			return ref.continuation(o).RunEventsContext(ctx, prompt, nil, func(event AgentEvent) {
+				switch e := event.(type) {
+				case StopEvent:
+					answer = e.Reason
+				case ErrorEvent:
+					// the failure of the run is reported once, by this run
+					return
+				}
+				emit(event)
+			})
+		}
+		phase = string(MessagePhaseObservation)
+		phaseStart = time.Now()
 		observation, err := o.Observe()
 		if err != nil {
 			return err
 		}
-		observationCallback(observation)
+		phaseDuration = time.Since(phaseStart)
+		logger.Debug("observation", "step", step, "duration", phaseDuration)
+		emit(ObservationEvent{EventInfo: info(), Observation: observation, Duration: phaseDuration})
+		if err := o.remember(observation); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,45 @@
+package gopheract
+
+import (
+	"errors"
+)
+
+// Struct type representing the parameters of the AskUser tool
+type AskUserParams struct {
+	Question string   `json:"question" description:"Question to ask to the user"`
+	Options  []string `json:"options" description:"Possible answers the user can choose from (leave empty for a free-form answer)"`
+}
+
+// Struct type representing a tool that routes a question back to the user and blocks until an answer arrives, so that the agent can resolve ambiguities instead of guessing.
+//
+// The `Ask` callback is provided by the host application, and is responsible for delivering the question to the user and collecting the answer.
+type AskUserTool struct {
+	Ask func(question string, options []string) (string, error)
+}
+
+// Constructor function for a new AskUserTool, given the callback used to ask questions to the user.
+func NewAskUserTool(ask func(question string, options []string) (string, error)) *AskUserTool {
+	return &AskUserTool{Ask: ask}
+}
+
+// Method to ask a question to the user, returning the answer.
+func (a *AskUserTool) Execute(params AskUserParams) (any, error) {
+	if a.Ask == nil {
+		return nil, errors.New("no way to reach the user is configured")
+	}
+	if params.Question == "" {
+		return nil, errors.New("no question provided")
+	}
+	return a.Ask(params.Question, params.Options)
+}
+
+// Helper method to expose the AskUser tool as a tool definition that can be passed to an agent.
+func (a *AskUserTool) AsTool() ToolDefinition[AskUserParams] {
+	return ToolDefinition[AskUserParams]{
+		Name:        "AskUser",
+		Description: "Ask the user a clarifying question when the request is ambiguous or you need information only the user has, by providing the question (`question` parameter - string) and, optionally, the possible answers (`options` parameter - list of strings). Returns the answer of the user.",
+		Fn:          a.Execute,
+		Cost:        ToolCostHigh,
+		Latency:     ToolLatencySlow,
+	}
+}
@@ -0,0 +1,113 @@
+package gopheract
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Struct type representing an entry of the audit log: a tool invocation, as it was executed on the machine.
+//
+// The arguments are only recorded as a hash, so that the audit log does not leak the content of the files or commands the agent worked on, while still allowing to match an entry with a transcript.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Operating system user running the agent
+	User      string `json:"user"`
+	SessionID string `json:"session_id"`
+	CallID    string `json:"call_id"`
+	Tool      string `json:"tool"`
+	// SHA-256 of the arguments, serialized as JSON with sorted keys
+	ArgsHash string `json:"args_hash"`
+	// Outcome of the invocation: "success", "error", "reused" (the result of an identical call in the same run was reused, so the tool was not executed) or "rejected" (the call was not approved, so the tool was not executed)
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Base interface for the audit logs recording every tool invocation, independently of the chat history
+type AuditLog interface {
+	WriteAudit(record AuditRecord) error
+}
+
+// Implementation of AuditLog appending the records as JSON lines to a file, which is only ever opened in append mode.
+type FileAuditLog struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Constructor function for a new FileAuditLog, creating the file (readable only by its owner) and its directory if they do not exist yet.
+func NewFileAuditLog(path string) (*FileAuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &FileAuditLog{Path: path}, nil
+}
+
+// Append a record to the audit log
+func (l *FileAuditLog) WriteAudit(record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Private function returning the SHA-256 of the arguments of a tool call
+func hashArgs(args map[string]any) string {
+	serialized, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(serialized)
+	return hex.EncodeToString(sum[:])
+}
+
+var auditUser = sync.OnceValue(func() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+})
+
+// Helper method that writes a tool invocation to the audit log of the agent, if one is set
+func (o *OpenAIReActAgent) audit(callID, tool string, args map[string]any, status string, callErr error, duration time.Duration) error {
+	if o.Audit == nil {
+		return nil
+	}
+	record := AuditRecord{
+		Time:       time.Now().UTC(),
+		User:       auditUser(),
+		SessionID:  o.SessionID,
+		CallID:     callID,
+		Tool:       tool,
+		ArgsHash:   hashArgs(args),
+		Status:     status,
+		DurationMs: duration.Milliseconds(),
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+	return o.Audit.WriteAudit(record)
+}
@@ -0,0 +1,20 @@
+//go:build windows || plan9
+
+package gopheract
+
+import (
+	"errors"
+)
+
+// Implementation of AuditLog for the system logger, which is not available on this platform
+type SyslogAuditLog struct{}
+
+// Constructor function for a new SyslogAuditLog, which always fails on this platform
+func NewSyslogAuditLog(tag string) (*SyslogAuditLog, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+// Send a record to the system logger (not supported on this platform)
+func (l *SyslogAuditLog) WriteAudit(record AuditRecord) error {
+	return errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+
+package gopheract
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// Implementation of AuditLog sending the records as JSON to the system logger, so that they are kept out of reach of the agent (e.g. forwarded to a remote log server).
+type SyslogAuditLog struct {
+	Writer *syslog.Writer
+}
+
+// Constructor function for a new SyslogAuditLog, logging to the local system logger with the given tag
+func NewSyslogAuditLog(tag string) (*SyslogAuditLog, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogAuditLog{Writer: writer}, nil
+}
+
+// Send a record to the system logger (records of failed invocations are logged as warnings)
+func (l *SyslogAuditLog) WriteAudit(record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if record.Status == "error" {
+		return l.Writer.Warning(string(data))
+	}
+	return l.Writer.Info(string(data))
+}
@@ -0,0 +1,178 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind of an entry of a blackboard
+type BlackboardEntryKind string
+
+const (
+	BlackboardFinding  BlackboardEntryKind = "finding"
+	BlackboardArtifact BlackboardEntryKind = "artifact"
+	BlackboardDecision BlackboardEntryKind = "decision"
+)
+
+// Struct type representing an entry of a blackboard
+type BlackboardEntry struct {
+	// Key identifying the entry: writing an entry with the same key replaces it
+	Key     string              `json:"key"`
+	Kind    BlackboardEntryKind `json:"kind"`
+	Content string              `json:"content"`
+	// Name of the agent that wrote the entry
+	Author string    `json:"author"`
+	Time   time.Time `json:"time"`
+}
+
+// Struct type representing a blackboard shared by the agents of an orchestration, on which they post their findings, artifacts and decisions for the others to read.
+//
+// It is safe for concurrent use, so that e.g. the branches of a fan-out can share one.
+type Blackboard struct {
+	mu      sync.RWMutex
+	entries []BlackboardEntry
+}
+
+// Constructor function for a new, empty Blackboard
+func NewBlackboard() *Blackboard {
+	return &Blackboard{}
+}
+
+// Method to write an entry on the blackboard, replacing the entry with the same key, if any
+func (b *Blackboard) Write(entry BlackboardEntry) error {
+	if strings.TrimSpace(entry.Key) == "" {
+		return errors.New("no key provided")
+	}
+	switch entry.Kind {
+	case BlackboardFinding, BlackboardArtifact, BlackboardDecision:
+	case "":
+		entry.Kind = BlackboardFinding
+	default:
+		return fmt.Errorf("invalid kind: %s", entry.Kind)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = slices.DeleteFunc(b.entries, func(e BlackboardEntry) bool { return e.Key == entry.Key })
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+// Method to read the entry with the given key, returning false if there is none
+func (b *Blackboard) Read(key string) (BlackboardEntry, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	index := slices.IndexFunc(b.entries, func(e BlackboardEntry) bool { return e.Key == key })
+	if index < 0 {
+		return BlackboardEntry{}, false
+	}
+	return b.entries[index], true
+}
+
+// Method returning the entries of the given kinds (all of them if none is given), from the least to the most recently written
+func (b *Blackboard) Entries(kinds ...BlackboardEntryKind) []BlackboardEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	entries := make([]BlackboardEntry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		if len(kinds) == 0 || slices.Contains(kinds, entry.Kind) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Private function rendering entries of a blackboard as a markdown list, one entry per line
+func blackboardList(entries []BlackboardEntry) string {
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("- [%s] %s (by %s): %s", entry.Kind, entry.Key, entry.Author, entry.Content))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Helper method returning the entries of the blackboard of the agent, formatted as a system message (nil if there is no blackboard or it is empty)
+func (o *OpenAIReActAgent) blackboardMessage() *ChatMessage {
+	if o.Blackboard == nil {
+		return nil
+	}
+	entries := o.Blackboard.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+	return NewChatMessage("system", "Entries of the blackboard shared with the other agents:\n"+blackboardList(entries))
+}
+
+// Struct type representing the parameters of the Blackboard tool
+type BlackboardParams struct {
+	Action  string `json:"action" description:"Either 'read' (list the entries, or get the one with the given key) or 'write' (add or replace the entry with the given key)"`
+	Key     string `json:"key" description:"Short key identifying the entry (e.g. 'api-design'), required to write"`
+	Kind    string `json:"kind" description:"Kind of the entry: 'finding', 'artifact' or 'decision' (defaults to 'finding'); when reading without a key, only the entries of this kind are listed"`
+	Content string `json:"content" description:"Content of the entry, required to write"`
+}
+
+// Struct type representing a tool with which an agent reads and writes the blackboard shared with the other agents of an orchestration
+type BlackboardTool struct {
+	Board *Blackboard
+	// Name of the agent the tool is given to, recorded as the author of its entries
+	Author string
+}
+
+// Constructor function for a new BlackboardTool, given the blackboard and the name of the agent using it
+func NewBlackboardTool(board *Blackboard, author string) *BlackboardTool {
+	return &BlackboardTool{Board: board, Author: author}
+}
+
+// Method to read or write the blackboard
+func (t *BlackboardTool) Execute(params BlackboardParams) (any, error) {
+	if t.Board == nil {
+		return nil, errors.New("no blackboard is configured")
+	}
+	switch params.Action {
+	case "read":
+		if params.Key != "" {
+			entry, ok := t.Board.Read(params.Key)
+			if !ok {
+				return nil, fmt.Errorf("no entry with key %s", params.Key)
+			}
+			return blackboardList([]BlackboardEntry{entry}), nil
+		}
+		kinds := []BlackboardEntryKind{}
+		if params.Kind != "" {
+			kinds = append(kinds, BlackboardEntryKind(params.Kind))
+		}
+		entries := t.Board.Entries(kinds...)
+		if len(entries) == 0 {
+			return "The blackboard is empty", nil
+		}
+		return blackboardList(entries), nil
+	case "write":
+		if strings.TrimSpace(params.Content) == "" {
+			return nil, errors.New("no content provided")
+		}
+		entry := BlackboardEntry{Key: params.Key, Kind: BlackboardEntryKind(params.Kind), Content: params.Content, Author: t.Author}
+		if err := t.Board.Write(entry); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Entry %s written on the blackboard", params.Key), nil
+	default:
+		return nil, fmt.Errorf("invalid action: %s", params.Action)
+	}
+}
+
+// Helper method to expose the Blackboard tool as a tool definition that can be passed to an agent.
+func (t *BlackboardTool) AsTool() ToolDefinition[BlackboardParams] {
+	return ToolDefinition[BlackboardParams]{
+		Name:        "Blackboard",
+		Description: "Read or write the blackboard shared with the other agents working on the task, to post your findings, artifacts and decisions and to build on theirs, by providing the action (`action` parameter - string, 'read' or 'write'), the key of the entry (`key` parameter - string, required to write, optional to read), its kind (`kind` parameter - string, 'finding', 'artifact' or 'decision') and its content (`content` parameter - string, required to write).",
+		Fn:          t.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+}
@@ -0,0 +1,189 @@
+package gopheract
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Default constant of the reciprocal rank fusion, damping the weight of the top ranks
+const DefaultRRFConstant = 60
+
+// Struct type representing a keyword index of chunks, scoring them against a query with BM25, so that searches also match exact terms (identifiers, error messages, acronyms) that embeddings tend to blur.
+//
+// The index is kept in memory: a knowledge base fills it from its store with `EnableKeywordSearch`.
+type BM25Index struct {
+	// Saturation of the term frequencies (defaults to 1.2)
+	K1 float64
+	// Normalization of the term frequencies by the length of the chunks (defaults to 0.75)
+	B float64
+
+	mu     sync.RWMutex
+	chunks map[string]bm25Chunk
+	// frequencies of every term in the chunks containing it, by chunk identifier
+	postings    map[string]map[string]int
+	totalLength int
+}
+
+// Private struct type representing a chunk in the keyword index, with its number of terms
+type bm25Chunk struct {
+	chunk  Chunk
+	terms  map[string]int
+	length int
+}
+
+// Constructor function for a new, empty BM25Index
+func NewBM25Index() *BM25Index {
+	return &BM25Index{K1: 1.2, B: 0.75, chunks: map[string]bm25Chunk{}, postings: map[string]map[string]int{}}
+}
+
+// Private function splitting a text into lowercase terms, at every character that is neither a letter nor a digit
+func keywordTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Method to add chunks to the index, replacing the chunks with the same identifiers
+func (x *BM25Index) Add(chunks ...Chunk) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, chunk := range chunks {
+		x.remove(chunk.ID)
+		terms := keywordTerms(chunk.Text)
+		entry := bm25Chunk{chunk: chunk, terms: map[string]int{}, length: len(terms)}
+		for _, term := range terms {
+			entry.terms[term]++
+		}
+		for term, frequency := range entry.terms {
+			if x.postings[term] == nil {
+				x.postings[term] = map[string]int{}
+			}
+			x.postings[term][chunk.ID] = frequency
+		}
+		x.chunks[chunk.ID] = entry
+		x.totalLength += entry.length
+	}
+}
+
+// Private method removing a chunk from the index (the lock must be held)
+func (x *BM25Index) remove(id string) {
+	entry, ok := x.chunks[id]
+	if !ok {
+		return
+	}
+	for term := range entry.terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLength -= entry.length
+	delete(x.chunks, id)
+}
+
+// Method to delete the chunks of the given documents from the index
+func (x *BM25Index) Delete(documentIDs ...string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for id, entry := range x.chunks {
+		for _, documentID := range documentIDs {
+			if entry.chunk.DocumentID == documentID {
+				x.remove(id)
+				break
+			}
+		}
+	}
+}
+
+// Method returning the number of chunks in the index
+func (x *BM25Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.chunks)
+}
+
+// Method returning the chunks matching the terms of a query, from the highest to the lowest BM25 score (the minimum score of the query does not apply, since BM25 scores are not bounded)
+func (x *BM25Index) Search(text string, query VectorQuery) []ScoredChunk {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	scored := []ScoredChunk{}
+	if len(x.chunks) == 0 {
+		return scored
+	}
+	k1, b := x.K1, x.B
+	if k1 <= 0 {
+		k1 = 1.2
+	}
+	if b <= 0 {
+		b = 0.75
+	}
+	count := float64(len(x.chunks))
+	averageLength := float64(x.totalLength) / count
+	scores := map[string]float64{}
+	seen := map[string]bool{}
+	for _, term := range keywordTerms(text) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := x.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (count-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id, frequency := range postings {
+			length := float64(x.chunks[id].length)
+			tf := float64(frequency)
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*length/averageLength))
+		}
+	}
+	for id, score := range scores {
+		chunk := x.chunks[id].chunk
+		if query.matches(chunk.Metadata) {
+			scored = append(scored, ScoredChunk{Chunk: chunk, Score: float32(score)})
+		}
+	}
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].ID < scored[j].ID
+	})
+	if len(scored) > query.topK() {
+		scored = scored[:query.topK()]
+	}
+	return scored
+}
+
+// Function merging rankings of chunks (e.g. from a vector store and from a keyword index) with reciprocal rank fusion: every chunk scores 1/(k+rank) in every ranking it appears in, so that the chunks ranked high by several rankings come first.
+//
+// Scores are normalized so that a chunk ranked first by every ranking scores 1 (k defaults to DefaultRRFConstant).
+func ReciprocalRankFusion(k int, rankings ...[]ScoredChunk) []ScoredChunk {
+	if k <= 0 {
+		k = DefaultRRFConstant
+	}
+	scores := map[string]float64{}
+	chunks := map[string]Chunk{}
+	order := []string{}
+	for _, ranking := range rankings {
+		for rank, chunk := range ranking {
+			if _, ok := chunks[chunk.ID]; !ok {
+				chunks[chunk.ID] = chunk.Chunk
+				order = append(order, chunk.ID)
+			}
+			scores[chunk.ID] += 1 / float64(k+rank+1)
+		}
+	}
+	best := float64(len(rankings)) / float64(k+1)
+	fused := make([]ScoredChunk, len(order))
+	for i, id := range order {
+		fused[i] = ScoredChunk{Chunk: chunks[id], Score: float32(scores[id] / best)}
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
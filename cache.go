@@ -0,0 +1,183 @@
+package gopheract
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Private function to build a key identifying a tool call.
+//
+// Arguments are canonicalized through JSON serialization, which sorts map keys.
+func toolCallKey(name string, args map[string]any) (string, error) {
+	serialized, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return name + ":" + string(serialized), nil
+}
+
+// Private struct type keeping track of the tool calls executed within a single run, to detect duplicate calls.
+//
+// Only the calls of read-only tools are recorded, and executing any other tool clears the record, since it might have changed the results of the other tools (like ToolCache does).
+type runToolCalls struct {
+	mu       sync.Mutex
+	disabled bool
+	readOnly []string
+	results  map[string]any
+	// Identifier of the run, set when the memory of the agent records runs
+	runID string
+	// Prefix of the identifiers of the tool calls, unique within the session (the identifier of the run)
+	prefix string
+	// Number of tool calls requested in the run, used to assign them identifiers
+	count int
+	// Sources returned by the citable tool results of the run, by number (starting from 1)
+	sources []Citation
+}
+
+// Private method assigning an identifier to a new tool call of the run
+func (r *runToolCalls) nextID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count++
+	return toolCallID(r.prefix, r.count)
+}
+
+// Private function returning the identifier of the n-th tool call of a run, e.g. "run_1760000000000000000/call_1" (just "call_1" without a run identifier)
+func toolCallID(runID string, n int) string {
+	if runID == "" {
+		return fmt.Sprintf("call_%d", n)
+	}
+	return fmt.Sprintf("%s/call_%d", runID, n)
+}
+
+func (r *runToolCalls) lookup(tool Tool, args map[string]any) (any, bool) {
+	name := tool.GetMetadata().Name
+	if r.disabled || !slices.Contains(r.readOnly, name) {
+		return nil, false
+	}
+	key, err := toolCallKey(name, args)
+	if err != nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.results[key]
+	return result, ok
+}
+
+func (r *runToolCalls) record(tool Tool, args map[string]any, result any) {
+	name := tool.GetMetadata().Name
+	if r.disabled {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.readOnly, name) {
+		clear(r.results)
+		return
+	}
+	if key, err := toolCallKey(name, args); err == nil {
+		r.results[key] = result
+	}
+}
+
+type toolCacheEntry struct {
+	result    any
+	expiresAt time.Time
+}
+
+// Struct type representing a cache for tool results, keyed by tool name and canonicalized arguments.
+//
+// Only the tools listed in `Tools` are cached. Since any other tool might have side effects (e.g. writing a file that was previously read), executing a tool that is not cacheable invalidates the whole cache.
+type ToolCache struct {
+	// Time to live of a cached result (0 means results never expire)
+	TTL time.Duration
+	// Names of the tools whose results can be cached
+	Tools   []string
+	mu      sync.Mutex
+	entries map[string]toolCacheEntry
+}
+
+// Constructor function for a new ToolCache, given the time to live of the cached results and the names of the tools to cache.
+func NewToolCache(ttl time.Duration, tools ...string) *ToolCache {
+	return &ToolCache{
+		TTL:     ttl,
+		Tools:   tools,
+		entries: map[string]toolCacheEntry{},
+	}
+}
+
+// Helper method to check whether the results of a tool can be cached
+func (c *ToolCache) IsCacheable(name string) bool {
+	return slices.Contains(c.Tools, name)
+}
+
+// Helper method to build the cache key for a tool call.
+func (c *ToolCache) Key(name string, args map[string]any) (string, error) {
+	return toolCallKey(name, args)
+}
+
+// Method to retrieve the cached result of a tool call, if present and not expired.
+func (c *ToolCache) Get(name string, args map[string]any) (any, bool) {
+	key, err := c.Key(name, args)
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// Method to store the result of a tool call in the cache.
+func (c *ToolCache) Set(name string, args map[string]any, result any) {
+	key, err := c.Key(name, args)
+	if err != nil {
+		return
+	}
+	entry := toolCacheEntry{result: result}
+	if c.TTL > 0 {
+		entry.expiresAt = time.Now().Add(c.TTL)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]toolCacheEntry{}
+	}
+	c.entries[key] = entry
+}
+
+// Method to remove all the results from the cache.
+func (c *ToolCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]toolCacheEntry{}
+}
+
+// Method to execute a tool through the cache: cacheable tools return the cached result when available, while other tools invalidate the cache.
+func (c *ToolCache) Execute(tool Tool, args map[string]any) (any, error) {
+	name := tool.GetMetadata().Name
+	if !c.IsCacheable(name) {
+		c.Clear()
+		return tool.Execute(args)
+	}
+	if result, ok := c.Get(name, args); ok {
+		return result, nil
+	}
+	result, err := tool.Execute(args)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(name, args, result)
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package gopheract
+
+import (
+	"testing"
+)
+
+// Helper function returning a tool with the given name and no parameters
+func namedTool(name string) Tool {
+	return ToolDefinition[struct{}]{Name: name, Fn: func(struct{}) (any, error) { return nil, nil }}
+}
+
+func TestRunToolCallsDuplicateDetection(t *testing.T) {
+	type call struct {
+		tool   string
+		path   string
+		result string
+		// whether the call is expected to reuse the result of an earlier call
+		reused bool
+	}
+	tests := []struct {
+		name     string
+		readOnly []string
+		disabled bool
+		calls    []call
+	}{
+		{
+			name:     "read-only tool called twice",
+			readOnly: []string{"Read"},
+			calls: []call{
+				{tool: "Read", path: "a", result: "first"},
+				{tool: "Read", path: "a", result: "first", reused: true},
+				{tool: "Read", path: "b", result: "other"},
+			},
+		},
+		{
+			name:     "write between identical reads",
+			readOnly: []string{"Read"},
+			calls: []call{
+				{tool: "Write", path: "a", result: "ok"},
+				{tool: "Read", path: "a", result: "first"},
+				{tool: "Write", path: "a", result: "ok"},
+				{tool: "Read", path: "a", result: "second"},
+			},
+		},
+		{
+			name: "tools with side effects are never skipped",
+			calls: []call{
+				{tool: "HTTP", path: "post", result: "created 1"},
+				{tool: "HTTP", path: "post", result: "created 2"},
+				{tool: "Bash", path: "date", result: "now"},
+				{tool: "Bash", path: "date", result: "later"},
+			},
+		},
+		{
+			name:     "detection disabled",
+			readOnly: []string{"Read"},
+			disabled: true,
+			calls: []call{
+				{tool: "Read", path: "a", result: "first"},
+				{tool: "Read", path: "a", result: "second"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &runToolCalls{disabled: test.disabled, readOnly: test.readOnly, results: map[string]any{}}
+			for i, c := range test.calls {
+				tool := namedTool(c.tool)
+				args := map[string]any{"path": c.path}
+				result, reused := calls.lookup(tool, args)
+				if reused != c.reused {
+					t.Fatalf("call %d: reused %v, want %v", i, reused, c.reused)
+				}
+				if reused {
+					if result != c.result {
+						t.Fatalf("call %d: got result %v, want %v", i, result, c.result)
+					}
+					continue
+				}
+				calls.record(tool, args, c.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key of the Chroma metadata holding the complete metadata of a chunk, encoded as JSON
+const chromaMetadataKey = "gopheract_metadata"
+
+// Implementation of VectorStore backed by a collection of a Chroma server, through its HTTP API (v2).
+//
+// Chroma only stores scalar metadata values, so the metadata of every chunk is stored twice: its scalar values as they are, so that queries can filter on them, and the complete metadata encoded as JSON, so that it is returned whole.
+type ChromaStore struct {
+	// Base URL of the Chroma server (defaults to "http://localhost:8000")
+	URL string
+	// Optional token of the server, sent as a bearer token
+	Token string
+	// Tenant and database of the collection (default to "default_tenant" and "default_database")
+	Tenant     string
+	Database   string
+	Collection string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+	mu     sync.Mutex
+	// identifier of the collection, resolved on first use
+	collectionID string
+}
+
+// Constructor function for a new ChromaStore using a collection of a Chroma server, created on first use if it does not exist yet
+func NewChromaStore(url, token, collection string) *ChromaStore {
+	return &ChromaStore{
+		URL:        url,
+		Token:      token,
+		Collection: collection,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Private method sending a request to the API of the database of the store (path is relative to the collections of the database)
+func (s *ChromaStore) request(ctx context.Context, method, path string, payload, result any) error {
+	base, tenant, database := s.URL, s.Tenant, s.Database
+	if base == "" {
+		base = "http://localhost:8000"
+	}
+	if tenant == "" {
+		tenant = "default_tenant"
+	}
+	if database == "" {
+		database = "default_database"
+	}
+	endpoint := fmt.Sprintf("%s/api/v2/tenants/%s/databases/%s/collections%s", strings.TrimSuffix(base, "/"), url.PathEscape(tenant), url.PathEscape(database), path)
+	return apiRequest(ctx, s.Client, method, endpoint, payload, result, func(req *http.Request) {
+		if s.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.Token)
+		}
+	})
+}
+
+// Method to create the collection of the store (comparing the embeddings by cosine similarity) if it does not exist yet, returning its identifier
+func (s *ChromaStore) EnsureCollection(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.collectionID != "" {
+		return s.collectionID, nil
+	}
+	var collection struct {
+		ID string `json:"id"`
+	}
+	payload := map[string]any{"name": s.Collection, "get_or_create": true, "metadata": map[string]any{"hnsw:space": "cosine"}}
+	if err := s.request(ctx, http.MethodPost, "", payload, &collection); err != nil {
+		return "", fmt.Errorf("error while creating the collection %s: %w", s.Collection, err)
+	}
+	s.collectionID = collection.ID
+	return collection.ID, nil
+}
+
+// Method to delete the collection of the store, with all its records
+func (s *ChromaStore) DeleteCollection(ctx context.Context) error {
+	s.mu.Lock()
+	s.collectionID = ""
+	s.mu.Unlock()
+	return s.request(ctx, http.MethodDelete, "/"+url.PathEscape(s.Collection), nil, nil)
+}
+
+// Add records to the collection, replacing the records of the same chunks
+func (s *ChromaStore) Add(ctx context.Context, records ...VectorRecord) error {
+	id, err := s.EnsureCollection(ctx)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(records); start += 100 {
+		batch := records[start:min(start+100, len(records))]
+		ids := make([]string, len(batch))
+		embeddings := make([][]float32, len(batch))
+		documents := make([]string, len(batch))
+		metadatas := make([]map[string]any, len(batch))
+		for i, record := range batch {
+			encoded, err := json.Marshal(record.Metadata)
+			if err != nil {
+				return fmt.Errorf("invalid metadata for chunk %s: %w", record.ID, err)
+			}
+			metadata := map[string]any{}
+			for key, value := range record.Metadata {
+				switch value.(type) {
+				case string, bool, int, int64, float32, float64:
+					metadata[key] = value
+				}
+			}
+			metadata["document_id"] = record.DocumentID
+			metadata["start"] = record.Start
+			metadata["end"] = record.End
+			metadata["start_line"] = record.StartLine
+			metadata["end_line"] = record.EndLine
+			metadata[chromaMetadataKey] = string(encoded)
+			ids[i] = record.ID
+			embeddings[i] = record.Embedding
+			documents[i] = record.Text
+			metadatas[i] = metadata
+		}
+		payload := map[string]any{"ids": ids, "embeddings": embeddings, "documents": documents, "metadatas": metadatas}
+		if err := s.request(ctx, http.MethodPost, "/"+id+"/upsert", payload, nil); err != nil {
+			return fmt.Errorf("error while adding the chunks: %w", err)
+		}
+	}
+	return nil
+}
+
+// Private function returning the Chroma filter requiring every value of a filter
+func chromaWhere(filter map[string]any) map[string]any {
+	if len(filter) == 1 {
+		return filter
+	}
+	conditions := make([]map[string]any, 0, len(filter))
+	for key, value := range filter {
+		conditions = append(conditions, map[string]any{key: value})
+	}
+	return map[string]any{"$and": conditions}
+}
+
+// Retrieve the chunks most similar to an embedding. The filter of the query only matches the scalar values of the metadata.
+func (s *ChromaStore) Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error) {
+	id, err := s.EnsureCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]any{
+		"query_embeddings": [][]float32{embedding},
+		"n_results":        query.topK(),
+		"include":          []string{"documents", "metadatas", "distances"},
+	}
+	if len(query.Filter) > 0 {
+		payload["where"] = chromaWhere(query.Filter)
+	}
+	var response struct {
+		IDs       [][]string         `json:"ids"`
+		Documents [][]string         `json:"documents"`
+		Metadatas [][]map[string]any `json:"metadatas"`
+		Distances [][]float32        `json:"distances"`
+	}
+	if err := s.request(ctx, http.MethodPost, "/"+id+"/query", payload, &response); err != nil {
+		return nil, err
+	}
+	scored := []ScoredChunk{}
+	if len(response.IDs) == 0 || len(response.Documents) == 0 || len(response.Metadatas) == 0 || len(response.Distances) == 0 {
+		return scored, nil
+	}
+	for i, chunkID := range response.IDs[0] {
+		if i >= len(response.Documents[0]) || i >= len(response.Metadatas[0]) || i >= len(response.Distances[0]) {
+			break
+		}
+		// the cosine distance of Chroma is one minus the similarity
+		score := 1 - response.Distances[0][i]
+		if score < query.MinScore {
+			continue
+		}
+		metadata := response.Metadatas[0][i]
+		chunk := ScoredChunk{Chunk: Chunk{ID: chunkID, Text: response.Documents[0][i]}, Score: score}
+		chunk.DocumentID, _ = metadata["document_id"].(string)
+		for key, field := range map[string]*int{"start": &chunk.Start, "end": &chunk.End, "start_line": &chunk.StartLine, "end_line": &chunk.EndLine} {
+			if value, ok := metadata[key].(float64); ok {
+				*field = int(value)
+			}
+		}
+		if encoded, ok := metadata[chromaMetadataKey].(string); ok {
+			if err := json.Unmarshal([]byte(encoded), &chunk.Metadata); err != nil {
+				return nil, fmt.Errorf("invalid metadata for chunk %s: %w", chunkID, err)
+			}
+		}
+		scored = append(scored, chunk)
+	}
+	return scored, nil
+}
+
+// Delete the records of the chunks of the given documents
+func (s *ChromaStore) Delete(ctx context.Context, documentIDs ...string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	id, err := s.EnsureCollection(ctx)
+	if err != nil {
+		return err
+	}
+	where := map[string]any{"document_id": map[string]any{"$in": documentIDs}}
+	return s.request(ctx, http.MethodPost, "/"+id+"/delete", map[string]any{"where": where}, nil)
+}
@@ -0,0 +1,454 @@
+package gopheract
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Default size of the chunks, in bytes
+const DefaultChunkSize = 1000
+
+// Struct type representing a chunk of a document, the unit of text that is embedded, retrieved and cited
+type Chunk struct {
+	// Identifier of the chunk: the identifier of its document followed by the index of the chunk (e.g. "docs/setup.md#chunk=2")
+	ID         string `json:"id"`
+	DocumentID string `json:"document_id"`
+	Text       string `json:"text"`
+	// Byte offsets of the start and end of the chunk in the text of its document
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// First and last lines of the chunk in its document, starting from 1
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	// Metadata of the document of the chunk, along with the "heading" of the Markdown chunks and the "symbols" declared in the code chunks
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// Base interface for the chunkers, splitting documents into chunks small enough to be embedded and shown to the agent
+type Chunker interface {
+	// Split a document into chunks, in order
+	Chunk(document Document) []Chunk
+}
+
+// Function splitting documents into chunks with a chunker
+func ChunkDocuments(chunker Chunker, documents []Document) []Chunk {
+	chunks := []Chunk{}
+	for _, document := range documents {
+		chunks = append(chunks, chunker.Chunk(document)...)
+	}
+	return chunks
+}
+
+// Private struct type representing a span of the text of a document, by byte offsets
+type textSpan struct{ start, end int }
+
+// Private function returning the default chunk size for non-positive sizes
+func chunkSize(size int) int {
+	if size <= 0 {
+		return DefaultChunkSize
+	}
+	return size
+}
+
+// Private function building the chunks of a document from spans of its text, dropping the blank ones. The metadata function (if any) returns the metadata specific to a span.
+func newChunks(document Document, spans []textSpan, metadata func(span textSpan) map[string]any) []Chunk {
+	newlines := []int{}
+	for i := 0; i < len(document.Text); i++ {
+		if document.Text[i] == '\n' {
+			newlines = append(newlines, i)
+		}
+	}
+	line := func(offset int) int {
+		return sort.SearchInts(newlines, offset) + 1
+	}
+	chunks := []Chunk{}
+	for _, span := range spans {
+		span = trimSpan(document.Text, span)
+		if span.start >= span.end {
+			continue
+		}
+		chunkMetadata := maps.Clone(document.Metadata)
+		if metadata != nil {
+			if chunkMetadata == nil {
+				chunkMetadata = map[string]any{}
+			}
+			maps.Copy(chunkMetadata, metadata(span))
+		}
+		chunks = append(chunks, Chunk{
+			ID:         fmt.Sprintf("%s#chunk=%d", document.ID, len(chunks)),
+			DocumentID: document.ID,
+			Text:       document.Text[span.start:span.end],
+			Start:      span.start,
+			End:        span.end,
+			StartLine:  line(span.start),
+			EndLine:    line(span.end - 1),
+			Metadata:   chunkMetadata,
+		})
+	}
+	return chunks
+}
+
+// Private function trimming the whitespace at the edges of a span
+func trimSpan(text string, span textSpan) textSpan {
+	for span.start < span.end && strings.ContainsRune(" \t\r\n", rune(text[span.start])) {
+		span.start++
+	}
+	for span.end > span.start && strings.ContainsRune(" \t\r\n", rune(text[span.end-1])) {
+		span.end--
+	}
+	return span
+}
+
+// Private function splitting a span into windows of at most size bytes overlapping by overlap bytes, cutting at whitespace when possible (and never within a character)
+func fixedSpans(text string, span textSpan, size, overlap int) []textSpan {
+	spans := []textSpan{}
+	for start := span.start; start < span.end; {
+		end := min(start+size, span.end)
+		if end < span.end {
+			if cut := strings.LastIndexAny(text[start+size/2:end], " \t\n"); cut >= 0 {
+				end = start + size/2 + cut + 1
+			}
+			for end > start+1 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+		spans = append(spans, textSpan{start, end})
+		if end == span.end {
+			break
+		}
+		next := max(end-overlap, start+1)
+		for next < end && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		start = next
+	}
+	return spans
+}
+
+// Private function merging consecutive spans into chunks of at most size bytes (unless a span is larger on its own), starting every chunk with the last spans of the previous one, up to overlap bytes
+func packSpans(spans []textSpan, size, overlap int) []textSpan {
+	packed := []textSpan{}
+	for i := 0; i < len(spans); {
+		start := spans[i].start
+		j := i + 1
+		for j < len(spans) && spans[j].end-start <= size {
+			j++
+		}
+		packed = append(packed, textSpan{start, spans[j-1].end})
+		if j == len(spans) {
+			break
+		}
+		// the overlap is dropped where it would leave no room for the next span
+		next := j
+		for next-1 > i && spans[j-1].end-spans[next-1].start <= overlap && spans[j].end-spans[next-1].start <= size {
+			next--
+		}
+		i = next
+	}
+	return packed
+}
+
+// Private function splitting the spans larger than size bytes into windows (see fixedSpans)
+func splitLargeSpans(text string, spans []textSpan, size, overlap int) []textSpan {
+	split := make([]textSpan, 0, len(spans))
+	for _, span := range spans {
+		if span.end-span.start > size {
+			split = append(split, fixedSpans(text, span, size, overlap)...)
+		} else {
+			split = append(split, span)
+		}
+	}
+	return split
+}
+
+// Chunker splitting documents into chunks of a fixed size, overlapping so that the text cut at the edges of a chunk is found whole in the next one
+type FixedSizeChunker struct {
+	// Maximum size of the chunks, in bytes (defaults to DefaultChunkSize)
+	Size int
+	// Size of the overlap between consecutive chunks, in bytes
+	Overlap int
+}
+
+// Constructor function for a new FixedSizeChunker
+func NewFixedSizeChunker(size, overlap int) *FixedSizeChunker {
+	return &FixedSizeChunker{Size: size, Overlap: overlap}
+}
+
+// Split a document into overlapping chunks of a fixed size, cutting at whitespace when possible
+func (c *FixedSizeChunker) Chunk(document Document) []Chunk {
+	size := chunkSize(c.Size)
+	return newChunks(document, fixedSpans(document.Text, textSpan{0, len(document.Text)}, size, min(c.Overlap, size/2)), nil)
+}
+
+// Pattern of the ends of the sentences and paragraphs of a text
+var sentenceEndPattern = regexp.MustCompile(`[.!?]+["'”’)\]]*[ \t]+|[.!?]+["'”’)\]]*\n|\n[ \t]*\n\s*`)
+
+// Private function splitting a span into sentences (and paragraphs)
+func sentenceSpans(text string, span textSpan) []textSpan {
+	spans := []textSpan{}
+	start := span.start
+	for _, match := range sentenceEndPattern.FindAllStringIndex(text[span.start:span.end], -1) {
+		end := span.start + match[1]
+		spans = append(spans, textSpan{start, end})
+		start = end
+	}
+	if start < span.end {
+		spans = append(spans, textSpan{start, span.end})
+	}
+	return spans
+}
+
+// Chunker splitting documents into chunks made of whole sentences, overlapping by whole sentences, so that no chunk starts or ends mid-sentence (sentences larger than a chunk are split anyway)
+type SentenceChunker struct {
+	// Maximum size of the chunks, in bytes (defaults to DefaultChunkSize)
+	Size int
+	// Maximum size of the sentences repeated at the start of the next chunk, in bytes
+	Overlap int
+}
+
+// Constructor function for a new SentenceChunker
+func NewSentenceChunker(size, overlap int) *SentenceChunker {
+	return &SentenceChunker{Size: size, Overlap: overlap}
+}
+
+// Split a document into chunks of whole sentences
+func (c *SentenceChunker) Chunk(document Document) []Chunk {
+	size := chunkSize(c.Size)
+	spans := splitLargeSpans(document.Text, sentenceSpans(document.Text, textSpan{0, len(document.Text)}), size, 0)
+	return newChunks(document, packSpans(spans, size, c.Overlap), nil)
+}
+
+// Pattern of the headings and code fences of a Markdown document
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	markdownFencePattern   = regexp.MustCompile("^[ \t]*(```|~~~)")
+)
+
+// Chunker splitting Markdown documents (and the documents converted to Markdown by the loaders, such as HTML files) into chunks that do not cross the sections of the document, each with the path of its heading in the "heading" metadata (e.g. "Setup > Install"). Sections larger than a chunk are split into whole sentences.
+type MarkdownChunker struct {
+	// Maximum size of the chunks, in bytes (defaults to DefaultChunkSize)
+	Size int
+	// Maximum size of the sentences repeated at the start of the next chunk of a section, in bytes
+	Overlap int
+}
+
+// Constructor function for a new MarkdownChunker
+func NewMarkdownChunker(size, overlap int) *MarkdownChunker {
+	return &MarkdownChunker{Size: size, Overlap: overlap}
+}
+
+// Private struct type representing a section of a Markdown document, with the path of its heading
+type markdownSection struct {
+	span    textSpan
+	heading string
+}
+
+// Private function splitting a span of a Markdown document into blocks separated by blank lines, keeping code blocks whole
+func markdownBlocks(text string, span textSpan) []textSpan {
+	blocks := []textSpan{}
+	start := span.start
+	inFence := false
+	for offset := span.start; offset < span.end; {
+		end := strings.IndexByte(text[offset:span.end], '\n') + offset + 1
+		if end == offset {
+			end = span.end
+		}
+		line := text[offset:end]
+		if markdownFencePattern.MatchString(line) {
+			inFence = !inFence
+		}
+		if strings.TrimSpace(line) == "" && !inFence {
+			blocks = append(blocks, textSpan{start, end})
+			start = end
+		}
+		offset = end
+	}
+	if start < span.end {
+		blocks = append(blocks, textSpan{start, span.end})
+	}
+	return blocks
+}
+
+// Split a Markdown document into chunks following its sections
+func (c *MarkdownChunker) Chunk(document Document) []Chunk {
+	size := chunkSize(c.Size)
+	text := document.Text
+	sections := []markdownSection{}
+	headings := []string{}
+	current := markdownSection{}
+	// whether the current section has content besides its heading: sections without content are merged into the next one
+	hasContent, inFence := false, false
+	for offset := 0; offset < len(text); {
+		end := strings.IndexByte(text[offset:], '\n') + offset + 1
+		if end == offset {
+			end = len(text)
+		}
+		line := strings.TrimRight(text[offset:end], "\r\n")
+		if markdownFencePattern.MatchString(line) {
+			inFence = !inFence
+		}
+		if match := markdownHeadingPattern.FindStringSubmatch(line); match != nil && !inFence {
+			if hasContent {
+				current.span.end = offset
+				sections = append(sections, current)
+				current = markdownSection{span: textSpan{start: offset}}
+				hasContent = false
+			}
+			level := len(match[1])
+			headings = append(headings[:min(level-1, len(headings))], match[2])
+			current.heading = strings.Join(headings, " > ")
+		} else if strings.TrimSpace(line) != "" {
+			hasContent = true
+		}
+		offset = end
+	}
+	current.span.end = len(text)
+	sections = append(sections, current)
+	spans := []textSpan{}
+	headingOf := map[textSpan]string{}
+	for _, section := range sections {
+		blocks := []textSpan{}
+		for _, block := range markdownBlocks(text, section.span) {
+			if block.end-block.start > size {
+				blocks = append(blocks, splitLargeSpans(text, sentenceSpans(text, block), size, 0)...)
+			} else {
+				blocks = append(blocks, block)
+			}
+		}
+		for _, span := range packSpans(blocks, size, c.Overlap) {
+			spans = append(spans, span)
+			headingOf[trimSpan(text, span)] = section.heading
+		}
+	}
+	return newChunks(document, spans, func(span textSpan) map[string]any {
+		if heading := headingOf[span]; heading != "" {
+			return map[string]any{"heading": heading}
+		}
+		return nil
+	})
+}
+
+// Pattern of the top-level declarations of the common programming languages, capturing the declared name
+var codeDeclarationPattern = regexp.MustCompile(`^(?:(?:export|default|pub(?:\([^)]*\))?|public|private|protected|internal|static|async|abstract|final|sealed|open|data|unsafe|extern)\s+)*(?:func|function|def|class|type|interface|struct|enum|trait|impl|fn|module|object|record|const|var|let|macro_rules!)\s*(?:\([^)]*\)\s*)?([A-Za-z_$][\w$]*)?`)
+
+// Pattern of the comments and decorators attached to the declaration that follows them
+var codeCommentPattern = regexp.MustCompile(`^\s*(?://|#|/\*|\*|--|@|"""|''')`)
+
+// Extensions of the source files split by declaration by the AutoChunker
+var codeExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".mjs": true, ".ts": true, ".tsx": true, ".jsx": true, ".java": true, ".kt": true,
+	".rs": true, ".rb": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true, ".swift": true,
+	".php": true, ".scala": true, ".lua": true, ".sh": true, ".ex": true, ".exs": true,
+}
+
+// Chunker splitting source code into chunks of whole top-level declarations (functions, methods, types, classes...), along with the comments preceding them, with the names of the declarations of each chunk in the "symbols" metadata. Small declarations are grouped, and declarations larger than a chunk are split by lines.
+//
+// Declarations are recognized by their keywords at the start of a line (func, def, class, fn, type...), which covers the common languages without parsing them.
+type CodeChunker struct {
+	// Maximum size of the chunks, in bytes (defaults to DefaultChunkSize)
+	Size int
+	// Maximum size of the lines repeated at the start of the next chunk of a declaration split by lines, in bytes
+	Overlap int
+}
+
+// Constructor function for a new CodeChunker
+func NewCodeChunker(size, overlap int) *CodeChunker {
+	return &CodeChunker{Size: size, Overlap: overlap}
+}
+
+// Private struct type representing a top-level declaration of a source file
+type codeDeclaration struct {
+	span textSpan
+	name string
+}
+
+// Split source code into chunks of whole declarations
+func (c *CodeChunker) Chunk(document Document) []Chunk {
+	size := chunkSize(c.Size)
+	text := document.Text
+	lines := []textSpan{}
+	for offset := 0; offset < len(text); {
+		end := strings.IndexByte(text[offset:], '\n') + offset + 1
+		if end == offset {
+			end = len(text)
+		}
+		lines = append(lines, textSpan{offset, end})
+		offset = end
+	}
+	declarations := []codeDeclaration{{}}
+	for i, line := range lines {
+		match := codeDeclarationPattern.FindStringSubmatch(text[line.start:line.end])
+		if match == nil {
+			continue
+		}
+		// the comments and decorators right above the declaration belong to it
+		first := i
+		for first > 0 && codeCommentPattern.MatchString(text[lines[first-1].start:lines[first-1].end]) {
+			first--
+		}
+		start := lines[first].start
+		if start < declarations[len(declarations)-1].span.start {
+			start = lines[i].start
+		}
+		declarations[len(declarations)-1].span.end = start
+		declarations = append(declarations, codeDeclaration{span: textSpan{start: start}, name: match[1]})
+	}
+	declarations[len(declarations)-1].span.end = len(text)
+	spans := []textSpan{}
+	for _, declaration := range declarations {
+		if declaration.span.end-declaration.span.start <= size {
+			spans = append(spans, declaration.span)
+			continue
+		}
+		declarationLines := []textSpan{}
+		for _, line := range lines {
+			if line.start >= declaration.span.start && line.end <= declaration.span.end {
+				declarationLines = append(declarationLines, line)
+			}
+		}
+		spans = append(spans, packSpans(splitLargeSpans(text, declarationLines, size, 0), size, c.Overlap)...)
+	}
+	return newChunks(document, packSpans(spans, size, 0), func(span textSpan) map[string]any {
+		symbols := []string{}
+		for _, declaration := range declarations {
+			if declaration.name != "" && declaration.span.end > span.start && declaration.span.start < span.end {
+				symbols = append(symbols, declaration.name)
+			}
+		}
+		if len(symbols) == 0 {
+			return nil
+		}
+		return map[string]any{"symbols": symbols}
+	})
+}
+
+// Chunker picking the chunker suited to every document: the MarkdownChunker for Markdown, HTML and Word documents, the CodeChunker for source files and the SentenceChunker for the others
+type AutoChunker struct {
+	// Maximum size of the chunks, in bytes (defaults to DefaultChunkSize)
+	Size int
+	// Size of the overlap between consecutive chunks, in bytes
+	Overlap int
+}
+
+// Constructor function for a new AutoChunker
+func NewAutoChunker(size, overlap int) *AutoChunker {
+	return &AutoChunker{Size: size, Overlap: overlap}
+}
+
+// Split a document with the chunker suited to its format
+func (c *AutoChunker) Chunk(document Document) []Chunk {
+	format, _ := document.Metadata["format"].(string)
+	source, _ := document.Metadata["source"].(string)
+	switch {
+	case format == "markdown" || format == "html" || format == "docx":
+		return NewMarkdownChunker(c.Size, c.Overlap).Chunk(document)
+	case codeExtensions[strings.ToLower(filepath.Ext(source))]:
+		return NewCodeChunker(c.Size, c.Overlap).Chunk(document)
+	}
+	return NewSentenceChunker(c.Size, c.Overlap).Chunk(document)
+}
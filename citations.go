@@ -0,0 +1,128 @@
+package gopheract
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pattern of the citation markers in the answers, e.g. [2] or [1, 3]
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Struct type representing a source cited by the final answer of a run, such as a chunk retrieved from a knowledge base
+type Citation struct {
+	// Number the answer refers to the source with, e.g. 2 for [2]
+	Number int `json:"number"`
+	// Path or URL of the cited document
+	Source     string `json:"source"`
+	Title      string `json:"title,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	ChunkID    string `json:"chunk_id,omitempty"`
+	// Range of the cited passage in the document: its byte offsets, its lines (zero if unknown) and its page (for paged documents)
+	Start     int `json:"start"`
+	End       int `json:"end"`
+	StartLine int `json:"start_line,omitempty"`
+	EndLine   int `json:"end_line,omitempty"`
+	Page      int `json:"page,omitempty"`
+	// Relevance of the passage to the query it was retrieved for
+	Score float32 `json:"score,omitempty"`
+	// Tool call that returned the source
+	Tool   string `json:"tool"`
+	CallID string `json:"call_id"`
+}
+
+// Method returning where the cited passage is, e.g. "docs/setup.md, lines 10-24"
+func (c Citation) String() string {
+	location := c.Source
+	if c.Page > 0 {
+		location += fmt.Sprintf(", page %d", c.Page)
+	} else if c.StartLine > 0 {
+		location += fmt.Sprintf(", lines %d-%d", c.StartLine, c.EndLine)
+	}
+	return location
+}
+
+// Interface that tool results can implement to be cited by the final answer: their sources are numbered after the ones of the previous tool calls of the run, and the sources the answer refers to by number (e.g. [2]) are reported as the citations of the run
+type CitableResult interface {
+	// Return the result with its sources numbered from first, in the order of the returned citations
+	Cite(first int) (any, []Citation)
+}
+
+// Private method numbering the sources of a citable tool result after the ones of the previous tool calls of the run, keeping them so that the answer can cite them
+func (r *runToolCalls) cite(result CitableResult, tool, callID string) any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	numbered, sources := result.Cite(len(r.sources) + 1)
+	for i := range sources {
+		sources[i].Tool = tool
+		sources[i].CallID = callID
+	}
+	r.sources = append(r.sources, sources...)
+	return numbered
+}
+
+// Private method returning the sources of the run cited by an answer, in the order in which the answer cites them
+func (r *runToolCalls) cited(answer string) []Citation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	citations := []Citation{}
+	seen := map[int]bool{}
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for field := range strings.SplitSeq(match[1], ",") {
+			number, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || number < 1 || number > len(r.sources) || seen[number] {
+				continue
+			}
+			seen[number] = true
+			citations = append(citations, r.sources[number-1])
+		}
+	}
+	return citations
+}
+
+// Private function returning an integer value of the metadata of a chunk, which is a float when the metadata was decoded from JSON
+func metadataInt(metadata map[string]any, key string) int {
+	switch value := metadata[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// Struct type representing the outcome of a run of an agent
+type RunResult struct {
+	Answer string `json:"answer"`
+	// Sources the answer cites, in the order in which it cites them
+	Citations []Citation `json:"citations"`
+	Steps     int        `json:"steps"`
+	Usage     TokenUsage `json:"usage"`
+}
+
+// Method that runs the agent like `RunEventsContext` and returns the outcome of the run, forwarding its events to the handler (which can be nil)
+func (o *OpenAIReActAgent) RunResult(ctx context.Context, prompt string, images []ImageContent, handler func(AgentEvent)) (*RunResult, error) {
+	result := &RunResult{Citations: []Citation{}}
+	err := o.RunEventsContext(ctx, prompt, images, func(event AgentEvent) {
+		switch e := event.(type) {
+		case ProgressEvent:
+			result.Steps = e.Step
+		case UsageEvent:
+			result.Usage = result.Usage.Add(e.Usage)
+		case StopEvent:
+			result.Answer = e.Reason
+			result.Citations = e.Citations
+		}
+		if handler != nil {
+			handler(event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,28 +2,73 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/AstraBert/gopheract"
 	"github.com/coder/acp-go-sdk"
 )
 
+// Fraction of the step limit of a run after which a warning is sent to the client at every step
+const progressWarningThreshold = 0.8
+
 type AgentSession struct {
 	cancel context.CancelFunc
+	// closed when the turn in progress ends (nil between turns)
+	done chan struct{}
+	mode acp.SessionModeId
+	// working directory of the session, given by the client (absolute, with symlinks resolved)
+	cwd string
+	// kinds of tool calls (file edits, command execution) the user allowed for the rest of the session
+	alwaysAllowed map[acp.ToolKind]bool
+}
+
+// The session and context of a turn being processed by the agent
+type activeTurn struct {
+	ctx  context.Context
+	sid  string
+	mode acp.SessionModeId
+	// working directory of the session
+	cwd string
+	// ids of the tool calls started but not yet completed, in the order the agent executes them
+	pendingCalls []acp.ToolCallId
+	// approved file changes, by id of the tool call that made them
+	changes map[acp.ToolCallId]gopheract.FileChange
+	// names of the tools of the pending calls
+	tools map[acp.ToolCallId]string
+	// absolute paths of the files the pending calls work on, if any
+	paths map[acp.ToolCallId]string
 }
 
 type CliAgent struct {
 	conn     *acp.AgentSideConnection
 	sessions map[string]*AgentSession
 	mu       sync.Mutex
-	agent    gopheract.OpenAIReActAgent
+	agents   *gopheract.SessionManager
+	// tools bound to every turn, so that the turns of different sessions can run at once (nil when replaying a trace)
+	toolbox  *Toolbox
+	askCount int
+	// recorded run replayed on every prompt instead of running the agent (see `trace replay --acp`)
+	replay *gopheract.Trace
+	// optional speech-to-text model transcribing the audio input of the prompts
+	transcriber gopheract.Transcriber
+	// capabilities advertised by the client when initializing the connection
+	clientCapabilities acp.ClientCapabilities
+	// policies of the tools set in the configuration, by tool name
+	policies map[string]toolPolicy
+	auth     acpAuth
+	// commands advertised to the clients, expanding into prompts
+	commands []promptCommand
 }
 
 var (
@@ -33,11 +78,215 @@ var (
 )
 
 func NewCliAgent(agent gopheract.OpenAIReActAgent) *CliAgent {
-	return &CliAgent{sessions: make(map[string]*AgentSession), agent: agent}
+	return &CliAgent{sessions: make(map[string]*AgentSession), agents: gopheract.NewSessionManager(&agent)}
+}
+
+// Ask a question to the user of the session of a turn, through an ACP permission request.
+//
+// ACP has no free-form input request, so the user picks one of the options proposed by the agent (or answers yes/no when there are none).
+func (a *CliAgent) askUser(turn *activeTurn, question string, options []string) (string, error) {
+	a.mu.Lock()
+	a.askCount += 1
+	callId := acp.ToolCallId(fmt.Sprintf("ask_%d", a.askCount))
+	a.mu.Unlock()
+	if len(options) == 0 {
+		options = []string{"Yes", "No"}
+	}
+	permOptions := make([]acp.PermissionOption, 0, len(options))
+	for i, option := range options {
+		permOptions = append(permOptions, acp.PermissionOption{
+			OptionId: acp.PermissionOptionId(strconv.Itoa(i)),
+			Name:     option,
+			Kind:     acp.PermissionOptionKindAllowOnce,
+		})
+	}
+	resp, err := a.conn.RequestPermission(turn.ctx, acp.RequestPermissionRequest{
+		SessionId: acp.SessionId(turn.sid),
+		ToolCall: acp.RequestPermissionToolCall{
+			ToolCallId: callId,
+			Title:      acp.Ptr(question),
+		},
+		Options: permOptions,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Outcome.Selected == nil {
+		return "", errors.New("the user did not answer the question")
+	}
+	idx, err := strconv.Atoi(string(resp.Outcome.Selected.OptionId))
+	if err != nil || idx < 0 || idx >= len(options) {
+		return "", fmt.Errorf("unexpected answer: %s", resp.Outcome.Selected.OptionId)
+	}
+	return options[idx], nil
+}
+
+// Ask the user of a session to approve a call of a tool, unless they already allowed its kind of tool calls for the rest of the session.
+//
+// The user can allow the call once, allow all the calls of the same kind for the rest of the session, or reject it. In ask mode, and for the tools whose policy is ask, every call needs approval, so the calls always allowed are ignored.
+func (a *CliAgent) requestApproval(turn *activeTurn, tool string, toolCall acp.RequestPermissionToolCall) (bool, error) {
+	kind := *toolCall.Kind
+	strict := turn.mode == modeAsk || a.policies[tool] == policyAsk
+	a.mu.Lock()
+	session := a.sessions[turn.sid]
+	allowed := !strict && session != nil && session.alwaysAllowed[kind]
+	a.mu.Unlock()
+	if allowed {
+		return true, nil
+	}
+	options := []acp.PermissionOption{{OptionId: "allow", Name: "Allow", Kind: acp.PermissionOptionKindAllowOnce}}
+	if !strict {
+		options = append(options, acp.PermissionOption{OptionId: "allow_always", Name: "Always allow", Kind: acp.PermissionOptionKindAllowAlways})
+	}
+	options = append(options, acp.PermissionOption{OptionId: "reject", Name: "Reject", Kind: acp.PermissionOptionKindRejectOnce})
+	resp, err := a.conn.RequestPermission(turn.ctx, acp.RequestPermissionRequest{
+		SessionId: acp.SessionId(turn.sid),
+		ToolCall:  toolCall,
+		Options:   options,
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.Outcome.Selected == nil {
+		return false, nil
+	}
+	switch resp.Outcome.Selected.OptionId {
+	case "allow_always":
+		a.mu.Lock()
+		if session != nil {
+			if session.alwaysAllowed == nil {
+				session.alwaysAllowed = map[acp.ToolKind]bool{}
+			}
+			session.alwaysAllowed[kind] = true
+		}
+		a.mu.Unlock()
+		return true, nil
+	case "allow":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Private method returning the id of the pending call making a change to a file: the file edit working on the path of the change and without an approved change yet (with batched tool calls, several edits can be pending at once)
+func (t *activeTurn) changeCall(change gopheract.FileChange) acp.ToolCallId {
+	var candidate acp.ToolCallId
+	for _, id := range t.pendingCalls {
+		if _, approved := t.changes[id]; approved || !slices.Contains([]string{"Write", "Edit"}, t.tools[id]) {
+			continue
+		}
+		if t.paths[id] == change.Path {
+			return id
+		}
+		if candidate == "" {
+			candidate = id
+		}
+	}
+	return candidate
+}
+
+// Ask the user of the session of a turn to approve a change to a file, sending the diff along with the permission request (outside ask mode, changes made by tools whose policy is allow are approved without asking).
+func (a *CliAgent) approveChange(turn *activeTurn, change gopheract.FileChange) (bool, error) {
+	a.mu.Lock()
+	callId := turn.changeCall(change)
+	tool := turn.tools[callId]
+	a.mu.Unlock()
+	// ask mode overrides the policies allowing tools
+	approved := a.policies[tool] == policyAllow && turn.mode != modeAsk
+	var err error
+	if !approved {
+		approved, err = a.requestApproval(turn, tool, acp.RequestPermissionToolCall{
+			ToolCallId: callId,
+			Title:      acp.Ptr(fmt.Sprintf("Apply changes to %s", change.Path)),
+			Kind:       acp.Ptr(acp.ToolKindEdit),
+			Content:    []acp.ToolCallContent{acp.ToolDiffContent(change.Path, change.NewText, change.OldText)},
+			Locations:  []acp.ToolCallLocation{{Path: change.Path}},
+		})
+	}
+	if approved {
+		// kept to send the diff along with the completion of the tool call
+		a.mu.Lock()
+		turn.changes[callId] = change
+		a.mu.Unlock()
+	}
+	return approved, err
 }
 
-// SetSessionMode implements acp.Agent.
+// Ask the user of the session of a turn to approve a tool call before it is executed: bash commands, the tools whose policy is ask, and in ask mode every other tool call (file changes are approved through approveChange, with their diff, questions to the user need no approval, and outside ask mode the tools whose policy is allow never do).
+func (a *CliAgent) approveToolCall(turn *activeTurn, callID string, toolCall gopheract.ToolCall) (bool, error) {
+	policy := a.policies[toolCall.Name]
+	if (policy == policyAllow && turn.mode != modeAsk) || slices.Contains([]string{"Write", "Edit", "AskUser", "UpdatePlan"}, toolCall.Name) || (toolCall.Name != "Bash" && turn.mode != modeAsk && policy != policyAsk) {
+		return true, nil
+	}
+	args, err := toolCall.ArgsToMap()
+	if err != nil {
+		return false, err
+	}
+	permission := acp.RequestPermissionToolCall{
+		ToolCallId: acp.ToolCallId(callID),
+		Title:      acp.Ptr(fmt.Sprintf("Call %s", toolCall.Name)),
+		Kind:       acp.Ptr(acp.ToolKindOther),
+		RawInput:   args,
+	}
+	switch toolCall.Name {
+	case "Bash":
+		command := fmt.Sprint(args["command"])
+		if arguments, ok := args["arguments"].([]any); ok {
+			for _, argument := range arguments {
+				command += " " + fmt.Sprint(argument)
+			}
+		}
+		permission.Title = acp.Ptr(fmt.Sprintf("Run `%s`", command))
+		permission.Kind = acp.Ptr(acp.ToolKindExecute)
+	case "Read":
+		permission.Title = acp.Ptr(fmt.Sprintf("Read %v", args["file_path"]))
+		permission.Kind = acp.Ptr(acp.ToolKindRead)
+	}
+	return a.requestApproval(turn, toolCall.Name, permission)
+}
+
+// Publish the plan of the agent to the session of a turn, as an ACP plan update the client renders as a checklist
+func (a *CliAgent) publishPlan(turn *activeTurn, steps []gopheract.PlanStep) {
+	entries := make([]acp.PlanEntry, 0, len(steps))
+	for _, step := range steps {
+		entries = append(entries, acp.PlanEntry{
+			Content:  step.Content,
+			Status:   acp.PlanEntryStatus(step.Status),
+			Priority: acp.PlanEntryPriority(step.Priority),
+		})
+	}
+	if err := a.conn.SessionUpdate(turn.ctx, acp.SessionNotification{
+		SessionId: acp.SessionId(turn.sid),
+		Update:    acp.UpdatePlan(entries...),
+	}); err != nil {
+		slog.Warn("failed to send the plan", "session", turn.sid, "error", err)
+	}
+}
+
+// Send the sources cited by the answer of a turn to its session, as resource links following the answer
+func (a *CliAgent) sendCitations(ctx context.Context, sid string, citations []gopheract.Citation) {
+	for _, citation := range citations {
+		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
+			SessionId: acp.SessionId(sid),
+			Update:    acp.UpdateAgentMessage(citationLink(citation)),
+		}); err != nil {
+			slog.Warn("failed to send the citation", "session", sid, "source", citation.Source, "error", err)
+		}
+	}
+}
+
+// SetSessionMode implements acp.Agent: the mode applies from the next turn of the session.
 func (a *CliAgent) SetSessionMode(ctx context.Context, params acp.SetSessionModeRequest) (acp.SetSessionModeResponse, error) {
+	if !isSessionMode(params.ModeId) {
+		return acp.SetSessionModeResponse{}, fmt.Errorf("unknown session mode %s", params.ModeId)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[string(params.SessionId)]
+	if !ok {
+		return acp.SetSessionModeResponse{}, fmt.Errorf("session %s not found", params.SessionId)
+	}
+	s.mode = params.ModeId
 	return acp.SetSessionModeResponse{}, nil
 }
 
@@ -50,46 +299,91 @@ func (a *CliAgent) SetSessionModel(ctx context.Context, params acp.SetSessionMod
 func (a *CliAgent) SetAgentConnection(conn *acp.AgentSideConnection) { a.conn = conn }
 
 func (a *CliAgent) Initialize(ctx context.Context, params acp.InitializeRequest) (acp.InitializeResponse, error) {
+	a.mu.Lock()
+	a.clientCapabilities = params.ClientCapabilities
+	a.mu.Unlock()
 	return acp.InitializeResponse{
 		ProtocolVersion: acp.ProtocolVersionNumber,
 		AgentCapabilities: acp.AgentCapabilities{
-			LoadSession: false,
+			LoadSession: true,
 			PromptCapabilities: acp.PromptCapabilities{
-				Audio:           false,
-				Image:           false,
-				EmbeddedContext: false,
+				Audio:           a.transcriber != nil,
+				Image:           a.agents.Base.AcceptsImages(),
+				EmbeddedContext: true,
 			},
 		},
+		AuthMethods: a.authMethods(),
 	}, nil
 }
 
 func (a *CliAgent) NewSession(ctx context.Context, params acp.NewSessionRequest) (acp.NewSessionResponse, error) {
+	cwd, err := sessionWorkdir(params.Cwd)
+	if err != nil {
+		return acp.NewSessionResponse{}, err
+	}
 	sid := RandomID()
 	a.mu.Lock()
-	a.sessions[sid] = &AgentSession{}
+	a.sessions[sid] = &AgentSession{mode: defaultMode, cwd: cwd}
 	a.mu.Unlock()
-	return acp.NewSessionResponse{SessionId: acp.SessionId(sid)}, nil
+	a.agents.Open(sid)
+	time.AfterFunc(availableCommandsDelay, func() { a.advertiseCommands(context.Background(), sid) })
+	return acp.NewSessionResponse{SessionId: acp.SessionId(sid), Modes: sessionModeState(defaultMode)}, nil
 }
 
-func (a *CliAgent) Authenticate(ctx context.Context, _ acp.AuthenticateRequest) (acp.AuthenticateResponse, error) {
-	return acp.AuthenticateResponse{}, nil
-}
-
-func (a *CliAgent) LoadSession(ctx context.Context, _ acp.LoadSessionRequest) (acp.LoadSessionResponse, error) {
-	return acp.LoadSessionResponse{}, nil
+// Reopen a session persisted in the memory store, replaying its history to the client before responding, as required by ACP.
+func (a *CliAgent) LoadSession(ctx context.Context, params acp.LoadSessionRequest) (acp.LoadSessionResponse, error) {
+	cwd, err := sessionWorkdir(params.Cwd)
+	if err != nil {
+		return acp.LoadSessionResponse{}, err
+	}
+	sid := string(params.SessionId)
+	history, err := a.agents.Open(sid).Agent.History()
+	if err == nil && len(history) == 0 {
+		err = fmt.Errorf("session %s not found", sid)
+	}
+	if err != nil {
+		a.agents.Close(sid)
+		return acp.LoadSessionResponse{}, err
+	}
+	a.mu.Lock()
+	s, ok := a.sessions[sid]
+	if !ok {
+		s = &AgentSession{mode: defaultMode}
+		a.sessions[sid] = s
+	}
+	// the client may reopen the session in another directory
+	s.cwd = cwd
+	mode := s.mode
+	a.mu.Unlock()
+	for _, update := range HistoryToUpdates(history) {
+		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
+			SessionId: params.SessionId,
+			Update:    update,
+		}); err != nil {
+			return acp.LoadSessionResponse{}, err
+		}
+	}
+	a.advertiseCommands(ctx, sid)
+	return acp.LoadSessionResponse{Modes: sessionModeState(mode)}, nil
 }
 
 func (a *CliAgent) Cancel(ctx context.Context, params acp.CancelNotification) error {
 	a.mu.Lock()
-	s, ok := a.sessions[string(params.SessionId)]
+	var cancel context.CancelFunc
+	if s, ok := a.sessions[string(params.SessionId)]; ok && s != nil {
+		cancel = s.cancel
+	}
 	a.mu.Unlock()
-	if ok && s != nil && s.cancel != nil {
-		s.cancel()
+	if cancel != nil {
+		cancel()
 	}
 	return nil
 }
 
-func (a *CliAgent) Prompt(_ context.Context, params acp.PromptRequest) (acp.PromptResponse, error) {
+func (a *CliAgent) Prompt(ctx context.Context, params acp.PromptRequest) (acp.PromptResponse, error) {
+	if !a.authorized() {
+		return acp.PromptResponse{}, acp.NewAuthRequired(map[string]any{"authMethods": a.authMethods()})
+	}
 	sid := string(params.SessionId)
 	a.mu.Lock()
 	s, ok := a.sessions[sid]
@@ -97,39 +391,64 @@ func (a *CliAgent) Prompt(_ context.Context, params acp.PromptRequest) (acp.Prom
 	if !ok {
 		return acp.PromptResponse{}, fmt.Errorf("session %s not found", sid)
 	}
-	prompt, err := ContentBlocksToString(params.Prompt)
+	prompt, images, err := ContentBlocksToPrompt(ctx, params.Prompt, a.transcriber)
 	if err != nil {
 		return acp.PromptResponse{}, fmt.Errorf("%s", err.Error())
 	}
+	prompt, err = expandPromptCommand(a.commands, prompt)
+	if err != nil {
+		return acp.PromptResponse{}, err
+	}
 
-	// cancel any previous turn
-	a.mu.Lock()
-	if s.cancel != nil {
-		prev := s.cancel
-		a.mu.Unlock()
-		prev()
-	} else {
-		a.mu.Unlock()
+	// the turn is interrupted by a session/cancel notification, as well as when the request is cancelled (e.g. the client disconnects)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done, err := a.startTurn(ctx, s, cancel)
+	if err != nil {
+		return acp.PromptResponse{StopReason: acp.StopReasonCancelled}, nil
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	a.mu.Lock()
-	s.cancel = cancel
-	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		s.cancel = nil
+		s.done = nil
+		a.mu.Unlock()
+		close(done)
+	}()
 
 	// simulate a full turn with streaming updates and a permission request
-	if err := a.takeTurn(ctx, sid, prompt); err != nil {
+	if err := a.takeTurn(ctx, sid, prompt, images); err != nil {
 		if ctx.Err() != nil {
 			return acp.PromptResponse{StopReason: acp.StopReasonCancelled}, nil
 		}
 		return acp.PromptResponse{}, err
 	}
-	a.mu.Lock()
-	s.cancel = nil
-	a.mu.Unlock()
 	return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
 }
 
-func (a *CliAgent) takeTurn(ctx context.Context, sid string, prompt string) error {
+// Private method cancelling the turn in progress in a session, if any, and waiting for it to end before registering a new one, returning the channel to close when the new turn ends (fails if the context is cancelled while waiting)
+func (a *CliAgent) startTurn(ctx context.Context, s *AgentSession, cancel context.CancelFunc) (chan struct{}, error) {
+	for {
+		a.mu.Lock()
+		if s.done == nil {
+			done := make(chan struct{})
+			s.cancel = cancel
+			s.done = done
+			a.mu.Unlock()
+			return done, nil
+		}
+		prev, prevDone := s.cancel, s.done
+		a.mu.Unlock()
+		// the previous turn still works on the agent and the history of the session
+		prev()
+		select {
+		case <-prevDone:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (a *CliAgent) takeTurn(ctx context.Context, sid string, prompt string, images []gopheract.ImageContent) error {
 	// disclaimer: stream a demo notice so clients see it's the example agent
 	if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
 		SessionId: acp.SessionId(sid),
@@ -137,83 +456,174 @@ func (a *CliAgent) takeTurn(ctx context.Context, sid string, prompt string) erro
 	}); err != nil {
 		return err
 	}
-	toolCallId := 0
-	thoughtCallback := func(s string) {
-		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
-			SessionId: acp.SessionId(sid),
-			Update:    acp.UpdateAgentThoughtText(s),
-		}); err != nil {
-			log.Printf("An error occurred while sending the thought: %s\n", err.Error())
-			return
+	a.mu.Lock()
+	mode := defaultMode
+	var cwd string
+	if s, ok := a.sessions[sid]; ok {
+		if s.mode != "" {
+			mode = s.mode
 		}
+		cwd = s.cwd
 	}
-	observationCallback := func(s string) {
-		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
-			SessionId: acp.SessionId(sid),
-			Update:    acp.UpdateAgentMessageText("### Observation\n" + s),
-		}); err != nil {
-			log.Printf("An error occurred while sending the observation: %s\n", err.Error())
+	turn := &activeTurn{ctx: ctx, sid: sid, mode: mode, cwd: cwd, changes: map[acp.ToolCallId]gopheract.FileChange{}, tools: map[acp.ToolCallId]string{}, paths: map[acp.ToolCallId]string{}}
+	a.mu.Unlock()
+	handleEvent := func(event gopheract.AgentEvent) {
+		var update acp.SessionUpdate
+		switch e := event.(type) {
+		case gopheract.ProgressEvent:
+			// clients have no progress indicator, so only warn when the run nears its step limit
+			if e.Fraction() < progressWarningThreshold {
+				return
+			}
+			update = acp.UpdateAgentMessageText(fmt.Sprintf("Step %d of %d (%s elapsed): the run is nearing its step limit", e.Step, e.MaxSteps, e.Elapsed.Round(time.Second)))
+		case gopheract.ThoughtEvent:
+			update = acp.UpdateAgentThoughtText(e.Thought)
+		case gopheract.ObservationEvent:
+			update = acp.UpdateAgentMessageText("### Observation\n" + e.Observation)
+		case gopheract.VerificationEvent:
+			if !e.Rework {
+				return
+			}
+			update = acp.UpdateAgentThoughtText("The answer was sent back for rework: " + e.Verdict.Feedback)
+		case gopheract.GuardrailEvent:
+			if !e.Blocked() && e.Verdict.Action != gopheract.GuardrailRewrite {
+				return
+			}
+			verb := "blocked"
+			if !e.Blocked() {
+				verb = "rewritten"
+			}
+			subject := "The " + string(e.Stage) + " was"
+			if e.Tool != "" {
+				subject = "The arguments of " + e.Tool + " were"
+			}
+			update = acp.UpdateAgentThoughtText(fmt.Sprintf("%s %s by the %s guardrail: %s", subject, verb, e.Guardrail, e.Verdict.Reason))
+		case gopheract.StopEvent:
+			slog.Debug("preparing to exit", "session", sid)
+			update = acp.UpdateAgentMessageText(e.Reason)
+			// the cited sources follow the answer as links, so that clients can open them
+			defer a.sendCitations(ctx, sid, e.Citations)
+		case gopheract.ToolStartEvent:
+			callId := acp.ToolCallId(e.CallID)
+			a.mu.Lock()
+			turn.pendingCalls = append(turn.pendingCalls, callId)
+			turn.tools[callId] = e.ToolCall.Name
+			a.mu.Unlock()
+			args, err := e.ToolCall.ArgsToMap()
+			if err != nil {
+				slog.Warn("failed to convert the arguments of the tool call", "session", sid, "tool", e.ToolCall.Name, "error", err)
+			}
+			opts := []acp.ToolCallStartOpt{acp.WithStartStatus(acp.ToolCallStatusPending), acp.WithStartRawInput(args)}
+			if path, ok := args["file_path"].(string); ok {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(cwd, path)
+				}
+				a.mu.Lock()
+				turn.paths[callId] = filepath.Clean(path)
+				a.mu.Unlock()
+				opts = append(opts, acp.WithStartLocations([]acp.ToolCallLocation{{Path: path}}))
+				if e.ToolCall.Name == "Read" {
+					opts = append(opts, acp.WithStartKind(acp.ToolKindRead))
+				} else {
+					opts = append(opts, acp.WithStartKind(acp.ToolKindEdit))
+				}
+			}
+			update = acp.StartToolCall(callId, toolCallTitle(e.ToolCall.Name), opts...)
+		case gopheract.ToolEndEvent:
+			callId := acp.ToolCallId(e.CallID)
+			a.mu.Lock()
+			turn.pendingCalls = slices.DeleteFunc(turn.pendingCalls, func(id acp.ToolCallId) bool { return id == callId })
+			change, changed := turn.changes[callId]
+			delete(turn.changes, callId)
+			delete(turn.tools, callId)
+			delete(turn.paths, callId)
+			a.mu.Unlock()
+			opts := []acp.ToolCallUpdateOpt{
+				acp.WithUpdateStatus(acp.ToolCallStatusCompleted),
+				acp.WithUpdateRawOutput(map[string]any{"result": e.Result, "duration": e.Duration.String()}),
+			}
+			// file changes are rendered as inline diffs by the clients
+			if changed {
+				opts = append(opts,
+					acp.WithUpdateContent([]acp.ToolCallContent{acp.ToolDiffContent(change.Path, change.NewText, change.OldText)}),
+					acp.WithUpdateLocations([]acp.ToolCallLocation{{Path: change.Path}}),
+				)
+			}
+			update = acp.UpdateToolCall(callId, opts...)
+		default:
 			return
 		}
-	}
-	stopCallback := func(s string) {
 		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
 			SessionId: acp.SessionId(sid),
-			Update:    acp.UpdateAgentMessageText(s),
+			Update:    update,
 		}); err != nil {
-			log.Printf("An error occurred while sending the stop message: %s\n", err.Error())
-			return
+			slog.Warn("failed to send the session update", "session", sid, "event", fmt.Sprintf("%T", event), "error", err)
 		}
 	}
-	actionCallback := func(action gopheract.Action) {
-		if action.ToolCall != nil {
-			toolCallId += 1
-			args, err := action.ToolCall.ArgsToMap()
-			if err != nil {
-				log.Printf("An error occurred while converting the arguments of the tool call: %s", err.Error())
-			}
-			var message string
-			if action.ToolCall.Name != "Bash" {
-				message = fmt.Sprintf("%sing file", action.ToolCall.Name)
-			} else {
-				message = "Executing bash command"
-			}
-			if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
-				SessionId: acp.SessionId(sid),
-				Update: acp.StartToolCall(
-					acp.ToolCallId(fmt.Sprintf("call_%d", toolCallId)),
-					message,
-					acp.WithStartStatus(acp.ToolCallStatusPending),
-					acp.WithStartRawInput(args),
-				),
-			}); err != nil {
-				log.Printf("An error occurred while sending the tool call: %s\n", err.Error())
-				return
-			}
-		}
-		if action.StopReason != nil {
-			log.Println("Preparing to exit...")
-		}
+	if a.replay != nil {
+		return gopheract.ReplayTrace(a.replay, handleEvent)
 	}
-	toolEndCallback := func(v any) {
+	session := a.agents.Open(sid)
+	// the agent of the session is configured for this turn, and must not be shared with a turn of another front end
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	defer session.End()
+	agent := session.Agent
+	agent.Tools = toolsForMode(a.toolbox.ForTurn(a.agents.Base.Tools, turnHandles{
+		Ask: func(question string, options []string) (string, error) {
+			return a.askUser(turn, question, options)
+		},
+		OnPlan: func(steps []gopheract.PlanStep) {
+			a.publishPlan(turn, steps)
+		},
+		Approve: agent.Notifier.NotifyApprovals(func(change gopheract.FileChange) (bool, error) {
+			return a.approveChange(turn, change)
+		}),
+		RunBash: func(params BashParams) (any, error) {
+			return a.runInTerminal(turn, params)
+		},
+		Backend: clientFiles{agent: a, turn: turn, fileMode: a.toolbox.FS.FileMode},
+		Root:    cwd,
+		Agent:   agent,
+	}), mode)
+	if cwd != "" {
+		agent.WorkingDir = cwd
+	}
+	if mode == modePlan {
+		prompt = planModeInstructions + prompt
+	}
+	agent.ApproveToolCall = func(callID string, toolCall gopheract.ToolCall) (bool, error) {
+		return a.approveToolCall(turn, callID, toolCall)
+	}
+	agent.OnRunReport = func(report *gopheract.RunReport) {
 		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
 			SessionId: acp.SessionId(sid),
-			Update: acp.UpdateToolCall(
-				acp.ToolCallId(fmt.Sprintf("call_%d", toolCallId)),
-				acp.WithUpdateStatus(acp.ToolCallStatusCompleted),
-				acp.WithUpdateRawOutput(map[string]any{"result": v}),
-			),
+			Update:    acp.UpdateAgentMessageText("### Run report\n" + report.String()),
 		}); err != nil {
-			log.Printf("An error occurred while sending the tool call completion: %s\n", err.Error())
-			return
+			slog.Warn("failed to send the run report", "session", sid, "error", err)
 		}
 	}
-	err := a.agent.Run(prompt, thoughtCallback, actionCallback, toolEndCallback, observationCallback, stopCallback)
+	// cancelling the turn interrupts the LLM calls and the tools in progress
+	return agent.RunEventsContext(ctx, prompt, images, handleEvent)
+}
 
-	return err
+func RunACP(agent gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy, auth acpAuth, commands []promptCommand, clientArgs []string) {
+	ag := NewCliAgent(agent)
+	ag.policies = policies
+	ag.auth = auth
+	ag.commands = commands
+	// the interactive tools are bound to the session of every turn
+	ag.toolbox = toolbox
+	if model := os.Getenv("GOPHERACT_TRANSCRIPTION_MODEL"); model != "" {
+		// the transcriptions share the API key and base URL of the agent
+		ag.transcriber = &gopheract.OpenAITranscriber{Model: model, Client: agent.Llm.Client}
+	}
+	serveACP(ag, clientArgs)
 }
 
-func RunACP(agent gopheract.OpenAIReActAgent) {
+// Serve an agent over ACP until the peer disconnects.
+func serveACP(ag *CliAgent, clientArgs []string) {
 	// If args provided, treat them as client program + args to spawn and connect via stdio.
 	// Otherwise, default to stdio (allowing manual wiring or use by another process).
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
@@ -224,8 +634,8 @@ func RunACP(agent gopheract.OpenAIReActAgent) {
 		in  io.Reader = os.Stdin
 		cmd *exec.Cmd
 	)
-	if len(os.Args) > 1 {
-		cmd = exec.CommandContext(ctx, os.Args[1], os.Args[2:]...)
+	if len(clientArgs) > 0 {
+		cmd = exec.CommandContext(ctx, clientArgs[0], clientArgs[1:]...)
 		cmd.Stderr = os.Stderr
 		stdin, _ := cmd.StdinPipe()
 		stdout, _ := cmd.StdoutPipe()
@@ -237,7 +647,6 @@ func RunACP(agent gopheract.OpenAIReActAgent) {
 		in = stdout
 	}
 
-	ag := NewCliAgent(agent)
 	asc := acp.NewAgentSideConnection(ag, out, in)
 	asc.SetLogger(slog.Default())
 	ag.SetAgentConnection(asc)
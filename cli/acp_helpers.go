@@ -1,13 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/AstraBert/gopheract"
 	"github.com/coder/acp-go-sdk"
 )
 
@@ -20,21 +30,200 @@ func RandomID() string {
 	return "sess_" + hex.EncodeToString(b[:])
 }
 
-func ContentBlocksToString(blocks []acp.ContentBlock) (string, error) {
+// Convert the content blocks of an ACP prompt to the text of the prompt and the images attached to it. Audio blocks are transcribed into text, if a transcriber is set.
+func ContentBlocksToPrompt(ctx context.Context, blocks []acp.ContentBlock, transcriber gopheract.Transcriber) (string, []gopheract.ImageContent, error) {
 	var prompt string
+	var images []gopheract.ImageContent
 	for _, block := range blocks {
 		switch {
 		case block.Image != nil:
-			return "", errors.New("image input not supported")
+			image := gopheract.ImageContent{MimeType: block.Image.MimeType, Data: block.Image.Data}
+			if image.Data == "" && block.Image.Uri != nil {
+				image.URL = *block.Image.Uri
+			}
+			images = append(images, image)
 		case block.Audio != nil:
-			return "", errors.New("audio input not supported")
-		case block.Resource != nil || block.ResourceLink != nil:
-			return "", errors.New("embedded content not supported")
+			if transcriber == nil {
+				return "", nil, errors.New("audio input not supported")
+			}
+			audio, err := base64.StdEncoding.DecodeString(block.Audio.Data)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid audio data: %w", err)
+			}
+			transcription, err := transcriber.Transcribe(ctx, audio, block.Audio.MimeType)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to transcribe the audio input: %w", err)
+			}
+			prompt += transcription + "\n"
+		case block.Resource != nil:
+			prompt += embeddedResourceContext(block.Resource.Resource) + "\n"
+		case block.ResourceLink != nil:
+			prompt += resourceLinkContext(block.ResourceLink) + "\n"
 		case block.Text != nil:
 			prompt += block.Text.Text + "\n"
 		default:
 			continue
 		}
 	}
-	return prompt, nil
+	return prompt, images, nil
+}
+
+// Maximum size of the content of a resource added to the prompt, in bytes (longer contents are truncated)
+const maxResourceSize = 100_000
+
+// Format the content of a resource as context for the prompt, truncating it to maxResourceSize
+func resourceContext(uri string, content []byte) string {
+	note := ""
+	if len(content) > maxResourceSize {
+		content = content[:maxResourceSize]
+		note = fmt.Sprintf("\n[truncated to the first %d bytes]", maxResourceSize)
+	}
+	return fmt.Sprintf("Content of %s:\n```\n%s\n```%s", resourcePath(uri), content, note)
+}
+
+// Path of the file a resource URI refers to, or the URI itself for resources that are not files
+func resourcePath(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil && parsed.Scheme == "file" {
+		return parsed.Path
+	}
+	return uri
+}
+
+// Whether a resource with the given MIME type holds text
+func isTextMimeType(mimeType *string) bool {
+	if mimeType == nil {
+		return false
+	}
+	return strings.HasPrefix(*mimeType, "text/") || slices.Contains([]string{"application/json", "application/xml", "application/yaml", "application/javascript", "application/toml"}, *mimeType)
+}
+
+// Convert a resource embedded in an ACP prompt (e.g. a file attached in the editor) to context for the prompt
+func embeddedResourceContext(resource acp.EmbeddedResourceResource) string {
+	switch {
+	case resource.TextResourceContents != nil:
+		return resourceContext(resource.TextResourceContents.Uri, []byte(resource.TextResourceContents.Text))
+	case resource.BlobResourceContents != nil:
+		blob := resource.BlobResourceContents
+		if content, err := base64.StdEncoding.DecodeString(blob.Blob); err == nil && isTextMimeType(blob.MimeType) {
+			return resourceContext(blob.Uri, content)
+		}
+		return fmt.Sprintf("Attached binary resource: %s", resourcePath(blob.Uri))
+	}
+	return ""
+}
+
+// Convert a link to a resource in an ACP prompt to context for the prompt: the content of local files is read, other resources are only referenced
+func resourceLinkContext(link *acp.ContentBlockResourceLink) string {
+	path := resourcePath(link.Uri)
+	reference := fmt.Sprintf("Referenced resource %s: %s", link.Name, link.Uri)
+	if path == link.Uri {
+		return reference
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return reference
+	}
+	defer f.Close()
+	// one more byte than the limit, so that truncation is detected
+	content, err := io.ReadAll(io.LimitReader(f, maxResourceSize+1))
+	// files with NUL bytes are binary
+	if err != nil || bytes.IndexByte(content, 0) >= 0 {
+		return reference
+	}
+	return resourceContext(link.Uri, content)
+}
+
+// Link to a source cited by an answer: local files are linked with a file URI pointing to the cited lines, other sources (e.g. URLs) as they are
+func citationLink(citation gopheract.Citation) acp.ContentBlock {
+	uri := citation.Source
+	if parsed, err := url.Parse(uri); err != nil || parsed.Scheme == "" {
+		path, err := filepath.Abs(citation.Source)
+		if err != nil {
+			path = citation.Source
+		}
+		uri = (&url.URL{Scheme: "file", Path: path}).String()
+		if citation.StartLine > 0 {
+			uri += fmt.Sprintf("#L%d-L%d", citation.StartLine, citation.EndLine)
+		}
+	}
+	block := acp.ResourceLinkBlock(fmt.Sprintf("[%d] %s", citation.Number, citation), uri)
+	if citation.Title != "" {
+		block.ResourceLink.Title = &citation.Title
+	}
+	return block
+}
+
+// Title of the tool call updates sent to the client for a tool
+func toolCallTitle(tool string) string {
+	switch tool {
+	case "Bash":
+		return "Executing bash command"
+	case "AskUser":
+		return "Asking the user"
+	case "UpdatePlan":
+		return "Updating the plan"
+	case gopheract.TaskToolName:
+		return "Delegating to a sub-agent"
+	default:
+		return fmt.Sprintf("%sing file", tool)
+	}
+}
+
+// Convert a persisted chat history to the session updates replaying it to the client when the session is loaded (system prompts and reminders are not shown).
+//
+// Tool call ids restart at every run, so they are prefixed with the index of the run to stay unique within the session.
+func HistoryToUpdates(history []*gopheract.ChatMessage) []acp.SessionUpdate {
+	updates := []acp.SessionUpdate{}
+	run := 0
+	for _, message := range history {
+		callId := acp.ToolCallId(fmt.Sprintf("run%d_%s", run, message.ToolCallID))
+		switch message.Phase {
+		case gopheract.MessagePhasePrompt:
+			if message.Role == "user" {
+				run += 1
+				updates = append(updates, acp.UpdateUserMessageText(message.Content))
+			}
+		case gopheract.MessagePhaseThought:
+			updates = append(updates, acp.UpdateAgentThoughtText(message.Content))
+		case gopheract.MessagePhaseAction:
+			tool, serializedArgs, ok := gopheract.ParseActionMessage(message.Content)
+			if !ok {
+				continue
+			}
+			var args any
+			if err := json.Unmarshal([]byte(serializedArgs), &args); err != nil {
+				args = serializedArgs
+			}
+			updates = append(updates, acp.StartToolCall(callId, toolCallTitle(tool), acp.WithStartStatus(acp.ToolCallStatusPending), acp.WithStartRawInput(args)))
+		case gopheract.MessagePhaseTool:
+			updates = append(updates, acp.UpdateToolCall(callId, acp.WithUpdateStatus(acp.ToolCallStatusCompleted), acp.WithUpdateRawOutput(map[string]any{"result": message.Content})))
+		case gopheract.MessagePhaseObservation:
+			updates = append(updates, acp.UpdateAgentMessageText("### Observation\n"+message.Content))
+		case gopheract.MessagePhaseVerification:
+			updates = append(updates, acp.UpdateAgentMessageText("### Verification\n"+message.Content))
+		case "":
+			switch message.Role {
+			case "user":
+				updates = append(updates, acp.UpdateUserMessageText(message.Content))
+			case "assistant":
+				updates = append(updates, acp.UpdateAgentMessageText(message.Content))
+			}
+		}
+	}
+	return updates
+}
+
+// Resolve the working directory of a session given by the client, which must be an absolute path to an existing directory (empty keeps the working directory of the CLI)
+func sessionWorkdir(cwd string) (string, error) {
+	if cwd == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(cwd) {
+		return "", acp.NewInvalidParams(map[string]any{"error": fmt.Sprintf("the working directory %s is not an absolute path", cwd)})
+	}
+	fs, err := gopheract.NewFileSystem(cwd)
+	if err != nil {
+		return "", acp.NewInvalidParams(map[string]any{"error": fmt.Sprintf("invalid working directory: %v", err)})
+	}
+	return fs.Root, nil
 }
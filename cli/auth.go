@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coder/acp-go-sdk"
+)
+
+// Authentication method verifying that the API key of the provider is set and accepted
+const authMethodAPIKey acp.AuthMethodId = "api-key"
+
+// Authentication of the ACP clients, which have the API key of the provider verified before prompting the agent
+type acpAuth struct {
+	// whether prompts are rejected until the client authenticates
+	required bool
+	// environment variable holding the API key of the provider (empty for providers needing no key)
+	apiKeyEnv     string
+	authenticated bool
+}
+
+// Private method returning the authentication methods advertised to the clients
+func (a *CliAgent) authMethods() []acp.AuthMethod {
+	description := "Verify that the provider accepts the API key given with the --api-key flag"
+	if a.auth.apiKeyEnv != "" {
+		description = fmt.Sprintf("Verify that the provider accepts the API key set in $%s (or given with the --api-key flag)", a.auth.apiKeyEnv)
+	}
+	return []acp.AuthMethod{{Id: authMethodAPIKey, Name: "API key", Description: acp.Ptr(description)}}
+}
+
+// Authenticate the client by listing the models of the provider, which fails if the API key is missing or invalid
+func (a *CliAgent) Authenticate(ctx context.Context, params acp.AuthenticateRequest) (acp.AuthenticateResponse, error) {
+	if params.MethodId != authMethodAPIKey {
+		return acp.AuthenticateResponse{}, acp.NewInvalidParams(map[string]any{"error": fmt.Sprintf("unknown authentication method %s", params.MethodId)})
+	}
+	if _, err := a.agents.Base.Llm.Client.Models.List(ctx); err != nil {
+		hint := "use the --api-key flag"
+		if a.auth.apiKeyEnv != "" {
+			hint = fmt.Sprintf("set $%s or use the --api-key flag", a.auth.apiKeyEnv)
+		}
+		return acp.AuthenticateResponse{}, fmt.Errorf("failed to verify the API key (%s): %w", hint, err)
+	}
+	a.mu.Lock()
+	a.auth.authenticated = true
+	a.mu.Unlock()
+	return acp.AuthenticateResponse{}, nil
+}
+
+// Private method returning whether the client may prompt the agent
+func (a *CliAgent) authorized() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !a.auth.required || a.auth.authenticated
+}
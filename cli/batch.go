@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Task of a batch, read from a line of the tasks file
+type batchTask struct {
+	// identifier of the task, reported with its result (defaults to its line number)
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	// session to continue (defaults to a new session)
+	Session string `json:"session"`
+	// maximum duration of the task (e.g. "5m"), overriding --timeout
+	Timeout string `json:"timeout"`
+	timeout time.Duration
+}
+
+// Result of a task of a batch, written as a line of the results file
+type batchResult struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	// "done", "failed", "timeout" or "cancelled"
+	Status        string               `json:"status"`
+	Answer        string               `json:"answer,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	Steps         int                  `json:"steps"`
+	ToolCalls     int                  `json:"tool_calls"`
+	Usage         gopheract.TokenUsage `json:"usage"`
+	EstimatedCost float64              `json:"estimated_cost,omitempty"`
+	DurationMs    int64                `json:"duration_ms"`
+}
+
+// Runner of the tasks of a batch, sharing the agent and the tools of the CLI between its workers
+type batchRunner struct {
+	turns   *turnRunner
+	timeout time.Duration
+	out     *json.Encoder
+	mu      sync.Mutex
+	// results by status, for the summary of the batch
+	counts map[string]int
+	usage  gopheract.TokenUsage
+	cost   float64
+}
+
+// Command running the agent over the prompts of a JSONL file, with bounded parallelism
+func newBatchCommand(opts *cliOptions) *cobra.Command {
+	var concurrency int
+	var output string
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "batch <tasks.jsonl | ->",
+		Short: "Run the agent over the prompts of a JSONL file, writing a result per prompt",
+		Long: `Run the agent over the prompts of a JSONL file (read from stdin with -), a few at once, and write the result of every prompt as a JSON object on its own line, as soon as it ends.
+
+Every line of the tasks file is a JSON object with a "prompt", and optionally an "id" (defaulting to the line number), the "session" to continue and a "timeout" overriding --timeout (e.g. "5m"). Every task runs on its own session, which is saved so that it can be continued with the resume command; the tasks continuing the same session run one after the other, in the order of the file.
+Results have the id of the task, its session_id, its status ("done", "failed", "timeout" or "cancelled"), the answer or the error, the number of steps and tool calls, the token usage, the estimated cost and the duration in milliseconds. A summary of the batch, with the overall usage and cost, is printed on stderr.
+
+Tasks cannot ask questions nor approvals: tool calls needing approval are rejected (allow tools in the configuration files or with --allow-tools to use them). The command fails if any task does not end with an answer.`,
+		Example: `  gopheract batch tasks.jsonl --concurrency 4 --out results.jsonl
+  jq -c '{id: .name, prompt: "Summarize \(.path)"}' files.json | gopheract batch - --timeout 2m`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if concurrency < 1 {
+				return errors.New("--concurrency must be at least 1")
+			}
+			tasks, err := readBatchTasks(args[0])
+			if err != nil {
+				return err
+			}
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			var w io.Writer = os.Stdout
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				w = file
+			}
+			runner := &batchRunner{
+				turns:   newTurnRunner(agent, toolbox, opts.config.Tools, opts.config.agentPool(concurrency)),
+				timeout: timeout,
+				out:     json.NewEncoder(w),
+				counts:  map[string]int{},
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			start := time.Now()
+			runner.run(ctx, tasks, concurrency)
+			fmt.Fprintln(os.Stderr, runner.summary(len(tasks), time.Since(start)))
+			if failed := len(tasks) - runner.counts["done"]; failed > 0 {
+				return fmt.Errorf("%d of %d tasks did not complete", failed, len(tasks))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVarP(&concurrency, "concurrency", "c", 4, "number of tasks running at once")
+	cmd.Flags().StringVarP(&output, "out", "o", "", "file the results are written to (defaults to stdout)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "maximum duration of every task (0 means no limit)")
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return []cobra.Completion{"jsonl", "json"}, cobra.ShellCompDirectiveFilterFileExt
+	}
+	return cmd
+}
+
+// Private function reading the tasks of a batch from a JSONL file (stdin for -), skipping the blank lines
+func readBatchTasks(path string) ([]batchTask, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	tasks := []batchTask{}
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var task batchTask
+		if err := json.Unmarshal(scanner.Bytes(), &task); err != nil {
+			return nil, fmt.Errorf("invalid task on line %d: %w", line, err)
+		}
+		if strings.TrimSpace(task.Prompt) == "" {
+			return nil, fmt.Errorf("invalid task on line %d: empty prompt", line)
+		}
+		if task.ID == "" {
+			task.ID = strconv.Itoa(line)
+		}
+		if ids[task.ID] {
+			return nil, fmt.Errorf("invalid task on line %d: duplicate id %q", line, task.ID)
+		}
+		ids[task.ID] = true
+		if task.Timeout != "" {
+			timeout, err := time.ParseDuration(task.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid task on line %d: %w", line, err)
+			}
+			task.timeout = timeout
+		}
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, errors.New("no tasks to run")
+	}
+	return tasks, nil
+}
+
+// Private function grouping the tasks continuing the same session, in the order of the file (every task without a session is a group of its own)
+func groupBatchTasks(tasks []batchTask) [][]batchTask {
+	groups := [][]batchTask{}
+	bySession := map[string]int{}
+	for _, task := range tasks {
+		if task.Session == "" {
+			groups = append(groups, []batchTask{task})
+			continue
+		}
+		i, ok := bySession[task.Session]
+		if !ok {
+			i = len(groups)
+			bySession[task.Session] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], task)
+	}
+	return groups
+}
+
+// Private method running the tasks with the given number of workers, until they are all done or the context is cancelled (the remaining tasks are then reported as cancelled).
+//
+// The tasks continuing the same session run one after the other on the same worker, in the order of the file.
+func (b *batchRunner) run(ctx context.Context, tasks []batchTask, concurrency int) {
+	groups := groupBatchTasks(tasks)
+	queue := make(chan []batchTask)
+	var workers sync.WaitGroup
+	for range min(concurrency, len(groups)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for group := range queue {
+				for _, task := range group {
+					b.write(b.runTask(ctx, task))
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		queue <- group
+	}
+	close(queue)
+	workers.Wait()
+}
+
+// Private method running a task on its session, without questions nor approvals
+func (b *batchRunner) runTask(ctx context.Context, task batchTask) batchResult {
+	result := batchResult{ID: task.ID, SessionID: task.Session}
+	if result.SessionID == "" {
+		result.SessionID = RandomID()
+	}
+	if ctx.Err() != nil {
+		result.Status = "cancelled"
+		return result
+	}
+	timeout := b.timeout
+	if task.timeout > 0 {
+		timeout = task.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	session := b.turns.agents.Open(result.SessionID)
+	// another task may be working on the same session
+	if err := session.Begin(); err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	defer func() {
+		session.End()
+		b.turns.agents.Close(result.SessionID)
+	}()
+	var report *gopheract.RunReport
+	session.Agent.OnRunReport = func(r *gopheract.RunReport) {
+		report = r
+	}
+	start := time.Now()
+	err := b.turns.runTurn(ctx, nil, session.Agent, task.Prompt, nil, func(event gopheract.AgentEvent) {
+		if e, ok := event.(gopheract.StopEvent); ok {
+			result.Answer = e.Reason
+		}
+	})
+	result.DurationMs = time.Since(start).Milliseconds()
+	if report != nil {
+		result.Steps = report.Steps
+		result.ToolCalls = report.ToolCalls
+		result.Usage = report.Usage
+		result.EstimatedCost = report.EstimatedCost
+	}
+	switch {
+	case err == nil:
+		result.Status = "done"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Status = "timeout"
+		result.Error = fmt.Sprintf("the task did not end within %s", timeout)
+	case ctx.Err() != nil:
+		result.Status = "cancelled"
+	default:
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Private method writing the result of a task and accounting it in the summary of the batch
+func (b *batchRunner) write(result batchResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counts[result.Status]++
+	b.usage = b.usage.Add(result.Usage)
+	b.cost += result.EstimatedCost
+	if err := b.out.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the result of task %s: %v\n", result.ID, err)
+	}
+}
+
+// Private method returning a human-readable summary of the batch
+func (b *batchRunner) summary(tasks int, duration time.Duration) string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Ran %d tasks in %s: %d done", tasks, duration.Round(time.Millisecond), b.counts["done"])
+	for _, status := range []string{"failed", "timeout", "cancelled"} {
+		if b.counts[status] > 0 {
+			fmt.Fprintf(&summary, ", %d %s", b.counts[status], status)
+		}
+	}
+	fmt.Fprintf(&summary, "; %d tokens (%d prompt, %d completion)", b.usage.TotalTokens, b.usage.PromptTokens, b.usage.CompletionTokens)
+	if b.cost > 0 {
+		fmt.Fprintf(&summary, ", estimated cost $%.4f", b.cost)
+	}
+	return summary.String()
+}
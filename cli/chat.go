@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+// Command chatting with the agent in an interactive session, whose chat history is persisted so that it can be resumed
+func newChatCommand(opts *cliOptions) *cobra.Command {
+	var sessionID string
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat with the agent in an interactive session",
+		Long: `Chat with the agent in an interactive session: every prompt starts a turn whose steps are printed as they happen, and the agent remembers the previous turns of the session.
+
+Lines starting with a slash are commands of the session, like /mode plan, /compact or /cost (type /help to list them). Press Ctrl-C to cancel the turn in progress, and Ctrl-D to exit. The session is persisted, so it can be resumed later with the --session flag.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			if sessionID == "" {
+				sessionID = RandomID()
+			}
+			agent.SessionID = sessionID
+			// the inputs are saved next to the chat history of the session
+			return runChat(agent, toolbox, opts.config.Tools, filepath.Join(store.Dir, url.PathEscape(sessionID)+".history"))
+		},
+	}
+	cmd.Flags().StringVarP(&sessionID, "session", "s", "", "identifier of the session to resume (defaults to a new session)")
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc("session", completeSessionIDs))
+	return cmd
+}
+
+// Read the prompts of the user with line editing until they exit, running a turn of the agent for each of them
+func runChat(agent *gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy, historyPath string) error {
+	out, err := newPrinter(verbosityNormal, "auto")
+	if err != nil {
+		return err
+	}
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	if file, err := os.Open(historyPath); err == nil {
+		_, _ = line.ReadHistory(file)
+		file.Close()
+	}
+	defer func() {
+		file, err := os.Create(historyPath)
+		if err != nil {
+			slog.Warn("failed to save the input history", "path", historyPath, "error", err)
+			return
+		}
+		defer file.Close()
+		if _, err := line.WriteHistory(file); err != nil {
+			slog.Warn("failed to save the input history", "path", historyPath, "error", err)
+		}
+	}()
+	ask := func(question string, options []string) (string, error) {
+		fmt.Printf("Question: %s\n", question)
+		if len(options) > 0 {
+			fmt.Printf("Options: %s\n", strings.Join(options, ", "))
+		}
+		answer, err := line.Prompt("? ")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(answer), nil
+	}
+	toolbox.AskUser.Ask = ask
+	toolbox.Plan.OnUpdate = out.plan
+	agent.OnRunReport = out.report
+	agent.ApproveToolCall = approveInTerminal(policies, ask)
+	session := newInteractiveSession(agent)
+	fmt.Printf("Session %s (/help lists the commands, Ctrl-C cancels the current turn, Ctrl-D exits)\n", agent.SessionID)
+	for {
+		prompt, err := line.Prompt("> ")
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Printf("\nResume this session with: gopheract chat --session %s\n", agent.SessionID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		prompt = strings.TrimSpace(prompt)
+		if prompt == "" {
+			continue
+		}
+		line.AppendHistory(prompt)
+		command, args, err := parseSlashCommand(prompt)
+		if err == nil && command != nil {
+			var output string
+			if output, err = command.run(session, args); err == nil {
+				fmt.Println(output)
+			}
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("Error: %s\n", err)
+		case command == nil:
+			chatTurn(agent, session.prompt(prompt), func(event gopheract.AgentEvent) {
+				session.record(event)
+				out.event(event)
+			})
+		}
+	}
+}
+
+// Run a turn of the chat, printing its events as they happen, until it ends or the user cancels it with Ctrl-C
+func chatTurn(agent *gopheract.OpenAIReActAgent, prompt string, handler func(gopheract.AgentEvent)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := agent.RunEventsContext(ctx, prompt, nil, handler)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		fmt.Println("Turn cancelled")
+	case err != nil:
+		fmt.Printf("Error: %s\n", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+
+	"github.com/coder/acp-go-sdk"
+)
+
+// Implementation of gopheract.FileBackend reading and writing the files through the ACP client, so that the agent works on the buffers of the editor (unsaved changes included).
+//
+// Files are read and written on the local disk when the client does not support the operation.
+type clientFiles struct {
+	agent *CliAgent
+	// turn whose session the files are read and written for
+	turn *activeTurn
+	// permissions of the files created on the local disk
+	fileMode os.FileMode
+}
+
+// The filesystem capabilities of the client
+func (c clientFiles) capability() acp.FileSystemCapability {
+	c.agent.mu.Lock()
+	defer c.agent.mu.Unlock()
+	return c.agent.clientCapabilities.Fs
+}
+
+func (c clientFiles) ReadFile(path string) (string, error) {
+	if !c.capability().ReadTextFile {
+		content, err := os.ReadFile(path)
+		return string(content), err
+	}
+	resp, err := c.agent.conn.ReadTextFile(c.turn.ctx, acp.ReadTextFileRequest{SessionId: acp.SessionId(c.turn.sid), Path: path})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (c clientFiles) WriteFile(path string, content string) error {
+	if !c.capability().WriteTextFile {
+		mode := c.fileMode
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		return os.WriteFile(path, []byte(content), mode)
+	}
+	_, err := c.agent.conn.WriteTextFile(c.turn.ctx, acp.WriteTextFileRequest{SessionId: acp.SessionId(c.turn.sid), Path: path, Content: content})
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/coder/acp-go-sdk"
+)
+
+// Delay before advertising the commands of a new session, so that the clients receive the session id before its first update
+const availableCommandsDelay = 100 * time.Millisecond
+
+// Pattern of the names of the commands, which the clients render as slash commands
+var promptCommandName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Commands available without configuration, which can be replaced by the ones of the configuration files
+var builtinPromptCommands = map[string]PromptCommandConfig{
+	"explain": {
+		Description: "Explain how a piece of code works",
+		Hint:        "file, function or feature to explain",
+		Prompt:      "Explain {{if .Input}}{{.Input}}{{else}}the code of this repository{{end}}: what it does, how it works and how it fits in the rest of the codebase. Read the relevant files before answering, and do not change any file.",
+	},
+	"tests": {
+		Description: "Write tests for a piece of code",
+		Hint:        "file, function or feature to test",
+		Prompt:      "Write tests for {{if .Input}}{{.Input}}{{else}}the changes since the last commit{{end}}, following the conventions of the existing tests of the repository, then run them and fix them until they pass.",
+	},
+	"review": {
+		Description: "Review the uncommitted changes",
+		Hint:        "what to focus on",
+		Prompt:      "Review the uncommitted changes of the repository (see git diff HEAD): look for bugs, missing edge cases and departures from the conventions of the codebase, and list your findings by file, the most important first. Do not change any file.{{if .Input}}\n\nFocus on: {{.Input}}{{end}}",
+	},
+}
+
+// A command expanding into a prompt, advertised to the ACP clients
+type promptCommand struct {
+	name        string
+	description string
+	hint        string
+	prompt      *template.Template
+}
+
+// Constructor function for a new promptCommand, checking its name and parsing the template of its prompt
+func newPromptCommand(name string, config PromptCommandConfig) (promptCommand, error) {
+	if !promptCommandName.MatchString(name) {
+		return promptCommand{}, fmt.Errorf("invalid command name %q (expected lowercase letters, digits, - and _)", name)
+	}
+	if strings.TrimSpace(config.Prompt) == "" {
+		return promptCommand{}, fmt.Errorf("command %s has no prompt", name)
+	}
+	tmpl, err := template.New(name).Parse(config.Prompt)
+	if err != nil {
+		return promptCommand{}, fmt.Errorf("invalid prompt for command %s: %w", name, err)
+	}
+	description := config.Description
+	if description == "" {
+		description = fmt.Sprintf("Run the %s prompt", name)
+	}
+	return promptCommand{name: name, description: description, hint: config.Hint, prompt: tmpl}, nil
+}
+
+// Private method returning the built-in commands along with the ones of the configuration, sorted by name
+func (c *Config) promptCommands() ([]promptCommand, error) {
+	configs := maps.Clone(builtinPromptCommands)
+	maps.Copy(configs, c.Commands)
+	commands := make([]promptCommand, 0, len(configs))
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		command, err := newPromptCommand(name, configs[name])
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// Private function expanding a prompt starting with /name into the prompt of the command, with the rest of the prompt as input.
+//
+// Prompts that do not start with a known command are returned unchanged.
+func expandPromptCommand(commands []promptCommand, prompt string) (string, error) {
+	text := strings.TrimLeft(prompt, " \t\n")
+	if !strings.HasPrefix(text, "/") {
+		return prompt, nil
+	}
+	name, input := text[1:], ""
+	// the input may start on the same line or on the next one
+	if end := strings.IndexFunc(name, unicode.IsSpace); end >= 0 {
+		name, input = name[:end], name[end:]
+	}
+	index := slices.IndexFunc(commands, func(command promptCommand) bool { return command.name == name })
+	if index < 0 {
+		return prompt, nil
+	}
+	var expanded strings.Builder
+	if err := commands[index].prompt.Execute(&expanded, struct{ Input string }{strings.TrimSpace(input)}); err != nil {
+		return "", fmt.Errorf("failed to expand the /%s command: %w", name, err)
+	}
+	return expanded.String(), nil
+}
+
+// Private method sending the commands to the client of a session, which renders them as slash commands
+func (a *CliAgent) advertiseCommands(ctx context.Context, sid string) {
+	if len(a.commands) == 0 {
+		return
+	}
+	available := make([]acp.AvailableCommand, 0, len(a.commands))
+	for _, command := range a.commands {
+		entry := acp.AvailableCommand{Name: command.name, Description: command.description}
+		if command.hint != "" {
+			entry.Input = &acp.AvailableCommandInput{UnstructuredCommandInput: &acp.AvailableCommandUnstructuredCommandInput{Hint: command.hint}}
+		}
+		available = append(available, entry)
+	}
+	if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
+		SessionId: acp.SessionId(sid),
+		Update:    acp.SessionUpdate{AvailableCommandsUpdate: &acp.SessionAvailableCommandsUpdate{AvailableCommands: available}},
+	}); err != nil {
+		slog.Warn("failed to send the available commands", "session", sid, "error", err)
+	}
+}
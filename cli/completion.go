@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Private function completing the models: the one of the configuration files, then the models of the registry
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	models := []cobra.Completion{}
+	// the completion runs without the pre-run hooks, so the configuration is loaded here
+	if config, err := loadConfig(); err == nil && config.Model != "" {
+		models = append(models, cobra.CompletionWithDesc(config.Model, "configured model"))
+	}
+	return append(models, gopheract.DefaultModels.Models()...), cobra.ShellCompDirectiveNoFileComp
+}
+
+// Private function completing the identifiers of the persisted sessions, from the most recently updated, described by their first prompt
+func completeSessionIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	store, err := sessionStore()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	sessions, err := store.ListSessions()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ids := make([]cobra.Completion, 0, len(sessions))
+	for _, session := range sessions {
+		if !strings.HasPrefix(session.ID, toComplete) {
+			continue
+		}
+		prompt := ""
+		if history, err := store.List(session.ID); err == nil {
+			prompt = firstPrompt(history)
+		}
+		ids = append(ids, cobra.CompletionWithDesc(session.ID, prompt))
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// Private function completing the last name of a comma-separated list of tools, skipping the ones already listed
+func completeToolNames(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	toolbox, err := GetTools()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	listed := strings.Split(toComplete, ",")
+	prefix := strings.Join(listed[:len(listed)-1], ",")
+	if prefix != "" {
+		prefix += ","
+	}
+	names := []cobra.Completion{}
+	for _, tool := range toolbox.Tools {
+		name := tool.GetMetadata().Name
+		if !slices.Contains(listed[:len(listed)-1], name) {
+			names = append(names, prefix+name)
+		}
+	}
+	// more tools can follow the completed one
+	return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// Private function completing a flag with a fixed set of values
+func completeValues(values ...string) cobra.CompletionFunc {
+	return cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)
+}
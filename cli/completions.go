@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+)
+
+// Name of the model exposed by the OpenAI-compatible API
+const completionsModel = "gopheract"
+
+// A message of an OpenAI chat completion request, whose content is either a string or a list of text and image parts
+type completionMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Request body of the OpenAI-compatible /v1/chat/completions endpoint (the other parameters are ignored)
+type completionRequest struct {
+	Model    string              `json:"model"`
+	Messages []completionMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+}
+
+// The content of a message or delta of a chat completion, where the steps of the run are sent as reasoning
+type completionContent struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+type completionChoice struct {
+	Index        int                `json:"index"`
+	Message      *completionContent `json:"message,omitempty"`
+	Delta        *completionContent `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+// Response body of the OpenAI-compatible /v1/chat/completions endpoint, or one of its chunks when streaming
+type completionResponse struct {
+	ID      string                `json:"id"`
+	Object  string                `json:"object"`
+	Created int64                 `json:"created"`
+	Model   string                `json:"model"`
+	Choices []completionChoice    `json:"choices"`
+	Usage   *gopheract.TokenUsage `json:"usage,omitempty"`
+}
+
+// Server of an OpenAI-compatible chat completions API in front of the agent, so that chat UIs can use it like any other model.
+//
+// The API is stateless: every request runs a turn on a new session, holding the conversation sent by the client.
+type completionsServer struct {
+	// the turns are run by the runner of the WebSocket server, so that they share its limit of turns running at once
+	turns *turnRunner
+}
+
+// Constructor function for a new completionsServer, running its turns through a turn runner
+func newCompletionsServer(turns *turnRunner) *completionsServer {
+	return &completionsServer{turns: turns}
+}
+
+// Private method registering the endpoints of the API
+func (s *completionsServer) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/models", s.models)
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+}
+
+// Private method listing the model of the API
+func (s *completionsServer) models(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   []map[string]any{{"id": completionsModel, "object": "model", "created": 0, "owned_by": "gopheract"}},
+	})
+}
+
+// Private method running a turn of the agent on the conversation of a chat completion request, answering with the final answer of the agent (streamed as server-sent events, along with its thoughts and tool calls, if requested)
+func (s *completionsServer) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req completionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeCompletionError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+	history, prompt, images, err := completionConversation(req.Messages)
+	if err != nil {
+		writeCompletionError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	agent := *s.turns.agents.Base
+	agent.Memory = gopheract.NewInMemoryStore()
+	agent.SessionID = RandomID()
+	if err := agent.AppendHistory(history...); err != nil {
+		writeCompletionError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx, cancel := s.turns.requestContext(r.Context())
+	defer cancel()
+	if req.Model == "" {
+		req.Model = completionsModel
+	}
+	now := time.Now()
+	response := completionResponse{ID: fmt.Sprintf("chatcmpl-%d", now.UnixNano()), Created: now.Unix(), Model: req.Model}
+	var usage gopheract.TokenUsage
+	answer := ""
+	if !req.Stream {
+		err := s.turns.runTurn(ctx, nil, &agent, prompt, images, func(event gopheract.AgentEvent) {
+			switch e := event.(type) {
+			case gopheract.UsageEvent:
+				usage = usage.Add(e.Usage)
+			case gopheract.StopEvent:
+				answer = e.Reason
+			}
+		})
+		if err != nil {
+			writeCompletionError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		stop := "stop"
+		response.Object = "chat.completion"
+		response.Choices = []completionChoice{{Message: &completionContent{Role: "assistant", Content: answer}, FinishReason: &stop}}
+		response.Usage = &usage
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeCompletionError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	response.Object = "chat.completion.chunk"
+	send := func(delta completionContent, finishReason *string) {
+		response.Choices = []completionChoice{{Delta: &delta, FinishReason: finishReason}}
+		data, err := json.Marshal(response)
+		if err != nil {
+			slog.Warn("failed to encode the chunk", "error", err)
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(completionContent{Role: "assistant"}, nil)
+	err = s.turns.runTurn(ctx, nil, &agent, prompt, images, func(event gopheract.AgentEvent) {
+		switch e := event.(type) {
+		case gopheract.ThoughtEvent:
+			send(completionContent{ReasoningContent: e.Thought + "\n\n"}, nil)
+		case gopheract.ToolStartEvent:
+			args, _ := e.ToolCall.ArgsToMap()
+			encoded, _ := json.Marshal(args)
+			send(completionContent{ReasoningContent: fmt.Sprintf("Calling `%s` with `%s`\n\n", e.ToolCall.Name, encoded)}, nil)
+		case gopheract.ObservationEvent:
+			send(completionContent{ReasoningContent: e.Observation + "\n\n"}, nil)
+		case gopheract.StopEvent:
+			send(completionContent{Content: e.Reason}, nil)
+		}
+	})
+	// the response has already started: failures are reported in the content of the answer
+	if err != nil && ctx.Err() == nil {
+		send(completionContent{Content: fmt.Sprintf("\n\nError: %s", err)}, nil)
+	}
+	stop := "stop"
+	send(completionContent{}, &stop)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// Private function splitting the messages of a chat completion request into the history of the conversation and the prompt of the turn, which is the content of the last message (a user message)
+func completionConversation(messages []completionMessage) ([]*gopheract.ChatMessage, string, []gopheract.ImageContent, error) {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return nil, "", nil, errors.New("the last message must be a user message")
+	}
+	history := make([]*gopheract.ChatMessage, 0, len(messages)-1)
+	for _, message := range messages[:len(messages)-1] {
+		content, images, err := completionMessageContent(message.Content)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		role := message.Role
+		// the tool calls of other models are not replayed, and developer messages are the system messages of newer models
+		switch role {
+		case "system", "user", "assistant":
+		case "developer":
+			role = "system"
+		default:
+			continue
+		}
+		chatMessage := gopheract.NewChatMessage(role, content)
+		chatMessage.Images = images
+		history = append(history, chatMessage)
+	}
+	prompt, images, err := completionMessageContent(messages[len(messages)-1].Content)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return history, prompt, images, nil
+}
+
+// Private function decoding the content of a message of a chat completion request, either a string or a list of text and image parts
+func completionMessageContent(raw json.RawMessage) (string, []gopheract.ImageContent, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil, nil
+	}
+	var parts []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		ImageURL struct {
+			URL string `json:"url"`
+		} `json:"image_url"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil, fmt.Errorf("invalid message content: %w", err)
+	}
+	texts := []string{}
+	images := []gopheract.ImageContent{}
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			images = append(images, gopheract.ImageContent{URL: part.ImageURL.URL})
+		}
+	}
+	return strings.Join(texts, "\n"), images, nil
+}
+
+// Private function writing an error in the format of the OpenAI API
+func writeCompletionError(w http.ResponseWriter, status int, message string) {
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "server_error"
+	}
+	writeJSON(w, status, map[string]any{"error": map[string]any{"message": message, "type": errorType}})
+}
+
+// Private function writing a JSON response
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("failed to write the response", "error", err)
+	}
+}
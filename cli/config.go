@@ -0,0 +1,482 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	"gopkg.in/yaml.v3"
+)
+
+// Name of the project-local configuration file, read from the working directory
+const projectConfigFile = ".gopheract.yaml"
+
+// Policy applied to the calls of a tool
+type toolPolicy string
+
+const (
+	// the tool runs without asking for approval
+	policyAllow toolPolicy = "allow"
+	// every call of the tool needs the approval of the user, even if they always allowed its kind of calls
+	policyAsk toolPolicy = "ask"
+	// the tool is not available to the agent
+	policyDeny toolPolicy = "deny"
+)
+
+// An OpenAI-compatible provider of models, with the base URL of its API and the environment variable holding its API key
+type provider struct {
+	baseURL   string
+	apiKeyEnv string
+}
+
+// Providers that can be selected by name, without setting their base URL
+var providers = map[string]provider{
+	"openai":     {apiKeyEnv: "OPENAI_API_KEY"},
+	"openrouter": {baseURL: "https://openrouter.ai/api/v1", apiKeyEnv: "OPENROUTER_API_KEY"},
+	"groq":       {baseURL: "https://api.groq.com/openai/v1", apiKeyEnv: "GROQ_API_KEY"},
+	// local models need no API key
+	"ollama": {baseURL: "http://localhost:11434/v1"},
+}
+
+// An MCP server whose tools are mounted into the agent, either spawned with a command or reached at a URL
+type MCPServerConfig struct {
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Env     map[string]string `yaml:"env"`
+	URL     string            `yaml:"url"`
+	// headers sent to the servers reached at a URL, e.g. Authorization
+	Headers map[string]string `yaml:"headers"`
+}
+
+// A command advertised to the ACP clients, which expands into a prompt
+type PromptCommandConfig struct {
+	Description string `yaml:"description"`
+	// hint displayed by the clients until the input of the command is typed
+	Hint string `yaml:"hint"`
+	// template of the prompt, in which {{.Input}} is replaced by the text typed after the command
+	Prompt string `yaml:"prompt"`
+}
+
+// Limits of the sub-agents spawned with the Task tool
+type SubAgentsConfig struct {
+	// maximum nesting of sub-agents (defaults to 1: sub-agents cannot spawn sub-agents)
+	MaxDepth int `yaml:"maxDepth"`
+	// maximum number of steps of every sub-agent (defaults to the one of the agent)
+	MaxSteps int `yaml:"maxSteps"`
+	// maximum number of tokens every sub-agent can consume (defaults to no limit)
+	MaxTokens int64 `yaml:"maxTokens"`
+}
+
+// Verification of the final answers of the agent, sending them back for rework when they are rejected
+type VerificationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// model checking the answers (defaults to the one of the agent)
+	Model string `yaml:"model"`
+	// instructions of the verifier, replacing the default critic prompt
+	Prompt string `yaml:"prompt"`
+	// maximum number of times an answer is sent back for rework in a run (defaults to 1)
+	MaxReworks int `yaml:"maxReworks"`
+}
+
+// Limits shared by the runs of the serve, daemon and batch modes
+type LimitsConfig struct {
+	// maximum number of LLM requests per minute (defaults to no limit)
+	RequestsPerMinute int `yaml:"requestsPerMinute"`
+	// maximum number of tokens consumed by all the runs of the process, after which they fail (defaults to no limit)
+	MaxTokens int64 `yaml:"maxTokens"`
+	// maximum number of runs waiting for a free slot, beyond which they fail (defaults to no limit)
+	MaxQueued int `yaml:"maxQueued"`
+}
+
+// Knowledge base searched by the agent with the SearchKnowledgeBase tool, filled with the ingest command
+type KnowledgeBaseConfig struct {
+	// URL of the vector store, e.g. sqlite://kb.db
+	Store string `yaml:"store"`
+	// model embedding the chunks and the queries (defaults to text-embedding-3-small)
+	EmbeddingModel string `yaml:"embeddingModel"`
+	// number of chunks retrieved by a search (defaults to 5)
+	TopK int `yaml:"topK"`
+	// whether searches also match the terms of the queries, merging keyword and semantic results
+	Hybrid bool `yaml:"hybrid"`
+}
+
+// Moderation of the prompts and the answers with the moderation endpoint of the provider, blocking the flagged ones
+type ModerationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// moderation model (defaults to omni-moderation-latest)
+	Model string `yaml:"model"`
+	// categories checked, e.g. harassment or self-harm/intent (defaults to all)
+	Categories []string `yaml:"categories"`
+	// scores from which the categories are blocked, by category ("*" for the other categories); the categories without a threshold are blocked when the endpoint flags them
+	Thresholds map[string]float64 `yaml:"thresholds"`
+}
+
+// Redaction of the secrets and personal data (API keys, emails, phone and card numbers) from the messages sent to the provider
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// whether the redacted values are replaced with placeholders that are put back locally in the arguments of the tool calls and in the answers
+	Restore bool `yaml:"restore"`
+	// tools whose arguments keep the placeholders, e.g. the ones sending data to third parties
+	RedactedTools []string `yaml:"redactedTools"`
+}
+
+// Configuration of the CLI, loaded from the user configuration file (config.yaml in the gopheract folder of the user config directory) and from the .gopheract.yaml file of the project, whose settings take precedence.
+//
+// Flags take precedence over both files.
+type Config struct {
+	// name of the provider of the models (defaults to openai)
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+	// base URL of an OpenAI-compatible API, overriding the one of the provider
+	BaseURL string `yaml:"baseUrl"`
+	// environment variable holding the API key, overriding the one of the provider
+	APIKeyEnv string `yaml:"apiKeyEnv"`
+	MaxSteps  int    `yaml:"maxSteps"`
+	// whether ACP clients must authenticate (having the API key verified) before prompting the agent
+	RequireAuth bool `yaml:"requireAuth"`
+	// template replacing the default system prompt, in which {{.}} is replaced by the description of the tools
+	SystemPrompt string `yaml:"systemPrompt"`
+	// instructions appended to the system prompt
+	Instructions string `yaml:"instructions"`
+	// policies of the tools, by tool name
+	Tools      map[string]toolPolicy      `yaml:"tools"`
+	MCPServers map[string]MCPServerConfig `yaml:"mcpServers"`
+	// commands expanding into prompts, by name, added to (or replacing) the built-in ones
+	Commands      map[string]PromptCommandConfig `yaml:"commands"`
+	SubAgents     SubAgentsConfig                `yaml:"subAgents"`
+	Verification  VerificationConfig             `yaml:"verification"`
+	Limits        LimitsConfig                   `yaml:"limits"`
+	KnowledgeBase KnowledgeBaseConfig            `yaml:"knowledgeBase"`
+	Moderation    ModerationConfig               `yaml:"moderation"`
+	Redaction     RedactionConfig                `yaml:"redaction"`
+}
+
+// Load the user and project configuration files, merged field by field (missing files are ignored).
+//
+// Since the project file comes with the repository the agent works on, it cannot change where the API key is sent nor run programs: its provider, base URL, API key variable and MCP servers are ignored, and its tool policies can only make the tools stricter (ask or deny, never allow).
+func loadConfig() (*Config, error) {
+	config := &Config{}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		user, err := readConfig(filepath.Join(configDir, "gopheract", "config.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		config.merge(user)
+	}
+	project, err := readConfig(projectConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if project.Provider != "" || project.BaseURL != "" || project.APIKeyEnv != "" {
+		slog.Warn("ignoring the provider, base URL and API key variable of the project configuration", "file", projectConfigFile)
+		project.Provider, project.BaseURL, project.APIKeyEnv = "", "", ""
+	}
+	if len(project.MCPServers) > 0 {
+		slog.Warn("ignoring the MCP servers of the project configuration, add them to the user configuration or with the --mcp flag", "file", projectConfigFile, "servers", slices.Sorted(maps.Keys(project.MCPServers)))
+		project.MCPServers = nil
+	}
+	if loosened := project.tightenTools(config.Tools); len(loosened) > 0 {
+		slog.Warn("ignoring the tool policies of the project configuration that do not make the tools stricter, allow them in the user configuration or with --allow-tools", "file", projectConfigFile, "tools", loosened)
+	}
+	config.merge(project)
+	return config, nil
+}
+
+// Private function ranking the tool policies from the most permissive to the strictest
+func policyStrictness(policy toolPolicy) int {
+	return slices.Index([]toolPolicy{policyAllow, policyAsk, policyDeny}, policy)
+}
+
+// Private method removing the tool policies that would make the tools more permissive than the given policies (allow is always removed, since tools without a policy may be asked about), returning the names of the removed tools
+func (c *Config) tightenTools(current map[string]toolPolicy) []string {
+	loosened := []string{}
+	for name, policy := range c.Tools {
+		if base, ok := current[name]; policy == policyAllow || (ok && policyStrictness(policy) < policyStrictness(base)) {
+			loosened = append(loosened, name)
+			delete(c.Tools, name)
+		}
+	}
+	slices.Sort(loosened)
+	return loosened
+}
+
+// Read and validate a configuration file, returning an empty configuration if the file does not exist
+func readConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Private method checking the provider, the tool policies, the MCP servers, the limits of the sub-agents, the verification and the runs, the knowledge base, the moderation and the commands of the configuration
+func (c *Config) validate() error {
+	if _, ok := providers[c.Provider]; c.Provider != "" && !ok {
+		return fmt.Errorf("unknown provider %s (known providers: %s)", c.Provider, strings.Join(slices.Sorted(maps.Keys(providers)), ", "))
+	}
+	for tool, policy := range c.Tools {
+		if !slices.Contains([]toolPolicy{policyAllow, policyAsk, policyDeny}, policy) {
+			return fmt.Errorf("invalid policy for tool %s: %s (expected allow, ask or deny)", tool, policy)
+		}
+	}
+	for name, server := range c.MCPServers {
+		if (server.Command == "") == (server.URL == "") {
+			return fmt.Errorf("MCP server %s needs either a command or a URL", name)
+		}
+	}
+	if c.SubAgents.MaxDepth < 0 || c.SubAgents.MaxSteps < 0 || c.SubAgents.MaxTokens < 0 {
+		return errors.New("the limits of the sub-agents cannot be negative")
+	}
+	if c.Verification.MaxReworks < 0 {
+		return errors.New("the maximum number of reworks cannot be negative")
+	}
+	if c.Limits.RequestsPerMinute < 0 || c.Limits.MaxTokens < 0 || c.Limits.MaxQueued < 0 {
+		return errors.New("the limits cannot be negative")
+	}
+	if c.KnowledgeBase.TopK < 0 {
+		return errors.New("the number of chunks retrieved from the knowledge base cannot be negative")
+	}
+	if c.KnowledgeBase.Store != "" {
+		if _, _, err := parseStoreURL(c.KnowledgeBase.Store); err != nil {
+			return err
+		}
+	}
+	for category, threshold := range c.Moderation.Thresholds {
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("invalid moderation threshold for %s: %g (expected a score between 0 and 1)", category, threshold)
+		}
+	}
+	for name, command := range c.Commands {
+		if _, err := newPromptCommand(name, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Private method overriding the settings of the configuration with the ones set in another configuration
+func (c *Config) merge(other *Config) {
+	if other.Provider != "" {
+		c.Provider = other.Provider
+	}
+	if other.Model != "" {
+		c.Model = other.Model
+	}
+	if other.BaseURL != "" {
+		c.BaseURL = other.BaseURL
+	}
+	if other.APIKeyEnv != "" {
+		c.APIKeyEnv = other.APIKeyEnv
+	}
+	if other.MaxSteps != 0 {
+		c.MaxSteps = other.MaxSteps
+	}
+	// either file can require authentication, but not waive it
+	if other.RequireAuth {
+		c.RequireAuth = true
+	}
+	if other.SystemPrompt != "" {
+		c.SystemPrompt = other.SystemPrompt
+	}
+	if other.Instructions != "" {
+		c.Instructions = other.Instructions
+	}
+	if len(other.Tools) > 0 {
+		if c.Tools == nil {
+			c.Tools = map[string]toolPolicy{}
+		}
+		maps.Copy(c.Tools, other.Tools)
+	}
+	if len(other.MCPServers) > 0 {
+		if c.MCPServers == nil {
+			c.MCPServers = map[string]MCPServerConfig{}
+		}
+		maps.Copy(c.MCPServers, other.MCPServers)
+	}
+	if len(other.Commands) > 0 {
+		if c.Commands == nil {
+			c.Commands = map[string]PromptCommandConfig{}
+		}
+		maps.Copy(c.Commands, other.Commands)
+	}
+	if other.SubAgents.MaxDepth != 0 {
+		c.SubAgents.MaxDepth = other.SubAgents.MaxDepth
+	}
+	if other.SubAgents.MaxSteps != 0 {
+		c.SubAgents.MaxSteps = other.SubAgents.MaxSteps
+	}
+	if other.SubAgents.MaxTokens != 0 {
+		c.SubAgents.MaxTokens = other.SubAgents.MaxTokens
+	}
+	if other.Verification.Enabled {
+		c.Verification.Enabled = true
+	}
+	if other.Verification.Model != "" {
+		c.Verification.Model = other.Verification.Model
+	}
+	if other.Verification.Prompt != "" {
+		c.Verification.Prompt = other.Verification.Prompt
+	}
+	if other.Verification.MaxReworks != 0 {
+		c.Verification.MaxReworks = other.Verification.MaxReworks
+	}
+	if other.Limits.RequestsPerMinute != 0 {
+		c.Limits.RequestsPerMinute = other.Limits.RequestsPerMinute
+	}
+	if other.Limits.MaxTokens != 0 {
+		c.Limits.MaxTokens = other.Limits.MaxTokens
+	}
+	if other.Limits.MaxQueued != 0 {
+		c.Limits.MaxQueued = other.Limits.MaxQueued
+	}
+	if other.KnowledgeBase.Store != "" {
+		c.KnowledgeBase.Store = other.KnowledgeBase.Store
+	}
+	if other.KnowledgeBase.EmbeddingModel != "" {
+		c.KnowledgeBase.EmbeddingModel = other.KnowledgeBase.EmbeddingModel
+	}
+	if other.KnowledgeBase.TopK != 0 {
+		c.KnowledgeBase.TopK = other.KnowledgeBase.TopK
+	}
+	if other.KnowledgeBase.Hybrid {
+		c.KnowledgeBase.Hybrid = true
+	}
+	// either file can enable the moderation, but not disable it
+	if other.Moderation.Enabled {
+		c.Moderation.Enabled = true
+	}
+	// either file can enable the redaction, but not disable it
+	if other.Redaction.Enabled {
+		c.Redaction.Enabled = true
+	}
+	if other.Redaction.Restore {
+		c.Redaction.Restore = true
+	}
+	if len(other.Redaction.RedactedTools) > 0 {
+		c.Redaction.RedactedTools = append(c.Redaction.RedactedTools, other.Redaction.RedactedTools...)
+	}
+	if other.Moderation.Model != "" {
+		c.Moderation.Model = other.Moderation.Model
+	}
+	if len(other.Moderation.Categories) > 0 {
+		c.Moderation.Categories = other.Moderation.Categories
+	}
+	if len(other.Moderation.Thresholds) > 0 {
+		if c.Moderation.Thresholds == nil {
+			c.Moderation.Thresholds = map[string]float64{}
+		}
+		maps.Copy(c.Moderation.Thresholds, other.Moderation.Thresholds)
+	}
+}
+
+// Private method overriding the policies of the configuration for the tools allowed and denied with the flags, checking that they are among the available tools
+func (c *Config) overridePolicies(tools []gopheract.Tool, allowed, denied []string) error {
+	available := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		available = append(available, tool.GetMetadata().Name)
+	}
+	policies := map[string]toolPolicy{}
+	for policy, names := range map[toolPolicy][]string{policyAllow: allowed, policyDeny: denied} {
+		for _, name := range names {
+			if !slices.Contains(available, name) {
+				return fmt.Errorf("unknown tool %s (available tools: %s)", name, strings.Join(available, ", "))
+			}
+			if other, ok := policies[name]; ok && other != policy {
+				return fmt.Errorf("tool %s is both allowed and denied", name)
+			}
+			policies[name] = policy
+		}
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	if c.Tools == nil {
+		c.Tools = map[string]toolPolicy{}
+	}
+	maps.Copy(c.Tools, policies)
+	return nil
+}
+
+// Private method returning the provider of the configuration, with its base URL and API key variable overridden by the configuration
+func (c *Config) provider() provider {
+	name := c.Provider
+	if name == "" {
+		name = "openai"
+	}
+	p := providers[name]
+	if c.BaseURL != "" {
+		p.baseURL = c.BaseURL
+	}
+	if c.APIKeyEnv != "" {
+		p.apiKeyEnv = c.APIKeyEnv
+	}
+	return p
+}
+
+// Private method returning a client of the API of the provider
+func (p provider) client(apiKey string) *openai.Client {
+	options := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if p.baseURL != "" {
+		options = append(options, option.WithBaseURL(p.baseURL))
+	}
+	client := openai.NewClient(options...)
+	return &client
+}
+
+// Private method applying the system prompt overrides of the configuration to an agent
+func (c *Config) applySystemPrompt(agent *gopheract.OpenAIReActAgent) error {
+	if c.SystemPrompt != "" {
+		tmpl, err := template.New("systemPrompt").Parse(c.SystemPrompt)
+		if err != nil {
+			return fmt.Errorf("invalid system prompt: %w", err)
+		}
+		agent.SystemPromptTemplate = tmpl
+	}
+	if c.Instructions == "" {
+		return nil
+	}
+	// the instructions are inserted as a value, so that they are not parsed as a template
+	tmpl, err := agent.SystemPromptTemplate.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl, err = tmpl.Funcs(template.FuncMap{"instructions": func() string { return c.Instructions }}).
+		New("withInstructions").
+		Parse(fmt.Sprintf("{{template %q .}}\n\n## Additional Instructions\n\n{{instructions}}", agent.SystemPromptTemplate.Name()))
+	if err != nil {
+		return err
+	}
+	agent.SystemPromptTemplate = tmpl
+	return nil
+}
+
+// Private method returning the pool running at most maxRuns runs at once (zero means no limit), with the limits of the configuration
+func (c *Config) agentPool(maxRuns int) *gopheract.AgentPool {
+	pool := gopheract.NewAgentPool(maxRuns)
+	pool.MaxQueued = c.Limits.MaxQueued
+	pool.MaxTokens = c.Limits.MaxTokens
+	if c.Limits.RequestsPerMinute > 0 {
+		pool.RateLimiter = gopheract.NewRateLimiter(c.Limits.RequestsPerMinute)
+	}
+	return pool
+}
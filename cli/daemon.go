@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Maximum number of finished runs remembered by the daemon, for the status and attach commands
+const daemonRunHistory = 100
+
+// Request sent by the clients of the daemon, one per connection:
+//   - `run` starts a run of the prompt on a session (a new one if empty), streaming its events if attach is set
+//   - `attach` streams the events of a run from its start, until it ends
+//   - `cancel` cancels a run
+//   - `status` lists the runs and the sessions loaded in memory
+type daemonRequest struct {
+	Command string `json:"command"`
+	Session string `json:"session,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+	Run     string `json:"run,omitempty"`
+	Attach  bool   `json:"attach,omitempty"`
+}
+
+// Message sent to the clients of the daemon, one JSON object per line: `run` with the status of a run (when it starts and when it ends), `event` for the events of the runs (see gopheract.EventPayload), `status` with the runs and sessions, and `error` for the requests that could not be processed
+type daemonMessage struct {
+	Type     string                `json:"type"`
+	Run      *daemonRunStatus      `json:"run,omitempty"`
+	Event    map[string]any        `json:"event,omitempty"`
+	Runs     []daemonRunStatus     `json:"runs,omitempty"`
+	Sessions []daemonSessionStatus `json:"sessions,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// Status of a run of the daemon: running, done, failed or cancelled
+type daemonRunStatus struct {
+	ID        string     `json:"id"`
+	SessionID string     `json:"session_id"`
+	Prompt    string     `json:"prompt"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Answer    string     `json:"answer,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// A session loaded in the memory of the daemon
+type daemonSessionStatus struct {
+	ID    string               `json:"id"`
+	Usage gopheract.TokenUsage `json:"usage"`
+}
+
+// A run of the daemon, with the events it emitted so far
+type daemonRun struct {
+	status daemonRunStatus
+	events []map[string]any
+	cancel context.CancelFunc
+	// closed and replaced whenever an event is emitted or the run ends, waking up the attached clients
+	updated chan struct{}
+}
+
+// Server of the daemon, keeping the agent, its sessions and the runs in memory across the commands of its clients
+type daemonServer struct {
+	// the runs are executed as non-interactive turns, sharing the limit of turns running at once and the idle sessions eviction of the runner
+	turns *turnRunner
+	mu    sync.Mutex
+	runs  map[string]*daemonRun
+	// identifiers of the runs, from the oldest
+	order []string
+	count int
+}
+
+// Constructor function for a new daemonServer, running its turns through a turn runner
+func newDaemonServer(turns *turnRunner) *daemonServer {
+	return &daemonServer{turns: turns, runs: map[string]*daemonRun{}}
+}
+
+// Command running the agent in a long-lived process, controlled over a Unix socket by its subcommands
+func newDaemonCommand(opts *cliOptions) *cobra.Command {
+	var socket string
+	var maxRuns int
+	var idleTimeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the agent in a long-lived process controlled over a Unix socket",
+		Long: `Run the agent in a long-lived process, which keeps its clients and sessions in memory and accepts commands over a Unix socket, so that editor integrations and quick invocations share one warmed-up process.
+
+Runs are started with gopheract daemon run, and keep going in the background when their client detaches: gopheract daemon attach streams their events again, gopheract daemon cancel stops them and gopheract daemon status lists them.
+Runs cannot ask questions nor approvals: tool calls needing approval are rejected (allow tools in the configuration files or with --allow-tools to use them).
+
+Clients connect with newline-delimited JSON: every connection sends one request ({"command": "run" | "attach" | "cancel" | "status", "session", "prompt", "run", "attach"}) and reads JSON messages until the daemon closes it.`,
+		Example: `  gopheract daemon &
+  gopheract daemon run "Fix the failing tests"
+  gopheract daemon run --detach --session sess_0123456789abcdef01234567 "Now update the changelog"
+  gopheract daemon status`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			path, err := daemonSocket(socket)
+			if err != nil {
+				return err
+			}
+			turns := newTurnRunner(agent, toolbox, opts.config.Tools, opts.config.agentPool(maxRuns))
+			if idleTimeout > 0 {
+				go turns.evictIdle(idleTimeout)
+			}
+			return newDaemonServer(turns).serve(path)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&socket, "socket", "", "path of the Unix socket (defaults to daemon.sock in the gopheract folder of the user config directory)")
+	cmd.Flags().IntVar(&maxRuns, "max-runs", 4, "maximum number of runs at once, the others waiting for a free slot (0 means no limit)")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 30*time.Minute, "time after which idle sessions are unloaded from memory (0 keeps them loaded)")
+
+	printOpts := &printOptions{}
+	var sessionID string
+	var detach bool
+	run := &cobra.Command{
+		Use:   "run [instruction | -]",
+		Short: "Start a run in the daemon, printing its steps like print mode",
+		Long: `Start a run in the daemon, on a new session or on the one given with --session, printing its steps like print mode.
+
+Press Ctrl-C to detach: the run keeps going in the daemon.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt, err := readPrompt(args, printOpts.promptFile, os.Stdin)
+			if err != nil {
+				return err
+			}
+			out, err := printOpts.printer(opts.verbose)
+			if err != nil {
+				return err
+			}
+			return daemonAttach(socket, daemonRequest{Command: "run", Session: sessionID, Prompt: prompt, Attach: !detach}, out)
+		},
+	}
+	printOpts.register(run)
+	run.Flags().StringVarP(&sessionID, "session", "s", "", "identifier of the session to continue (defaults to a new session)")
+	run.Flags().BoolVarP(&detach, "detach", "d", false, "print the id of the run and return without waiting for it to end")
+	cobra.CheckErr(run.RegisterFlagCompletionFunc("session", completeSessionIDs))
+
+	attachOpts := &printOptions{}
+	attach := &cobra.Command{
+		Use:   "attach <run-id>",
+		Short: "Print the steps of a run of the daemon, from its start, until it ends",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := attachOpts.printer(opts.verbose)
+			if err != nil {
+				return err
+			}
+			return daemonAttach(socket, daemonRequest{Command: "attach", Run: args[0]}, out)
+		},
+	}
+	attachOpts.register(attach)
+
+	cancel := &cobra.Command{
+		Use:   "cancel <run-id>",
+		Short: "Cancel a run of the daemon",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return daemonCall(context.Background(), socket, daemonRequest{Command: "cancel", Run: args[0]}, func(msg daemonMessage) error {
+				fmt.Printf("Run %s cancelled\n", msg.Run.ID)
+				return nil
+			})
+		},
+	}
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List the runs of the daemon and the sessions it keeps in memory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return daemonCall(context.Background(), socket, daemonRequest{Command: "status"}, printDaemonStatus)
+		},
+	}
+	cmd.AddCommand(run, attach, cancel, status)
+	return cmd
+}
+
+// Private function returning the path of the socket of the daemon, defaulting to daemon.sock in the gopheract folder of the user config directory
+func daemonSocket(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gopheract", "daemon.sock"), nil
+}
+
+// Private method accepting the connections of the clients until the process is interrupted (or terminated), then cancelling the runs in progress and waiting for their history to be saved
+func (d *daemonServer) serve(path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	// the socket of a daemon that did not shut down cleanly is left behind
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// only the user can control the agent
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	slog.Info("daemon listening", "socket", path)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		go d.handle(conn)
+	}
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return d.turns.close(shutdownCtx)
+}
+
+// Private method processing the request of a connection
+func (d *daemonServer) handle(conn net.Conn) {
+	defer conn.Close()
+	encoder := json.NewEncoder(conn)
+	send := func(msg daemonMessage) error {
+		return encoder.Encode(msg)
+	}
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		_ = send(daemonMessage{Type: "error", Error: fmt.Sprintf("invalid request: %s", err)})
+		return
+	}
+	var err error
+	switch req.Command {
+	case "run":
+		var run *daemonRun
+		run, err = d.start(req.Session, req.Prompt)
+		if err == nil && req.Attach {
+			err = d.attach(run, send)
+		} else if err == nil {
+			err = send(daemonMessage{Type: "run", Run: d.status(run)})
+		}
+	case "attach":
+		var run *daemonRun
+		run, err = d.find(req.Run)
+		if err == nil {
+			err = d.attach(run, send)
+		}
+	case "cancel":
+		var run *daemonRun
+		run, err = d.find(req.Run)
+		if err == nil {
+			run.cancel()
+			err = send(daemonMessage{Type: "run", Run: d.status(run)})
+		}
+	case "status":
+		err = send(d.statusMessage())
+	default:
+		err = fmt.Errorf("unknown command %s", req.Command)
+	}
+	if err != nil {
+		_ = send(daemonMessage{Type: "error", Error: err.Error()})
+	}
+}
+
+// Private method starting a run of a prompt on a session (a new one if empty) in the background
+func (d *daemonServer) start(sessionID, prompt string) (*daemonRun, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, errors.New("empty prompt")
+	}
+	if sessionID == "" {
+		sessionID = RandomID()
+	}
+	session := d.turns.agents.Open(sessionID)
+	// another run may be working on the same session
+	if err := session.Begin(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(d.turns.ctx)
+	d.mu.Lock()
+	d.count += 1
+	run := &daemonRun{
+		status:  daemonRunStatus{ID: fmt.Sprintf("run_%d", d.count), SessionID: sessionID, Prompt: prompt, Status: "running", StartedAt: time.Now().UTC()},
+		cancel:  cancel,
+		updated: make(chan struct{}),
+	}
+	d.runs[run.status.ID] = run
+	d.order = append(d.order, run.status.ID)
+	d.forgetFinished()
+	d.mu.Unlock()
+	go func() {
+		defer cancel()
+		err := d.turns.runTurn(ctx, nil, session.Agent, prompt, nil, func(event gopheract.AgentEvent) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if e, ok := event.(gopheract.StopEvent); ok {
+				run.status.Answer = e.Reason
+			}
+			run.events = append(run.events, gopheract.EventPayload(event))
+			run.notify()
+		})
+		session.End()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			run.status.Status = "cancelled"
+		case err != nil:
+			run.status.Status = "failed"
+			run.status.Error = err.Error()
+		default:
+			run.status.Status = "done"
+		}
+		ended := time.Now().UTC()
+		run.status.EndedAt = &ended
+		run.notify()
+	}()
+	return run, nil
+}
+
+// Private method forgetting the oldest finished runs beyond daemonRunHistory (the lock must be held)
+func (d *daemonServer) forgetFinished() {
+	for len(d.order) > daemonRunHistory {
+		index := -1
+		for i, id := range d.order {
+			if d.runs[id].status.EndedAt != nil {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return
+		}
+		delete(d.runs, d.order[index])
+		d.order = append(d.order[:index], d.order[index+1:]...)
+	}
+}
+
+// Wake up the clients attached to the run (the lock of the daemon must be held)
+func (r *daemonRun) notify() {
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+// Private method returning the run with the given identifier
+func (d *daemonServer) find(id string) (*daemonRun, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	run, ok := d.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("run %s not found", id)
+	}
+	return run, nil
+}
+
+// Private method returning a copy of the status of a run
+func (d *daemonServer) status(run *daemonRun) *daemonRunStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := run.status
+	return &status
+}
+
+// Private method returning the status of the runs and of the sessions loaded in memory
+func (d *daemonServer) statusMessage() daemonMessage {
+	msg := daemonMessage{Type: "status", Runs: []daemonRunStatus{}, Sessions: []daemonSessionStatus{}}
+	d.mu.Lock()
+	for _, id := range d.order {
+		msg.Runs = append(msg.Runs, d.runs[id].status)
+	}
+	d.mu.Unlock()
+	for _, session := range d.turns.agents.Sessions() {
+		msg.Sessions = append(msg.Sessions, daemonSessionStatus{ID: session.ID, Usage: session.Usage()})
+	}
+	return msg
+}
+
+// Private method sending the status of a run, its events from the start and the ones it emits until it ends, and its final status
+func (d *daemonServer) attach(run *daemonRun, send func(daemonMessage) error) error {
+	if err := send(daemonMessage{Type: "run", Run: d.status(run)}); err != nil {
+		return err
+	}
+	next := 0
+	for {
+		d.mu.Lock()
+		events := run.events[next:]
+		next = len(run.events)
+		status := run.status
+		updated := run.updated
+		d.mu.Unlock()
+		for _, event := range events {
+			if err := send(daemonMessage{Type: "event", Event: event}); err != nil {
+				return err
+			}
+		}
+		if status.EndedAt != nil {
+			return send(daemonMessage{Type: "run", Run: &status})
+		}
+		<-updated
+	}
+}
+
+// Send a request to the daemon, handling the messages it sends back until it closes the connection or the context is done
+func daemonCall(ctx context.Context, socket string, req daemonRequest, handle func(daemonMessage) error) error {
+	path, err := daemonSocket(socket)
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("cannot reach the daemon (start it with gopheract daemon): %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(conn)
+	for {
+		var msg daemonMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if msg.Type == "error" {
+			return errors.New(msg.Error)
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Start or attach to a run of the daemon, printing its events until it ends or the user detaches with Ctrl-C
+func daemonAttach(socket string, req daemonRequest, out *printer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var final *daemonRunStatus
+	var runID string
+	err := daemonCall(ctx, socket, req, func(msg daemonMessage) error {
+		switch msg.Type {
+		case "run":
+			if runID == "" {
+				runID = msg.Run.ID
+				if !req.Attach && req.Command == "run" {
+					fmt.Println(runID)
+				} else if out.json == nil {
+					fmt.Fprintf(os.Stderr, "Run %s on session %s\n", msg.Run.ID, msg.Run.SessionID)
+				}
+			}
+			if msg.Run.EndedAt != nil {
+				final = msg.Run
+			}
+		case "event":
+			out.payload(msg.Event)
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return err
+	case final == nil && runID != "" && ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Detached, the run goes on (gopheract daemon attach %s to follow it, gopheract daemon cancel %s to stop it)\n", runID, runID)
+	case final != nil && final.Status == "failed":
+		return errors.New(final.Error)
+	case final != nil && final.Status == "cancelled":
+		return fmt.Errorf("run %s cancelled", final.ID)
+	}
+	return nil
+}
+
+// Print the runs and the sessions of the daemon
+func printDaemonStatus(msg daemonMessage) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSESSION\tSTATUS\tSTARTED\tPROMPT")
+	for _, run := range msg.Runs {
+		prompt := firstPrompt([]*gopheract.ChatMessage{gopheract.NewChatMessage("user", run.Prompt)})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", run.ID, run.SessionID, run.Status, run.StartedAt.Local().Format(time.DateTime), prompt)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d sessions in memory\n", len(msg.Sessions))
+	return nil
+}
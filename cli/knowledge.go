@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AstraBert/gopheract"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/cobra"
+)
+
+// Embedding model of the knowledge base when the configuration does not set one
+const defaultEmbeddingModel = "text-embedding-3-small"
+
+// Scheme and location of the URL of a vector store (only sqlite://path is supported)
+func parseStoreURL(raw string) (string, string, error) {
+	scheme, location, found := strings.Cut(raw, "://")
+	if !found || location == "" {
+		return "", "", fmt.Errorf("invalid store %s (expected sqlite://path)", raw)
+	}
+	if scheme != "sqlite" {
+		return "", "", fmt.Errorf("unsupported store %s (expected sqlite://path)", raw)
+	}
+	return scheme, location, nil
+}
+
+// Vector store at the given URL, created if it does not exist yet
+func openVectorStore(raw string) (*gopheract.SQLiteVectorStore, error) {
+	_, path, err := parseStoreURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection avoids "database is locked" errors between the writes of the store
+	db.SetMaxOpenConns(1)
+	store, err := gopheract.NewSQLiteVectorStore(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error while opening the store %s: %w", raw, err)
+	}
+	return store, nil
+}
+
+// Knowledge base configured in the configuration files, embedding the chunks with the API of the provider
+func newKnowledgeBase(config KnowledgeBaseConfig, provider provider, apiKey string) (*gopheract.KnowledgeBase, error) {
+	store, err := openVectorStore(config.Store)
+	if err != nil {
+		return nil, err
+	}
+	model := config.EmbeddingModel
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+	embedder := gopheract.NewOpenAIEmbedder(apiKey, model)
+	embedder.Client = provider.client(apiKey)
+	knowledgeBase := gopheract.NewKnowledgeBase(embedder, store)
+	knowledgeBase.TopK = config.TopK
+	return knowledgeBase, nil
+}
+
+// Command ingesting files and directories into the knowledge base searched by the agent
+func newIngestCommand(opts *cliOptions) *cobra.Command {
+	var store, embeddingModel string
+	cmd := &cobra.Command{
+		Use:   "ingest <path>...",
+		Short: "Load, chunk, embed and store documents in the knowledge base searched by the agent",
+		Long: `Load the given files, or the files of the given directories and their subdirectories (skipping hidden ones), split them into chunks, embed the chunks and store them in the knowledge base, which the agent searches with the SearchKnowledgeBase tool.
+
+Text, Markdown, HTML, PDF and Word (.docx) files are supported. Ingestion is incremental: the files whose content did not change since they were last ingested are skipped, the chunks of the files that changed are replaced, and the chunks of the files removed from the ingested directories are deleted.
+
+The store defaults to the knowledgeBase.store setting of the configuration files, which the agent reads the knowledge base from.`,
+		Example: `  gopheract ingest ./docs --store sqlite://kb.db
+  gopheract ingest README.md docs/ --embedding-model text-embedding-3-large`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := opts.config.KnowledgeBase
+			if cmd.Flags().Changed("store") {
+				config.Store = store
+			}
+			if cmd.Flags().Changed("embedding-model") {
+				config.EmbeddingModel = embeddingModel
+			}
+			if config.Store == "" {
+				return errors.New("no store: set knowledgeBase.store in a configuration file or use the --store flag")
+			}
+			if _, _, err := parseStoreURL(config.Store); err != nil {
+				return err
+			}
+			provider, apiKey, err := resolveProvider(cmd, opts)
+			if err != nil {
+				return err
+			}
+			knowledgeBase, err := newKnowledgeBase(config, provider, apiKey)
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			failed := 0
+			for _, path := range args {
+				report, err := knowledgeBase.Ingest(ctx, path)
+				printIngestReport(path, report)
+				if err != nil {
+					return err
+				}
+				failed += len(report.Failed)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d files could not be ingested", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&store, "store", "", "URL of the store of the knowledge base, e.g. sqlite://kb.db (defaults to knowledgeBase.store of the configuration files)")
+	cmd.Flags().StringVar(&embeddingModel, "embedding-model", "", fmt.Sprintf("model embedding the chunks, which the agent must use as well (defaults to knowledgeBase.embeddingModel of the configuration files, or %s)", defaultEmbeddingModel))
+	return cmd
+}
+
+// Print the files whose chunks changed during an ingestion and the ones that failed, followed by a summary
+func printIngestReport(path string, report gopheract.IngestReport) {
+	for _, outcome := range []struct {
+		label string
+		paths []string
+	}{{"added", report.Added}, {"updated", report.Updated}, {"removed", report.Removed}} {
+		for _, file := range outcome.paths {
+			fmt.Printf("%-8s %s\n", outcome.label, file)
+		}
+	}
+	for _, file := range slices.Sorted(maps.Keys(report.Failed)) {
+		fmt.Fprintf(os.Stderr, "failed   %s: %v\n", file, report.Failed[file])
+	}
+	fmt.Printf("%s: %s\n", path, report)
+}
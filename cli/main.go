@@ -1,21 +1,512 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/AstraBert/gopheract"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
 )
 
+// Model used when neither the flags nor the configuration files set one
+const defaultModel = "gpt-4.1"
+
+// Options shared by the commands running the agent, set with the global flags
+type cliOptions struct {
+	provider string
+	model    string
+	apiKey   string
+	baseURL  string
+	maxSteps int
+	tools    []string
+	// MCP servers added to the ones of the configuration files, as name=command [args...] or name=url
+	mcpServers []string
+	// tools running without approval, and tools not available to the agent
+	allowTools []string
+	denyTools  []string
+	workdir    string
+	verbose    bool
+	// whether the agent can start without an API key, which the ACP clients then have verified when authenticating
+	deferAPIKey bool
+	// configuration loaded from the configuration files, overridden by the flags
+	config *Config
+}
+
 func main() {
-	tools := GetTools()
-	agent, err := gopheract.NewDefaultOpenAIReactAgent(os.Getenv("OPENAI_API_KEY"), "gpt-4.1", tools)
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Root command, serving the agent over ACP (on stdio, or spawning the client program given as arguments), with the print and trace subcommands
+func newRootCommand() *cobra.Command {
+	opts := &cliOptions{}
+	root := &cobra.Command{
+		Use:   "gopheract [flags] [client [args...]]",
+		Short: "A ReAct coding agent speaking the Agent Client Protocol",
+		Long: `gopheract is a ReAct coding agent with tools to read, search and edit files and to run bash commands.
+
+Without a subcommand, the agent is served over ACP (Agent Client Protocol) on stdin and stdout, so that editors and other ACP clients can spawn it. If a client program is given as argument, it is spawned and connected to the agent instead.`,
+		Example: `  gopheract
+  gopheract --model gpt-4.1-mini --max-steps 30
+  gopheract print "Find all the TODO comments in this repository"
+  gopheract trace show ~/.config/gopheract/traces/run_123.json
+  source <(gopheract completion bash)`,
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := newLogger(opts.verbose)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+			if opts.workdir != "" {
+				if err := os.Chdir(opts.workdir); err != nil {
+					return fmt.Errorf("invalid working directory: %w", err)
+				}
+			}
+			// the project configuration is looked up in the working directory
+			opts.config, err = loadConfig()
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.deferAPIKey = opts.config.RequireAuth
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			commands, err := opts.config.promptCommands()
+			if err != nil {
+				return err
+			}
+			auth := acpAuth{required: opts.config.RequireAuth, apiKeyEnv: opts.config.provider().apiKeyEnv}
+			RunACP(*agent, toolbox, opts.config.Tools, auth, commands, args)
+			return nil
+		},
+	}
+	// the arguments following the client program are passed to it as they are
+	root.Flags().SetInterspersed(false)
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.provider, "provider", "", fmt.Sprintf("provider of the models, one of %s (defaults to openai)", strings.Join(slices.Sorted(maps.Keys(providers)), ", ")))
+	flags.StringVarP(&opts.model, "model", "m", "", fmt.Sprintf("model used by the agent (defaults to %s)", defaultModel))
+	flags.StringVar(&opts.apiKey, "api-key", "", "API key of the provider (defaults to the value of its environment variable, e.g. $OPENAI_API_KEY)")
+	flags.StringVar(&opts.baseURL, "base-url", "", "base URL of an OpenAI-compatible API (defaults to the one of the provider)")
+	flags.IntVar(&opts.maxSteps, "max-steps", 0, "maximum number of think-act-observe iterations of a run, 0 for no limit (defaults to $GOPHERACT_MAX_STEPS)")
+	flags.StringSliceVar(&opts.tools, "tools", nil, "comma-separated list of the tools available to the agent (defaults to all the tools)")
+	flags.StringSliceVar(&opts.allowTools, "allow-tools", nil, "comma-separated list of the tools running without asking for approval (overrides the policies of the configuration files)")
+	flags.StringSliceVar(&opts.denyTools, "deny-tools", nil, "comma-separated list of the tools not available to the agent, e.g. Bash,Write for a read-only run (overrides the policies of the configuration files)")
+	flags.StringArrayVar(&opts.mcpServers, "mcp", nil, "MCP server whose tools are mounted into the agent, as name=command [args...] or name=url (can be repeated)")
+	flags.StringVarP(&opts.workdir, "workdir", "C", "", "directory the agent works in (defaults to the current directory)")
+	flags.BoolVarP(&opts.verbose, "verbose", "v", false, "log at debug level (overrides $GOPHERACT_LOG_LEVEL)")
+	cobra.CheckErr(root.RegisterFlagCompletionFunc("provider", completeValues(slices.Sorted(maps.Keys(providers))...)))
+	cobra.CheckErr(root.RegisterFlagCompletionFunc("model", completeModels))
+	for _, flag := range []string{"tools", "allow-tools", "deny-tools"} {
+		cobra.CheckErr(root.RegisterFlagCompletionFunc(flag, completeToolNames))
+	}
+	cobra.CheckErr(root.RegisterFlagCompletionFunc("workdir", cobra.FixedCompletions(nil, cobra.ShellCompDirectiveFilterDirs)))
+
+	root.AddCommand(newPrintCommand(opts))
+	root.AddCommand(newChatCommand(opts))
+	root.AddCommand(newTUICommand(opts))
+	root.AddCommand(newServeCommand(opts))
+	root.AddCommand(newDaemonCommand(opts))
+	root.AddCommand(newBatchCommand(opts))
+	root.AddCommand(newRunTeamCommand(opts))
+	root.AddCommand(newSessionsCommand())
+	root.AddCommand(newResumeCommand(opts))
+	root.AddCommand(newTraceCommand())
+	root.AddCommand(newIngestCommand(opts))
+	return root
+}
+
+// Agent and tools configured with the global flags, the configuration files and the environment variables
+func newAgent(cmd *cobra.Command, opts *cliOptions) (*gopheract.OpenAIReActAgent, *Toolbox, error) {
+	config := opts.config
+	flags := cmd.Flags()
+	provider, apiKey, err := resolveProvider(cmd, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if flags.Changed("model") {
+		config.Model = opts.model
+	}
+	if config.Model == "" {
+		config.Model = defaultModel
+	}
+	for _, value := range opts.mcpServers {
+		name, server, err := parseMCPFlag(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if config.MCPServers == nil {
+			config.MCPServers = map[string]MCPServerConfig{}
+		}
+		config.MCPServers[name] = server
+	}
+	toolbox, err := GetTools()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tool := range mountMCPServers(config.MCPServers) {
+		// the tools of MCP servers might have any side effect, so they ask for approval unless allowed
+		name := tool.GetMetadata().Name
+		if _, ok := config.Tools[name]; !ok {
+			if config.Tools == nil {
+				config.Tools = map[string]toolPolicy{}
+			}
+			config.Tools[name] = policyAsk
+		}
+		toolbox.Tools = append(toolbox.Tools, tool)
+	}
+	if err := config.overridePolicies(toolbox.Tools, opts.allowTools, opts.denyTools); err != nil {
+		return nil, nil, err
+	}
+	toolbox.Tools = slices.DeleteFunc(toolbox.Tools, func(tool gopheract.Tool) bool {
+		return config.Tools[tool.GetMetadata().Name] == policyDeny
+	})
+	if len(opts.tools) > 0 {
+		toolbox.Tools, err = selectTools(toolbox.Tools, opts.tools)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	agent, err := gopheract.NewDefaultOpenAIReactAgent(apiKey, config.Model, toolbox.Tools)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	if len(os.Args) == 3 && os.Args[1] == "print" {
-		RunPrint(*agent, os.Args[2])
+	if provider.baseURL != "" {
+		agent.Llm.Client = provider.client(apiKey)
+	}
+	if config.KnowledgeBase.Store != "" {
+		agent.KnowledgeBase, err = newKnowledgeBase(config.KnowledgeBase, provider, apiKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the keyword index is built from the chunks of the store when the agent starts
+		if config.KnowledgeBase.Hybrid {
+			if err := agent.KnowledgeBase.EnableKeywordSearch(context.Background()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := config.applySystemPrompt(agent); err != nil {
+		return nil, nil, err
+	}
+	// ACP sessions have their own working directory, stated instead of this one
+	agent.WorkingDir = toolbox.FS.Root
+	// the sub-agents are copies of the agent, or of the agent of the session of the turn when sessions run at once
+	toolbox.Task.Agent = agent
+	toolbox.Task.MaxDepth = max(config.SubAgents.MaxDepth, 1)
+	toolbox.Task.MaxSteps = config.SubAgents.MaxSteps
+	toolbox.Task.MaxTokens = config.SubAgents.MaxTokens
+	if config.Verification.Enabled {
+		agent.Verifier = &gopheract.Verifier{Prompt: config.Verification.Prompt, MaxReworks: config.Verification.MaxReworks}
+		if config.Verification.Model != "" {
+			llm := *agent.Llm
+			llm.Model = config.Verification.Model
+			agent.Verifier.Llm = &llm
+		}
+	}
+	if config.Redaction.Enabled {
+		agent.Redactor = gopheract.NewRedactor()
+		agent.Redactor.Restore = config.Redaction.Restore
+		agent.Redactor.RedactedTools = config.Redaction.RedactedTools
+	}
+	if config.Moderation.Enabled {
+		moderation := &gopheract.ModerationGuardrail{
+			Model:      config.Moderation.Model,
+			Categories: config.Moderation.Categories,
+			Thresholds: config.Moderation.Thresholds,
+			Action:     gopheract.GuardrailBlock,
+			Client:     provider.client(apiKey),
+		}
+		agent.Guardrails = &gopheract.Guardrails{Prompt: []gopheract.Guardrail{moderation}, Answer: []gopheract.Guardrail{moderation}}
+	}
+	// only the calls of the tools without side effects are answered with the result of an identical call of the run, and any other tool (including the ones of MCP servers) invalidates the results of previous calls
+	agent.ReadOnlyTools = []string{"Read", "SearchKnowledgeBase"}
+	// models served by OpenAI-compatible APIs might be unknown, in which case the context window is not managed
+	if err := setModelContext(agent, agent.Llm.Model); err != nil {
+		slog.Warn("context window not managed", "error", err)
+	}
+	logger := slog.Default()
+	agent.Logger = logger
+	agent.Llm.Logger = logger
+	if addr := os.Getenv("GOPHERACT_METRICS_ADDR"); addr != "" {
+		metrics, err := gopheract.NewMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, nil, err
+		}
+		agent.Metrics = metrics
+		agent.Llm.Metrics = metrics
+		go serveMetrics(addr)
+	}
+	switch auditLog := os.Getenv("GOPHERACT_AUDIT_LOG"); auditLog {
+	case "":
+	case "syslog":
+		agent.Audit, err = gopheract.NewSyslogAuditLog("gopheract")
+	default:
+		agent.Audit, err = gopheract.NewFileAuditLog(auditLog)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	agent.Tracer, err = traceRecorder()
+	if err != nil {
+		return nil, nil, err
+	}
+	agent.EventExporters = eventExporters()
+	if urls := os.Getenv("GOPHERACT_WEBHOOK_URLS"); urls != "" {
+		agent.Notifier = gopheract.NewWebhookNotifier(os.Getenv("GOPHERACT_WEBHOOK_SECRET"), strings.Split(urls, ",")...)
+	}
+	if debugDir := os.Getenv("GOPHERACT_DEBUG_DIR"); debugDir != "" {
+		agent.Llm.Debug, err = gopheract.NewDebugDumper(debugDir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if flags.Changed("max-steps") {
+		agent.MaxSteps = opts.maxSteps
+	} else if value := os.Getenv("GOPHERACT_MAX_STEPS"); value != "" {
+		agent.MaxSteps, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid GOPHERACT_MAX_STEPS: %w", err)
+		}
 	} else {
-		RunACP(*agent)
+		agent.MaxSteps = config.MaxSteps
+	}
+	agent.DedupeToolResults = true
+	if profile := os.Getenv("GOPHERACT_PROFILE"); profile != "" {
+		agent.Profile = gopheract.NewFileProfileStore(profile)
+		agent.ExtractFacts = true
+	}
+	return agent, toolbox, nil
+}
+
+// Number of tokens above which the sessions of the models missing from the registry are compacted
+const defaultCompactionThreshold = 200_000
+
+// Private function managing the context window of the model of an agent: the history is fitted into the window, and long sessions are summarized once they fill three quarters of the window left for the prompt, well before reaching it (for unknown models, the window is not managed and sessions are compacted at a fixed threshold)
+func setModelContext(agent *gopheract.OpenAIReActAgent, model string) error {
+	compaction := gopheract.CompactionPolicy{}
+	if agent.Compaction != nil {
+		compaction = *agent.Compaction
+	}
+	agent.Compaction = &compaction
+	info, ok := gopheract.DefaultModels.ModelInfo(model)
+	if !ok {
+		agent.ContextPolicy = nil
+		compaction.Threshold = defaultCompactionThreshold
+		return fmt.Errorf("unknown context window for model %s", model)
+	}
+	agent.ContextPolicy = gopheract.NewContextWindowPolicy(info.ContextWindow, info.MaxOutputTokens)
+	// some models can fill their whole window with the response
+	prompt := max(info.ContextWindow-info.MaxOutputTokens, info.ContextWindow/2)
+	compaction.Threshold = prompt * 3 / 4
+	return nil
+}
+
+// Provider of the models and its API key, set with the global flags, the configuration files and the environment variables
+func resolveProvider(cmd *cobra.Command, opts *cliOptions) (provider, string, error) {
+	config := opts.config
+	flags := cmd.Flags()
+	if flags.Changed("provider") {
+		if _, ok := providers[opts.provider]; !ok {
+			return provider{}, "", fmt.Errorf("unknown provider %s", opts.provider)
+		}
+		// the base URL and API key variable of the configuration are meant for its own provider
+		config.Provider, config.BaseURL, config.APIKeyEnv = opts.provider, "", ""
+	}
+	if flags.Changed("base-url") {
+		config.BaseURL = opts.baseURL
+	}
+	provider := config.provider()
+	apiKey := opts.apiKey
+	if apiKey == "" && provider.apiKeyEnv != "" {
+		apiKey = os.Getenv(provider.apiKeyEnv)
+		if apiKey == "" && !opts.deferAPIKey {
+			return provider, "", fmt.Errorf("no API key: set %s or use the --api-key flag", provider.apiKeyEnv)
+		}
+	}
+	return provider, apiKey, nil
+}
+
+// Tools with the given names, in the order in which they are given
+func selectTools(tools []gopheract.Tool, names []string) ([]gopheract.Tool, error) {
+	selected := make([]gopheract.Tool, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(tools, func(tool gopheract.Tool) bool { return tool.GetMetadata().Name == name })
+		if index < 0 {
+			available := make([]string, 0, len(tools))
+			for _, tool := range tools {
+				available = append(available, tool.GetMetadata().Name)
+			}
+			return nil, fmt.Errorf("unknown tool %s (available tools: %s)", name, strings.Join(available, ", "))
+		}
+		selected = append(selected, tools[index])
+	}
+	return selected, nil
+}
+
+// Logger writing to stderr (stdout carries the ACP messages), at debug level if verbose, and otherwise at the level set by GOPHERACT_LOG_LEVEL (defaults to warn)
+func newLogger(verbose bool) (*slog.Logger, error) {
+	return newLoggerTo(os.Stderr, verbose)
+}
+
+// Logger writing to the given writer, at the same level as newLogger
+func newLoggerTo(w io.Writer, verbose bool) (*slog.Logger, error) {
+	var level slog.Level
+	if verbose {
+		level = slog.LevelDebug
+	} else if value := os.Getenv("GOPHERACT_LOG_LEVEL"); value == "" {
+		level = slog.LevelWarn
+	} else if err := level.UnmarshalText([]byte(value)); err != nil {
+		return nil, fmt.Errorf("invalid GOPHERACT_LOG_LEVEL: %w", err)
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})), nil
+}
+
+// Expose the Prometheus metrics on the /metrics endpoint of the given address
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics server stopped", "addr", addr, "error", err)
+	}
+}
+
+// Recorder of the run traces, writing them under GOPHERACT_TRACES_DIR and exporting them to Langfuse (LANGFUSE_PUBLIC_KEY, LANGFUSE_SECRET_KEY and optionally LANGFUSE_HOST) and LangSmith (LANGSMITH_API_KEY and optionally LANGSMITH_PROJECT and LANGSMITH_ENDPOINT). Traces are not recorded if none of these is set.
+func traceRecorder() (*gopheract.TraceRecorder, error) {
+	exporters := []gopheract.TraceExporter{}
+	if publicKey, secretKey := os.Getenv("LANGFUSE_PUBLIC_KEY"), os.Getenv("LANGFUSE_SECRET_KEY"); publicKey != "" && secretKey != "" {
+		exporter := gopheract.NewLangfuseExporter(publicKey, secretKey)
+		if host := os.Getenv("LANGFUSE_HOST"); host != "" {
+			exporter.Host = host
+		}
+		exporters = append(exporters, exporter)
+	}
+	if apiKey := os.Getenv("LANGSMITH_API_KEY"); apiKey != "" {
+		exporter := gopheract.NewLangSmithExporter(apiKey, os.Getenv("LANGSMITH_PROJECT"))
+		if endpoint := os.Getenv("LANGSMITH_ENDPOINT"); endpoint != "" {
+			exporter.Endpoint = endpoint
+		}
+		exporters = append(exporters, exporter)
+	}
+	dir := os.Getenv("GOPHERACT_TRACES_DIR")
+	if dir == "" && len(exporters) == 0 {
+		return nil, nil
+	}
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(configDir, "gopheract", "traces")
+	}
+	recorder, err := gopheract.NewTraceRecorder(dir)
+	if err != nil {
+		return nil, err
+	}
+	recorder.Exporters = exporters
+	return recorder, nil
+}
+
+// Exporters of the events of the runs, configured with the standard OTEL_EXPORTER_OTLP_* environment variables (the events are sent as OTLP logs)
+func eventExporters() []gopheract.EventExporter {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/logs"
+	}
+	exporter := gopheract.NewOTLPLogExporter(endpoint)
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	for header := range strings.SplitSeq(headers, ",") {
+		key, value, found := strings.Cut(header, "=")
+		if !found {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		exporter.Headers[strings.TrimSpace(key)] = value
+	}
+	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
+		exporter.ServiceName = serviceName
+	}
+	return []gopheract.EventExporter{exporter}
+}
+
+// Store persisting the history of the sessions, under GOPHERACT_SESSIONS_DIR (defaults to the gopheract/sessions folder in the user config directory)
+func sessionStore() (*gopheract.FileStore, error) {
+	dir := os.Getenv("GOPHERACT_SESSIONS_DIR")
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(configDir, "gopheract", "sessions")
+	}
+	store, err := gopheract.NewFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	store.Retention, err = sessionRetention()
+	if err != nil {
+		return nil, err
+	}
+	// sessions exceeding the retention policy are pruned on startup, and then whenever a new session is created
+	if _, err := store.Prune(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Retention policy of the persisted sessions: by default, the 500 most recent sessions updated in the last 30 days are kept (override with GOPHERACT_SESSIONS_MAX, GOPHERACT_SESSIONS_MAX_AGE and GOPHERACT_SESSIONS_MAX_BYTES)
+func sessionRetention() (gopheract.RetentionPolicy, error) {
+	policy := gopheract.RetentionPolicy{MaxSessions: 500, MaxAge: 30 * 24 * time.Hour}
+	if value := os.Getenv("GOPHERACT_SESSIONS_MAX"); value != "" {
+		maxSessions, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid GOPHERACT_SESSIONS_MAX: %w", err)
+		}
+		policy.MaxSessions = maxSessions
+	}
+	if value := os.Getenv("GOPHERACT_SESSIONS_MAX_AGE"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid GOPHERACT_SESSIONS_MAX_AGE: %w", err)
+		}
+		policy.MaxAge = maxAge
+	}
+	if value := os.Getenv("GOPHERACT_SESSIONS_MAX_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return policy, fmt.Errorf("invalid GOPHERACT_SESSIONS_MAX_BYTES: %w", err)
+		}
+		policy.MaxBytes = maxBytes
 	}
+	return policy, nil
 }
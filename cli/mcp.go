@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+)
+
+// Maximum duration of the initialization of an MCP server, including listing its tools
+const mcpStartTimeout = 30 * time.Second
+
+// Parse the value of an --mcp flag, either name=command [args...] or name=url for servers reached over HTTP
+func parseMCPFlag(value string) (string, MCPServerConfig, error) {
+	name, spec, ok := strings.Cut(value, "=")
+	fields := strings.Fields(spec)
+	if !ok || name == "" || len(fields) == 0 {
+		return "", MCPServerConfig{}, fmt.Errorf("invalid MCP server %q (expected name=command [args...] or name=url)", value)
+	}
+	if strings.HasPrefix(fields[0], "http://") || strings.HasPrefix(fields[0], "https://") {
+		return name, MCPServerConfig{URL: fields[0]}, nil
+	}
+	return name, MCPServerConfig{Command: fields[0], Args: fields[1:]}, nil
+}
+
+// Start the MCP servers and return their tools, prefixed with the name of their server.
+//
+// The servers that fail to start are skipped with a warning, so that a broken server does not prevent the agent from running. The spawned servers exit along with the CLI, when their input is closed.
+func mountMCPServers(servers map[string]MCPServerConfig) []gopheract.Tool {
+	tools := []gopheract.Tool{}
+	for _, name := range slices.Sorted(maps.Keys(servers)) {
+		serverTools, err := mountMCPServer(name, servers[name])
+		if err != nil {
+			slog.Warn("failed to mount the MCP server, ignoring it", "server", name, "error", err)
+			continue
+		}
+		slog.Debug("mounted the MCP server", "server", name, "tools", len(serverTools))
+		tools = append(tools, serverTools...)
+	}
+	return tools
+}
+
+// Private function connecting to an MCP server and listing its tools
+func mountMCPServer(name string, server MCPServerConfig) ([]gopheract.Tool, error) {
+	var client *gopheract.MCPClient
+	if server.URL != "" {
+		client = gopheract.NewHTTPMCPClient(name, server.URL, server.Headers)
+	} else {
+		var err error
+		client, err = gopheract.NewStdioMCPClient(name, server.Command, server.Args, server.Env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+	if err := client.Initialize(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	tools, err := client.Tools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return tools, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/coder/acp-go-sdk"
+)
+
+// Session modes advertised over ACP
+const (
+	// read-only tools, and the agent answers with a plan instead of making changes
+	modePlan acp.SessionModeId = "plan"
+	// every tool call needs the approval of the user
+	modeAsk acp.SessionModeId = "ask"
+	// full tool access: file changes and bash commands need approval, unless always allowed
+	modeCode acp.SessionModeId = "code"
+)
+
+// Mode of new sessions
+const defaultMode = modeCode
+
+var sessionModes = []acp.SessionMode{
+	{Id: modePlan, Name: "Plan", Description: acp.Ptr("Explore the codebase with read-only tools and propose a plan, without making changes")},
+	{Id: modeAsk, Name: "Ask", Description: acp.Ptr("Ask for approval before every tool call")},
+	{Id: modeCode, Name: "Code", Description: acp.Ptr("Full tool access, asking for approval before changing files or running commands")},
+}
+
+// Tools that change the workspace, which are not available in plan mode
+var mutatingTools = []string{"Write", "Edit", "Bash"}
+
+// Instructions prepended to the prompts of the turns in plan mode
+const planModeInstructions = "You are in plan mode: only use read-only tools to explore, do not try to change any file nor run commands, and answer with a step-by-step plan of the changes needed to fulfill the following request (publishing it with the UpdatePlan tool).\n\n"
+
+// State of the session modes, as sent to the client when a session is created or loaded
+func sessionModeState(current acp.SessionModeId) *acp.SessionModeState {
+	return &acp.SessionModeState{AvailableModes: sessionModes, CurrentModeId: current}
+}
+
+// Whether a mode is one of the advertised session modes
+func isSessionMode(mode acp.SessionModeId) bool {
+	return slices.ContainsFunc(sessionModes, func(m acp.SessionMode) bool { return m.Id == mode })
+}
+
+// Tools available to the agent in a mode
+func toolsForMode(tools []gopheract.Tool, mode acp.SessionModeId) []gopheract.Tool {
+	if mode != modePlan {
+		return tools
+	}
+	return slices.DeleteFunc(slices.Clone(tools), func(tool gopheract.Tool) bool {
+		// the tools of MCP servers are kept only if their server declares them read-only
+		if mcpTool, ok := tool.(*gopheract.MCPServerTool); ok {
+			return !mcpTool.ReadOnly()
+		}
+		return slices.Contains(mutatingTools, tool.GetMetadata().Name)
+	})
+}
@@ -1,47 +1,437 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
 )
 
-func thoughtCallback(s string) {
-	fmt.Printf("Thougt: %s\n", s)
+// Verbosity of the output of the runs printed to the console
+type verbosity int
+
+const (
+	// only the final answer, for piping into other commands
+	verbosityQuiet verbosity = iota
+	// the steps of the runs, with the tool results truncated
+	verbosityNormal
+	// the progress of the runs, the full tool results and the token usage of every LLM request as well
+	verbosityVerbose
+)
+
+// Maximum length of the tool results printed outside verbose mode, in bytes
+const toolResultPrintLimit = 1000
+
+// ANSI escape codes of the colors of the sections of the output
+const (
+	ansiReset   = "\033[0m"
+	ansiBold    = "\033[1m"
+	ansiDim     = "\033[2m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+)
+
+// Printer of the events, plans and reports of runs to the console
+type printer struct {
+	out io.Writer
+	// where the questions to the user are printed (stderr in quiet and JSON modes, so that they do not end up in the piped output)
+	prompts io.Writer
+	level   verbosity
+	color   bool
+	// encoder of the JSON objects printed in JSON mode, one per line (nil in text mode)
+	json *json.Encoder
 }
 
-func observationCallback(s string) {
-	fmt.Printf("Observation: %s\n", s)
+// Constructor function for a new printer writing to stdout, whose colors are enabled when colorMode is always, or when it is auto, stdout is a terminal and $NO_COLOR is not set
+func newPrinter(level verbosity, colorMode string) (*printer, error) {
+	p := &printer{out: os.Stdout, prompts: os.Stdout, level: level}
+	if level == verbosityQuiet {
+		p.prompts = os.Stderr
+	}
+	switch colorMode {
+	case "always":
+		p.color = true
+	case "never":
+	case "auto", "":
+		info, err := os.Stdout.Stat()
+		p.color = err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+	default:
+		return nil, fmt.Errorf("invalid color mode %s (expected auto, always or never)", colorMode)
+	}
+	return p, nil
 }
 
-func stopCallback(s string) {
-	fmt.Printf("Stop Reason: %s\n", s)
+// Constructor function for a new printer writing the events, plans and reports of runs to stdout as JSON objects, one per line (see gopheract.EventPayload)
+func newJSONPrinter() *printer {
+	return &printer{out: os.Stdout, prompts: os.Stderr, level: verbosityVerbose, json: json.NewEncoder(os.Stdout)}
+}
+
+// Private method printing a JSON object on its own line
+func (p *printer) encode(value map[string]any) {
+	if err := p.json.Encode(value); err != nil {
+		slog.Warn("failed to print the JSON object", "error", err)
+	}
 }
 
-func actionCallback(a gopheract.Action) {
-	fmt.Printf("Action type: %s\n", a.ActionType)
-	if a.ToolCall != nil {
-		fmt.Printf("Tool name: %s\n", a.ToolCall.Name)
-		args, err := a.ToolCall.ArgsToMap()
+// Private method coloring a text, if colors are enabled
+func (p *printer) paint(color, text string) string {
+	if !p.color {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// Private method printing a section of the output, with its colored label
+func (p *printer) section(color, label, format string, args ...any) {
+	fmt.Fprintf(p.out, "%s %s\n", p.paint(color, label+":"), fmt.Sprintf(format, args...))
+}
+
+// Print the events of a run to the console
+func (p *printer) event(event gopheract.AgentEvent) {
+	if p.json != nil {
+		p.encode(gopheract.EventPayload(event))
+		return
+	}
+	if p.level == verbosityQuiet {
+		if e, ok := event.(gopheract.StopEvent); ok {
+			fmt.Fprintln(p.out, e.Reason)
+		}
+		return
+	}
+	switch e := event.(type) {
+	case gopheract.ProgressEvent:
+		if p.level < verbosityVerbose {
+			return
+		}
+		if e.MaxSteps > 0 {
+			fmt.Fprintln(p.out, p.paint(ansiDim, fmt.Sprintf("Step %d of %d (%s elapsed)", e.Step, e.MaxSteps, e.Elapsed.Round(time.Second))))
+		} else {
+			fmt.Fprintln(p.out, p.paint(ansiDim, fmt.Sprintf("Step %d (%s elapsed)", e.Step, e.Elapsed.Round(time.Second))))
+		}
+	case gopheract.ThoughtEvent:
+		p.section(ansiMagenta, "Thought", "%s", e.Thought)
+	case gopheract.ToolStartEvent:
+		args, err := e.ToolCall.ArgsToMap()
 		if err == nil {
-			fmt.Printf("Tool args: %v\n", args)
+			p.section(ansiYellow, "Tool call", "%s %v", e.ToolCall.Name, args)
+		} else {
+			p.section(ansiRed, "Tool call", "%s (an error occurred while getting the arguments of the tool call: %s)", e.ToolCall.Name, err)
+		}
+	case gopheract.ToolEndEvent:
+		result := p.toolResult(e.Result)
+		if e.Reused {
+			p.section(ansiDim, "Tool result (reused)", "%s", result)
 		} else {
-			fmt.Printf("An error occurred while getting the arguments of the tool call: %s", err.Error())
+			p.section(ansiDim, fmt.Sprintf("Tool result (took %s)", e.Duration.Round(time.Millisecond)), "%s", result)
+		}
+	case gopheract.ObservationEvent:
+		p.section(ansiBlue, "Observation", "%s", e.Observation)
+	case gopheract.VerificationEvent:
+		switch {
+		case e.Verdict.Approved:
+			fmt.Fprintln(p.out, p.paint(ansiDim, "Verification: answer approved"))
+		case e.Rework:
+			p.section(ansiYellow, "Verification", "answer sent back for rework: %s", e.Verdict.Feedback)
+		default:
+			p.section(ansiRed, "Verification", "answer rejected, returned anyway after the last rework: %s", e.Verdict.Feedback)
+		}
+	case gopheract.GuardrailEvent:
+		subject := string(e.Stage)
+		if e.Tool != "" {
+			subject = "arguments of " + e.Tool
+		}
+		switch {
+		case e.Blocked():
+			p.section(ansiRed, "Guardrail", "%s blocked by %s: %s", subject, e.Guardrail, e.Verdict.Reason)
+		case e.Verdict.Action == gopheract.GuardrailRewrite:
+			p.section(ansiYellow, "Guardrail", "%s rewritten by %s: %s", subject, e.Guardrail, e.Verdict.Reason)
+		default:
+			p.section(ansiYellow, "Guardrail", "%s approved despite %s: %s", subject, e.Guardrail, e.Verdict.Reason)
+		}
+	case gopheract.StopEvent:
+		p.section(ansiBold+ansiGreen, "Answer", "%s", e.Reason)
+		for _, citation := range e.Citations {
+			fmt.Fprintln(p.out, p.paint(ansiDim, fmt.Sprintf("[%d] %s", citation.Number, citation)))
+		}
+	case gopheract.UsageEvent:
+		if p.level < verbosityVerbose {
+			return
+		}
+		fmt.Fprintln(p.out, p.paint(ansiDim, fmt.Sprintf("Tokens (%s): %d prompt, %d completion", e.Phase, e.Usage.PromptTokens, e.Usage.CompletionTokens)))
+	}
+}
+
+// Print an event of a run received as a JSON object (see gopheract.EventPayload), e.g. from the daemon
+func (p *printer) payload(payload map[string]any) {
+	if p.json != nil {
+		p.encode(payload)
+		return
+	}
+	kind, _ := payload["type"].(string)
+	if p.level == verbosityQuiet {
+		if kind == "stop" {
+			fmt.Fprintln(p.out, payload["reason"])
+		}
+		return
+	}
+	switch kind {
+	case "thought":
+		p.section(ansiMagenta, "Thought", "%v", payload["thought"])
+	case "tool_start":
+		p.section(ansiYellow, "Tool call", "%v %v", payload["tool"], payload["args"])
+	case "tool_end":
+		p.section(ansiDim, "Tool result", "%s", p.toolResult(payload["result"]))
+	case "observation":
+		p.section(ansiBlue, "Observation", "%v", payload["observation"])
+	case "stop":
+		p.section(ansiBold+ansiGreen, "Answer", "%v", payload["reason"])
+	}
+}
+
+// Private method returning the result of a tool call as printed, truncated outside verbose mode
+func (p *printer) toolResult(result any) string {
+	text := fmt.Sprint(result)
+	if p.level < verbosityVerbose && len(text) > toolResultPrintLimit {
+		text = fmt.Sprintf("%s... (%d more bytes, use --verbose to print them all)", text[:toolResultPrintLimit], len(text)-toolResultPrintLimit)
+	}
+	return text
+}
+
+// Print the plan of the agent as a checklist
+func (p *printer) plan(steps []gopheract.PlanStep) {
+	if p.json != nil {
+		p.encode(map[string]any{"type": "plan", "time": time.Now().UTC(), "steps": steps})
+		return
+	}
+	if p.level == verbosityQuiet {
+		return
+	}
+	fmt.Fprintln(p.out, p.paint(ansiCyan, "Plan:"))
+	for _, step := range steps {
+		mark := " "
+		switch step.Status {
+		case gopheract.PlanStepInProgress:
+			mark = "~"
+		case gopheract.PlanStepCompleted:
+			mark = "x"
 		}
+		fmt.Fprintf(p.out, "  [%s] %s\n", mark, step.Content)
 	}
-	if a.StopReason.Reason != "" {
-		fmt.Printf("Preparing to exit...")
+}
+
+// Print the report of a run
+func (p *printer) report(report *gopheract.RunReport) {
+	if p.json != nil {
+		payload := map[string]any{
+			"type":           "report",
+			"time":           time.Now().UTC(),
+			"session_id":     report.SessionID,
+			"model":          report.Model,
+			"steps":          report.Steps,
+			"tool_calls":     report.ToolCalls,
+			"usage":          report.Usage,
+			"usage_by_phase": report.UsageByPhase,
+			"estimated_cost": report.EstimatedCost,
+			"duration_ms":    report.Duration.Milliseconds(),
+		}
+		if report.Err != nil {
+			payload["error"] = report.Err.Error()
+		}
+		p.encode(payload)
+		return
+	}
+	if p.level == verbosityQuiet {
+		return
 	}
+	fmt.Fprintln(p.out, p.paint(ansiDim, report.String()))
 }
 
-func toolEndCallback(v any) {
-	fmt.Printf("Tool result: %v\n", v)
+// Ask a question to the user in the terminal, reading the answer from stdin
+func (p *printer) ask(question string, options []string) (string, error) {
+	fmt.Fprintf(p.prompts, "%s %s\n", p.paint(ansiCyan, "Question:"), question)
+	if len(options) > 0 {
+		fmt.Fprintf(p.prompts, "Options: %s\n", strings.Join(options, ", "))
+	}
+	fmt.Fprint(p.prompts, "> ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
 }
 
-func RunPrint(agent gopheract.OpenAIReActAgent, prompt string) {
-	err := agent.Run(prompt, thoughtCallback, actionCallback, toolEndCallback, observationCallback, stopCallback)
+// Flags of the commands running the agent on a prompt and printing its steps to the console
+type printOptions struct {
+	quiet      bool
+	colorMode  string
+	output     string
+	promptFile string
+}
+
+// Private method registering the flags on a command
+func (o *printOptions) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&o.quiet, "quiet", "q", false, "only print the final answer")
+	cmd.Flags().StringVarP(&o.promptFile, "prompt-file", "f", "", "file to read the prompt from")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "text", "output format: text, or json to print every event as a JSON object on its own line")
+	cmd.Flags().StringVar(&o.colorMode, "color", "auto", "whether to color the output: auto (when printing to a terminal and $NO_COLOR is not set), always or never")
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc("output", completeValues("text", "json")))
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc("color", completeValues("auto", "always", "never")))
+}
+
+// Private method returning the printer selected by the flags
+func (o *printOptions) printer(verbose bool) (*printer, error) {
+	level := verbosityNormal
+	switch {
+	case o.quiet && verbose:
+		return nil, errors.New("--quiet and --verbose cannot be used together")
+	case o.quiet:
+		level = verbosityQuiet
+	case verbose:
+		level = verbosityVerbose
+	}
+	switch o.output {
+	case "text":
+		return newPrinter(level, o.colorMode)
+	case "json":
+		if o.quiet {
+			return nil, errors.New("--quiet only applies to the text output")
+		}
+		return newJSONPrinter(), nil
+	default:
+		return nil, fmt.Errorf("invalid output format %s (expected text or json)", o.output)
+	}
+}
+
+// Command running the agent on a prompt, printing its steps to the console
+func newPrintCommand(opts *cliOptions) *cobra.Command {
+	printOpts := &printOptions{}
+	cmd := &cobra.Command{
+		Use:   "print [prompt | -]",
+		Short: "Run the agent on a prompt, printing its steps to the console",
+		Long: `Run the agent on a prompt, printing its thoughts, tool calls, observations and final answer to the console.
+
+With --quiet, only the final answer is printed, so that it can be piped into other commands. With --verbose, the progress of the run, the full tool results and the token usage are printed as well.
+
+The prompt is given as argument, read from stdin when the argument is -, or read from a file with --prompt-file. When the prompt is not read from stdin, the content piped to the command is appended to the prompt as context.
+
+With --output json, every event of the run (as well as the plan updates and the report of the run) is printed as a JSON object on its own line, with a "type" field, for scripts and other programs.`,
+		Example: `  gopheract print "Find all the TODO comments in this repository"
+  gopheract print -q "Write a commit message for the staged changes" | git commit -F -
+  echo "Fix the failing test" | gopheract print -
+  gopheract print --prompt-file task.md
+  git diff | gopheract print "Review these changes"
+  gopheract print --output json "List the exported functions" | jq -r 'select(.type == "tool_start") | .tool'`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt, err := readPrompt(args, printOpts.promptFile, os.Stdin)
+			if err != nil {
+				return err
+			}
+			out, err := printOpts.printer(opts.verbose)
+			if err != nil {
+				return err
+			}
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			// the run is persisted, so that it can be continued with the resume command
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			agent.SessionID = RandomID()
+			return runPrinted(agent, toolbox, opts.config.Tools, out, prompt)
+		},
+	}
+	printOpts.register(cmd)
+	return cmd
+}
+
+// Run the agent on a prompt, printing its steps and asking the questions of its tools with the given printer, then how to continue its session (on stderr, so that it does not end up in the piped output)
+func runPrinted(agent *gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy, out *printer, prompt string) error {
+	toolbox.AskUser.Ask = out.ask
+	toolbox.Plan.OnUpdate = out.plan
+	agent.OnRunReport = out.report
+	agent.ApproveToolCall = approveInTerminal(policies, out.ask)
+	err := agent.RunEvents(prompt, out.event)
+	// the report of JSON mode already carries the session id
+	if out.json == nil {
+		fmt.Fprintf(os.Stderr, "Continue this session with: gopheract resume %s \"...\"\n", agent.SessionID)
+	}
+	return err
+}
+
+// Read the prompt of print mode from the arguments (stdin when the argument is -) or from the prompt file, appending the content piped to stdin as context when the prompt is not read from it
+func readPrompt(args []string, promptFile string, stdin *os.File) (string, error) {
+	var prompt string
+	switch {
+	case len(args) == 1 && promptFile != "":
+		return "", errors.New("the prompt is given both as argument and with --prompt-file")
+	case len(args) == 0 && promptFile == "":
+		return "", errors.New("no prompt given: pass it as argument, - to read it from stdin, or --prompt-file")
+	case promptFile != "":
+		content, err := os.ReadFile(promptFile)
+		if err != nil {
+			return "", err
+		}
+		prompt = string(content)
+	case args[0] == "-":
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		prompt = strings.TrimSpace(string(content))
+		if prompt == "" {
+			return "", errors.New("empty prompt read from stdin")
+		}
+		return prompt, nil
+	default:
+		prompt = args[0]
+	}
+	// only pipes and redirected files are read, since reading a terminal (or a pipe left open by the parent process) would block
+	info, err := stdin.Stat()
+	if err != nil || (info.Mode()&os.ModeNamedPipe == 0 && !info.Mode().IsRegular()) {
+		return prompt, nil
+	}
+	piped, err := io.ReadAll(stdin)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+	if len(strings.TrimSpace(string(piped))) == 0 {
+		return prompt, nil
+	}
+	return fmt.Sprintf("%s\n\nThe following content was piped to the command:\n\n<stdin>\n%s\n</stdin>", prompt, strings.TrimRight(string(piped), "\n")), nil
+}
+
+// Approval of the tool calls asked to the user in the terminal with the given function, for the tools whose policy is ask (the other tools run without approval)
+func approveInTerminal(policies map[string]toolPolicy, ask func(question string, options []string) (string, error)) func(callID string, toolCall gopheract.ToolCall) (bool, error) {
+	return func(callID string, toolCall gopheract.ToolCall) (bool, error) {
+		if policies[toolCall.Name] != policyAsk {
+			return true, nil
+		}
+		args, err := toolCall.ArgsToMap()
+		if err != nil {
+			return false, err
+		}
+		answer, err := ask(fmt.Sprintf("Allow the call to %s with args %v?", toolCall.Name, args), []string{"yes", "no"})
+		if err != nil {
+			return false, err
+		}
+		return slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)), nil
 	}
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Command serving the agent over HTTP, for browser UIs and other programs
+func newServeCommand(opts *cliOptions) *cobra.Command {
+	var addr string
+	var origins []string
+	var maxRuns int
+	var idleTimeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the agent over HTTP",
+		Long: `Serve the agent over HTTP, with the following endpoints:
+
+  /ws                    WebSocket carrying prompts, run events, questions, approvals and cancellations, for interactive UIs
+  /v1/chat/completions   OpenAI-compatible chat completions API, for chat UIs (the steps of the runs are streamed as reasoning)
+  /v1/models             models of the OpenAI-compatible API
+  /sessions              persisted sessions, which WebSocket clients can resume
+
+Every WebSocket connection works on its own session, which is persisted and can be resumed with the session query parameter, even after a restart.
+The turns of different sessions run concurrently, up to --max-runs at once, and sessions idle for --idle-timeout are unloaded from memory.
+The chat completions API is stateless, and cannot ask questions: tool calls needing approval are rejected.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			ws := newWSServer(agent, toolbox, opts.config.Tools, opts.config.agentPool(maxRuns))
+			ws.originPatterns = origins
+			if idleTimeout > 0 {
+				go ws.evictIdle(idleTimeout)
+			}
+			mux := http.NewServeMux()
+			mux.Handle("/ws", ws)
+			mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+				sessions, err := store.ListSessions()
+				if err != nil {
+					writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+					return
+				}
+				writeJSON(w, http.StatusOK, sessions)
+			})
+			newCompletionsServer(ws.turnRunner).register(mux)
+			return serveHTTP(addr, mux, ws.close)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().IntVar(&maxRuns, "max-runs", 4, "maximum number of turns running at once, the others waiting for a free slot (0 means no limit)")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 30*time.Minute, "time after which idle sessions are unloaded from memory (0 keeps them loaded)")
+	cmd.Flags().StringSliceVar(&origins, "allowed-origins", nil, "host patterns of the web pages allowed to connect to the WebSocket, besides the ones served from the same host (e.g. localhost:3000)")
+	return cmd
+}
+
+// Serve HTTP requests until the process is interrupted (or terminated), then wait for the requests in progress to complete and call the shutdown function (e.g. closing hijacked connections)
+func serveHTTP(addr string, handler http.Handler, shutdown func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("serving the agent", "addr", addr)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// the turns are cancelled alongside the requests, so that the ones of the chat completions API end as well
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- shutdown(shutdownCtx) }()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
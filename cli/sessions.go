@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Maximum length of the prompts shown when listing the sessions
+const sessionPromptPreview = 60
+
+// Command managing the sessions persisted by the chat, ACP and serve modes
+func newSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage the persisted sessions",
+	}
+	var limit int
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the persisted sessions, from the most recently updated",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			return listSessions(store, limit)
+		},
+	}
+	list.Flags().IntVarP(&limit, "limit", "n", 20, "maximum number of sessions to list (0 lists them all)")
+	cmd.AddCommand(list)
+	return cmd
+}
+
+// Print the most recently updated sessions of a store, with their first prompt
+func listSessions(store *gopheract.FileStore, limit int) error {
+	sessions, err := store.ListSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions")
+		return nil
+	}
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tFIRST PROMPT")
+	for _, session := range sessions {
+		prompt := ""
+		if history, err := store.List(session.ID); err == nil {
+			prompt = firstPrompt(history)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", session.ID, session.UpdatedAt.Local().Format(time.DateTime), session.MessageCount, prompt)
+	}
+	return w.Flush()
+}
+
+// Private function returning the first prompt of the user in a chat history, on a single line and truncated for display
+func firstPrompt(history []*gopheract.ChatMessage) string {
+	for _, message := range history {
+		if message.Role != "user" {
+			continue
+		}
+		prompt := strings.Join(strings.Fields(message.Content), " ")
+		if runes := []rune(prompt); len(runes) > sessionPromptPreview {
+			prompt = string(runes[:sessionPromptPreview-3]) + "..."
+		}
+		return prompt
+	}
+	return ""
+}
+
+// Command continuing a persisted session with a new instruction, printing the steps of the run like print mode
+func newResumeCommand(opts *cliOptions) *cobra.Command {
+	printOpts := &printOptions{}
+	cmd := &cobra.Command{
+		Use:   "resume <session-id> [instruction | -]",
+		Short: "Continue a persisted session with a new instruction",
+		Long: `Continue a persisted session (see gopheract sessions list) with a new instruction, printing the steps of the run like print mode.
+
+The agent remembers the previous turns of the session, so that long multi-step tasks can be continued across invocations. The instruction is given and printed like the prompt of print mode.`,
+		Example: `  gopheract sessions list
+  gopheract resume sess_0123456789abcdef01234567 "Now run the tests and fix the failures"`,
+		Args: cobra.RangeArgs(1, 2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return completeSessionIDs(cmd, args, toComplete)
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			sessionID := args[0]
+			history, err := store.List(sessionID)
+			if err != nil {
+				return err
+			}
+			if len(history) == 0 {
+				return fmt.Errorf("session %s not found (run gopheract sessions list to list the sessions)", sessionID)
+			}
+			prompt, err := readPrompt(args[1:], printOpts.promptFile, os.Stdin)
+			if err != nil {
+				return err
+			}
+			out, err := printOpts.printer(opts.verbose)
+			if err != nil {
+				return err
+			}
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			agent.SessionID = sessionID
+			return runPrinted(agent, toolbox, opts.config.Tools, out, prompt)
+		},
+	}
+	printOpts.register(cmd)
+	return cmd
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/coder/acp-go-sdk"
+)
+
+// Maximum number of messages listed by the /history command
+const historyPreviewMessages = 20
+
+// State of an interactive session (chat or terminal interface), which the slash commands act on
+type interactiveSession struct {
+	agent *gopheract.OpenAIReActAgent
+	// all the tools of the agent, of which the mode keeps a subset
+	tools []gopheract.Tool
+	mode  acp.SessionModeId
+	// tokens consumed since the session was started or resumed
+	usage gopheract.TokenUsage
+}
+
+// Constructor function for a new interactiveSession, in the default mode
+func newInteractiveSession(agent *gopheract.OpenAIReActAgent) *interactiveSession {
+	return &interactiveSession{agent: agent, tools: agent.Tools, mode: defaultMode}
+}
+
+// A command of the interactive sessions, entered as /name followed by its arguments
+type slashCommand struct {
+	name        string
+	args        string
+	description string
+	// whether the command sends requests to the LLM, so that interfaces run it in the background
+	slow bool
+	run  func(s *interactiveSession, args []string) (string, error)
+}
+
+// Commands of the interactive sessions (/help lists them)
+var slashCommands = []slashCommand{
+	{name: "tools", description: "list the tools of the agent", run: (*interactiveSession).listTools},
+	{name: "history", description: "show the most recent messages of the chat history", run: (*interactiveSession).showHistory},
+	{name: "compact", description: "summarize the older turns of the chat history", slow: true, run: (*interactiveSession).compact},
+	{name: "model", args: "[name]", description: "show or switch the model of the agent", run: (*interactiveSession).switchModel},
+	{name: "mode", args: "[plan|code]", description: "show or switch the mode: plan explores with read-only tools and proposes a plan", run: (*interactiveSession).switchMode},
+	{name: "cost", description: "show the tokens consumed and their estimated cost", run: (*interactiveSession).showCost},
+	{name: "save", args: "[path]", description: "save the transcript of the session as Markdown (or JSONL if the path ends with .jsonl)", run: (*interactiveSession).save},
+}
+
+// Private function returning the slash command of an input line, with its arguments (nil if the line is not a slash command)
+func parseSlashCommand(line string) (*slashCommand, []string, error) {
+	if !strings.HasPrefix(line, "/") {
+		return nil, nil, nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(fields) == 0 {
+		return nil, nil, errors.New("missing command, type /help to list the commands")
+	}
+	if fields[0] == "help" {
+		return &slashCommand{name: "help", run: func(*interactiveSession, []string) (string, error) { return slashHelp(), nil }}, nil, nil
+	}
+	index := slices.IndexFunc(slashCommands, func(command slashCommand) bool { return command.name == fields[0] })
+	if index < 0 {
+		return nil, nil, fmt.Errorf("unknown command /%s, type /help to list the commands", fields[0])
+	}
+	return &slashCommands[index], fields[1:], nil
+}
+
+// Private function listing the slash commands
+func slashHelp() string {
+	lines := []string{"Commands:"}
+	for _, command := range append(slices.Clone(slashCommands), slashCommand{name: "help", description: "list the commands"}) {
+		usage := "/" + command.name
+		if command.args != "" {
+			usage += " " + command.args
+		}
+		lines = append(lines, fmt.Sprintf("  %-20s %s", usage, command.description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Private method accounting the token usage of an event of a run
+func (s *interactiveSession) record(event gopheract.AgentEvent) {
+	if e, ok := event.(gopheract.UsageEvent); ok {
+		s.usage = s.usage.Add(e.Usage)
+	}
+}
+
+// Private method returning the estimated cost of the tokens consumed, and whether the prices of the model are known
+func (s *interactiveSession) cost() (float64, bool) {
+	info, ok := gopheract.DefaultModels.ModelInfo(s.agent.Llm.Model)
+	if !ok {
+		return 0, false
+	}
+	return info.Cost(s.usage), true
+}
+
+// Private method returning the prompt of a turn in the mode of the session
+func (s *interactiveSession) prompt(text string) string {
+	if s.mode == modePlan {
+		return planModeInstructions + text
+	}
+	return text
+}
+
+// Private method listing the tools of the agent, with the ones unavailable in the current mode
+func (s *interactiveSession) listTools(args []string) (string, error) {
+	available := toolsForMode(s.tools, s.mode)
+	lines := []string{fmt.Sprintf("Tools (%s mode):", s.mode)}
+	for _, tool := range s.tools {
+		metadata := tool.GetMetadata()
+		description := metadata.Description
+		if runes := []rune(description); len(runes) > 80 {
+			description = string(runes[:77]) + "..."
+		}
+		line := fmt.Sprintf("  %-12s %s", metadata.Name, description)
+		if !slices.ContainsFunc(available, func(t gopheract.Tool) bool { return t.GetMetadata().Name == metadata.Name }) {
+			line += fmt.Sprintf(" (unavailable in %s mode)", s.mode)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Private method showing the size of the chat history and its most recent messages
+func (s *interactiveSession) showHistory(args []string) (string, error) {
+	history, err := s.agent.History()
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("%d messages", len(history))
+	if remaining, err := s.agent.RemainingContext(); err == nil {
+		summary += fmt.Sprintf(", %d tokens left in the context window", remaining)
+	}
+	lines := []string{summary}
+	messages := slices.DeleteFunc(slices.Clone(history), func(message *gopheract.ChatMessage) bool { return message.Role == "system" })
+	if len(messages) > historyPreviewMessages {
+		lines = append(lines, fmt.Sprintf("  ... %d earlier messages", len(messages)-historyPreviewMessages))
+		messages = messages[len(messages)-historyPreviewMessages:]
+	}
+	for _, message := range messages {
+		label := message.Role
+		if message.Phase != "" {
+			label = string(message.Phase)
+		}
+		content := strings.Join(strings.Fields(message.Content), " ")
+		if runes := []rune(content); len(runes) > 100 {
+			content = string(runes[:97]) + "..."
+		}
+		lines = append(lines, fmt.Sprintf("  [%s] %s", label, content))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Private method compacting the chat history
+func (s *interactiveSession) compact(args []string) (string, error) {
+	before, err := s.agent.History()
+	if err != nil {
+		return "", err
+	}
+	if err := s.agent.Compact(); err != nil {
+		return "", err
+	}
+	after, err := s.agent.History()
+	if err != nil {
+		return "", err
+	}
+	if len(after) == len(before) {
+		return "The chat history is too short to be compacted", nil
+	}
+	return fmt.Sprintf("Chat history compacted from %d to %d messages", len(before), len(after)), nil
+}
+
+// Private method switching the model of the agent, along with its context window and compaction threshold
+func (s *interactiveSession) switchModel(args []string) (string, error) {
+	if len(args) == 0 {
+		return fmt.Sprintf("Model: %s", s.agent.Llm.Model), nil
+	}
+	if len(args) > 1 {
+		return "", errors.New("usage: /model [name]")
+	}
+	s.agent.Llm.Model = args[0]
+	if err := setModelContext(s.agent, args[0]); err != nil {
+		return fmt.Sprintf("Switched to %s (unknown model: the context window is not managed)", args[0]), nil
+	}
+	return fmt.Sprintf("Switched to %s", args[0]), nil
+}
+
+// Private method switching the mode of the session, which applies from the next turn
+func (s *interactiveSession) switchMode(args []string) (string, error) {
+	if len(args) == 0 {
+		return fmt.Sprintf("Mode: %s", s.mode), nil
+	}
+	mode := acp.SessionModeId(args[0])
+	if len(args) > 1 || (mode != modePlan && mode != modeCode) {
+		return "", errors.New("usage: /mode [plan|code]")
+	}
+	s.mode = mode
+	s.agent.Tools = toolsForMode(s.tools, mode)
+	return fmt.Sprintf("Switched to %s mode", mode), nil
+}
+
+// Private method showing the tokens consumed since the start of the session and their estimated cost
+func (s *interactiveSession) showCost(args []string) (string, error) {
+	text := fmt.Sprintf("%d tokens (%d prompt, %d completion)", s.usage.TotalTokens, s.usage.PromptTokens, s.usage.CompletionTokens)
+	if cost, ok := s.cost(); ok {
+		text += fmt.Sprintf(", estimated cost $%.4f", cost)
+	} else {
+		text += fmt.Sprintf(" (unknown prices for %s)", s.agent.Llm.Model)
+	}
+	return text, nil
+}
+
+// Private method saving the transcript of the session to a file (defaults to <session id>.md in the working directory)
+func (s *interactiveSession) save(args []string) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("usage: /save [path]")
+	}
+	path := s.agent.SessionID + ".md"
+	if len(args) == 1 {
+		path = args[0]
+	}
+	format := "markdown"
+	if strings.HasSuffix(path, ".jsonl") {
+		format = "jsonl"
+	}
+	transcript, err := s.agent.ExportTranscript(format)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, transcript, 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Transcript saved to %s", path), nil
+}
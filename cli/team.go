@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Command running a team of agents defined in a YAML or JSON file on a prompt, printing the steps of its agents to the console
+func newRunTeamCommand(opts *cliOptions) *cobra.Command {
+	printOpts := &printOptions{}
+	cmd := &cobra.Command{
+		Use:   "run-team <team.yaml> [prompt | -]",
+		Short: "Run a team of agents defined in a YAML or JSON file on a prompt",
+		Long: `Run a team of agents defined in a YAML or JSON file on a prompt, printing the steps of every agent to the console, then the final answer of the team.
+
+The file defines the agents of the team (their name, description, model, instructions, tools and maximum number of steps) and how they are orchestrated: a supervisor routing the prompt to them, a swarm handing the conversation off between them, a fan-out running them at once and merging their answers, or a debate between them. Every agent is a copy of the agent configured with the flags and the configuration files, so the tools of the team are among the ones of the CLI and follow the same policies.
+
+The prompt is read like in print mode, and --quiet and --output json apply as well: in JSON mode, every event has the name of its agent in an "agent" field, and the final answer of the team is printed as a "team_answer" object.`,
+		Example: `  gopheract run-team team.yaml "Add pagination to the users endpoint"
+  gopheract run-team .gopheract/review-team.json - < task.md
+  gopheract run-team team.yaml -q "Is this module thread-safe?"`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := gopheract.LoadTeamConfig(args[0])
+			if err != nil {
+				return err
+			}
+			prompt, err := readPrompt(args[1:], printOpts.promptFile, os.Stdin)
+			if err != nil {
+				return err
+			}
+			out, err := printOpts.printer(opts.verbose)
+			if err != nil {
+				return err
+			}
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			toolbox.AskUser.Ask = out.ask
+			toolbox.Plan.OnUpdate = out.plan
+			agent.ApproveToolCall = approveInTerminal(opts.config.Tools, out.ask)
+			agent.SessionID = RandomID()
+			team, err := config.Build(agent)
+			if err != nil {
+				return err
+			}
+			team.OnEvent = teamPrinter(out)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			answer, err := team.Run(ctx, prompt)
+			if err != nil {
+				return err
+			}
+			switch {
+			case out.json != nil:
+				out.encode(map[string]any{"type": "team_answer", "team": team.Name, "answer": answer})
+			case out.level == verbosityQuiet:
+				fmt.Fprintln(out.out, answer)
+			default:
+				out.section(ansiBold+ansiGreen, "Team answer", "%s", answer)
+			}
+			return nil
+		},
+	}
+	printOpts.register(cmd)
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return []cobra.Completion{"yaml", "yml", "json"}, cobra.ShellCompDirectiveFilterFileExt
+	}
+	return cmd
+}
+
+// Private function returning the callback printing the events of the agents of a team, with a heading whenever another agent takes over (the agents of a fan-out run at once, so the printing is serialized).
+//
+// In quiet mode nothing is printed, since only the final answer of the team matters.
+func teamPrinter(out *printer) func(agent string, event gopheract.AgentEvent) {
+	var mu sync.Mutex
+	last := ""
+	return func(agent string, event gopheract.AgentEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case out.json != nil:
+			payload := gopheract.EventPayload(event)
+			payload["agent"] = agent
+			out.encode(payload)
+		case out.level == verbosityQuiet:
+		default:
+			if agent != last {
+				fmt.Fprintln(out.out, out.paint(ansiBold+ansiCyan, fmt.Sprintf("── %s ──", agent)))
+				last = agent
+			}
+			out.event(event)
+		}
+	}
+}
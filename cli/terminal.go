@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/coder/acp-go-sdk"
+)
+
+// Maximum size of the output of a command run in the terminal of the client, in bytes (the client keeps the end of longer outputs)
+const terminalOutputLimit = 100_000
+
+// Run a bash command in a terminal of the client of the session of a turn, embedding the terminal in the tool call so that the user sees the command run live.
+//
+// Commands run locally when the client has no terminal support. Either way, they are stopped when the turn is cancelled.
+func (a *CliAgent) runInTerminal(turn *activeTurn, params BashParams) (any, error) {
+	a.mu.Lock()
+	terminal := a.clientCapabilities.Terminal
+	var callId acp.ToolCallId
+	if len(turn.pendingCalls) > 0 {
+		callId = turn.pendingCalls[0]
+	}
+	a.mu.Unlock()
+	if !terminal {
+		return execBashIn(turn.ctx, turn.cwd, params)
+	}
+	sid := acp.SessionId(turn.sid)
+	request := acp.CreateTerminalRequest{
+		SessionId:       sid,
+		Command:         params.Command,
+		Args:            params.Arguments,
+		OutputByteLimit: acp.Ptr(terminalOutputLimit),
+	}
+	if turn.cwd != "" {
+		request.Cwd = acp.Ptr(turn.cwd)
+	} else if wd, err := os.Getwd(); err == nil {
+		request.Cwd = acp.Ptr(wd)
+	}
+	created, err := a.conn.CreateTerminal(turn.ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// released even if the turn was cancelled, so that the client frees the terminal
+		if _, err := a.conn.ReleaseTerminal(context.Background(), acp.ReleaseTerminalRequest{SessionId: sid, TerminalId: created.TerminalId}); err != nil {
+			slog.Warn("failed to release the terminal", "session", turn.sid, "terminal", created.TerminalId, "error", err)
+		}
+	}()
+	if callId != "" {
+		if err := a.conn.SessionUpdate(turn.ctx, acp.SessionNotification{
+			SessionId: sid,
+			Update: acp.UpdateToolCall(
+				callId,
+				acp.WithUpdateStatus(acp.ToolCallStatusInProgress),
+				acp.WithUpdateContent([]acp.ToolCallContent{acp.ToolTerminalRef(created.TerminalId)}),
+			),
+		}); err != nil {
+			slog.Warn("failed to send the session update", "session", turn.sid, "error", err)
+		}
+	}
+	exit, err := a.conn.WaitForTerminalExit(turn.ctx, acp.WaitForTerminalExitRequest{SessionId: sid, TerminalId: created.TerminalId})
+	if err != nil {
+		return nil, err
+	}
+	var output string
+	// the SDK rejects empty outputs, so failing to get the output of a command that exited is not fatal
+	if resp, err := a.conn.TerminalOutput(turn.ctx, acp.TerminalOutputRequest{SessionId: sid, TerminalId: created.TerminalId}); err == nil {
+		output = resp.Output
+	} else {
+		slog.Debug("failed to get the terminal output", "session", turn.sid, "terminal", created.TerminalId, "error", err)
+	}
+	switch {
+	case exit.Signal != nil:
+		return nil, fmt.Errorf("command killed by signal %s: %s", *exit.Signal, output)
+	case exit.ExitCode != nil && *exit.ExitCode != 0:
+		return nil, fmt.Errorf("command exited with status %d: %s", *exit.ExitCode, output)
+	}
+	return output, nil
+}
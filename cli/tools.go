@@ -1,86 +1,154 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"os/exec"
-	"strings"
 
 	"github.com/AstraBert/gopheract"
 )
 
-type ReadParams struct {
-	FilePath string `json:"file_path" description:"Path to the file to read"`
+type BashParams struct {
+	Command   string   `json:"command" description:"Main bash command to execute"`
+	Arguments []string `json:"arguments" description:"Arguments for the bash command"`
 }
 
-type WriteParams struct {
-	FilePath string `json:"file_path" description:"Path to the file to write"`
-	Content  string `json:"content" description:"Content to write to the file"`
+func execBash(params BashParams) (any, error) {
+	return execBashIn(context.Background(), "", params)
 }
 
-type EditParams struct {
-	FilePath  string `json:"file_path" description:"Path to the file to edit"`
-	OldString string `json:"old_string" description:"String to be replaced"`
-	NewString string `json:"new_string" description:"String to replace with"`
-	Count     int    `json:"count" description:"Number of replacements to make"`
+// Run a bash command locally in the given directory (the current directory if empty), killing it when the context is cancelled
+func execBashIn(ctx context.Context, dir string, params BashParams) (any, error) {
+	cmd := exec.CommandContext(ctx, params.Command, params.Arguments...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	return string(output), nil
 }
 
-type BashParams struct {
-	Command   string   `json:"command" description:"Main bash command to execute"`
-	Arguments []string `json:"arguments" description:"Arguments for the bash command"`
+// The tools available to the CLI agent, along with the handles needed to wire them to the user interface
+type Toolbox struct {
+	FS      *gopheract.FileSystem
+	AskUser *gopheract.AskUserTool
+	Plan    *gopheract.UpdatePlanTool
+	// tool spawning sub-agents, copies of the agent whose turn calls it
+	Task *gopheract.TaskTool
+	// function running the commands of the Bash tool, unless they run in a sandbox (defaults to running them locally)
+	RunBash func(params BashParams) (any, error)
+	Tools   []gopheract.Tool
+	// sandbox running the commands of the Bash tool, ignoring RunBash (nil to run them locally)
+	sandbox *gopheract.ContainerSandbox
 }
 
-func readFile(params ReadParams) (any, error) {
-	fmt.Println(params)
-	content, err := os.ReadFile(params.FilePath)
-	if err == nil {
-		return string(content), nil
-	}
-	return nil, err
+// Handles wiring the interactive tools to the user of a turn, for hosts running the turns of several sessions at once (nil handles keep the ones of the toolbox)
+type turnHandles struct {
+	Ask     func(question string, options []string) (string, error)
+	OnPlan  func(steps []gopheract.PlanStep)
+	Approve func(change gopheract.FileChange) (bool, error)
+	RunBash func(params BashParams) (any, error)
+	Backend gopheract.FileBackend
+	// working directory of the session (absolute, with symlinks resolved), confining the file tools and running the commands instead of the one of the toolbox
+	Root string
+	// agent of the session, which the sub-agents of the Task tool are copied from
+	Agent *gopheract.OpenAIReActAgent
 }
 
-func writeFile(params WriteParams) (any, error) {
-	return nil, os.WriteFile(params.FilePath, []byte(params.Content), 0777)
+// Constructor function for a new Bash tool, running its commands with the given function
+func newBashTool(run func(params BashParams) (any, error)) gopheract.Tool {
+	return gopheract.ToolDefinition[BashParams]{
+		Name:        "Bash",
+		Description: "Execute a bash command by providing the main command (`command` parameter - string) and the arguments for it (`arguments` parameter - list of strings)",
+		Fn:          run,
+		Cost:        gopheract.ToolCostLow,
+		Latency:     gopheract.ToolLatencyFast,
+	}
 }
 
-func editFile(params EditParams) (any, error) {
-	content, err := os.ReadFile(params.FilePath)
-	if err != nil {
-		return nil, err
+// Method returning a copy of the given tools (picked from the toolbox) where the interactive tools are bound to the handles of a turn, so that the questions, plans, approvals and commands of concurrent turns reach their own users (and run in the working directory of their session)
+func (t *Toolbox) ForTurn(tools []gopheract.Tool, handles turnHandles) []gopheract.Tool {
+	fs := *t.FS
+	if handles.Approve != nil {
+		fs.Approve = handles.Approve
 	}
-	newContent := strings.Replace(string(content), params.OldString, params.NewString, params.Count)
-	return nil, os.WriteFile(params.FilePath, []byte(newContent), 0777)
+	if handles.Backend != nil {
+		fs.Backend = handles.Backend
+	}
+	if handles.Root != "" {
+		fs.Root = handles.Root
+	}
+	askUser := *t.AskUser
+	if handles.Ask != nil {
+		askUser.Ask = handles.Ask
+	}
+	plan := *t.Plan
+	if handles.OnPlan != nil {
+		plan.OnUpdate = handles.OnPlan
+	}
+	bound := map[string]gopheract.Tool{}
+	for _, tool := range append(fs.Tools(), askUser.AsTool(), plan.AsTool()) {
+		bound[tool.GetMetadata().Name] = tool
+	}
+	if handles.Agent != nil {
+		task := *t.Task
+		task.Agent = handles.Agent
+		bound[gopheract.TaskToolName] = task.AsTool()
+	}
+	switch {
+	case t.sandbox != nil && handles.Root != "":
+		sandbox := *t.sandbox
+		sandbox.Workspace = handles.Root
+		bound["Bash"] = sandbox.AsTool()
+	case t.sandbox != nil:
+		// the commands of the sandbox are not bound to the turns
+	case handles.RunBash != nil:
+		bound["Bash"] = newBashTool(handles.RunBash)
+	case handles.Root != "":
+		bound["Bash"] = newBashTool(func(params BashParams) (any, error) {
+			return execBashIn(context.Background(), handles.Root, params)
+		})
+	}
+	result := make([]gopheract.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if replacement, ok := bound[tool.GetMetadata().Name]; ok {
+			tool = replacement
+		}
+		result = append(result, tool)
+	}
+	return result
 }
 
-func execBash(params BashParams) (any, error) {
-	cmd := exec.Command(params.Command, params.Arguments...)
-	output, err := cmd.CombinedOutput()
+func GetTools() (*Toolbox, error) {
+	wd, err := os.Getwd()
 	if err != nil {
 		return nil, err
 	}
-	return string(output), nil
-}
-
-func GetTools() []gopheract.Tool {
-	readTool := gopheract.ToolDefinition[ReadParams]{
-		Name:        "Read",
-		Description: "Read a file, providing its path as `file_path` (string)",
-		Fn:          readFile,
-	}
-	writeTool := gopheract.ToolDefinition[WriteParams]{
-		Name:        "Write",
-		Description: "Write a file (providing its path as `file_path` - string) by passing a `content` (string) to write.",
-		Fn:          writeFile,
+	fs, err := gopheract.NewFileSystem(wd)
+	if err != nil {
+		return nil, err
 	}
-	editTool := gopheract.ToolDefinition[EditParams]{
-		Name:        "Edit",
-		Description: "Edit a file (providing its path as `file_path` - string), by passing the old and new string (`old_string` and `new_string` parameters) and how many times to replace it (the `count` parameter, an integer)",
-		Fn:          editFile,
+	fs.ReadOnly = os.Getenv("GOPHERACT_READ_ONLY") != ""
+	toolbox := &Toolbox{
+		FS:      fs,
+		AskUser: &gopheract.AskUserTool{},
+		Plan:    &gopheract.UpdatePlanTool{},
+		// the agent is set once it is created
+		Task:    gopheract.NewTaskTool(nil),
+		RunBash: execBash,
 	}
-	bashTool := gopheract.ToolDefinition[BashParams]{
-		Name:        "Bash",
-		Description: "Execute a bash command by providing the main command (`command` parameter - string) and the arguments for it (`arguments` parameter - list of strings)",
-		Fn:          execBash,
+	bashTool := newBashTool(func(params BashParams) (any, error) {
+		return toolbox.RunBash(params)
+	})
+	// run bash commands in an ephemeral container when a sandbox image is configured
+	if image := os.Getenv("GOPHERACT_SANDBOX_IMAGE"); image != "" {
+		sandbox := gopheract.NewContainerSandbox(image, wd)
+		if runtime := os.Getenv("GOPHERACT_SANDBOX_RUNTIME"); runtime != "" {
+			sandbox.Runtime = runtime
+		}
+		bashTool = sandbox.AsTool()
+		toolbox.sandbox = sandbox
 	}
-	return []gopheract.Tool{readTool, writeTool, editTool, bashTool}
+	toolbox.Tools = append(fs.Tools(), bashTool, toolbox.AskUser.AsTool(), toolbox.Plan.AsTool(), toolbox.Task.AsTool())
+	return toolbox, nil
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/spf13/cobra"
+)
+
+// Command inspecting the runs recorded with GOPHERACT_TRACES_DIR without contacting OpenAI:
+//   - `trace show <file>` prints every step of the run, with the messages sent to the model, the completions, the tool calls and their timing
+//   - `trace replay <file>` renders the run as in print mode, and `trace replay --acp <file>` serves it over ACP (every prompt replays the run)
+func newTraceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trace",
+		Short: "Inspect and replay recorded runs",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <file>",
+		Short: "Print every step of a recorded run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			trace, err := gopheract.LoadTrace(args[0])
+			if err != nil {
+				return err
+			}
+			showTrace(trace)
+			return nil
+		},
+	})
+	var acpMode bool
+	replay := &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Replay a recorded run, printing it to the console or serving it over ACP",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			trace, err := gopheract.LoadTrace(args[0])
+			if err != nil {
+				return err
+			}
+			if acpMode {
+				ag := NewCliAgent(gopheract.OpenAIReActAgent{})
+				ag.replay = trace
+				serveACP(ag, nil)
+				return nil
+			}
+			out, err := newPrinter(verbosityNormal, "auto")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Prompt: %s\n", trace.Prompt)
+			return gopheract.ReplayTrace(trace, out.event)
+		},
+	}
+	replay.Flags().BoolVar(&acpMode, "acp", false, "serve the run over ACP instead of printing it")
+	cmd.AddCommand(replay)
+	return cmd
+}
+
+// Print a recorded run step by step
+func showTrace(trace *gopheract.Trace) {
+	fmt.Printf("Run %s (session %s, model %s)\n", trace.ID, trace.SessionID, trace.Model)
+	fmt.Printf("Started at %s", trace.StartedAt.Format(time.RFC3339))
+	if !trace.FinishedAt.IsZero() {
+		fmt.Printf(", took %s", trace.FinishedAt.Sub(trace.StartedAt).Round(time.Millisecond))
+	}
+	fmt.Printf("\nPrompt: %s\n", trace.Prompt)
+	step := 0
+	for _, entry := range trace.Entries {
+		if entry.Step != step {
+			step = entry.Step
+			fmt.Printf("\n## Step %d\n", step)
+		}
+		duration := time.Duration(entry.Duration).Round(time.Millisecond)
+		switch entry.Kind {
+		case gopheract.TraceEntryCompletion:
+			var messages []json.RawMessage
+			_ = json.Unmarshal(entry.Messages, &messages)
+			fmt.Printf("\n[%s] %d messages sent, took %s\n%s\n", entry.Phase, len(messages), duration, indentJSON(entry.Completion))
+		case gopheract.TraceEntryToolCall:
+			fmt.Printf("\n[tool %s] %s", entry.CallID, entry.Tool)
+			if entry.Reused {
+				fmt.Print(" (reused from an identical call)")
+			} else {
+				fmt.Printf(", took %s", duration)
+			}
+			fmt.Printf("\nArgs: %v\n", entry.Args)
+			if entry.Error != "" {
+				fmt.Printf("Error: %s\n", entry.Error)
+			} else {
+				fmt.Printf("Result: %v\n", entry.Result)
+			}
+		}
+	}
+	if trace.Answer != "" {
+		fmt.Printf("\nAnswer: %s\n", trace.Answer)
+	}
+	if trace.Error != "" {
+		fmt.Printf("\nError: %s\n", trace.Error)
+	}
+}
+
+// Indent a JSON document for display, returning it unchanged if it is not valid JSON
+func indentJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
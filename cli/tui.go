@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/spf13/cobra"
+)
+
+// Width of the panel listing the plan and the tool calls of the session
+const tuiPanelWidth = 44
+
+var (
+	tuiHeaderStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	tuiPromptStyle  = lipgloss.NewStyle().Bold(true)
+	tuiThoughtStyle = lipgloss.NewStyle().Faint(true).Italic(true)
+	tuiAnswerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	tuiErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	tuiFaintStyle   = lipgloss.NewStyle().Faint(true)
+	tuiPanelStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8")).Padding(0, 1)
+)
+
+// Command running the agent in a full-screen terminal user interface
+func newTUICommand(opts *cliOptions) *cobra.Command {
+	var sessionID string
+	cmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Chat with the agent in a full-screen terminal interface",
+		Long: `Chat with the agent in a full-screen terminal interface, showing the conversation, a live panel with the plan and the tool calls of the agent, and the tokens consumed with their estimated cost.
+
+Press Ctrl-C to cancel the turn in progress (or to exit when no turn is in progress), and PgUp/PgDown or the mouse wheel to scroll the conversation. Lines starting with a slash are commands of the interface, like /mode plan or /cost (type /help to list them). Logs are written to the tui.log file in the gopheract folder of the user config directory.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, toolbox, err := newAgent(cmd, opts)
+			if err != nil {
+				return err
+			}
+			store, err := sessionStore()
+			if err != nil {
+				return err
+			}
+			agent.Memory = store
+			if sessionID == "" {
+				sessionID = RandomID()
+			}
+			agent.SessionID = sessionID
+			// the logs would garble the screen, so they are written to a file
+			logFile, err := openTUILog()
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+			logger, err := newLoggerTo(logFile, opts.verbose)
+			if err != nil {
+				return err
+			}
+			agent.Logger = logger
+			agent.Llm.Logger = logger
+			slog.SetDefault(logger)
+			return runTUI(agent, toolbox, opts.config.Tools)
+		},
+	}
+	cmd.Flags().StringVarP(&sessionID, "session", "s", "", "identifier of the session to resume (defaults to a new session)")
+	cobra.CheckErr(cmd.RegisterFlagCompletionFunc("session", completeSessionIDs))
+	return cmd
+}
+
+// Open the log file of the terminal interface, in the gopheract folder of the user config directory
+func openTUILog() (*os.File, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(configDir, "gopheract")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(dir, "tui.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// A tool call shown in the panel of the terminal interface
+type tuiToolCall struct {
+	id       string
+	tool     string
+	args     string
+	status   string
+	duration time.Duration
+}
+
+// Messages sent by the agent to the terminal interface
+type (
+	tuiEventMsg  struct{ event gopheract.AgentEvent }
+	tuiPlanMsg   struct{ steps []gopheract.PlanStep }
+	tuiReportMsg struct{ report string }
+	tuiDoneMsg   struct{ err error }
+	// output of a slash command run in the background
+	tuiSlashMsg struct {
+		output string
+		err    error
+	}
+	// question to the user (or approval request), answered on the channel
+	tuiQuestionMsg struct {
+		question string
+		options  []string
+		answer   chan tuiAnswer
+	}
+)
+
+type tuiAnswer struct {
+	text string
+	err  error
+}
+
+// State of the terminal interface, following the Elm architecture of Bubble Tea
+type tuiModel struct {
+	agent   *gopheract.OpenAIReActAgent
+	session *interactiveSession
+	program **tea.Program
+	// rendered entries of the conversation
+	entries  []string
+	plan     []gopheract.PlanStep
+	calls    []tuiToolCall
+	question *tuiQuestionMsg
+	running  bool
+	cancel   context.CancelFunc
+	width    int
+	height   int
+	viewport viewport.Model
+	input    textinput.Model
+	spinner  spinner.Model
+}
+
+// Run the terminal interface until the user exits
+func runTUI(agent *gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy) error {
+	var program *tea.Program
+	ask := func(question string, options []string) (string, error) {
+		answer := make(chan tuiAnswer, 1)
+		program.Send(tuiQuestionMsg{question: question, options: options, answer: answer})
+		result := <-answer
+		return result.text, result.err
+	}
+	toolbox.AskUser.Ask = ask
+	toolbox.Plan.OnUpdate = func(steps []gopheract.PlanStep) { program.Send(tuiPlanMsg{steps: steps}) }
+	agent.ApproveToolCall = approveInTerminal(policies, ask)
+	agent.OnRunReport = func(report *gopheract.RunReport) { program.Send(tuiReportMsg{report: report.String()}) }
+	input := textinput.New()
+	input.Placeholder = "Ask the agent to do something, or type /help to list the commands..."
+	input.Prompt = "> "
+	input.Focus()
+	vp := viewport.New(0, 0)
+	// only the page keys scroll, the other keys go to the input
+	vp.KeyMap = viewport.KeyMap{
+		PageDown: key.NewBinding(key.WithKeys("pgdown")),
+		PageUp:   key.NewBinding(key.WithKeys("pgup")),
+	}
+	model := tuiModel{
+		agent:    agent,
+		session:  newInteractiveSession(agent),
+		program:  &program,
+		input:    input,
+		viewport: vp,
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+	program = tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	_, err := program.Run()
+	return err
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, m.spinner.Tick)
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.viewport.Width = max(m.width-tuiPanelWidth-1, 20)
+		// header, input and footer take a line each
+		m.viewport.Height = max(m.height-3, 1)
+		m.input.Width = m.width - 4
+		m.refresh()
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			if m.question != nil {
+				m.answer(tuiAnswer{err: errors.New("the user cancelled the question")})
+			}
+			if m.running {
+				// the slash commands running in the background cannot be cancelled
+				if m.cancel != nil {
+					m.cancel()
+				}
+				return m, nil
+			}
+			return m, tea.Quit
+		case tea.KeyCtrlD:
+			if !m.running {
+				return m, tea.Quit
+			}
+		case tea.KeyEnter:
+			text := strings.TrimSpace(m.input.Value())
+			m.input.Reset()
+			if m.question != nil {
+				m.addEntry(tuiPromptStyle.Render("? " + text))
+				m.answer(tuiAnswer{text: text})
+				return m, nil
+			}
+			if text == "" || m.running {
+				return m, nil
+			}
+			if strings.HasPrefix(text, "/") {
+				return m, m.runSlashCommand(text)
+			}
+			return m, m.startTurn(text)
+		}
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		cmds = append(cmds, cmd)
+	case tuiQuestionMsg:
+		m.question = &msg
+		text := "Question: " + msg.question
+		if len(msg.options) > 0 {
+			text += "\nOptions: " + strings.Join(msg.options, ", ")
+		}
+		m.addEntry(tuiAnswerStyle.Render(text))
+	case tuiPlanMsg:
+		m.plan = msg.steps
+	case tuiReportMsg:
+		m.addEntry(tuiFaintStyle.Render(msg.report))
+	case tuiEventMsg:
+		m.handleEvent(msg.event)
+	case tuiSlashMsg:
+		m.running = false
+		m.addSlashOutput(msg.output, msg.err)
+	case tuiDoneMsg:
+		m.running = false
+		m.cancel = nil
+		for i := range m.calls {
+			if m.calls[i].status == "pending" {
+				m.calls[i].status = "interrupted"
+			}
+		}
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			m.addEntry(tuiErrorStyle.Render("Turn cancelled"))
+		case msg.err != nil:
+			m.addEntry(tuiErrorStyle.Render("Error: " + msg.err.Error()))
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	cmds = append(cmds, cmd)
+	m.viewport, cmd = m.viewport.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+// Start a turn of the agent in the background, sending its events to the interface
+func (m *tuiModel) startTurn(prompt string) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.running = true
+	m.cancel = cancel
+	m.addEntry(tuiPromptStyle.Render("> " + prompt))
+	agent, program := m.agent, *m.program
+	prompt = m.session.prompt(prompt)
+	return func() tea.Msg {
+		defer cancel()
+		err := agent.RunEventsContext(ctx, prompt, nil, func(event gopheract.AgentEvent) {
+			program.Send(tuiEventMsg{event: event})
+		})
+		return tuiDoneMsg{err: err}
+	}
+}
+
+// Run a slash command, in the background if it sends requests to the LLM
+func (m *tuiModel) runSlashCommand(line string) tea.Cmd {
+	m.addEntry(tuiPromptStyle.Render("> " + line))
+	command, args, err := parseSlashCommand(line)
+	if err != nil {
+		m.addSlashOutput("", err)
+		return nil
+	}
+	if !command.slow {
+		output, err := command.run(m.session, args)
+		m.addSlashOutput(output, err)
+		return nil
+	}
+	m.running = true
+	session := m.session
+	return func() tea.Msg {
+		output, err := command.run(session, args)
+		return tuiSlashMsg{output: output, err: err}
+	}
+}
+
+// Add the output of a slash command to the conversation
+func (m *tuiModel) addSlashOutput(output string, err error) {
+	if err != nil {
+		m.addEntry(tuiErrorStyle.Render("Error: " + err.Error()))
+		return
+	}
+	m.addEntry(tuiFaintStyle.Render(output))
+}
+
+// Send the answer of the user to the pending question
+func (m *tuiModel) answer(answer tuiAnswer) {
+	m.question.answer <- answer
+	m.question = nil
+}
+
+// Update the conversation, the tool calls and the token usage with an event of the run
+func (m *tuiModel) handleEvent(event gopheract.AgentEvent) {
+	switch e := event.(type) {
+	case gopheract.ThoughtEvent:
+		m.addEntry(tuiThoughtStyle.Render(e.Thought))
+	case gopheract.ObservationEvent:
+		m.addEntry(e.Observation)
+	case gopheract.StopEvent:
+		m.addEntry(tuiAnswerStyle.Render(e.Reason))
+	case gopheract.ToolStartEvent:
+		args, _ := e.ToolCall.ArgsToMap()
+		m.calls = append(m.calls, tuiToolCall{id: e.CallID, tool: e.ToolCall.Name, args: fmt.Sprint(args), status: "pending"})
+	case gopheract.ToolEndEvent:
+		for i := len(m.calls) - 1; i >= 0; i-- {
+			if m.calls[i].id == e.CallID && m.calls[i].status == "pending" {
+				m.calls[i].status = "done"
+				if e.Reused {
+					m.calls[i].status = "reused"
+				}
+				m.calls[i].duration = e.Duration
+				break
+			}
+		}
+	case gopheract.UsageEvent:
+		m.session.record(e)
+	}
+}
+
+// Add an entry to the conversation, following it if it was scrolled to the bottom
+func (m *tuiModel) addEntry(entry string) {
+	m.entries = append(m.entries, entry)
+	m.refresh()
+}
+
+// Render the conversation in the viewport
+func (m *tuiModel) refresh() {
+	atBottom := m.viewport.AtBottom()
+	style := lipgloss.NewStyle().Width(m.viewport.Width)
+	rendered := make([]string, 0, len(m.entries))
+	for _, entry := range m.entries {
+		rendered = append(rendered, style.Render(entry))
+	}
+	m.viewport.SetContent(strings.Join(rendered, "\n\n"))
+	if atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m tuiModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	header := tuiHeaderStyle.Render(fmt.Sprintf("gopheract · %s · session %s", m.agent.Llm.Model, m.agent.SessionID))
+	body := lipgloss.JoinHorizontal(lipgloss.Top, m.viewport.View(), " ", m.panelView())
+	status := "idle"
+	if m.running {
+		status = m.spinner.View() + " working (Ctrl-C to cancel)"
+	}
+	if m.question != nil {
+		status = "waiting for your answer"
+	}
+	usage := m.session.usage
+	cost, _ := m.session.cost()
+	footer := tuiFaintStyle.Render(fmt.Sprintf("%d tokens (%d prompt, %d completion) · estimated cost $%.4f · %s", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, cost, status))
+	return lipgloss.JoinVertical(lipgloss.Left, header, body, m.input.View(), footer)
+}
+
+// Render the panel with the plan and the most recent tool calls
+func (m tuiModel) panelView() string {
+	width := tuiPanelWidth - 4
+	var lines []string
+	if len(m.plan) > 0 {
+		lines = append(lines, tuiHeaderStyle.Render("Plan"))
+		for _, step := range m.plan {
+			mark := "[ ]"
+			switch step.Status {
+			case gopheract.PlanStepInProgress:
+				mark = "[~]"
+			case gopheract.PlanStepCompleted:
+				mark = "[x]"
+			}
+			lines = append(lines, ansi.Truncate(mark+" "+step.Content, width, "…"))
+		}
+		lines = append(lines, "")
+	}
+	lines = append(lines, tuiHeaderStyle.Render("Tool calls"))
+	// keep the most recent calls that fit in the panel
+	height := max(m.viewport.Height-2, 1)
+	calls := m.calls
+	if room := height - len(lines); len(calls) > room {
+		calls = calls[max(len(calls)-room, 0):]
+	}
+	for _, call := range calls {
+		var line string
+		switch call.status {
+		case "pending":
+			line = m.spinner.View() + " " + call.tool
+		case "done":
+			line = fmt.Sprintf("✓ %s (%s)", call.tool, call.duration.Round(time.Millisecond))
+		case "reused":
+			line = fmt.Sprintf("✓ %s (reused)", call.tool)
+		default:
+			line = tuiErrorStyle.Render("✗ " + call.tool + " (" + call.status + ")")
+		}
+		lines = append(lines, ansi.Truncate(line+" "+tuiFaintStyle.Render(call.args), width, "…"))
+	}
+	return tuiPanelStyle.Width(tuiPanelWidth - 2).Height(height).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AstraBert/gopheract"
+)
+
+// Client of a turn, getting the questions of the agent, its plan and the approval requests of its tool calls (non-interactive turns have none)
+type turnClient interface {
+	// Ask a question to the user, returning the answer
+	ask(ctx context.Context, question string, options []string) (string, error)
+	// Send the plan of the agent to the user
+	publishPlan(steps []gopheract.PlanStep)
+	// Ask the user to approve a tool call, along with the change it makes to a file (nil for the other tool calls)
+	approve(ctx context.Context, call gopheract.ToolStartEvent, change *gopheract.FileChange) (bool, error)
+	// Notify the user that the turn waits for a free slot of the pool
+	queued()
+}
+
+// Runner of the turns of the sessions of an agent, shared by the servers (WebSocket, chat completions, daemon) and the batches.
+//
+// The turns of different sessions run concurrently, up to a maximum number of turns at once: the tools of every turn are bound to its own client, which gets its questions and approvals.
+type turnRunner struct {
+	agents   *gopheract.SessionManager
+	toolbox  *Toolbox
+	policies map[string]toolPolicy
+	// pool running the turns, bounding the turns running at once and sharing the rate limit and the token budget between them
+	pool *gopheract.AgentPool
+	// cancelled when the runner is closed, cancelling the turns in progress
+	ctx   context.Context
+	stop  context.CancelFunc
+	turns sync.WaitGroup
+	mu    sync.Mutex
+}
+
+// The client (nil for non-interactive turns) and context of a turn in progress
+type runnerTurn struct {
+	ctx    context.Context
+	client turnClient
+	// ids and tools of the tool calls started but not yet completed
+	pendingCalls []gopheract.ToolStartEvent
+	// resolved paths of the files of the pending calls, by call id
+	paths map[string]string
+	// ids of the pending calls whose change was already submitted for approval
+	changed map[string]bool
+}
+
+// Constructor function for a new turnRunner, running its turns in the given pool
+func newTurnRunner(agent *gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy, pool *gopheract.AgentPool) *turnRunner {
+	r := &turnRunner{agents: gopheract.NewSessionManager(agent), toolbox: toolbox, policies: policies, pool: pool}
+	r.ctx, r.stop = context.WithCancel(context.Background())
+	return r
+}
+
+// Private method deriving a context from the one of a request, which is cancelled as well when the runner is closed (the contexts of hijacked connections are not cancelled on shutdown)
+func (r *turnRunner) requestContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(r.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Private method closing the sessions idle for the given duration, until the runner is closed (their history stays in the memory store, so they are picked up again when their clients come back)
+func (r *turnRunner) evictIdle(idle time.Duration) {
+	ticker := time.NewTicker(min(idle, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if closed := r.agents.CloseIdle(idle); len(closed) > 0 {
+				slog.Info("closed idle sessions", "sessions", closed)
+			}
+		}
+	}
+}
+
+// Private method cancelling the turns in progress and waiting for them to end, so that their history is saved, until the context is done
+func (r *turnRunner) close(ctx context.Context) error {
+	r.stop()
+	done := make(chan struct{})
+	go func() {
+		r.turns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Private method running a turn of an agent once a slot of the pool is free, binding its tools to a client (nil for the turns of non-interactive clients, whose tool calls needing approval are rejected)
+func (r *turnRunner) runTurn(ctx context.Context, client turnClient, agent *gopheract.OpenAIReActAgent, prompt string, images []gopheract.ImageContent, handler func(gopheract.AgentEvent)) error {
+	r.turns.Add(1)
+	defer r.turns.Done()
+	turn := &runnerTurn{ctx: ctx, client: client, paths: map[string]string{}, changed: map[string]bool{}}
+	agent.Tools = r.toolbox.ForTurn(r.agents.Base.Tools, turnHandles{
+		Ask: func(question string, options []string) (string, error) {
+			return r.ask(turn, question, options)
+		},
+		OnPlan: func(steps []gopheract.PlanStep) {
+			if turn.client != nil {
+				turn.client.publishPlan(steps)
+			}
+		},
+		Approve: agent.Notifier.NotifyApprovals(func(change gopheract.FileChange) (bool, error) {
+			return r.approveChange(turn, change)
+		}),
+		Agent: agent,
+	})
+	agent.ApproveToolCall = func(callID string, toolCall gopheract.ToolCall) (bool, error) {
+		return r.approveToolCall(turn, callID, toolCall)
+	}
+	return r.pool.Run(ctx, agent, prompt, images, func(event gopheract.AgentEvent) {
+		if _, ok := event.(gopheract.QueuedEvent); ok {
+			if client != nil {
+				client.queued()
+			}
+			return
+		}
+		switch e := event.(type) {
+		case gopheract.ToolStartEvent:
+			path := r.callPath(e.ToolCall)
+			r.mu.Lock()
+			turn.pendingCalls = append(turn.pendingCalls, e)
+			if path != "" {
+				turn.paths[e.CallID] = path
+			}
+			r.mu.Unlock()
+		case gopheract.ToolEndEvent:
+			r.mu.Lock()
+			turn.pendingCalls = slices.DeleteFunc(turn.pendingCalls, func(call gopheract.ToolStartEvent) bool { return call.CallID == e.CallID })
+			delete(turn.paths, e.CallID)
+			delete(turn.changed, e.CallID)
+			r.mu.Unlock()
+		}
+		handler(event)
+	})
+}
+
+// Private method resolving the path of the file a tool call works on, as the file tools do (empty for the calls without one)
+func (r *turnRunner) callPath(toolCall gopheract.ToolCall) string {
+	args, err := toolCall.ArgsToMap()
+	if err != nil {
+		return ""
+	}
+	path, ok := args["file_path"].(string)
+	if !ok {
+		return ""
+	}
+	resolved, err := r.toolbox.FS.Resolve(path)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
+
+// Private method returning the pending Write or Edit call of a turn making a change to a file: the first one on the path of the change whose change was not submitted yet, falling back to the first such call on any path (the tool calls of a batch are all pending while they run)
+func (t *runnerTurn) changeCall(change gopheract.FileChange) gopheract.ToolStartEvent {
+	var candidate *gopheract.ToolStartEvent
+	for i, call := range t.pendingCalls {
+		if t.changed[call.CallID] || !slices.Contains([]string{"Write", "Edit"}, call.ToolCall.Name) {
+			continue
+		}
+		if t.paths[call.CallID] == change.Path {
+			return call
+		}
+		if candidate == nil {
+			candidate = &t.pendingCalls[i]
+		}
+	}
+	if candidate == nil {
+		return gopheract.ToolStartEvent{}
+	}
+	return *candidate
+}
+
+// Ask a question to the user of a turn
+func (r *turnRunner) ask(turn *runnerTurn, question string, options []string) (string, error) {
+	if turn.client == nil {
+		return "", errors.New("the client cannot answer questions, proceed with your best judgement")
+	}
+	return turn.client.ask(turn.ctx, question, options)
+}
+
+// Ask the user of a turn to approve a tool call before it is executed: bash commands and the tools whose policy is ask, unless their policy is allow (file changes are approved through approveChange, with their diff)
+func (r *turnRunner) approveToolCall(turn *runnerTurn, callID string, toolCall gopheract.ToolCall) (bool, error) {
+	policy := r.policies[toolCall.Name]
+	if policy == policyAllow || slices.Contains([]string{"Write", "Edit"}, toolCall.Name) || (toolCall.Name != "Bash" && policy != policyAsk) {
+		return true, nil
+	}
+	if turn.client == nil {
+		return false, nil
+	}
+	return turn.client.approve(turn.ctx, gopheract.ToolStartEvent{CallID: callID, ToolCall: toolCall}, nil)
+}
+
+// Ask the user of a turn to approve a change to a file, sending its diff (changes made by tools whose policy is allow are approved without asking)
+func (r *turnRunner) approveChange(turn *runnerTurn, change gopheract.FileChange) (bool, error) {
+	r.mu.Lock()
+	call := turn.changeCall(change)
+	if call.CallID != "" {
+		turn.changed[call.CallID] = true
+	}
+	r.mu.Unlock()
+	if r.policies[call.ToolCall.Name] == policyAllow {
+		return true, nil
+	}
+	if turn.client == nil {
+		return false, nil
+	}
+	return turn.client.approve(turn.ctx, call, &change)
+}
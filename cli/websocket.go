@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/AstraBert/gopheract"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// Message sent by the clients of the WebSocket endpoint:
+//   - `prompt` starts a turn with the given text
+//   - `answer` answers the question with the given id
+//   - `approval` approves (or rejects) the tool call with the given id
+//   - `cancel` cancels the turn in progress
+type wsClientMessage struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Approved bool   `json:"approved,omitempty"`
+}
+
+// Message sent to the clients of the WebSocket endpoint: `session` when connecting, `event` for the events of the runs (see gopheract.EventPayload), `plan`, `question`, `approval_request`, `queued` when the turn waits for a free slot, `done` at the end of every turn, and `error` for the messages that could not be processed
+type wsServerMessage struct {
+	Type      string               `json:"type"`
+	SessionID string               `json:"session_id,omitempty"`
+	ID        string               `json:"id,omitempty"`
+	Event     map[string]any       `json:"event,omitempty"`
+	Steps     []gopheract.PlanStep `json:"steps,omitempty"`
+	Question  string               `json:"question,omitempty"`
+	Options   []string             `json:"options,omitempty"`
+	Tool      string               `json:"tool,omitempty"`
+	Args      map[string]any       `json:"args,omitempty"`
+	Path      string               `json:"path,omitempty"`
+	Diff      string               `json:"diff,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// Server of the WebSocket endpoint, where every connection works on its own session.
+//
+// The turns of different sessions run concurrently, up to a maximum number of turns at once: the tools of every turn are bound to its own connection, which gets its questions and approvals.
+type wsServer struct {
+	*turnRunner
+	// host patterns of the origins allowed besides the host of the server
+	originPatterns []string
+}
+
+// A connection to the WebSocket endpoint
+type wsConn struct {
+	conn *websocket.Conn
+	// context of the connection, cancelled when it is closed
+	ctx context.Context
+	sid string
+	mu  sync.Mutex
+	// cancels the turn of the connection in progress, if any
+	cancel context.CancelFunc
+	// channels waiting for the answers and approvals of the user, by id
+	pending map[string]chan wsClientMessage
+	// number of questions asked to the user, used to assign them identifiers
+	count int
+}
+
+// Constructor function for a new wsServer, running its turns in the given pool
+func newWSServer(agent *gopheract.OpenAIReActAgent, toolbox *Toolbox, policies map[string]toolPolicy, pool *gopheract.AgentPool) *wsServer {
+	return &wsServer{turnRunner: newTurnRunner(agent, toolbox, policies, pool)}
+}
+
+func (s *wsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: s.originPatterns})
+	if err != nil {
+		slog.Warn("failed to accept the WebSocket connection", "error", err)
+		return
+	}
+	defer conn.CloseNow()
+	sid := r.URL.Query().Get("session")
+	if sid == "" {
+		sid = RandomID()
+	}
+	ctx, cancel := s.requestContext(r.Context())
+	defer cancel()
+	c := &wsConn{conn: conn, ctx: ctx, sid: sid, pending: map[string]chan wsClientMessage{}}
+	if err := c.send(wsServerMessage{Type: "session", SessionID: sid}); err != nil {
+		return
+	}
+	for {
+		var msg wsClientMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			if websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+				slog.Warn("failed to read from the WebSocket", "session", sid, "error", err)
+			}
+			c.mu.Lock()
+			if c.cancel != nil {
+				c.cancel()
+			}
+			c.mu.Unlock()
+			return
+		}
+		switch msg.Type {
+		case "prompt":
+			c.mu.Lock()
+			busy := c.cancel != nil
+			var turnCtx context.Context
+			if !busy {
+				turnCtx, c.cancel = context.WithCancel(ctx)
+			}
+			c.mu.Unlock()
+			if busy {
+				_ = c.send(wsServerMessage{Type: "error", Error: "a turn is already in progress"})
+				continue
+			}
+			go s.takeTurn(turnCtx, c, msg.Text)
+		case "answer", "approval":
+			c.mu.Lock()
+			answer, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if !ok {
+				_ = c.send(wsServerMessage{Type: "error", ID: msg.ID, Error: "no pending question with this id"})
+				continue
+			}
+			answer <- msg
+		case "cancel":
+			c.mu.Lock()
+			if c.cancel != nil {
+				c.cancel()
+			}
+			c.mu.Unlock()
+		default:
+			_ = c.send(wsServerMessage{Type: "error", Error: fmt.Sprintf("unknown message type %s", msg.Type)})
+		}
+	}
+}
+
+// Send a message to the client of the connection
+func (c *wsConn) send(msg wsServerMessage) error {
+	// writes are not safe for concurrent use, and are bound to the context of the connection since a cancelled write closes it
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return wsjson.Write(c.ctx, c.conn, msg)
+}
+
+// Send a request to the client of the connection, and wait for the matching answer or approval
+func (c *wsConn) request(ctx context.Context, msg wsServerMessage) (wsClientMessage, error) {
+	answer := make(chan wsClientMessage, 1)
+	c.mu.Lock()
+	c.pending[msg.ID] = answer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+	}()
+	if err := c.send(msg); err != nil {
+		return wsClientMessage{}, err
+	}
+	select {
+	case reply := <-answer:
+		return reply, nil
+	case <-ctx.Done():
+		return wsClientMessage{}, ctx.Err()
+	}
+}
+
+// Run a turn of the session of a connection, streaming its events to the client
+func (s *wsServer) takeTurn(ctx context.Context, c *wsConn, prompt string) {
+	defer func() {
+		c.mu.Lock()
+		c.cancel()
+		c.cancel = nil
+		c.mu.Unlock()
+	}()
+	done := wsServerMessage{Type: "done"}
+	session := s.agents.Open(c.sid)
+	// another connection may be working on the same session
+	err := session.Begin()
+	if err == nil {
+		err = s.runTurn(ctx, c, session.Agent, prompt, nil, func(event gopheract.AgentEvent) {
+			if err := c.send(wsServerMessage{Type: "event", Event: gopheract.EventPayload(event)}); err != nil {
+				slog.Warn("failed to send the event", "session", c.sid, "error", err)
+			}
+		})
+		session.End()
+	}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	if err := c.send(done); err != nil {
+		slog.Warn("failed to send the end of the turn", "session", c.sid, "error", err)
+	}
+}
+
+// Ask a question to the user of the connection
+func (c *wsConn) ask(ctx context.Context, question string, options []string) (string, error) {
+	c.mu.Lock()
+	c.count += 1
+	id := fmt.Sprintf("question_%d", c.count)
+	c.mu.Unlock()
+	reply, err := c.request(ctx, wsServerMessage{Type: "question", ID: id, Question: question, Options: options})
+	if err != nil {
+		return "", err
+	}
+	return reply.Text, nil
+}
+
+// Send the plan of the agent to the user of the connection
+func (c *wsConn) publishPlan(steps []gopheract.PlanStep) {
+	if err := c.send(wsServerMessage{Type: "plan", Steps: steps}); err != nil {
+		slog.Warn("failed to send the plan", "session", c.sid, "error", err)
+	}
+}
+
+// Ask the user of the connection to approve a tool call, sending the diff of the change it makes to a file if any
+func (c *wsConn) approve(ctx context.Context, call gopheract.ToolStartEvent, change *gopheract.FileChange) (bool, error) {
+	args, err := call.ToolCall.ArgsToMap()
+	if err != nil && change == nil {
+		return false, err
+	}
+	msg := wsServerMessage{Type: "approval_request", ID: call.CallID, Tool: call.ToolCall.Name, Args: args}
+	if change != nil {
+		msg.Path = change.Path
+		msg.Diff = change.Diff
+	}
+	reply, err := c.request(ctx, msg)
+	if err != nil {
+		return false, err
+	}
+	return reply.Approved, nil
+}
+
+// Notify the user of the connection that the turn waits for a free slot
+func (c *wsConn) queued() {
+	_ = c.send(wsServerMessage{Type: "queued"})
+}
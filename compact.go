@@ -0,0 +1,130 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Struct type representing the summary of the older turns of a conversation, produced when compacting the chat history
+type HistorySummary struct {
+	Summary string `json:"summary" jsonschema_description:"Compact summary of the conversation so far, keeping the facts, decisions, results and open questions needed to continue the task"`
+}
+
+// Struct type representing the settings used to compact the chat history of an agent.
+type CompactionPolicy struct {
+	// Number of tokens above which the history is compacted automatically before each step of a run (zero disables automatic compaction)
+	Threshold int
+	// Number of most recent messages kept verbatim (defaults to 6)
+	KeepRecent int
+	// Counter used to count the tokens of the history (defaults to EstimateTokenCounter)
+	Counter TokenCounter
+}
+
+// Prefix of the message holding the summary of the compacted turns
+const compactedHistoryPrefix = "Memory so far (summary of the earlier turns of the conversation):\n"
+
+var toolResultPattern = regexp.MustCompile(`Tool call result from ([^\s:(]+)`)
+
+// Private function returning the names of the tools whose results are reported in a message
+func toolResultNames(message *ChatMessage) []string {
+	names := []string{}
+	for _, match := range toolResultPattern.FindAllStringSubmatch(message.Content, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Private function selecting, among the older messages, the tool results that are still referenced by the recent ones: the latest result of each tool whose name is mentioned in the recent messages.
+func referencedToolResults(older, recent []*ChatMessage) map[int]bool {
+	referenced := map[int]bool{}
+	seen := map[string]bool{}
+	for i := len(older) - 1; i >= 0; i-- {
+		for _, name := range toolResultNames(older[i]) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if slices.ContainsFunc(recent, func(m *ChatMessage) bool { return strings.Contains(m.Content, name) }) {
+				referenced[i] = true
+			}
+		}
+	}
+	return referenced
+}
+
+// Method that compacts the chat history of the current session: the older turns are summarized into a single "memory so far" message using the LLM, while the system prompt, the pinned messages, the most recent messages and the tool results they still reference are kept verbatim.
+func (o *OpenAIReActAgent) Compact() error {
+	history, err := o.History()
+	if err != nil {
+		return err
+	}
+	keep := 6
+	if o.Compaction != nil && o.Compaction.KeepRecent > 0 {
+		keep = o.Compaction.KeepRecent
+	}
+	leading := 0
+	for leading < len(history) && history[leading].Role == "system" {
+		leading++
+	}
+	rest := history[leading:]
+	if len(rest) <= keep {
+		return nil
+	}
+	older, recent := rest[:len(rest)-keep], rest[len(rest)-keep:]
+	referenced := referencedToolResults(older, recent)
+	preserved := []*ChatMessage{}
+	var transcript strings.Builder
+	defer o.beginRedactionScope(o.SessionID + "/compaction")()
+	redacted := o.redact(older)
+	for i, message := range older {
+		if referenced[i] || message.Pinned {
+			preserved = append(preserved, message)
+			continue
+		}
+		fmt.Fprintf(&transcript, "[%s]: %s\n\n", message.Role, redacted[i].Content)
+	}
+	summarizationHistory := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You summarize the earlier turns of a conversation between a user and an AI agent using tools, so that the agent can continue its task without them. Keep the facts, decisions, tool results and open questions that matter, and drop everything else."),
+		openai.UserMessage(transcript.String()),
+	}
+	response, err := OpenAILLMStructuredPredict[HistorySummary](o.Llm, summarizationHistory, "summary", "Summary of the earlier turns of the conversation")
+	if err != nil {
+		return err
+	}
+	summary, ok := response.(HistorySummary)
+	if !ok {
+		return errors.New("error while generating the summary: unexpected structured output")
+	}
+	compacted := append([]*ChatMessage{}, history[:leading]...)
+	// the summary is kept locally, so the values redacted from the transcript are put back in it
+	compacted = append(compacted, NewChatMessage("user", compactedHistoryPrefix+o.restore(summary.Summary)))
+	compacted = append(compacted, preserved...)
+	compacted = append(compacted, recent...)
+	// the history is replaced at once, so that a failure cannot lose it
+	if err := o.Memory.Replace(o.SessionID, compacted...); err != nil {
+		return err
+	}
+	o.logger().Info("history compacted", "messages_before", len(history), "messages_after", len(compacted))
+	return nil
+}
+
+// Helper method that compacts the chat history if automatic compaction is enabled and the history exceeds its threshold
+func (o *OpenAIReActAgent) autoCompact() error {
+	if o.Compaction == nil || o.Compaction.Threshold <= 0 {
+		return nil
+	}
+	history, err := o.History()
+	if err != nil {
+		return err
+	}
+	policy := ContextWindowPolicy{Counter: o.Compaction.Counter}
+	if policy.CountTokens(history) <= o.Compaction.Threshold {
+		return nil
+	}
+	return o.Compact()
+}
@@ -0,0 +1,145 @@
+package gopheract
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Struct type representing the token limits and pricing of a model
+type ModelInfo struct {
+	// Size of the context window, in tokens (prompt and completion)
+	ContextWindow int
+	// Maximum number of tokens the model can generate in a response
+	MaxOutputTokens int
+	// Price of the prompt tokens, in USD per million tokens (zero if unknown)
+	InputPrice float64
+	// Price of the completion tokens, in USD per million tokens (zero if unknown)
+	OutputPrice float64
+	// Whether the model accepts images in the user messages
+	Vision bool
+}
+
+// Method returning the estimated cost of a token usage with the model, in USD
+func (i ModelInfo) Cost(usage TokenUsage) float64 {
+	return (float64(usage.PromptTokens)*i.InputPrice + float64(usage.CompletionTokens)*i.OutputPrice) / 1_000_000
+}
+
+// Base interface for the providers of model information, allowing custom (e.g. self-hosted or fine-tuned) models to be described
+type ModelInfoProvider interface {
+	ModelInfo(model string) (ModelInfo, bool)
+}
+
+// Struct type representing a registry of model information, keyed by model name.
+//
+// Lookups match the longest registered prefix of the model name, so that dated snapshots (e.g. "gpt-4o-2024-08-06") resolve to their model family.
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models map[string]ModelInfo
+}
+
+// Constructor function for a new ModelRegistry, pre-populated with the known OpenAI models (with their list prices)
+func NewModelRegistry() *ModelRegistry {
+	return &ModelRegistry{models: map[string]ModelInfo{
+		"gpt-5":         {ContextWindow: 400_000, MaxOutputTokens: 128_000, InputPrice: 1.25, OutputPrice: 10, Vision: true},
+		"gpt-5-mini":    {ContextWindow: 400_000, MaxOutputTokens: 128_000, InputPrice: 0.25, OutputPrice: 2, Vision: true},
+		"gpt-5-nano":    {ContextWindow: 400_000, MaxOutputTokens: 128_000, InputPrice: 0.05, OutputPrice: 0.4, Vision: true},
+		"gpt-4.1":       {ContextWindow: 1_047_576, MaxOutputTokens: 32_768, InputPrice: 2, OutputPrice: 8, Vision: true},
+		"gpt-4.1-mini":  {ContextWindow: 1_047_576, MaxOutputTokens: 32_768, InputPrice: 0.4, OutputPrice: 1.6, Vision: true},
+		"gpt-4.1-nano":  {ContextWindow: 1_047_576, MaxOutputTokens: 32_768, InputPrice: 0.1, OutputPrice: 0.4, Vision: true},
+		"gpt-4o":        {ContextWindow: 128_000, MaxOutputTokens: 16_384, InputPrice: 2.5, OutputPrice: 10, Vision: true},
+		"gpt-4o-mini":   {ContextWindow: 128_000, MaxOutputTokens: 16_384, InputPrice: 0.15, OutputPrice: 0.6, Vision: true},
+		"gpt-4-turbo":   {ContextWindow: 128_000, MaxOutputTokens: 4_096, InputPrice: 10, OutputPrice: 30, Vision: true},
+		"gpt-4":         {ContextWindow: 8_192, MaxOutputTokens: 8_192, InputPrice: 30, OutputPrice: 60},
+		"gpt-3.5-turbo": {ContextWindow: 16_385, MaxOutputTokens: 4_096, InputPrice: 0.5, OutputPrice: 1.5},
+		"o1":            {ContextWindow: 200_000, MaxOutputTokens: 100_000, InputPrice: 15, OutputPrice: 60, Vision: true},
+		"o1-mini":       {ContextWindow: 128_000, MaxOutputTokens: 65_536, InputPrice: 1.1, OutputPrice: 4.4},
+		"o3":            {ContextWindow: 200_000, MaxOutputTokens: 100_000, InputPrice: 2, OutputPrice: 8, Vision: true},
+		"o4-mini":       {ContextWindow: 200_000, MaxOutputTokens: 100_000, InputPrice: 1.1, OutputPrice: 4.4, Vision: true},
+	}}
+}
+
+// Default registry of model information, used by agents without a custom provider
+var DefaultModels = NewModelRegistry()
+
+// Method to add (or replace) the information of a model
+func (r *ModelRegistry) Register(model string, info ModelInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.models == nil {
+		r.models = map[string]ModelInfo{}
+	}
+	r.models[model] = info
+}
+
+// Method returning the names of the registered models, sorted
+func (r *ModelRegistry) Models() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.models))
+}
+
+// Method returning the information of a model, matching the longest registered prefix of its name
+func (r *ModelRegistry) ModelInfo(model string) (ModelInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if info, ok := r.models[model]; ok {
+		return info, true
+	}
+	var found ModelInfo
+	longest := 0
+	for name, info := range r.models {
+		if len(name) > longest && strings.HasPrefix(model, name+"-") {
+			found, longest = info, len(name)
+		}
+	}
+	return found, longest > 0
+}
+
+// Constructor function for a new ContextWindowPolicy fitting the history into the context window of a known model, reserving its maximum output
+func NewContextWindowPolicyForModel(provider ModelInfoProvider, model string) (*ContextWindowPolicy, error) {
+	info, ok := provider.ModelInfo(model)
+	if !ok {
+		return nil, fmt.Errorf("unknown context window for model %s", model)
+	}
+	return NewContextWindowPolicy(info.ContextWindow, info.MaxOutputTokens), nil
+}
+
+// Helper method returning the information of the model of the agent
+func (o *OpenAIReActAgent) modelInfo() (ModelInfo, bool) {
+	provider := o.Models
+	if provider == nil {
+		provider = DefaultModels
+	}
+	return provider.ModelInfo(o.Llm.Model)
+}
+
+// Method returning whether the model of the agent accepts images (models missing from the registry are assumed to accept them)
+func (o *OpenAIReActAgent) AcceptsImages() bool {
+	if o.Llm == nil {
+		return false
+	}
+	info, ok := o.modelInfo()
+	return !ok || info.Vision
+}
+
+// Method returning the number of tokens left in the context window of the model, given the current chat history and the tokens reserved for the response (it is negative when the history overflows the window).
+//
+// Tokens are counted with the counter of the context policy of the agent, if set.
+func (o *OpenAIReActAgent) RemainingContext() (int, error) {
+	info, ok := o.modelInfo()
+	if !ok {
+		return 0, fmt.Errorf("unknown context window for model %s", o.Llm.Model)
+	}
+	history, err := o.History()
+	if err != nil {
+		return 0, err
+	}
+	policy := o.ContextPolicy
+	if policy == nil {
+		policy = NewContextWindowPolicy(info.ContextWindow, info.MaxOutputTokens)
+	}
+	return info.ContextWindow - policy.ReservedTokens - policy.CountTokens(history), nil
+}
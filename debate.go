@@ -0,0 +1,152 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Struct type representing a message of a participant to a debate
+type DebateMessage struct {
+	// Round the message was given in (starting from 1)
+	Round       int
+	Participant string
+	Content     string
+}
+
+// Event emitted when a participant to a debate has spoken, with the step of the event set to the round
+type DebateTurnEvent struct {
+	EventInfo
+	Message DebateMessage
+}
+
+// Struct type representing the final answer synthesized from a debate
+type DebateConclusion struct {
+	Answer string `json:"answer" jsonschema_description:"Final answer to the request, keeping the arguments that survived the debate and settling the disagreements"`
+}
+
+// Struct type representing the outcome of a debate
+type DebateResult struct {
+	Answer string
+	// Messages of the participants, in order
+	Transcript []DebateMessage
+}
+
+// Struct type representing a debate, in which agents with different roles (e.g. a proposer and a critic) take turns on a request for a bounded number of rounds, before a final answer is synthesized from the exchange.
+//
+// The role of every participant is its description. Participants run on copies of their agent with an in-memory history, kept across the rounds, and every turn shows them the messages of the others since their previous turn.
+type Debate struct {
+	Participants []Specialist
+	// Number of rounds, in which every participant speaks once (defaults to 2)
+	Rounds int
+	// LLM synthesizing the final answer (defaults to the one of the first participant)
+	Llm *OpenAILLM
+	// Optional callback receiving the events of the runs of the participants and a DebateTurnEvent after every turn, along with the name of the participant
+	OnEvent func(participant string, event AgentEvent)
+}
+
+// Constructor function for a new Debate between the given participants, over 2 rounds
+func NewDebate(participants ...Specialist) *Debate {
+	return &Debate{Participants: participants, Rounds: 2}
+}
+
+// Method to hold the debate on a request and synthesize its final answer
+func (d *Debate) Run(ctx context.Context, prompt string) (*DebateResult, error) {
+	if len(d.Participants) < 2 {
+		return nil, errors.New("a debate needs at least two participants")
+	}
+	agents := make([]OpenAIReActAgent, len(d.Participants))
+	for i, participant := range d.Participants {
+		if participant.Agent == nil {
+			return nil, fmt.Errorf("participant %s has no agent", participant.Name)
+		}
+		agents[i] = *participant.Agent
+		agents[i].Memory = NewInMemoryStore()
+		agents[i].SessionID = fmt.Sprintf("%s/debate_%s", participant.Agent.SessionID, participant.Name)
+	}
+	rounds := d.Rounds
+	if rounds <= 0 {
+		rounds = 2
+	}
+	result := &DebateResult{}
+	// index of the first message each participant has not seen yet
+	seen := make([]int, len(d.Participants))
+	for round := 1; round <= rounds; round++ {
+		for i, participant := range d.Participants {
+			turn := debateTurnPrompt(participant, prompt, result.Transcript[seen[i]:], round == 1)
+			seen[i] = len(result.Transcript)
+			var content string
+			err := agents[i].RunEventsContext(ctx, turn, nil, func(event AgentEvent) {
+				if e, ok := event.(StopEvent); ok {
+					content = e.Reason
+				}
+				if d.OnEvent != nil {
+					d.OnEvent(participant.Name, event)
+				}
+			})
+			if err != nil {
+				return nil, fmt.Errorf("participant %s failed in round %d: %w", participant.Name, round, err)
+			}
+			message := DebateMessage{Round: round, Participant: participant.Name, Content: content}
+			result.Transcript = append(result.Transcript, message)
+			if d.OnEvent != nil {
+				d.OnEvent(participant.Name, DebateTurnEvent{EventInfo: EventInfo{Time: time.Now().UTC(), Step: round}, Message: message})
+			}
+		}
+	}
+	answer, err := d.conclude(ctx, prompt, result.Transcript)
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = answer
+	return result, nil
+}
+
+// Private function returning the prompt of the turn of a participant: its role and the request on its first turn, then the messages of the others since its previous turn
+func debateTurnPrompt(participant Specialist, prompt string, unseen []DebateMessage, first bool) string {
+	var turn strings.Builder
+	if first {
+		fmt.Fprintf(&turn, "You are taking part in a debate on a request as the %s: %s\nAnswer with your contribution to the debate, responding to the other participants.\n\n## Request\n\n%s", participant.Name, participant.Description, prompt)
+	} else {
+		turn.WriteString("The debate continues: respond to the new messages of the other participants, defending, revising or conceding your points.")
+	}
+	if len(unseen) > 0 {
+		turn.WriteString("\n\n## New messages\n")
+		for _, message := range unseen {
+			fmt.Fprintf(&turn, "\n### %s (round %d)\n\n%s\n", message.Participant, message.Round, message.Content)
+		}
+	}
+	return turn.String()
+}
+
+// Private method synthesizing the final answer to the request from the transcript of the debate
+func (d *Debate) conclude(ctx context.Context, prompt string, transcript []DebateMessage) (string, error) {
+	base := d.Llm
+	if base == nil {
+		base = d.Participants[0].Agent.Llm
+	}
+	var exchange strings.Builder
+	fmt.Fprintf(&exchange, "## Request\n\n%s\n\n## Debate\n", prompt)
+	for _, message := range transcript {
+		fmt.Fprintf(&exchange, "\n### %s (round %d)\n\n%s\n", message.Participant, message.Round, message.Content)
+	}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You synthesize the final answer to a request from a debate between agents with different roles: keep the arguments that survived the debate, settle the disagreements and answer the request directly."),
+		openai.UserMessage(exchange.String()),
+	}
+	llm := *base
+	llm.ctx = ctx
+	response, err := OpenAILLMStructuredPredict[DebateConclusion](&llm, history, "conclusion", "Final answer synthesized from the debate")
+	if err != nil {
+		return "", err
+	}
+	conclusion, ok := response.(DebateConclusion)
+	if !ok {
+		return "", errors.New("error while synthesizing the answer: unexpected structured output")
+	}
+	return conclusion.Answer, nil
+}
@@ -0,0 +1,104 @@
+package gopheract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Struct type representing a debug mode for an LLM, dumping the exact messages, response schema and raw response of every request to a JSON file under a directory.
+//
+// Secrets are redacted from the dumps, but they still contain the full conversation, so the directory should be treated as sensitive.
+type DebugDumper struct {
+	Dir string
+	// Redactor applied to every string of the dumps (defaults to the default redaction filters)
+	Redactor *Redactor
+	mu       sync.Mutex
+	count    int
+}
+
+// Constructor function for a new DebugDumper, creating the directory if it does not exist yet.
+func NewDebugDumper(dir string) (*DebugDumper, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DebugDumper{Dir: dir, Redactor: NewRedactor()}, nil
+}
+
+// Private struct type representing the content of a dump
+type debugDump struct {
+	Time           time.Time `json:"time"`
+	Model          string    `json:"model"`
+	Duration       Duration  `json:"duration"`
+	Messages       any       `json:"messages"`
+	ResponseFormat any       `json:"response_format"`
+	RawResponse    any       `json:"raw_response,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Private function redacting the strings of a JSON value (as decoded into `any`)
+func redactJSONValue(value any, redactor *Redactor) any {
+	switch v := value.(type) {
+	case string:
+		redacted, _ := redactor.RedactText(v)
+		return redacted
+	case []any:
+		for i := range v {
+			v[i] = redactJSONValue(v[i], redactor)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = redactJSONValue(v[key], redactor)
+		}
+	}
+	return value
+}
+
+// Private function converting a value to its generic JSON representation, with its strings redacted
+func redactedJSON(value any, redactor *Redactor) any {
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("unserializable value: %s", err)
+	}
+	var generic any
+	if err := json.Unmarshal(serialized, &generic); err != nil {
+		return fmt.Sprintf("unserializable value: %s", err)
+	}
+	return redactJSONValue(generic, redactor)
+}
+
+// Private method writing the dump of an LLM request (no-op on nil DebugDumper). The name of the response schema is used to name the file after the phase of the agent (e.g. "thought" or "action").
+func (d *DebugDumper) dump(model, schemaName string, messages, responseFormat any, rawResponse string, duration time.Duration, requestErr error) error {
+	if d == nil {
+		return nil
+	}
+	redactor := d.Redactor
+	if redactor == nil {
+		redactor = NewRedactor()
+	}
+	dump := debugDump{
+		Time:           time.Now().UTC(),
+		Model:          model,
+		Duration:       Duration(duration),
+		Messages:       redactedJSON(messages, redactor),
+		ResponseFormat: redactedJSON(responseFormat, redactor),
+	}
+	if rawResponse != "" {
+		dump.RawResponse = redactedJSON(json.RawMessage(rawResponse), redactor)
+	}
+	if requestErr != nil {
+		dump.Error = requestErr.Error()
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.count++
+	name := fmt.Sprintf("%s-%04d-%s.json", dump.Time.Format("20060102T150405"), d.count, schemaName)
+	d.mu.Unlock()
+	return os.WriteFile(filepath.Join(d.Dir, name), data, 0o600)
+}
@@ -0,0 +1,39 @@
+package gopheract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Minimum length of a tool result for its copies to be replaced by a reference, as shorter results would not save tokens
+const minDedupeLength = 200
+
+// Function replacing the tool results that repeat an earlier result verbatim (e.g. the same file read twice, or the same command output) with a reference to the earlier tool call, leaving the input slice untouched.
+func DedupeToolResults(messages []*ChatMessage) []*ChatMessage {
+	seen := map[string]string{}
+	deduped := make([]*ChatMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Phase != MessagePhaseTool {
+			deduped = append(deduped, message)
+			continue
+		}
+		header, payload, ok := strings.Cut(message.Content, ": ")
+		if !ok || len(payload) < minDedupeLength {
+			deduped = append(deduped, message)
+			continue
+		}
+		if callID, ok := seen[payload]; ok {
+			clone := *message
+			clone.Content = fmt.Sprintf("%s: same as result of %s", header, callID)
+			deduped = append(deduped, &clone)
+			continue
+		}
+		callID := message.ToolCallID
+		if callID == "" {
+			callID = "an earlier tool call"
+		}
+		seen[payload] = callID
+		deduped = append(deduped, message)
+	}
+	return deduped
+}
@@ -0,0 +1,83 @@
+package gopheract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupeToolResults(t *testing.T) {
+	long := strings.Repeat("file content ", 20)
+	other := strings.Repeat("other content ", 20)
+	result := func(callID, payload string) *ChatMessage {
+		return NewPhaseMessage("user", MessagePhaseTool, callID, "Tool call result from Read: "+payload)
+	}
+	tests := []struct {
+		name     string
+		messages []*ChatMessage
+		want     []string
+	}{
+		{
+			name:     "repeated result",
+			messages: []*ChatMessage{result("run_1/call_1", long), result("run_1/call_2", long)},
+			want:     []string{"Tool call result from Read: " + long, "Tool call result from Read: same as result of run_1/call_1"},
+		},
+		{
+			name:     "repeated result across runs",
+			messages: []*ChatMessage{result("run_1/call_1", long), result("run_2/call_1", other), result("run_2/call_2", long)},
+			want:     []string{"Tool call result from Read: " + long, "Tool call result from Read: " + other, "Tool call result from Read: same as result of run_1/call_1"},
+		},
+		{
+			name:     "short results are kept",
+			messages: []*ChatMessage{result("run_1/call_1", "ok"), result("run_1/call_2", "ok")},
+			want:     []string{"Tool call result from Read: ok", "Tool call result from Read: ok"},
+		},
+		{
+			name:     "other phases are kept",
+			messages: []*ChatMessage{NewChatMessage("user", "prompt: "+long), NewChatMessage("user", "prompt: "+long)},
+			want:     []string{"prompt: " + long, "prompt: " + long},
+		},
+		{
+			name:     "result without call id",
+			messages: []*ChatMessage{result("", long), result("run_1/call_2", long)},
+			want:     []string{"Tool call result from Read: " + long, "Tool call result from Read: same as result of an earlier tool call"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := make([]string, len(test.messages))
+			for i, message := range test.messages {
+				original[i] = message.Content
+			}
+			deduped := DedupeToolResults(test.messages)
+			if len(deduped) != len(test.want) {
+				t.Fatalf("got %d messages, want %d", len(deduped), len(test.want))
+			}
+			for i, message := range deduped {
+				if message.Content != test.want[i] {
+					t.Errorf("message %d: got %q, want %q", i, message.Content, test.want[i])
+				}
+				if test.messages[i].Content != original[i] {
+					t.Errorf("message %d of the input was modified", i)
+				}
+			}
+		})
+	}
+}
+
+func TestToolCallIDs(t *testing.T) {
+	first := &runToolCalls{prefix: "run_1"}
+	second := &runToolCalls{prefix: "run_2"}
+	seen := map[string]bool{}
+	for _, calls := range []*runToolCalls{first, second} {
+		for range 3 {
+			id := calls.nextID()
+			if seen[id] {
+				t.Fatalf("duplicate call id %s", id)
+			}
+			seen[id] = true
+		}
+	}
+	if id := (&runToolCalls{}).nextID(); id != "call_1" {
+		t.Errorf("got %s without a run identifier, want call_1", id)
+	}
+}
@@ -2,7 +2,7 @@ package gopheract
 
 import "text/template"
 
-// Constructor for an OpenAIReactAgent starting based on defaults for the system prompt template and the chat history. Takes, as arguments, an OpenAI API key, an OpenAI model identifier and a list of tool defitions.
+// Constructor for an OpenAIReactAgent starting based on defaults for the system prompt template and the chat history (kept in memory, under the "default" session). Takes, as arguments, an OpenAI API key, an OpenAI model identifier and a list of tool defitions.
 func NewDefaultOpenAIReactAgent(apiKey, model string, tools []Tool) (*OpenAIReActAgent, error) {
 	sysPromptT := template.New("sysPromptT")
 	sysPromptT, err := sysPromptT.Parse(`You are designed to help with a variety of tasks, from answering questions to providing summaries to other types of analyses.
@@ -16,6 +16,8 @@ You have access to the following tools:
 
 {{.}}
 
+Each tool is annotated with its estimated cost and latency. When several tools can accomplish the same subtask, prefer the cheaper and faster ones (e.g. searching local files before fetching remote resources).
+
 ## Output Format
 
 Please answer in the same language as the question and use the following format:
@@ -47,7 +49,8 @@ Answer: [your answer here (In the same language as the user's question)]
 	}
 	return &OpenAIReActAgent{
 		Llm:                  NewOpenAILLM(apiKey, model),
-		ChatHistory:          []*ChatMessage{},
+		Memory:               NewInMemoryStore(),
+		SessionID:            "default",
 		SystemPromptTemplate: sysPromptT,
 		Tools:                tools,
 	}, nil
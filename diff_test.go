@@ -0,0 +1,56 @@
+package gopheract
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{"identical", "a\n", "a\n", ""},
+		{"new file", "", "a\nb\n", "--- /dev/null\n+++ b/file.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"changed line", "a\nb\nc\n", "a\nx\nc\n", "--- a/file.txt\n+++ b/file.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"no newline at end of file", "a\n", "a\nb", "--- a/file.txt\n+++ b/file.txt\n@@ -1,1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := UnifiedDiff("file.txt", test.oldText, test.newText); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesLargeChange(t *testing.T) {
+	// changes at both ends of a large file leave too many lines for the table of the longest common subsequence
+	oldLines := make([]string, 30_000)
+	newLines := make([]string, 30_000)
+	for i := range oldLines {
+		oldLines[i] = fmt.Sprintf("line %d\n", i)
+		newLines[i] = oldLines[i]
+	}
+	newLines[0] = "first\n"
+	newLines[len(newLines)-1] = "last\n"
+	ops := diffLines(oldLines, newLines)
+	var deleted, inserted int
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			deleted++
+		case '+':
+			inserted++
+		}
+	}
+	if deleted != len(oldLines) || inserted != len(newLines) {
+		t.Errorf("got %d deleted and %d inserted lines, want %d and %d", deleted, inserted, len(oldLines), len(newLines))
+	}
+	if got := UnifiedDiff("file.txt", strings.Join(oldLines, ""), strings.Join(newLines, "")); !strings.HasPrefix(got, "--- a/file.txt\n+++ b/file.txt\n@@ -1,30000 +1,30000 @@\n") {
+		t.Errorf("got %q", got[:min(len(got), 100)])
+	}
+}
@@ -0,0 +1,391 @@
+package gopheract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error returned by the loaders for files they cannot read (e.g. binary files)
+var ErrUnsupportedDocument = errors.New("unsupported document")
+
+// Struct type representing a document loaded from a file, to be chunked, embedded and searched by the retrieval tooling of the package
+type Document struct {
+	// Identifier of the document: its path, followed by its page for the files split into pages (e.g. "report.pdf#page=3")
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	// Metadata of the document: its "source" path and "format", along with the "title" and "page" of the document when known (and the front matter of Markdown files)
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// Base interface for the document loaders, extracting the text of a file of a given format
+type DocumentLoader interface {
+	// Load the documents of a file (one per page for paginated formats)
+	Load(path string) ([]Document, error)
+}
+
+// Loaders of the supported formats, by file extension: the files with other extensions are loaded as plain text, unless they are binary
+var DocumentLoaders = map[string]DocumentLoader{
+	".md":       MarkdownLoader{},
+	".markdown": MarkdownLoader{},
+	".mdx":      MarkdownLoader{},
+	".html":     HTMLLoader{},
+	".htm":      HTMLLoader{},
+	".xhtml":    HTMLLoader{},
+	".pdf":      PDFLoader{},
+	".docx":     DocxLoader{},
+}
+
+// Function loading the documents of a file with the loader of its format (see `DocumentLoaders`)
+func LoadDocument(path string) ([]Document, error) {
+	loader, ok := DocumentLoaders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		loader = TextLoader{}
+	}
+	return loader.Load(path)
+}
+
+// Function loading the documents of a file, or of all the files of a directory and its subdirectories, skipping hidden files and directories (e.g. .git) and the files that are not supported
+func LoadDocuments(root string) ([]Document, error) {
+	paths, err := DocumentFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	documents := []Document{}
+	for _, path := range paths {
+		loaded, err := LoadDocument(path)
+		if errors.Is(err, ErrUnsupportedDocument) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error while loading %s: %w", path, err)
+		}
+		documents = append(documents, loaded...)
+	}
+	return documents, nil
+}
+
+// Function returning the paths of the files under a directory that LoadDocuments would load, skipping hidden files and directories (a file is returned as it is)
+func DocumentFiles(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+	paths := []string{}
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// Private function returning the metadata shared by the documents of a file
+func documentMetadata(path, format string) map[string]any {
+	return map[string]any{"source": path, "format": format}
+}
+
+// Private function tidying up the text extracted from a formatted document: trailing spaces are removed, and runs of blank lines are collapsed into one
+func tidyText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	tidy := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\u00a0")
+		if line == "" && (len(tidy) == 0 || tidy[len(tidy)-1] == "") {
+			continue
+		}
+		tidy = append(tidy, line)
+	}
+	return strings.TrimSpace(strings.Join(tidy, "\n"))
+}
+
+// Loader of plain text files (including source code), which fails with ErrUnsupportedDocument on binary files
+type TextLoader struct{}
+
+// Load a text file as a single document
+func (TextLoader) Load(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	head := data[:min(len(data), 8192)]
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(data) {
+		return nil, fmt.Errorf("%w: %s is a binary file", ErrUnsupportedDocument, path)
+	}
+	return []Document{{ID: path, Text: string(data), Metadata: documentMetadata(path, "text")}}, nil
+}
+
+// Loader of Markdown files, which moves their YAML front matter (if any) to the metadata of the document
+type MarkdownLoader struct{}
+
+// Pattern of the first level-one heading of a Markdown document
+var markdownTitlePattern = regexp.MustCompile(`(?m)^#\s+(.+?)\s*#*\s*$`)
+
+// Load a Markdown file as a single document, titled after its front matter or its first heading
+func (MarkdownLoader) Load(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	metadata := documentMetadata(path, "markdown")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if end := strings.Index(rest, "\n---"); end >= 0 {
+			frontMatter := map[string]any{}
+			if err := yaml.Unmarshal([]byte(rest[:end]), &frontMatter); err != nil {
+				return nil, fmt.Errorf("invalid front matter in %s: %w", path, err)
+			}
+			for key, value := range frontMatter {
+				if _, ok := metadata[key]; !ok {
+					metadata[key] = value
+				}
+			}
+			text = strings.TrimLeft(rest[end+len("\n---"):], "-")
+		}
+	}
+	if _, ok := metadata["title"]; !ok {
+		if match := markdownTitlePattern.FindStringSubmatch(text); match != nil {
+			metadata["title"] = match[1]
+		}
+	}
+	return []Document{{ID: path, Text: strings.TrimSpace(text), Metadata: metadata}}, nil
+}
+
+// Loader of HTML files, converting them to text: scripts and styles are dropped, and headings, list items and paragraphs are kept on lines of their own (headings in the Markdown style, so that the chunkers can split on them)
+type HTMLLoader struct{}
+
+// Pattern of the tags and comments of an HTML document
+var htmlTagPattern = regexp.MustCompile(`(?s)<!--.*?-->|<[!?][^>]*>|<(/?)([a-zA-Z][a-zA-Z0-9-]*)[^>]*>`)
+
+// Elements of an HTML document whose content is not text
+var htmlSkippedElements = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "svg": true, "head": true}
+
+// Elements of an HTML document laid out as blocks
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "li": true, "ul": true, "ol": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "tr": true, "section": true, "article": true, "header": true, "footer": true, "nav": true, "aside": true,
+	"main": true, "blockquote": true, "pre": true, "figure": true, "figcaption": true, "form": true, "address": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Block elements of an HTML document followed by a blank line
+var htmlParagraphElements = map[string]bool{
+	"p": true, "ul": true, "ol": true, "dl": true, "table": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Pattern of the whitespace collapsed in the text of an HTML document
+var htmlSpacePattern = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+// Load an HTML file as a single document, titled after its title element
+func (HTMLLoader) Load(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	title, text := htmlToText(string(data))
+	metadata := documentMetadata(path, "html")
+	if title != "" {
+		metadata["title"] = title
+	}
+	return []Document{{ID: path, Text: text, Metadata: metadata}}, nil
+}
+
+// Private function converting an HTML document to text, returning its title along with it
+func htmlToText(document string) (string, string) {
+	var text, title strings.Builder
+	// element whose content is skipped (if any), and whether the text is in a title or a preformatted block
+	skipping := ""
+	inTitle, inPre := false, false
+	lineStart := true
+	writeText := func(raw string) {
+		if skipping != "" && !inTitle {
+			return
+		}
+		raw = html.UnescapeString(raw)
+		if inTitle {
+			title.WriteString(raw)
+			return
+		}
+		if !inPre {
+			raw = htmlSpacePattern.ReplaceAllString(raw, " ")
+			if lineStart {
+				raw = strings.TrimLeft(raw, " ")
+			}
+		}
+		if raw != "" {
+			text.WriteString(raw)
+			lineStart = false
+		}
+	}
+	// blocks start on a line of their own, and paragraphs (in the broad sense) are followed by a blank line
+	breakLine := func(blank bool) {
+		if !lineStart {
+			text.WriteString("\n")
+			lineStart = true
+		}
+		if blank {
+			text.WriteString("\n")
+		}
+	}
+	position := 0
+	for _, match := range htmlTagPattern.FindAllStringSubmatchIndex(document, -1) {
+		writeText(document[position:match[0]])
+		position = match[1]
+		if match[4] < 0 {
+			continue
+		}
+		closing := match[3] > match[2]
+		name := strings.ToLower(document[match[4]:match[5]])
+		switch {
+		case name == "title":
+			inTitle = !closing
+			continue
+		case skipping != "":
+			if closing && name == skipping {
+				skipping = ""
+			}
+			continue
+		case htmlSkippedElements[name] && !closing:
+			skipping = name
+			continue
+		case name == "pre":
+			inPre = !closing
+		case (name == "td" || name == "th") && !closing && !lineStart:
+			text.WriteString(" ")
+		}
+		if !htmlBlockElements[name] {
+			continue
+		}
+		breakLine(closing && htmlParagraphElements[name])
+		if closing {
+			continue
+		}
+		switch {
+		case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+			level, _ := strconv.Atoi(name[1:])
+			text.WriteString(strings.Repeat("#", level) + " ")
+			lineStart = false
+		case name == "li":
+			text.WriteString("- ")
+			lineStart = false
+		}
+	}
+	writeText(document[position:])
+	return strings.TrimSpace(htmlSpacePattern.ReplaceAllString(title.String(), " ")), tidyText(text.String())
+}
+
+// Loader of Word documents (.docx), converting them to text: paragraphs are kept on lines of their own, and headings are written in the Markdown style
+type DocxLoader struct{}
+
+// Load a Word document as a single document, titled after its properties
+func (DocxLoader) Load(path string) ([]Document, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a Word document: %w", ErrUnsupportedDocument, path, err)
+	}
+	defer archive.Close()
+	body, err := archive.Open("word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a Word document", ErrUnsupportedDocument, path)
+	}
+	defer body.Close()
+	text, err := docxText(body)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading %s: %w", path, err)
+	}
+	metadata := documentMetadata(path, "docx")
+	if properties, err := archive.Open("docProps/core.xml"); err == nil {
+		defer properties.Close()
+		var core struct {
+			Title string `xml:"title"`
+		}
+		if xml.NewDecoder(properties).Decode(&core) == nil && strings.TrimSpace(core.Title) != "" {
+			metadata["title"] = strings.TrimSpace(core.Title)
+		}
+	}
+	return []Document{{ID: path, Text: text, Metadata: metadata}}, nil
+}
+
+// Private function extracting the text of the body of a Word document
+func docxText(body io.Reader) (string, error) {
+	decoder := xml.NewDecoder(body)
+	var text, paragraph strings.Builder
+	heading := 0
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+			case "pStyle":
+				for _, attr := range token.Attr {
+					if attr.Name.Local != "val" {
+						continue
+					}
+					if level, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(attr.Value), "heading")); err == nil && level > 0 {
+						heading = min(level, 6)
+					} else if strings.EqualFold(attr.Value, "title") {
+						heading = 1
+					}
+				}
+			}
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "t":
+				inText = false
+			case "tc":
+				paragraph.WriteString("\t")
+			case "p":
+				if heading > 0 && strings.TrimSpace(paragraph.String()) != "" {
+					text.WriteString(strings.Repeat("#", heading) + " ")
+				}
+				text.WriteString(paragraph.String())
+				text.WriteString("\n")
+				paragraph.Reset()
+				heading = 0
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(token)
+			}
+		}
+	}
+	return tidyText(text.String()), nil
+}
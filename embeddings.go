@@ -0,0 +1,82 @@
+package gopheract
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// Base interface for the embedding models, turning texts into vectors that can be compared by semantic similarity.
+//
+// It is the foundation of the long-term memory of agents and of the retrieval tooling of the package.
+type Embedder interface {
+	// Embed a batch of texts, returning one vector per text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Implementation of Embedder for OpenAI text embedding models
+type OpenAIEmbedder struct {
+	// The OpenAI embedding model to use
+	Model openai.EmbeddingModel
+	// Number of dimensions of the embeddings (zero uses the default of the model; only supported by `text-embedding-3` and later models)
+	Dimensions int64
+	// Maximum number of texts embedded in a single request (defaults to 2048, the limit of the API)
+	BatchSize int
+
+	// OpenAI API client
+	Client *openai.Client
+}
+
+// Constructor function for a new OpenAIEmbedder (provide an API key and the model identifier, e.g. "text-embedding-3-small")
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	client := openai.NewClient(option.WithAPIKey(apiKey))
+	return &OpenAIEmbedder{
+		Model:     model,
+		BatchSize: 2048,
+		Client:    &client,
+	}
+}
+
+// Embed a batch of texts, splitting it into several requests if it exceeds the batch size
+func (o *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batchSize := o.BatchSize
+	if batchSize <= 0 {
+		batchSize = 2048
+	}
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		params := openai.EmbeddingNewParams{
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+			Model: o.Model,
+		}
+		if o.Dimensions > 0 {
+			params.Dimensions = openai.Int(o.Dimensions)
+		}
+		response, err := o.Client.Embeddings.New(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Data) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(response.Data))
+		}
+		vectors := make([][]float32, len(batch))
+		for _, data := range response.Data {
+			if data.Index < 0 || data.Index >= int64(len(batch)) {
+				return nil, fmt.Errorf("invalid embedding index %d", data.Index)
+			}
+			if vectors[data.Index] != nil {
+				return nil, fmt.Errorf("duplicate embedding index %d", data.Index)
+			}
+			vector := make([]float32, len(data.Embedding))
+			for i, value := range data.Embedding {
+				vector[i] = float32(value)
+			}
+			vectors[data.Index] = vector
+		}
+		embeddings = append(embeddings, vectors...)
+	}
+	return embeddings, nil
+}
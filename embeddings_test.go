@@ -0,0 +1,50 @@
+package gopheract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+func TestOpenAIEmbedderIndices(t *testing.T) {
+	tests := []struct {
+		name    string
+		indices []int
+		wantErr bool
+	}{
+		{"indices in order", []int{0, 1}, false},
+		{"indices out of order", []int{1, 0}, false},
+		{"index out of range", []int{0, 2}, true},
+		{"negative index", []int{-1, 0}, true},
+		{"duplicate index", []int{0, 0}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"object":"list","model":"test","data":[{"object":"embedding","index":%d,"embedding":[1,0]},{"object":"embedding","index":%d,"embedding":[0,1]}]}`, test.indices[0], test.indices[1])
+			}))
+			defer server.Close()
+			client := openai.NewClient(option.WithAPIKey("test"), option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+			embedder := &OpenAIEmbedder{Model: "test", Client: &client}
+			embeddings, err := embedder.Embed(context.Background(), []string{"a", "b"})
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got embeddings %v, want an error", embeddings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if embeddings[test.indices[0]][0] != 1 || embeddings[test.indices[1]][1] != 1 {
+				t.Errorf("got embeddings %v in the wrong order", embeddings)
+			}
+		})
+	}
+}
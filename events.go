@@ -0,0 +1,288 @@
+package gopheract
+
+import (
+	"time"
+)
+
+// Base interface for the events emitted by an agent during a run: ProgressEvent, ThoughtEvent, ActionEvent, ToolStartEvent, ToolEndEvent, ObservationEvent, VerificationEvent, GuardrailEvent, StopEvent, HandoffEvent, TransferEvent, ErrorEvent and UsageEvent (plus DebateTurnEvent, emitted by debates, and QueuedEvent, emitted by agent pools before the run starts).
+//
+// Consumers receive them as a single ordered stream and select the variants they care about with a type switch.
+type AgentEvent interface {
+	// Time at which the event occurred
+	EventTime() time.Time
+	// Index of the think-act-observe iteration the event belongs to (starting from 1, or 0 before the first iteration)
+	EventStep() int
+	isAgentEvent()
+}
+
+// Struct type holding the fields shared by all the events, embedded in every variant
+type EventInfo struct {
+	Time time.Time
+	Step int
+}
+
+// Time at which the event occurred
+func (e EventInfo) EventTime() time.Time {
+	return e.Time
+}
+
+// Index of the iteration the event belongs to
+func (e EventInfo) EventStep() int {
+	return e.Step
+}
+
+func (EventInfo) isAgentEvent() {}
+
+// Event emitted at the start of every iteration of the run, so that frontends can render its progress
+type ProgressEvent struct {
+	EventInfo
+	// Maximum number of iterations of the run (zero means no limit)
+	MaxSteps int
+	// Time elapsed since the start of the run
+	Elapsed time.Duration
+}
+
+// Method returning the fraction of the iteration budget used once the current iteration completes (zero when there is no limit)
+func (e ProgressEvent) Fraction() float64 {
+	if e.MaxSteps <= 0 {
+		return 0
+	}
+	return float64(e.Step) / float64(e.MaxSteps)
+}
+
+// Event emitted when the model produced a thought
+type ThoughtEvent struct {
+	EventInfo
+	Thought string
+	// Duration of the thinking phase, including the LLM request
+	Duration time.Duration
+}
+
+// Event emitted when the model chose an action (a tool call, several tool calls or stopping)
+type ActionEvent struct {
+	EventInfo
+	Action Action
+	// Duration of the acting phase, including the LLM request
+	Duration time.Duration
+}
+
+// Event emitted before the execution of each tool call requested by the model
+type ToolStartEvent struct {
+	EventInfo
+	// Identifier of the tool call, unique within the session (e.g. "run_1760000000000000000/call_1"), shared with the matching ToolEndEvent
+	CallID   string
+	ToolCall ToolCall
+}
+
+// Event emitted when a tool call completed successfully (failed tool calls end the run with an ErrorEvent)
+type ToolEndEvent struct {
+	EventInfo
+	CallID string
+	Tool   string
+	Result any
+	// Whether the result was reused from an identical call in the same run instead of executing the tool again
+	Reused bool
+	// Duration of the execution of the tool (zero when the result was reused)
+	Duration time.Duration
+}
+
+// Event emitted when the model produced an observation
+type ObservationEvent struct {
+	EventInfo
+	Observation string
+	// Duration of the observation phase, including the LLM request
+	Duration time.Duration
+}
+
+// Event emitted when the verifier of the agent checked an answer, before the StopEvent if it approved it (or if the answer cannot be sent back anymore)
+type VerificationEvent struct {
+	EventInfo
+	// Answer that was checked
+	Answer  string
+	Verdict Verdict
+	// Whether the answer was sent back for rework
+	Rework bool
+}
+
+// Event emitted when a guardrail of the agent found a violation in a prompt, in the arguments of a tool call or in a final answer
+type GuardrailEvent struct {
+	EventInfo
+	Stage GuardrailStage
+	// Name of the guardrail
+	Guardrail string
+	Verdict   GuardrailVerdict
+	// Content that was checked
+	Content string
+	// Tool call whose arguments were checked, for the tool_args stage
+	CallID string
+	Tool   string
+	// Whether the content was approved, for the verdicts requiring approval
+	Approved bool
+}
+
+// Method returning whether the violation stopped the content, which is the case of the blocked contents and of the contents that were not approved
+func (e GuardrailEvent) Blocked() bool {
+	return e.Verdict.Action == GuardrailBlock || (e.Verdict.Action == GuardrailRequireApproval && !e.Approved)
+}
+
+// Private method returning the reason of the violation, or the name of the guardrail if it gave none
+func (e GuardrailEvent) reason() string {
+	if e.Verdict.Reason != "" {
+		return e.Verdict.Reason
+	}
+	return "violates " + e.Guardrail
+}
+
+// Event emitted when the model decided to stop, with its final answer
+type StopEvent struct {
+	EventInfo
+	Reason string
+	// Sources returned by the tools of the run that the answer cites (see CitableResult)
+	Citations []Citation
+}
+
+// Event emitted when a tool transferred the conversation to another agent, as the last event of the run instead of a StopEvent
+type HandoffEvent struct {
+	EventInfo
+	Handoff Handoff
+}
+
+// Event emitted when a tool returned an AgentRef, after which the events of the run are the ones of the referenced agent, continuing the conversation
+type TransferEvent struct {
+	EventInfo
+	// Name of the agent taking over
+	Agent  string
+	Reason string
+}
+
+// Event emitted by an AgentPool when a run waits for a free slot, before the run starts
+type QueuedEvent struct {
+	EventInfo
+	// Position of the run in the queue (starting from 1)
+	Position int
+}
+
+// Event emitted when the run fails, as the last event of the stream
+type ErrorEvent struct {
+	EventInfo
+	Err error
+}
+
+// Event emitted after every LLM request of the run, with its token usage
+type UsageEvent struct {
+	EventInfo
+	// Phase the request belongs to: "thought", "action", "observation", "verification", "compaction" or "facts"
+	Phase string
+	Usage TokenUsage
+}
+
+// Function adapting the callbacks of `Run` to an event handler.
+//
+// Every tool call is reported to the action callback as a `tool_call` action before being executed, and only the successful tool calls are reported to the tool end callback.
+func CallbackHandler(thoughtCallback func(string), actionCallback func(Action), toolEndCallback func(any), observationCallback func(string), stopCallback func(string)) func(AgentEvent) {
+	return func(event AgentEvent) {
+		switch e := event.(type) {
+		case ThoughtEvent:
+			thoughtCallback(e.Thought)
+		case ToolStartEvent:
+			actionCallback(Action{ActionType: "tool_call", ToolCall: &e.ToolCall})
+		case ToolEndEvent:
+			toolEndCallback(e.Result)
+		case ObservationEvent:
+			observationCallback(e.Observation)
+		case StopEvent:
+			stopCallback(e.Reason)
+		}
+	}
+}
+
+// Function returning the representation of an event as a JSON object, for transports and machine-readable outputs.
+//
+// The object has the type of the event ("progress", "thought", "action", "tool_start", "tool_end", "observation", "verification", "guardrail", "stop", "handoff", "transfer", "debate_turn", "queued", "error" or "usage"), its time and step, and the fields of the variant in snake case (durations in milliseconds).
+func EventPayload(event AgentEvent) map[string]any {
+	payload := map[string]any{"time": event.EventTime(), "step": event.EventStep()}
+	switch e := event.(type) {
+	case ProgressEvent:
+		payload["type"] = "progress"
+		payload["max_steps"] = e.MaxSteps
+		payload["elapsed_ms"] = e.Elapsed.Milliseconds()
+	case ThoughtEvent:
+		payload["type"] = "thought"
+		payload["thought"] = e.Thought
+		payload["duration_ms"] = e.Duration.Milliseconds()
+	case ActionEvent:
+		payload["type"] = "action"
+		payload["action"] = e.Action
+		payload["duration_ms"] = e.Duration.Milliseconds()
+	case ToolStartEvent:
+		payload["type"] = "tool_start"
+		payload["call_id"] = e.CallID
+		payload["tool"] = e.ToolCall.Name
+		if args, err := e.ToolCall.ArgsToMap(); err == nil {
+			payload["args"] = args
+		}
+	case ToolEndEvent:
+		payload["type"] = "tool_end"
+		payload["call_id"] = e.CallID
+		payload["tool"] = e.Tool
+		payload["result"] = e.Result
+		payload["reused"] = e.Reused
+		payload["duration_ms"] = e.Duration.Milliseconds()
+	case ObservationEvent:
+		payload["type"] = "observation"
+		payload["observation"] = e.Observation
+		payload["duration_ms"] = e.Duration.Milliseconds()
+	case VerificationEvent:
+		payload["type"] = "verification"
+		payload["answer"] = e.Answer
+		payload["approved"] = e.Verdict.Approved
+		payload["feedback"] = e.Verdict.Feedback
+		payload["rework"] = e.Rework
+	case GuardrailEvent:
+		payload["type"] = "guardrail"
+		payload["stage"] = e.Stage
+		payload["guardrail"] = e.Guardrail
+		payload["action"] = e.Verdict.Action
+		payload["reason"] = e.Verdict.Reason
+		payload["content"] = e.Content
+		if e.Verdict.Action == GuardrailRewrite {
+			payload["rewrite"] = e.Verdict.Content
+		}
+		if e.CallID != "" {
+			payload["call_id"] = e.CallID
+			payload["tool"] = e.Tool
+		}
+		payload["approved"] = e.Approved
+		payload["blocked"] = e.Blocked()
+	case StopEvent:
+		payload["type"] = "stop"
+		payload["reason"] = e.Reason
+		if len(e.Citations) > 0 {
+			payload["citations"] = e.Citations
+		}
+	case HandoffEvent:
+		payload["type"] = "handoff"
+		payload["agent"] = e.Handoff.Agent
+		payload["context"] = e.Handoff.Context
+		payload["reason"] = e.Handoff.Reason
+	case TransferEvent:
+		payload["type"] = "transfer"
+		payload["agent"] = e.Agent
+		payload["reason"] = e.Reason
+	case DebateTurnEvent:
+		payload["type"] = "debate_turn"
+		payload["participant"] = e.Message.Participant
+		payload["content"] = e.Message.Content
+	case QueuedEvent:
+		payload["type"] = "queued"
+		payload["position"] = e.Position
+	case ErrorEvent:
+		payload["type"] = "error"
+		payload["error"] = e.Err.Error()
+	case UsageEvent:
+		payload["type"] = "usage"
+		payload["phase"] = e.Phase
+		payload["usage"] = e.Usage
+	}
+	return payload
+}
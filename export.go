@@ -0,0 +1,197 @@
+package gopheract
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Interface that tools can implement to provide the JSON schema of their input parameters.
+//
+// `ToolDefinition` implements it by reflecting on its parameters struct type; for other tools, the schema is derived from their parameters metadata.
+type SchemaTool interface {
+	InputSchema() map[string]any
+}
+
+// Struct type representing a tool descriptor in the format used by the Model Context Protocol (MCP)
+type MCPTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+	// Hints about the behavior of the tool, e.g. `readOnlyHint`
+	Annotations map[string]any `json:"annotations,omitempty"`
+}
+
+// Method returning the JSON schema of the parameters of the tool, built from the `json` and `description` tags of the parameters struct type.
+func (t ToolDefinition[T]) InputSchema() map[string]any {
+	var params T
+	paramType := reflect.TypeOf(params)
+	if paramType == nil || paramType.Kind() != reflect.Struct {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return jsonSchemaForType(paramType)
+}
+
+// Private function building the JSON schema for a Go type
+func jsonSchemaForType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field.Tag.Get("json"), field.Name)
+			if name == "-" {
+				continue
+			}
+			schema := jsonSchemaForType(field.Type)
+			if desc := field.Tag.Get("description"); desc != "" {
+				schema["description"] = desc
+			}
+			properties[name] = schema
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	default:
+		return map[string]any{}
+	}
+}
+
+// Private function returning the JSON name of a struct field from its `json` tag, and whether the field is omitted when empty
+func jsonFieldName(tag, fieldName string) (string, bool) {
+	if tag == "" {
+		return fieldName, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = fieldName
+	}
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+// Private function mapping the string representation of a Go type (as found in the parameters metadata) to a JSON schema
+func jsonSchemaForTypeName(typeName string) map[string]any {
+	switch {
+	case strings.HasPrefix(typeName, "*"):
+		return jsonSchemaForTypeName(typeName[1:])
+	case strings.HasPrefix(typeName, "[]"):
+		return map[string]any{"type": "array", "items": jsonSchemaForTypeName(typeName[2:])}
+	case strings.HasPrefix(typeName, "map["):
+		if end := strings.Index(typeName, "]"); end > 0 {
+			return map[string]any{"type": "object", "additionalProperties": jsonSchemaForTypeName(typeName[end+1:])}
+		}
+		return map[string]any{"type": "object"}
+	case typeName == "string":
+		return map[string]any{"type": "string"}
+	case typeName == "bool":
+		return map[string]any{"type": "boolean"}
+	case strings.HasPrefix(typeName, "int") || strings.HasPrefix(typeName, "uint"):
+		return map[string]any{"type": "integer"}
+	case strings.HasPrefix(typeName, "float"):
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// Function returning the JSON schema of the input parameters of a tool.
+func ToolInputSchema(tool Tool) map[string]any {
+	if schemaTool, ok := tool.(SchemaTool); ok {
+		return schemaTool.InputSchema()
+	}
+	return schemaFromMetadata(tool.GetMetadata().ParametersMetadata)
+}
+
+// Private function building the JSON schema of the input parameters of a tool from their metadata
+func schemaFromMetadata(params []ToolParamsMetadata) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, param := range params {
+		name, omitempty := jsonFieldName(param.JsonDef, "")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := jsonSchemaForTypeName(param.Type)
+		if param.Description != "" {
+			schema["description"] = param.Description
+		}
+		properties[name] = schema
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// Method converting the registered tools into OpenAI function tool definitions, to be used with native function calling.
+func (r *ToolRegistry) ToOpenAITools() []openai.ChatCompletionToolUnionParam {
+	tools := r.Tools()
+	converted := make([]openai.ChatCompletionToolUnionParam, 0, len(tools))
+	for _, tool := range tools {
+		metadata := tool.GetMetadata()
+		converted = append(converted, openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:        metadata.Name,
+			Description: openai.String(metadata.Description),
+			Parameters:  openai.FunctionParameters(ToolInputSchema(tool)),
+		}))
+	}
+	return converted
+}
+
+// Method converting the registered tools into MCP tool descriptors, to be served by an MCP server or used to generate documentation.
+func (r *ToolRegistry) ToMCPTools() []MCPTool {
+	tools := r.Tools()
+	converted := make([]MCPTool, 0, len(tools))
+	for _, tool := range tools {
+		metadata := tool.GetMetadata()
+		converted = append(converted, MCPTool{
+			Name:        metadata.Name,
+			Description: metadata.Description,
+			InputSchema: ToolInputSchema(tool),
+		})
+	}
+	return converted
+}
+
+// Method serializing the registered tools as OpenAI tools JSON (`format` is "openai") or as an MCP `tools/list` result (`format` is "mcp").
+func (r *ToolRegistry) ExportJSON(format string) ([]byte, error) {
+	switch format {
+	case "openai":
+		return json.MarshalIndent(r.ToOpenAITools(), "", "  ")
+	case "mcp":
+		return json.MarshalIndent(map[string]any{"tools": r.ToMCPTools()}, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
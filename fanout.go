@@ -0,0 +1,220 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Struct type representing a branch of a fan-out: an agent working on the request, or on its own variant of it
+type FanOutBranch struct {
+	Name  string
+	Agent *OpenAIReActAgent
+	// Optional prompt of the branch, replacing the request (e.g. to ask for a different approach)
+	Prompt string
+}
+
+// Struct type representing the outcome of a branch of a fan-out
+type FanOutOutput struct {
+	Branch string
+	// Final answer of the agent of the branch (empty if it failed)
+	Answer string
+	// Error the run of the branch failed with, if any
+	Err error
+}
+
+// Base interface for the reducers merging the answers of the branches of a fan-out into one answer
+type FanOutReducer interface {
+	// Merge the outputs of the branches that succeeded (at least one) into one answer to the request
+	Reduce(ctx context.Context, prompt string, outputs []FanOutOutput) (string, error)
+}
+
+// Struct type representing the outcome of a fan-out
+type FanOutResult struct {
+	// Answer merged by the reducer
+	Answer string
+	// Outputs of the branches, in the order of the branches
+	Outputs []FanOutOutput
+}
+
+// Struct type representing a fan-out, which runs several agents (or copies of the same agent with different prompts) on a request concurrently, and merges their answers with a reducer, for ensemble-style answers.
+//
+// Every branch runs on a copy of its agent with an in-memory history, so that the same agent can back several branches.
+type FanOut struct {
+	Branches []FanOutBranch
+	// Reducer merging the answers of the branches (defaults to ConcatReducer)
+	Reducer FanOutReducer
+	// Maximum number of branches running at once (zero means no limit)
+	MaxConcurrency int
+	// Optional callback receiving the events of the runs of the branches, along with their name (called concurrently from the branches)
+	OnEvent func(branch string, event AgentEvent)
+}
+
+// Constructor function for a new FanOut, merging the answers of the branches with the given reducer
+func NewFanOut(reducer FanOutReducer, branches ...FanOutBranch) *FanOut {
+	return &FanOut{Branches: branches, Reducer: reducer}
+}
+
+// Method to run the branches on a request and merge their answers.
+//
+// Failed branches are left out of the merge, and it fails only if every branch fails (or if the context is cancelled).
+func (f *FanOut) Run(ctx context.Context, prompt string) (*FanOutResult, error) {
+	if len(f.Branches) == 0 {
+		return nil, errors.New("no branches are configured for the fan-out")
+	}
+	result := &FanOutResult{Outputs: make([]FanOutOutput, len(f.Branches))}
+	var slots chan struct{}
+	if f.MaxConcurrency > 0 {
+		slots = make(chan struct{}, f.MaxConcurrency)
+	}
+	var wg sync.WaitGroup
+	for i, branch := range f.Branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			result.Outputs[i] = f.runBranch(ctx, i, branch, prompt)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	succeeded := []FanOutOutput{}
+	for _, output := range result.Outputs {
+		if output.Err == nil {
+			succeeded = append(succeeded, output)
+		}
+	}
+	if len(succeeded) == 0 {
+		return nil, fmt.Errorf("every branch failed: %w", result.Outputs[0].Err)
+	}
+	reducer := f.Reducer
+	if reducer == nil {
+		reducer = ConcatReducer{}
+	}
+	answer, err := reducer.Reduce(ctx, prompt, succeeded)
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = answer
+	return result, nil
+}
+
+// Private method running a branch on a copy of its agent, returning its output
+func (f *FanOut) runBranch(ctx context.Context, index int, branch FanOutBranch, prompt string) FanOutOutput {
+	name := branch.Name
+	if name == "" {
+		name = fmt.Sprintf("branch_%d", index+1)
+	}
+	output := FanOutOutput{Branch: name}
+	if branch.Agent == nil {
+		output.Err = fmt.Errorf("branch %s has no agent", name)
+		return output
+	}
+	if branch.Prompt != "" {
+		prompt = branch.Prompt
+	}
+	agent := *branch.Agent
+	agent.Memory = NewInMemoryStore()
+	agent.SessionID = fmt.Sprintf("%s/%s", branch.Agent.SessionID, name)
+	output.Err = agent.RunEventsContext(ctx, prompt, nil, func(event AgentEvent) {
+		if e, ok := event.(StopEvent); ok {
+			output.Answer = e.Reason
+		}
+		if f.OnEvent != nil {
+			f.OnEvent(name, event)
+		}
+	})
+	return output
+}
+
+// Implementation of FanOutReducer concatenating the answers of the branches, each under a heading with the name of its branch
+type ConcatReducer struct{}
+
+// Method concatenating the answers, in the order of the branches
+func (ConcatReducer) Reduce(ctx context.Context, prompt string, outputs []FanOutOutput) (string, error) {
+	sections := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		sections = append(sections, fmt.Sprintf("### %s\n\n%s", output.Branch, output.Answer))
+	}
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// Implementation of FanOutReducer picking the answer given by most branches (the earliest branch wins ties)
+type MajorityVoteReducer struct {
+	// Optional function normalizing the answers before they are compared (defaults to trimming the spaces and ignoring the case)
+	Normalize func(answer string) string
+}
+
+// Method returning the most common answer
+func (m MajorityVoteReducer) Reduce(ctx context.Context, prompt string, outputs []FanOutOutput) (string, error) {
+	normalize := m.Normalize
+	if normalize == nil {
+		normalize = func(answer string) string { return strings.ToLower(strings.TrimSpace(answer)) }
+	}
+	votes := map[string]int{}
+	best := 0
+	for i, output := range outputs {
+		key := normalize(output.Answer)
+		votes[key]++
+		if votes[key] > votes[normalize(outputs[best].Answer)] {
+			best = i
+		}
+	}
+	return outputs[best].Answer, nil
+}
+
+// Struct type representing the choice of the best answer by a JudgeReducer
+type JudgeVerdict struct {
+	Best   int    `json:"best" jsonschema_description:"Number of the best answer, as numbered in the list of answers"`
+	Reason string `json:"reason" jsonschema_description:"Why this answer is the best one"`
+}
+
+// Implementation of FanOutReducer asking an LLM to pick the best answer to the request
+type JudgeReducer struct {
+	Llm *OpenAILLM
+	// Optional criteria the answers are judged on (defaults to correctness, completeness and clarity)
+	Criteria string
+}
+
+// Method returning the answer picked by the LLM (the only answer is returned without asking)
+func (j JudgeReducer) Reduce(ctx context.Context, prompt string, outputs []FanOutOutput) (string, error) {
+	if len(outputs) == 1 {
+		return outputs[0].Answer, nil
+	}
+	if j.Llm == nil {
+		return "", errors.New("no LLM is configured for the judge")
+	}
+	criteria := j.Criteria
+	if criteria == "" {
+		criteria = "correctness, completeness and clarity"
+	}
+	var answers strings.Builder
+	fmt.Fprintf(&answers, "## Request\n\n%s\n\n## Answers\n", prompt)
+	for i, output := range outputs {
+		fmt.Fprintf(&answers, "\n### Answer %d\n\n%s\n", i+1, output.Answer)
+	}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(fmt.Sprintf("You judge the answers several agents gave to the same request, and pick the best one based on %s.", criteria)),
+		openai.UserMessage(answers.String()),
+	}
+	llm := *j.Llm
+	llm.ctx = ctx
+	response, err := OpenAILLMStructuredPredict[JudgeVerdict](&llm, history, "verdict", "Choice of the best answer to the request")
+	if err != nil {
+		return "", err
+	}
+	verdict, ok := response.(JudgeVerdict)
+	if !ok || verdict.Best < 1 || verdict.Best > len(outputs) {
+		return "", errors.New("error while judging the answers: unexpected structured output")
+	}
+	return outputs[verdict.Best-1].Answer, nil
+}
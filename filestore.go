@@ -0,0 +1,226 @@
+package gopheract
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Implementation of HistoryStore writing each session as a JSONL file (one message per line) under a directory.
+//
+// The store has no external dependencies, and the files it writes can be inspected or edited with any text editor.
+type FileStore struct {
+	Dir string
+	// Retention policy applied every time a new session is created
+	Retention RetentionPolicy
+	mu        sync.Mutex
+}
+
+// Constructor function for a new FileStore, creating the directory if it does not exist yet.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// Private method returning the path of the file holding the history of a session
+func (s *FileStore) path(sessionID string) string {
+	return filepath.Join(s.Dir, url.PathEscape(sessionID)+".jsonl")
+}
+
+// Private method reading the messages of a session from its file
+func (s *FileStore) read(sessionID string) ([]*ChatMessage, error) {
+	f, err := os.Open(s.path(sessionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*ChatMessage{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	messages := []*ChatMessage{}
+	decoder := json.NewDecoder(f)
+	for {
+		var message ChatMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, nil
+}
+
+// Append messages to the history of a session
+func (s *FileStore) Append(sessionID string, messages ...*ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, statErr := os.Stat(s.path(sessionID))
+	f, err := os.OpenFile(s.path(sessionID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if errors.Is(statErr, fs.ErrNotExist) && !s.Retention.IsZero() {
+		_, err := s.prune(sessionID)
+		return err
+	}
+	return nil
+}
+
+// List the messages in the history of a session
+func (s *FileStore) List(sessionID string) ([]*ChatMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(sessionID)
+}
+
+// Trim the history of a session, keeping only the newest `keep` messages along with the pinned ones. The file is rewritten atomically.
+func (s *FileStore) Trim(sessionID string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages, err := s.read(sessionID)
+	if err != nil {
+		return err
+	}
+	kept := trimMessages(messages, keep)
+	if len(kept) == len(messages) {
+		return nil
+	}
+	return s.write(sessionID, kept)
+}
+
+// Replace the history of a session with the given messages. The file is rewritten atomically.
+func (s *FileStore) Replace(sessionID string, messages ...*ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, statErr := os.Stat(s.path(sessionID))
+	if err := s.write(sessionID, messages); err != nil {
+		return err
+	}
+	if errors.Is(statErr, fs.ErrNotExist) && !s.Retention.IsZero() {
+		_, err := s.prune(sessionID)
+		return err
+	}
+	return nil
+}
+
+// Private method writing the messages of a session to a temporary file, renamed over the file of the session once complete. The caller must hold the lock.
+func (s *FileStore) write(sessionID string, messages []*ChatMessage) error {
+	tmp, err := os.CreateTemp(s.Dir, ".write-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	encoder := json.NewEncoder(tmp)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(sessionID))
+}
+
+// Clear the history of a session, removing its file
+func (s *FileStore) Clear(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(sessionID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Private method listing the session files, with their last modification time and size
+func (s *FileStore) sessionFiles() ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []SessionInfo{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		sessionID, err := url.PathUnescape(name)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:        sessionID,
+			UpdatedAt: info.ModTime(),
+			Size:      info.Size(),
+		})
+	}
+	return sessions, nil
+}
+
+// List the persisted sessions, from the most recently updated
+func (s *FileStore) ListSessions() ([]SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, err := s.sessionFiles()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		messages, err := s.read(sessions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		sessions[i].MessageCount = len(messages)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Remove the sessions exceeding the retention policy of the store, returning their identifiers
+func (s *FileStore) Prune() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prune("")
+}
+
+// Private method removing the sessions exceeding the retention policy, except for the session `keep`. The caller must hold the lock.
+func (s *FileStore) prune(keep string) ([]string, error) {
+	sessions, err := s.sessionFiles()
+	if err != nil {
+		return nil, err
+	}
+	pruned := sessionsToPrune(sessions, s.Retention, time.Now(), keep)
+	for _, sessionID := range pruned {
+		if err := os.Remove(s.path(sessionID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,47 @@
+package gopheract
+
+import "fmt"
+
+// Interface that history stores can implement to fork sessions more efficiently than by copying their messages one by one
+type SessionForker interface {
+	// Create the session `targetID` with the first `at` messages of the history of `sourceID`
+	Fork(sourceID, targetID string, at int) error
+}
+
+// Function forking a session at a given message index: the new session starts with the first `at` messages of the source one, and the two histories evolve independently from then on, so that alternative approaches can be explored without losing the original trajectory.
+func ForkSession(store HistoryStore, sourceID, targetID string, at int) error {
+	if sourceID == targetID {
+		return fmt.Errorf("cannot fork session %s into itself", sourceID)
+	}
+	source, err := store.List(sourceID)
+	if err != nil {
+		return err
+	}
+	if at < 0 || at > len(source) {
+		return fmt.Errorf("cannot fork session %s at message %d: the session has %d messages", sourceID, at, len(source))
+	}
+	target, err := store.List(targetID)
+	if err != nil {
+		return err
+	}
+	if len(target) > 0 {
+		return fmt.Errorf("cannot fork into session %s: the session already exists", targetID)
+	}
+	if forker, ok := store.(SessionForker); ok {
+		return forker.Fork(sourceID, targetID, at)
+	}
+	copied := make([]*ChatMessage, 0, at)
+	for _, message := range source[:at] {
+		clone := *message
+		copied = append(copied, &clone)
+	}
+	return store.Append(targetID, copied...)
+}
+
+// Method forking a session at a given message index into a new session, returning it
+func (m *SessionManager) Fork(sourceID, targetID string, at int) (*Session, error) {
+	if err := ForkSession(m.Base.Memory, sourceID, targetID, at); err != nil {
+		return nil, err
+	}
+	return m.Open(targetID), nil
+}
@@ -0,0 +1,279 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Struct type representing the parameters of the Read tool
+type ReadParams struct {
+	FilePath string `json:"file_path" description:"Path to the file to read"`
+}
+
+// Struct type representing the parameters of the Write tool
+type WriteParams struct {
+	FilePath string `json:"file_path" description:"Path to the file to write"`
+	Content  string `json:"content" description:"Content to write to the file"`
+}
+
+// Struct type representing the parameters of the Edit tool
+type EditParams struct {
+	FilePath  string `json:"file_path" description:"Path to the file to edit"`
+	OldString string `json:"old_string" description:"String to be replaced"`
+	NewString string `json:"new_string" description:"String to replace with"`
+	Count     int    `json:"count" description:"Number of replacements to make"`
+}
+
+// Struct type representing a filesystem confined to a root directory, on which the Read, Write and Edit tools operate.
+//
+// Every path is resolved against the root (following symlinks), and paths that escape it are rejected.
+type FileSystem struct {
+	// Root directory in which the tools are confined (absolute, with symlinks resolved)
+	Root string
+	// Whether the filesystem is read-only (Write and Edit are rejected)
+	ReadOnly bool
+	// Permissions for newly created files
+	FileMode fs.FileMode
+	// Whether Write and Edit only return the diff of the change, without applying it, unless approved through `Approve`
+	PreviewDiffs bool
+	// Optional callback asked to approve each change before it is applied (the change is discarded if it returns false)
+	Approve func(change FileChange) (bool, error)
+	// Optional backend reading and writing the content of the files instead of the local disk (e.g. the buffers of an editor, unsaved changes included)
+	Backend FileBackend
+}
+
+// Base interface for the backends reading and writing the content of the files of a FileSystem, given their absolute (resolved) path
+type FileBackend interface {
+	ReadFile(path string) (string, error)
+	WriteFile(path string, content string) error
+}
+
+// Struct type representing a change to a file, proposed by the Write or Edit tools
+type FileChange struct {
+	// Absolute path of the file
+	Path string
+	// Content of the file before the change (empty for new files)
+	OldText string
+	// Content of the file after the change
+	NewText string
+	// Unified diff between the old and new content
+	Diff string
+}
+
+// Constructor function for a new FileSystem confined to the given root directory.
+func NewFileSystem(root string) (*FileSystem, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	return &FileSystem{
+		Root:     resolved,
+		FileMode: 0644,
+	}, nil
+}
+
+// Maximum number of broken symlinks followed while resolving a path, as a guard against symlink loops
+const maxSymlinks = 40
+
+// Method to resolve a path (absolute or relative to the root) to an absolute path within the root directory.
+//
+// Symlinks are resolved for the existing part of the path (broken symlinks are followed to their target, which writing to the path would create), and an error is returned if the resulting path lies outside of the root.
+func (f *FileSystem) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("no file path provided")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.Root, path)
+	}
+	path = filepath.Clean(path)
+	// resolve symlinks on the longest existing prefix of the path, so that files yet to be created can be resolved too
+	existing := path
+	missing := []string{}
+	links := 0
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			existing = resolved
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if target, err := os.Readlink(existing); err == nil {
+			// a broken symlink, whose target may lie outside of the root
+			if links++; links > maxSymlinks {
+				return "", fmt.Errorf("too many levels of symbolic links in %s", path)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			existing = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+	resolved := filepath.Join(append([]string{existing}, missing...)...)
+	rel, err := filepath.Rel(f.Root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of the workspace %s", path, f.Root)
+	}
+	return resolved, nil
+}
+
+// Method implementing the Read tool: reads a file within the root directory and returns its content.
+func (f *FileSystem) Read(params ReadParams) (any, error) {
+	path, err := f.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return f.readFile(path)
+}
+
+// Private method reading a file, through the backend if one is set
+func (f *FileSystem) readFile(path string) (string, error) {
+	if f.Backend != nil {
+		return f.Backend.ReadFile(path)
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+// Private method writing a file, through the backend if one is set
+func (f *FileSystem) writeFile(path, content string) error {
+	if f.Backend != nil {
+		return f.Backend.WriteFile(path, content)
+	}
+	return os.WriteFile(path, []byte(content), f.fileMode(path))
+}
+
+// Method implementing the Write tool: writes a file within the root directory.
+func (f *FileSystem) Write(params WriteParams) (any, error) {
+	if f.ReadOnly {
+		return nil, errors.New("the filesystem is read-only")
+	}
+	path, err := f.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	// backends may not report missing files as such, so new files are detected on disk
+	oldContent := ""
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		if oldContent, err = f.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return f.apply(path, oldContent, params.Content)
+}
+
+// Method implementing the Edit tool: replaces occurrences of a string within a file in the root directory.
+func (f *FileSystem) Edit(params EditParams) (any, error) {
+	if f.ReadOnly {
+		return nil, errors.New("the filesystem is read-only")
+	}
+	path, err := f.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	newContent := strings.Replace(content, params.OldString, params.NewString, params.Count)
+	return f.apply(path, content, newContent)
+}
+
+// Private method applying a change to a file, after approval if required.
+//
+// When preview or approval are enabled, the unified diff of the change is returned as the tool result; otherwise, the result is nil as for a plain write.
+func (f *FileSystem) apply(path, oldContent, newContent string) (any, error) {
+	if !f.PreviewDiffs && f.Approve == nil {
+		return nil, f.writeFile(path, newContent)
+	}
+	rel, err := filepath.Rel(f.Root, path)
+	if err != nil {
+		rel = path
+	}
+	change := FileChange{
+		Path:    path,
+		OldText: oldContent,
+		NewText: newContent,
+		Diff:    UnifiedDiff(filepath.ToSlash(rel), oldContent, newContent),
+	}
+	if change.Diff == "" {
+		return "No changes to apply", nil
+	}
+	if f.Approve == nil {
+		return "Proposed change (not applied):\n" + change.Diff, nil
+	}
+	approved, err := f.Approve(change)
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		return "The user rejected the following change, which was not applied:\n" + change.Diff, nil
+	}
+	if err := f.writeFile(path, newContent); err != nil {
+		return nil, err
+	}
+	return "Applied change:\n" + change.Diff, nil
+}
+
+// Private method returning the permissions to write a file with: the current ones if the file exists, the configured ones otherwise
+func (f *FileSystem) fileMode(path string) fs.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	if f.FileMode == 0 {
+		return 0644
+	}
+	return f.FileMode
+}
+
+// Helper method returning the Read, Write and Edit tools operating on the filesystem (only Read if the filesystem is read-only).
+func (f *FileSystem) Tools() []Tool {
+	readTool := ToolDefinition[ReadParams]{
+		Name:        "Read",
+		Description: "Read a file, providing its path as `file_path` (string)",
+		Fn:          f.Read,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+	if f.ReadOnly {
+		return []Tool{readTool}
+	}
+	writeTool := ToolDefinition[WriteParams]{
+		Name:        "Write",
+		Description: "Write a file (providing its path as `file_path` - string) by passing a `content` (string) to write.",
+		Fn:          f.Write,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+	editTool := ToolDefinition[EditParams]{
+		Name:        "Edit",
+		Description: "Edit a file (providing its path as `file_path` - string), by passing the old and new string (`old_string` and `new_string` parameters) and how many times to replace it (the `count` parameter, an integer)",
+		Fn:          f.Edit,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+	return []Tool{readTool, writeTool, editTool}
+}
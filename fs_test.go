@@ -0,0 +1,84 @@
+package gopheract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSystemResolve(t *testing.T) {
+	fsys, err := NewFileSystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fsys.Root
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(root, "dir", "file.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(file, []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"link-in":      filepath.Join(root, "dir"),
+		"link-out":     outside,
+		"relative-out": filepath.Join("..", filepath.Base(outside)),
+		"dangling-in":  filepath.Join(root, "dir", "new.txt"),
+		"dangling-out": filepath.Join(outside, "new.txt"),
+		"loop-a":       filepath.Join(root, "loop-b"),
+		"loop-b":       filepath.Join(root, "loop-a"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		// resolved path, relative to the root (empty when an error is expected)
+		want string
+	}{
+		{"relative file", "dir/file.txt", "dir/file.txt"},
+		{"absolute file", filepath.Join(root, "dir", "file.txt"), "dir/file.txt"},
+		{"root", ".", "."},
+		{"file to be created", "new/sub/file.txt", "new/sub/file.txt"},
+		{"dot segments within the root", "dir/../dir/./file.txt", "dir/file.txt"},
+		{"symlink within the root", "link-in/file.txt", "dir/file.txt"},
+		{"file to be created through a symlink", "link-in/new.txt", "dir/new.txt"},
+		{"broken symlink within the root", "dangling-in", "dir/new.txt"},
+		{"empty path", "", ""},
+		{"parent directory", "..", ""},
+		{"dot segments escaping the root", "dir/../../secret.txt", ""},
+		{"absolute path outside of the root", filepath.Join(outside, "secret.txt"), ""},
+		{"sibling with the root as prefix", root + "-evil/file.txt", ""},
+		{"symlink outside of the root", "link-out/secret.txt", ""},
+		{"file to be created through a symlink outside of the root", "link-out/new/file.txt", ""},
+		{"relative symlink outside of the root", "relative-out/secret.txt", ""},
+		{"broken symlink outside of the root", "dangling-out", ""},
+		{"symlink loop", "loop-a", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := fsys.Resolve(test.path)
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("resolved %q to %s, want an error", test.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(test.want)); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Error wrapped by the errors of the runs whose prompt or answer was blocked by a guardrail
+var ErrGuardrailBlocked = errors.New("the content was blocked by a guardrail")
+
+// Type representing what a guardrail does with the content it checked
+type GuardrailAction string
+
+const (
+	// The content passes the guardrail unchanged
+	GuardrailAllow GuardrailAction = "allow"
+	// The content is blocked: prompts and answers fail the run, tool calls are not executed
+	GuardrailBlock GuardrailAction = "block"
+	// The content is replaced with the one of the verdict
+	GuardrailRewrite GuardrailAction = "rewrite"
+	// The content passes only if the approval callback of the guardrails approves it (it is blocked if there is none)
+	GuardrailRequireApproval GuardrailAction = "require_approval"
+)
+
+// Type representing the content a guardrail is attached to
+type GuardrailStage string
+
+const (
+	GuardrailStagePrompt   GuardrailStage = "prompt"
+	GuardrailStageToolArgs GuardrailStage = "tool_args"
+	GuardrailStageAnswer   GuardrailStage = "answer"
+)
+
+// Struct type representing the verdict of a guardrail on a content
+type GuardrailVerdict struct {
+	// What to do with the content (an empty action allows it)
+	Action GuardrailAction `json:"action"`
+	// Why the content violates the guardrail
+	Reason string `json:"reason,omitempty"`
+	// Content replacing the checked one, for the rewrite action
+	Content string `json:"content,omitempty"`
+}
+
+// Base interface for the guardrails checking the prompts, the arguments of the tool calls and the final answers of the runs of an agent
+type Guardrail interface {
+	// Name of the guardrail, reported in the events of its violations
+	Name() string
+	// Check a content, returning the verdict of the guardrail on it (an error fails the run)
+	Check(ctx context.Context, content string) (GuardrailVerdict, error)
+}
+
+// Struct type representing the guardrails of an agent, by the content they check.
+//
+// The guardrails of a stage run in order, each one checking the content left by the previous ones, and every violation is emitted as a GuardrailEvent.
+type Guardrails struct {
+	// Guardrails checking the prompt of every run, before it is added to the history
+	Prompt []Guardrail
+	// Guardrails checking the arguments of every tool call (as a JSON object), before it is executed
+	ToolArgs []Guardrail
+	// Guardrails checking the final answer of every run, before it is returned
+	Answer []Guardrail
+	// Optional callback asked to approve the contents for which a guardrail requires approval (without it, such contents are blocked)
+	Approve func(event GuardrailEvent) (bool, error)
+}
+
+// Private method returning the guardrails attached to a stage
+func (g *Guardrails) stage(stage GuardrailStage) []Guardrail {
+	switch stage {
+	case GuardrailStagePrompt:
+		return g.Prompt
+	case GuardrailStageToolArgs:
+		return g.ToolArgs
+	case GuardrailStageAnswer:
+		return g.Answer
+	}
+	return nil
+}
+
+// Private method running the guardrails of the stage of an event on its content, returning the content to use (rewritten by the guardrails, if any) along with the events of the violations.
+//
+// When the content is blocked, the returned error wraps ErrGuardrailBlocked.
+func (g *Guardrails) check(ctx context.Context, event GuardrailEvent) (string, []GuardrailEvent, error) {
+	content := event.Content
+	if g == nil {
+		return content, nil, nil
+	}
+	violations := []GuardrailEvent{}
+	for _, guardrail := range g.stage(event.Stage) {
+		verdict, err := guardrail.Check(ctx, content)
+		if err != nil {
+			return "", violations, fmt.Errorf("error while running guardrail %s: %w", guardrail.Name(), err)
+		}
+		if verdict.Action == "" || verdict.Action == GuardrailAllow {
+			continue
+		}
+		violation := event
+		violation.Guardrail = guardrail.Name()
+		violation.Verdict = verdict
+		violation.Content = content
+		switch verdict.Action {
+		case GuardrailBlock:
+		case GuardrailRewrite:
+			content = verdict.Content
+		case GuardrailRequireApproval:
+			if g.Approve != nil {
+				if violation.Approved, err = g.Approve(violation); err != nil {
+					return "", violations, err
+				}
+			}
+		default:
+			return "", violations, fmt.Errorf("unknown action of guardrail %s: %s", guardrail.Name(), verdict.Action)
+		}
+		violations = append(violations, violation)
+		if violation.Blocked() {
+			return "", violations, fmt.Errorf("%w: %s (%s)", ErrGuardrailBlocked, violation.reason(), guardrail.Name())
+		}
+	}
+	return content, violations, nil
+}
+
+// Implementation of Guardrail matching a regular expression, e.g. to block a forbidden command in the arguments of the tool calls or to remove internal hostnames from the answers
+type RegexGuardrail struct {
+	GuardrailName string
+	Pattern       *regexp.Regexp
+	// Action taken when the content matches (the rewrite action replaces the matches)
+	Action GuardrailAction
+	// Optional reason reported for the matches (defaults to "matches <name>")
+	Reason string
+	// Text replacing the matches, for the rewrite action (defaults to "[REMOVED:<name>]")
+	Replacement string
+}
+
+// Constructor function for a new RegexGuardrail, given its name, the regular expression matching the violations and the action taken on them
+func NewRegexGuardrail(name, pattern string, action GuardrailAction) (*RegexGuardrail, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for guardrail %s: %w", name, err)
+	}
+	return &RegexGuardrail{GuardrailName: name, Pattern: re, Action: action}, nil
+}
+
+// Name of the guardrail
+func (g *RegexGuardrail) Name() string {
+	return g.GuardrailName
+}
+
+// Check whether a content matches the regular expression
+func (g *RegexGuardrail) Check(ctx context.Context, content string) (GuardrailVerdict, error) {
+	if !g.Pattern.MatchString(content) {
+		return GuardrailVerdict{Action: GuardrailAllow}, nil
+	}
+	verdict := GuardrailVerdict{Action: g.Action, Reason: g.Reason}
+	if verdict.Reason == "" {
+		verdict.Reason = "matches " + g.GuardrailName
+	}
+	if g.Action == GuardrailRewrite {
+		replacement := g.Replacement
+		if replacement == "" {
+			replacement = fmt.Sprintf("[REMOVED:%s]", g.GuardrailName)
+		}
+		verdict.Content = g.Pattern.ReplaceAllLiteralString(content, replacement)
+	}
+	return verdict, nil
+}
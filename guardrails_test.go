@@ -0,0 +1,58 @@
+package gopheract
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Implementation of Guardrail returning the same verdict and error for every content
+type staticGuardrail struct {
+	verdict GuardrailVerdict
+	err     error
+}
+
+func (g staticGuardrail) Name() string { return "static" }
+
+func (g staticGuardrail) Check(ctx context.Context, content string) (GuardrailVerdict, error) {
+	return g.verdict, g.err
+}
+
+type echoParams struct {
+	Text string `json:"text"`
+}
+
+func TestCallToolBlockedByGuardrail(t *testing.T) {
+	tests := []struct {
+		name      string
+		guardrail staticGuardrail
+		reason    string
+	}{
+		{"blocking verdict", staticGuardrail{verdict: GuardrailVerdict{Action: GuardrailBlock, Reason: "forbidden path"}}, "forbidden path"},
+		{"error wrapping ErrGuardrailBlocked", staticGuardrail{err: fmt.Errorf("custom check: %w", ErrGuardrailBlocked)}, "custom check"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executed := false
+			agent := &OpenAIReActAgent{
+				Llm: &OpenAILLM{},
+				Tools: []Tool{ToolDefinition[echoParams]{Name: "Echo", Fn: func(params echoParams) (any, error) {
+					executed = true
+					return params.Text, nil
+				}}},
+				Guardrails: &Guardrails{ToolArgs: []Guardrail{test.guardrail}},
+			}
+			result, err := agent.callTool(&ToolCall{Name: "Echo", Args: []ToolCallArgs{{ParameterValue: `{"text": "hello"}`}}}, "call_1", &runToolCalls{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if executed {
+				t.Error("the blocked call was executed")
+			}
+			if text, _ := result.result.(string); !strings.Contains(text, test.reason) {
+				t.Errorf("got result %v, want the reason %q", result.result, test.reason)
+			}
+		})
+	}
+}
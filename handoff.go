@@ -0,0 +1,228 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Name of the Handoff tool
+const HandoffToolName = "Handoff"
+
+// Struct type representing the transfer of the conversation to another agent.
+//
+// When a tool returns a Handoff, the run of the agent ends after the tool calls of the step, with a HandoffEvent instead of a StopEvent.
+type Handoff struct {
+	// Name of the agent taking over
+	Agent string `json:"agent"`
+	// Context passed along to the agent taking over (e.g. what the user wants and what has been done so far)
+	Context string `json:"context"`
+	Reason  string `json:"reason"`
+}
+
+// Method returning the result of the tool call reported to the model
+func (h Handoff) String() string {
+	return fmt.Sprintf("Transferred the conversation to %s", h.Agent)
+}
+
+// Struct type representing a reference to an agent, returned by a tool to have that agent continue the conversation, so that tools can make routing decisions.
+//
+// Unlike a Handoff, which ends the run so that an orchestrator starts the next agent, the referenced agent takes over the run right away, continuing the same chat history with its own model, system prompt and tools. Its run is nested in the one of the agent, and is subject to the same limits (see RunLimits): an agent cannot take over a conversation it transferred.
+type AgentRef struct {
+	// Name of the agent, reported in the TransferEvent
+	Name  string
+	Agent *OpenAIReActAgent
+	// Why the agent takes over
+	Reason string
+}
+
+// Method returning the result of the tool call reported to the model
+func (r AgentRef) String() string {
+	return fmt.Sprintf("Transferred the conversation to %s", r.Name)
+}
+
+// Private function returning the first agent reference among the results of the tool calls of a step, if any
+func findAgentRef(results []toolCallResult) (AgentRef, bool) {
+	for _, res := range results {
+		switch ref := res.result.(type) {
+		case AgentRef:
+			return ref, ref.Agent != nil
+		case *AgentRef:
+			if ref != nil && ref.Agent != nil {
+				return *ref, true
+			}
+		}
+	}
+	return AgentRef{}, false
+}
+
+// Private method returning the copy of the referenced agent continuing the conversation of an agent: it works on the same chat history, and its events and token usage are reported by the run of the agent
+func (r AgentRef) continuation(from *OpenAIReActAgent) *OpenAIReActAgent {
+	next := *r.Agent
+	next.Memory = from.Memory
+	next.SessionID = from.SessionID
+	next.OnRunReport = nil
+	next.Notifier = nil
+	next.EventExporters = nil
+	next.Tracer = nil
+	next.ExtractFacts = false
+	next.continued = true
+	if r.Name != "" {
+		next.Name = r.Name
+	}
+	return &next
+}
+
+// Struct type representing the parameters of the Handoff tool
+type HandoffParams struct {
+	Agent   string `json:"agent" description:"Name of the agent to transfer the conversation to"`
+	Context string `json:"context" description:"Everything the agent needs to take over, since it does not see the conversation: what the user wants, what has been done so far and what is left"`
+	Reason  string `json:"reason" description:"Why the agent is better suited to continue"`
+}
+
+// Struct type representing a tool with which an agent transfers the conversation to another agent, better suited to continue it
+type HandoffTool struct {
+	// Agents the conversation can be transferred to, with their description
+	Agents []Specialist
+}
+
+// Method to transfer the conversation to one of the agents, returning the Handoff ending the run
+func (h *HandoffTool) Execute(params HandoffParams) (any, error) {
+	if !slices.ContainsFunc(h.Agents, func(agent Specialist) bool { return agent.Name == params.Agent }) {
+		return nil, fmt.Errorf("unknown agent %s (available agents: %s)", params.Agent, strings.Join(specialistNames(h.Agents), ", "))
+	}
+	if strings.TrimSpace(params.Context) == "" {
+		return nil, errors.New("no context provided")
+	}
+	return Handoff(params), nil
+}
+
+// Helper method to expose the Handoff tool as a tool definition that can be passed to an agent.
+func (h *HandoffTool) AsTool() ToolDefinition[HandoffParams] {
+	var agents strings.Builder
+	for _, agent := range h.Agents {
+		fmt.Fprintf(&agents, "%s (%s); ", agent.Name, agent.Description)
+	}
+	return ToolDefinition[HandoffParams]{
+		Name:        HandoffToolName,
+		Description: fmt.Sprintf("Transfer the conversation to another agent better suited to continue it, ending your turn, by providing the name of the agent (`agent` parameter - string, one of: %s), the context it needs to take over, since it does not see the conversation (`context` parameter - string) and the reason of the transfer (`reason` parameter - string).", strings.TrimSuffix(agents.String(), "; ")),
+		Fn:          h.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+}
+
+// Private function returning the names of specialists
+func specialistNames(specialists []Specialist) []string {
+	names := make([]string, 0, len(specialists))
+	for _, specialist := range specialists {
+		names = append(names, specialist.Name)
+	}
+	return names
+}
+
+// Struct type representing the outcome of a conversation handled by a swarm
+type SwarmResult struct {
+	// Name of the agent that answered
+	Agent  string
+	Answer string
+	// Handoffs between the agents, in order
+	Handoffs []Handoff
+}
+
+// Struct type representing a swarm of agents which transfer the conversation to each other with the Handoff tool, until one of them answers.
+//
+// The conversation starts with the first agent. Every agent is given a Handoff tool listing the other agents, and the agent taking over receives the request of the user along with the context passed by the previous one.
+type Swarm struct {
+	Agents []Specialist
+	// Maximum number of handoffs in a conversation (defaults to 5)
+	MaxHandoffs int
+	// Optional callback receiving the events of the runs of the agents (HandoffEvent included), along with their name
+	OnEvent func(agent string, event AgentEvent)
+}
+
+// Constructor function for a new Swarm, starting the conversations with the first agent
+func NewSwarm(agents ...Specialist) *Swarm {
+	return &Swarm{Agents: agents}
+}
+
+// Method to handle a request, running the agents the conversation is transferred to until one of them answers
+func (s *Swarm) Run(ctx context.Context, prompt string) (*SwarmResult, error) {
+	if len(s.Agents) == 0 {
+		return nil, errors.New("no agents are configured for the swarm")
+	}
+	maxHandoffs := s.MaxHandoffs
+	if maxHandoffs <= 0 {
+		maxHandoffs = 5
+	}
+	result := &SwarmResult{}
+	current := s.Agents[0]
+	request := prompt
+	for {
+		handoff, answered, err := s.runAgent(ctx, current, request)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s failed: %w", current.Name, err)
+		}
+		if handoff == nil {
+			result.Agent = current.Name
+			result.Answer = answered
+			return result, nil
+		}
+		result.Handoffs = append(result.Handoffs, *handoff)
+		if len(result.Handoffs) > maxHandoffs {
+			return nil, fmt.Errorf("the conversation was transferred more than %d times", maxHandoffs)
+		}
+		index := slices.IndexFunc(s.Agents, func(agent Specialist) bool { return agent.Name == handoff.Agent })
+		if index < 0 {
+			return nil, fmt.Errorf("agent %s handed off to an unknown agent %s", current.Name, handoff.Agent)
+		}
+		request = fmt.Sprintf("%s\n\n## Context from %s\n\n%s", prompt, current.Name, handoff.Context)
+		current = s.Agents[index]
+	}
+}
+
+// Private method running an agent of the swarm, with a Handoff tool listing the other agents, returning its handoff or its answer
+func (s *Swarm) runAgent(ctx context.Context, member Specialist, prompt string) (*Handoff, string, error) {
+	if member.Agent == nil {
+		return nil, "", fmt.Errorf("agent %s has no agent configured", member.Name)
+	}
+	others := slices.DeleteFunc(slices.Clone(s.Agents), func(agent Specialist) bool { return agent.Name == member.Name })
+	agent := *member.Agent
+	agent.Name = member.Name
+	agent.Tools = slices.Clone(agent.Tools)
+	if len(others) > 0 {
+		handoffTool := &HandoffTool{Agents: others}
+		agent.Tools = append(agent.Tools, handoffTool.AsTool())
+	}
+	var handoff *Handoff
+	var answer string
+	err := agent.RunEventsContext(ctx, prompt, nil, func(event AgentEvent) {
+		switch e := event.(type) {
+		case HandoffEvent:
+			handoff = &e.Handoff
+		case StopEvent:
+			answer = e.Reason
+		}
+		if s.OnEvent != nil {
+			s.OnEvent(member.Name, event)
+		}
+	})
+	return handoff, answer, err
+}
+
+// Private function returning the first handoff among the results of the tool calls of a step, if any
+func findHandoff(results []toolCallResult) (Handoff, bool) {
+	for _, res := range results {
+		switch handoff := res.result.(type) {
+		case Handoff:
+			return handoff, true
+		case *Handoff:
+			if handoff != nil {
+				return *handoff, true
+			}
+		}
+	}
+	return Handoff{}, false
+}
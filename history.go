@@ -0,0 +1,194 @@
+package gopheract
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Base interface for the stores that hold the chat history of an agent, keyed by session.
+//
+// Swapping the store allows persisting conversations to different backends without touching the agent loop.
+type HistoryStore interface {
+	// Append messages to the history of a session
+	Append(sessionID string, messages ...*ChatMessage) error
+	// List the messages in the history of a session, from the oldest to the newest
+	List(sessionID string) ([]*ChatMessage, error)
+	// Trim the history of a session, keeping only the newest `keep` messages (pinned messages are always kept, and do not count towards `keep`)
+	Trim(sessionID string, keep int) error
+	// Clear the history of a session
+	Clear(sessionID string) error
+	// Replace the history of a session with the given messages, atomically (the history is left unchanged if it fails)
+	Replace(sessionID string, messages ...*ChatMessage) error
+}
+
+// Implementation of HistoryStore that keeps the chat history in memory
+type InMemoryStore struct {
+	// Retention policy applied every time a new session is created
+	Retention RetentionPolicy
+	mu        sync.RWMutex
+	sessions  map[string][]*ChatMessage
+	updated   map[string]time.Time
+}
+
+// Constructor function for a new, empty InMemoryStore
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{sessions: map[string][]*ChatMessage{}}
+}
+
+// Append messages to the history of a session
+func (s *InMemoryStore) Append(sessionID string, messages ...*ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = map[string][]*ChatMessage{}
+	}
+	if s.updated == nil {
+		s.updated = map[string]time.Time{}
+	}
+	_, exists := s.sessions[sessionID]
+	s.sessions[sessionID] = append(s.sessions[sessionID], messages...)
+	s.updated[sessionID] = time.Now()
+	if !exists && !s.Retention.IsZero() {
+		s.prune(sessionID)
+	}
+	return nil
+}
+
+// List the messages in the history of a session. The returned slice is a copy, and can be modified safely.
+func (s *InMemoryStore) List(sessionID string) ([]*ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	messages := s.sessions[sessionID]
+	listed := make([]*ChatMessage, len(messages))
+	copy(listed, messages)
+	return listed, nil
+}
+
+// Trim the history of a session, keeping only the newest `keep` messages
+func (s *InMemoryStore) Trim(sessionID string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sessionID]; ok {
+		s.sessions[sessionID] = trimMessages(s.sessions[sessionID], keep)
+	}
+	return nil
+}
+
+// Clear the history of a session
+func (s *InMemoryStore) Clear(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	delete(s.updated, sessionID)
+	return nil
+}
+
+// Replace the history of a session with the given messages
+func (s *InMemoryStore) Replace(sessionID string, messages ...*ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = map[string][]*ChatMessage{}
+	}
+	if s.updated == nil {
+		s.updated = map[string]time.Time{}
+	}
+	_, exists := s.sessions[sessionID]
+	s.sessions[sessionID] = append([]*ChatMessage{}, messages...)
+	s.updated[sessionID] = time.Now()
+	if !exists && !s.Retention.IsZero() {
+		s.prune(sessionID)
+	}
+	return nil
+}
+
+// List the sessions held in memory, from the most recently updated
+func (s *InMemoryStore) ListSessions() ([]SessionInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := s.sessionInfos()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Private method summarizing the sessions held in memory. The caller must hold the lock.
+func (s *InMemoryStore) sessionInfos() []SessionInfo {
+	sessions := make([]SessionInfo, 0, len(s.sessions))
+	for sessionID, messages := range s.sessions {
+		var size int64
+		for _, message := range messages {
+			size += int64(len(message.Content))
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:           sessionID,
+			UpdatedAt:    s.updated[sessionID],
+			MessageCount: len(messages),
+			Size:         size,
+		})
+	}
+	return sessions
+}
+
+// Remove the sessions exceeding the retention policy of the store, returning their identifiers
+func (s *InMemoryStore) Prune() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prune(""), nil
+}
+
+// Private method removing the sessions exceeding the retention policy, except for the session `keep`. The caller must hold the lock.
+func (s *InMemoryStore) prune(keep string) []string {
+	pruned := sessionsToPrune(s.sessionInfos(), s.Retention, time.Now(), keep)
+	for _, sessionID := range pruned {
+		delete(s.sessions, sessionID)
+		delete(s.updated, sessionID)
+	}
+	return pruned
+}
+
+// Create the session `targetID` with copies of the first `at` messages of the history of `sourceID`, so that changes to the messages of either history (e.g. pinning them) do not affect the other one
+func (s *InMemoryStore) Fork(sourceID, targetID string, at int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := s.sessions[sourceID]
+	if at < 0 || at > len(messages) {
+		return fmt.Errorf("cannot fork session %s at message %d: the session has %d messages", sourceID, at, len(messages))
+	}
+	if len(s.sessions[targetID]) > 0 {
+		return fmt.Errorf("cannot fork into session %s: the session already exists", targetID)
+	}
+	if s.sessions == nil {
+		s.sessions = map[string][]*ChatMessage{}
+	}
+	if s.updated == nil {
+		s.updated = map[string]time.Time{}
+	}
+	copied := make([]*ChatMessage, 0, at)
+	for _, message := range messages[:at] {
+		clone := *message
+		copied = append(copied, &clone)
+	}
+	s.sessions[targetID] = copied
+	s.updated[targetID] = time.Now()
+	return nil
+}
+
+// Private function returning the newest `keep` messages, along with the pinned ones, in their original order
+func trimMessages(messages []*ChatMessage, keep int) []*ChatMessage {
+	kept := []*ChatMessage{}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Pinned || keep > 0 {
+			kept = append(kept, messages[i])
+			if !messages[i].Pinned {
+				keep--
+			}
+		}
+	}
+	slices.Reverse(kept)
+	return kept
+}
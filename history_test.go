@@ -0,0 +1,88 @@
+package gopheract
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHistoryStoreReplace(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := []struct {
+		name  string
+		store HistoryStore
+	}{
+		{"in memory", NewInMemoryStore()},
+		{"file", fileStore},
+	}
+	for _, test := range stores {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.store.Append("session", NewChatMessage("user", "one"), NewChatMessage("assistant", "two"), NewChatMessage("user", "three")); err != nil {
+				t.Fatal(err)
+			}
+			if err := test.store.Replace("session", NewChatMessage("user", "summary"), NewChatMessage("user", "three")); err != nil {
+				t.Fatal(err)
+			}
+			if err := test.store.Replace("new", NewChatMessage("user", "created")); err != nil {
+				t.Fatal(err)
+			}
+			for sessionID, want := range map[string][]string{"session": {"summary", "three"}, "new": {"created"}} {
+				messages, err := test.store.List(sessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(messages) != len(want) {
+					t.Fatalf("session %s: got %d messages, want %d", sessionID, len(messages), len(want))
+				}
+				for i, message := range messages {
+					if message.Content != want[i] {
+						t.Errorf("session %s, message %d: got %q, want %q", sessionID, i, message.Content, want[i])
+					}
+				}
+			}
+		})
+	}
+	// the temporary files are renamed or removed
+	entries, err := os.ReadDir(fileStore.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d files in the store directory, want 2", len(entries))
+	}
+}
+
+func TestInMemoryStoreFork(t *testing.T) {
+	store := &InMemoryStore{}
+	if err := store.Fork("missing", "target", 0); err != nil {
+		t.Fatalf("forking an empty session on a zero-value store: %v", err)
+	}
+	if err := store.Append("source", NewChatMessage("user", "one"), NewChatMessage("assistant", "two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Fork("source", "fork", 1); err != nil {
+		t.Fatal(err)
+	}
+	forked, err := store.List("fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forked[0].Pinned = true
+	source, err := store.List("source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source[0].Pinned {
+		t.Error("pinning a message of the fork pinned the message of the source")
+	}
+	for _, at := range []int{-1, 3} {
+		if err := store.Fork("source", "other", at); err == nil {
+			t.Errorf("forked at message %d of a session of 2 messages", at)
+		}
+	}
+	if err := store.Fork("source", "fork", 2); err == nil {
+		t.Error("forked into an existing session")
+	}
+}
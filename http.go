@@ -0,0 +1,238 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Struct type representing the parameters of the HTTP request tool
+type HTTPParams struct {
+	Method  string            `json:"method" description:"HTTP method to use (e.g. GET, POST, PUT, DELETE)"`
+	URL     string            `json:"url" description:"URL to send the request to"`
+	Headers map[string]string `json:"headers" description:"Additional headers to send with the request"`
+	Body    string            `json:"body" description:"Body of the request (leave empty for no body)"`
+	Auth    string            `json:"auth" description:"Name of the authentication profile to use (leave empty for no authentication)"`
+}
+
+// Struct type representing a named authentication profile for the HTTP tool.
+//
+// Credentials are configured by the host application and never exposed to the model, which can only refer to the profile by name. Since the model also chooses the URL, the credentials are only sent to the hosts of the profile, and they are dropped when a redirect leads to another host.
+type HTTPAuthProfile struct {
+	// Type of authentication: `bearer`, `basic` or `api_key`
+	Type string
+	// Token used with the `bearer` type
+	Token string
+	// Username used with the `basic` type
+	Username string
+	// Password used with the `basic` type
+	Password string
+	// Name of the header carrying the key with the `api_key` type (defaults to `X-API-Key`)
+	Header string
+	// Key used with the `api_key` type
+	Key string
+	// Hosts the credentials can be sent to, e.g. `api.github.com`, or `*.example.com` for the subdomains of a domain (a profile without hosts cannot be used)
+	AllowedHosts []string
+}
+
+// Helper method to check whether the credentials of the profile can be sent to a URL
+func (p HTTPAuthProfile) Allows(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Private method returning the headers carrying the credentials of the profile
+func (p HTTPAuthProfile) headers() []string {
+	if p.Type != "api_key" {
+		return []string{"Authorization"}
+	}
+	if p.Header == "" {
+		return []string{"X-API-Key"}
+	}
+	return []string{p.Header}
+}
+
+// Helper method to apply the authentication profile to an HTTP request
+func (p HTTPAuthProfile) Apply(req *http.Request) error {
+	switch p.Type {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	case "basic":
+		req.SetBasicAuth(p.Username, p.Password)
+	case "api_key":
+		header := p.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		req.Header.Set(header, p.Key)
+	default:
+		return fmt.Errorf("unsupported authentication type: %s", p.Type)
+	}
+	return nil
+}
+
+// Struct type representing the result of a request performed by the HTTP tool
+type HTTPResult struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Truncated  bool              `json:"truncated"`
+}
+
+// Struct type representing a generic HTTP request tool, with named authentication profiles and limits on response size and request duration.
+type HTTPTool struct {
+	// HTTP client used to perform the requests
+	Client *http.Client
+	// Authentication profiles, keyed by name
+	AuthProfiles map[string]HTTPAuthProfile
+	// Maximum number of bytes of the response body returned to the agent (0 means no limit)
+	MaxResponseBytes int64
+	// Allowed HTTP methods (empty means any method is allowed)
+	AllowedMethods []string
+}
+
+// Constructor function for a new HTTPTool, given the authentication profiles it can use. Requests time out after 30 seconds and responses are truncated at 1MB.
+func NewHTTPTool(authProfiles map[string]HTTPAuthProfile) *HTTPTool {
+	return &HTTPTool{
+		Client:           &http.Client{Timeout: 30 * time.Second},
+		AuthProfiles:     authProfiles,
+		MaxResponseBytes: 1 << 20,
+	}
+}
+
+// Method to perform an HTTP request, returning an `HTTPResult`
+func (h *HTTPTool) Execute(params HTTPParams) (any, error) {
+	if params.URL == "" {
+		return nil, errors.New("no url provided")
+	}
+	method := strings.ToUpper(params.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if len(h.AllowedMethods) > 0 && !containsFold(h.AllowedMethods, method) {
+		return nil, fmt.Errorf("http method %s is not allowed", method)
+	}
+	var body io.Reader
+	if params.Body != "" {
+		body = strings.NewReader(params.Body)
+	}
+	req, err := http.NewRequest(method, params.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range params.Headers {
+		req.Header.Set(k, v)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if params.Auth != "" {
+		profile, ok := h.AuthProfiles[params.Auth]
+		if !ok {
+			return nil, fmt.Errorf("authentication profile %s not found", params.Auth)
+		}
+		if !profile.Allows(req.URL) {
+			return nil, fmt.Errorf("authentication profile %s cannot be used with host %s", params.Auth, req.URL.Hostname())
+		}
+		if err := profile.Apply(req); err != nil {
+			return nil, err
+		}
+		client = withoutCredentialsOnRedirect(client, profile)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reader := io.Reader(resp.Body)
+	if h.MaxResponseBytes > 0 {
+		reader = io.LimitReader(resp.Body, h.MaxResponseBytes+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	truncated := false
+	if h.MaxResponseBytes > 0 && int64(len(content)) > h.MaxResponseBytes {
+		content = content[:h.MaxResponseBytes]
+		truncated = true
+	}
+	headers := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	return HTTPResult{
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       string(content),
+		Truncated:  truncated,
+	}, nil
+}
+
+// Private function returning a copy of a client that drops the credentials of a profile when a redirect leads to another host (Go keeps custom headers, such as the ones of API keys, across hosts)
+func withoutCredentialsOnRedirect(client *http.Client, profile HTTPAuthProfile) *http.Client {
+	checkRedirect := client.CheckRedirect
+	copied := *client
+	copied.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) || !profile.Allows(req.URL) {
+			for _, header := range profile.headers() {
+				req.Header.Del(header)
+			}
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		// the default policy of the http package
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &copied
+}
+
+// Helper method to expose the HTTP tool as a tool definition that can be passed to an agent.
+func (h *HTTPTool) AsTool() ToolDefinition[HTTPParams] {
+	profiles := make([]string, 0, len(h.AuthProfiles))
+	for name := range h.AuthProfiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	desc := "Send an HTTP request by providing the method (`method` parameter - string), the URL (`url` parameter - string), optional headers (`headers` parameter - object of strings) and an optional body (`body` parameter - string)"
+	if len(profiles) > 0 {
+		desc += fmt.Sprintf(". To authenticate, pass the name of an authentication profile as `auth` (string, one of: %s)", strings.Join(profiles, ", "))
+	}
+	return ToolDefinition[HTTPParams]{
+		Name:        "HTTP",
+		Description: desc,
+		Fn:          h.Execute,
+		Cost:        ToolCostMedium,
+		Latency:     ToolLatencyModerate,
+	}
+}
+
+// Private function to check whether a slice of strings contains a value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package gopheract
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTTPAuthProfileAllows(t *testing.T) {
+	profile := HTTPAuthProfile{AllowedHosts: []string{"api.github.com", "*.example.com"}}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.github.com/repos", true},
+		{"https://API.GitHub.com:443/repos", true},
+		{"https://github.com/", false},
+		{"https://api.github.com.attacker.net/", false},
+		{"https://docs.example.com/", true},
+		{"https://a.b.example.com/", true},
+		{"https://example.com/", false},
+		{"https://notexample.com/", false},
+		{"https://attacker.net/?host=api.github.com", false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := profile.Allows(u); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+	if (HTTPAuthProfile{}).Allows(&url.URL{Host: "api.github.com"}) {
+		t.Error("a profile without hosts allows a host")
+	}
+}
+
+func TestHTTPToolCredentials(t *testing.T) {
+	received := make(chan http.Header, 2)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			// the same server under another host name
+			http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+			return
+		}
+		received <- r.Header.Clone()
+	}))
+	defer server.Close()
+	tool := NewHTTPTool(map[string]HTTPAuthProfile{
+		"key":    {Type: "api_key", Header: "X-Token", Key: "secret", AllowedHosts: []string{"127.0.0.1"}},
+		"bearer": {Type: "bearer", Token: "secret", AllowedHosts: []string{"127.0.0.1"}},
+	})
+
+	if _, err := tool.Execute(HTTPParams{URL: server.URL, Auth: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := (<-received).Get("X-Token"); got != "secret" {
+		t.Errorf("allowed host got key %q", got)
+	}
+
+	if _, err := tool.Execute(HTTPParams{URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1), Auth: "key"}); err == nil {
+		t.Error("expected the profile to be rejected for another host")
+	}
+
+	for _, auth := range []string{"key", "bearer"} {
+		if _, err := tool.Execute(HTTPParams{URL: server.URL + "/redirect", Auth: auth}); err != nil {
+			t.Fatal(err)
+		}
+		headers := <-received
+		if headers.Get("X-Token") != "" || headers.Get("Authorization") != "" {
+			t.Errorf("%s: the credentials followed the redirect to another host: %v", auth, headers)
+		}
+	}
+}
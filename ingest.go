@@ -0,0 +1,222 @@
+package gopheract
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Number of files ingested between two flushes of the stores buffering their changes, so that an interrupted ingestion keeps most of the files ingested so far
+const ingestFlushInterval = 50
+
+// Struct type representing a file ingested into a knowledge base, with the hash of its content and the documents loaded from it
+type IngestedFile struct {
+	Path string `json:"path"`
+	// SHA-256 hash of the content of the file, hex encoded
+	Hash        string    `json:"hash"`
+	DocumentIDs []string  `json:"document_ids"`
+	IngestedAt  time.Time `json:"ingested_at"`
+}
+
+// Interface that the vector stores can implement to remember the files ingested into them, so that ingesting a directory again only processes the files that changed
+type IngestionIndex interface {
+	// List the files ingested into the store, by path
+	IngestedFiles(ctx context.Context) (map[string]IngestedFile, error)
+	// Record a file ingested into the store, replacing the previous record of the same path
+	RecordIngestedFile(ctx context.Context, file IngestedFile) error
+	// Forget a file ingested into the store
+	ForgetIngestedFile(ctx context.Context, path string) error
+}
+
+// Struct type representing the outcome of the ingestion of a directory into a knowledge base, with the paths of the files by outcome
+type IngestReport struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	// files ingested before that are no longer there, whose chunks were deleted
+	Removed []string `json:"removed"`
+	// files in a format that is not supported
+	Skipped []string `json:"skipped"`
+	// files that could not be loaded, with the error
+	Failed map[string]error `json:"-"`
+	// number of chunks added to the store
+	Chunks int `json:"chunks"`
+}
+
+// Method returning a one-line summary of the ingestion
+func (r IngestReport) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d unchanged, %d removed, %d skipped, %d failed (%d chunks stored)", len(r.Added), len(r.Updated), len(r.Unchanged), len(r.Removed), len(r.Skipped), len(r.Failed), r.Chunks)
+}
+
+// Method to ingest a file, or the files of a directory and its subdirectories, into the knowledge base: they are loaded, chunked, embedded and stored file by file, so that an interrupted ingestion keeps the files ingested so far.
+//
+// Files are identified by their absolute path, so that ingesting the same directory through a relative or an absolute path has the same outcome.
+//
+// If the store implements IngestionIndex, the ingestion is incremental: the files whose content did not change since they were last ingested are skipped, and the chunks of the files that were removed from the directory are deleted. Files that cannot be loaded are reported without stopping the ingestion. If the store implements FlushableVectorStore, it is flushed every few files and at the end of the ingestion.
+func (k *KnowledgeBase) Ingest(ctx context.Context, root string) (report IngestReport, err error) {
+	report = IngestReport{Failed: map[string]error{}}
+	flushable, buffered := k.Store.(FlushableVectorStore)
+	if buffered {
+		defer func() {
+			if flushErr := flushable.Flush(); flushErr != nil {
+				err = errors.Join(err, fmt.Errorf("error while flushing the vector store: %w", flushErr))
+			}
+		}()
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return report, err
+	}
+	paths, err := DocumentFiles(root)
+	if err != nil {
+		return report, err
+	}
+	index, incremental := k.Store.(IngestionIndex)
+	previous := map[string]IngestedFile{}
+	if incremental {
+		var duplicates []IngestedFile
+		if previous, duplicates, err = ingestedFilesByAbsPath(ctx, index); err != nil {
+			return report, fmt.Errorf("error while listing the ingested files: %w", err)
+		}
+		// the same file ingested through different paths has duplicate documents, only the ones of a single record are kept
+		for _, duplicate := range duplicates {
+			if err := k.deleteDocuments(ctx, duplicate.DocumentIDs...); err != nil {
+				return report, fmt.Errorf("error while deleting the chunks of %s: %w", duplicate.Path, err)
+			}
+			if err := index.ForgetIngestedFile(ctx, duplicate.Path); err != nil {
+				return report, err
+			}
+		}
+	}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report.Failed[path] = err
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		ingested, known := previous[path]
+		if known && ingested.Hash == hash {
+			// files recorded by older ingestions under a relative path are recorded again under their absolute one
+			if ingested.Path != path {
+				if err := rekeyIngestedFile(ctx, index, ingested, path); err != nil {
+					return report, err
+				}
+			}
+			report.Unchanged = append(report.Unchanged, path)
+			continue
+		}
+		documents, err := LoadDocument(path)
+		if errors.Is(err, ErrUnsupportedDocument) {
+			report.Skipped = append(report.Skipped, path)
+			continue
+		} else if err != nil {
+			report.Failed[path] = err
+			continue
+		}
+		documentIDs := make([]string, len(documents))
+		for i, document := range documents {
+			documentIDs[i] = document.ID
+		}
+		// the documents the file no longer has (e.g. the pages removed from a PDF) are not replaced by AddDocuments
+		stale := slices.DeleteFunc(slices.Clone(ingested.DocumentIDs), func(id string) bool {
+			return slices.Contains(documentIDs, id)
+		})
+		if err := k.deleteDocuments(ctx, stale...); err != nil {
+			return report, fmt.Errorf("error while deleting the chunks of %s: %w", path, err)
+		}
+		chunks, err := k.addDocuments(ctx, documents)
+		if err != nil {
+			return report, fmt.Errorf("error while ingesting %s: %w", path, err)
+		}
+		report.Chunks += chunks
+		if incremental {
+			if err := index.RecordIngestedFile(ctx, IngestedFile{Path: path, Hash: hash, DocumentIDs: documentIDs, IngestedAt: time.Now()}); err != nil {
+				return report, fmt.Errorf("error while recording the ingestion of %s: %w", path, err)
+			}
+			if known && ingested.Path != path {
+				if err := index.ForgetIngestedFile(ctx, ingested.Path); err != nil {
+					return report, err
+				}
+			}
+		}
+		if known {
+			report.Updated = append(report.Updated, path)
+		} else {
+			report.Added = append(report.Added, path)
+		}
+		if buffered && (len(report.Added)+len(report.Updated))%ingestFlushInterval == 0 {
+			if err := flushable.Flush(); err != nil {
+				return report, fmt.Errorf("error while flushing the vector store: %w", err)
+			}
+		}
+	}
+	for path, ingested := range previous {
+		if slices.Contains(paths, path) || !withinRoot(root, path) {
+			continue
+		}
+		if err := k.deleteDocuments(ctx, ingested.DocumentIDs...); err != nil {
+			return report, fmt.Errorf("error while deleting the chunks of %s: %w", path, err)
+		}
+		if err := index.ForgetIngestedFile(ctx, ingested.Path); err != nil {
+			return report, err
+		}
+		report.Removed = append(report.Removed, path)
+	}
+	slices.Sort(report.Removed)
+	return report, nil
+}
+
+// Private function listing the files ingested into a store by absolute path, keeping the path they were recorded under (older ingestions recorded the paths as given, possibly relative to the working directory).
+//
+// When a file was recorded under several paths, the record under its absolute path is kept and the other ones are returned as duplicates.
+func ingestedFilesByAbsPath(ctx context.Context, index IngestionIndex) (map[string]IngestedFile, []IngestedFile, error) {
+	files, err := index.IngestedFiles(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	byAbsPath := make(map[string]IngestedFile, len(files))
+	duplicates := []IngestedFile{}
+	for path, file := range files {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		file.Path = path
+		if kept, ok := byAbsPath[absPath]; ok {
+			if kept.Path == absPath {
+				duplicates = append(duplicates, file)
+				continue
+			}
+			duplicates = append(duplicates, kept)
+		}
+		byAbsPath[absPath] = file
+	}
+	return byAbsPath, duplicates, nil
+}
+
+// Private function recording an unchanged file ingested under another path under its absolute path, forgetting the previous record
+func rekeyIngestedFile(ctx context.Context, index IngestionIndex, ingested IngestedFile, path string) error {
+	previousPath := ingested.Path
+	ingested.Path = path
+	if err := index.RecordIngestedFile(ctx, ingested); err != nil {
+		return fmt.Errorf("error while recording the ingestion of %s: %w", path, err)
+	}
+	return index.ForgetIngestedFile(ctx, previousPath)
+}
+
+// Private function returning whether a path is the root of an ingestion or is under it
+func withinRoot(root, path string) bool {
+	relative, err := filepath.Rel(root, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
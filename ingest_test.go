@@ -0,0 +1,123 @@
+package gopheract
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Implementation of Embedder returning the same vector for every text
+type constantEmbedder struct{}
+
+func (constantEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = []float32{1, 0}
+	}
+	return embeddings, nil
+}
+
+// Implementation of IngestionIndex over an InMemoryVectorStore, keeping the ingested files in memory
+type indexedVectorStore struct {
+	*InMemoryVectorStore
+	files map[string]IngestedFile
+}
+
+func (s *indexedVectorStore) IngestedFiles(ctx context.Context) (map[string]IngestedFile, error) {
+	files := make(map[string]IngestedFile, len(s.files))
+	for path, file := range s.files {
+		files[path] = file
+	}
+	return files, nil
+}
+
+func (s *indexedVectorStore) RecordIngestedFile(ctx context.Context, file IngestedFile) error {
+	s.files[file.Path] = file
+	return nil
+}
+
+func (s *indexedVectorStore) ForgetIngestedFile(ctx context.Context, path string) error {
+	delete(s.files, path)
+	return nil
+}
+
+func TestIngestRelativeAndAbsolutePaths(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.MkdirAll("docs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join("docs", name), []byte("# "+name+"\n\nSome content."), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &indexedVectorStore{InMemoryVectorStore: NewInMemoryVectorStore(), files: map[string]IngestedFile{}}
+	kb := NewKnowledgeBase(constantEmbedder{}, store)
+	report, err := kb.Ingest(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 2 {
+		t.Fatalf("got %d added files, want 2", len(report.Added))
+	}
+	records := store.Len()
+
+	report, err = kb.Ingest(ctx, filepath.Join(dir, "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unchanged) != 2 || len(report.Added) != 0 {
+		t.Errorf("got %s through the absolute path, want 2 unchanged files", report)
+	}
+	if store.Len() != records {
+		t.Errorf("got %d records through the absolute path, want %d", store.Len(), records)
+	}
+
+	if err := os.Remove(filepath.Join("docs", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	report, err = kb.Ingest(ctx, "./docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 1 {
+		t.Errorf("got %s after removing a file, want 1 removed file", report)
+	}
+	if len(store.files) != 1 {
+		t.Errorf("got %d ingested files, want 1", len(store.files))
+	}
+}
+
+func TestIngestRecordsOfRelativePaths(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("a.md", []byte("# A\n\nSome content."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := &indexedVectorStore{InMemoryVectorStore: NewInMemoryVectorStore(), files: map[string]IngestedFile{}}
+	kb := NewKnowledgeBase(constantEmbedder{}, store)
+	// a file ingested under its relative path by an older ingestion, and again under its absolute one
+	if err := kb.AddDocuments(ctx, Document{ID: "a.md", Text: "# A\n\nSome content."}); err != nil {
+		t.Fatal(err)
+	}
+	store.files["a.md"] = IngestedFile{Path: "a.md", Hash: "outdated", DocumentIDs: []string{"a.md"}}
+	if _, err := kb.Ingest(ctx, filepath.Join(dir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := store.Chunks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if chunk.DocumentID != filepath.Join(dir, "a.md") {
+			t.Errorf("got a chunk of document %s, want only the ones of the absolute path", chunk.DocumentID)
+		}
+	}
+	if _, ok := store.files[filepath.Join(dir, "a.md")]; !ok || len(store.files) != 1 {
+		t.Errorf("got ingested files %v, want only the absolute path", store.files)
+	}
+}
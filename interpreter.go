@@ -0,0 +1,198 @@
+package gopheract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Struct type representing the parameters of the code interpreter tool
+type CodeParams struct {
+	Language string `json:"language" description:"Language of the snippet (python or javascript)"`
+	Code     string `json:"code" description:"Source code of the snippet to execute. Print the result to standard output (print JSON to return structured data)"`
+}
+
+// Struct type representing the result of a snippet executed by the code interpreter
+type CodeResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	// Standard output decoded as JSON, if it is valid JSON
+	JSON any `json:"json,omitempty"`
+}
+
+// Struct type representing the interpreter used for a language: the command to run and the file extension of the snippets.
+type Interpreter struct {
+	Command   string
+	Args      []string
+	Extension string
+}
+
+// Struct type representing a tool that executes short code snippets in a sandboxed subprocess.
+//
+// Each snippet runs in a fresh temporary directory, with an empty environment and resource limits (CPU time, memory, output file size) applied through `ulimit` in a POSIX shell.
+type CodeInterpreter struct {
+	// Interpreters available to the tool, keyed by language
+	Interpreters map[string]Interpreter
+	// Maximum wall clock time for a snippet
+	Timeout time.Duration
+	// Maximum CPU time for a snippet, in seconds (0 means no limit)
+	CPUSeconds int
+	// Maximum virtual memory for a snippet, in kilobytes (0 means no limit)
+	MemoryKB int
+	// Maximum size of the files written by a snippet, in kilobytes (0 means no limit)
+	FileSizeKB int
+	// Maximum number of bytes of output returned to the agent (0 means no limit)
+	MaxOutputBytes int
+}
+
+// Constructor function for a new CodeInterpreter supporting Python (`python3`) and JavaScript (`node`), with default resource limits.
+func NewCodeInterpreter() *CodeInterpreter {
+	return &CodeInterpreter{
+		Interpreters: map[string]Interpreter{
+			"python":     {Command: "python3", Args: []string{"-I"}, Extension: ".py"},
+			"javascript": {Command: "node", Extension: ".js"},
+		},
+		Timeout:        30 * time.Second,
+		CPUSeconds:     10,
+		MemoryKB:       1 << 20,
+		FileSizeKB:     10 << 10,
+		MaxOutputBytes: 64 << 10,
+	}
+}
+
+func (c *CodeInterpreter) limits() string {
+	limits := []string{}
+	if c.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", c.CPUSeconds))
+	}
+	if c.MemoryKB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", c.MemoryKB))
+	}
+	if c.FileSizeKB > 0 {
+		// POSIX shells express the file size limit in 512-byte blocks
+		limits = append(limits, fmt.Sprintf("ulimit -f %d", c.FileSizeKB*2))
+	}
+	return strings.Join(limits, " && ")
+}
+
+// Method to execute a code snippet, returning a `CodeResult`.
+//
+// A non-zero exit code is not treated as an error: it is reported in the result, along with the standard error, so that the agent can fix its snippet.
+func (c *CodeInterpreter) Execute(params CodeParams) (any, error) {
+	interpreter, ok := c.Interpreters[strings.ToLower(params.Language)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", params.Language)
+	}
+	if params.Code == "" {
+		return nil, errors.New("no code provided")
+	}
+	dir, err := os.MkdirTemp("", "gopheract-code-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "snippet"+interpreter.Extension)
+	if err := os.WriteFile(file, []byte(params.Code), 0600); err != nil {
+		return nil, err
+	}
+
+	words := []string{shellQuote(interpreter.Command)}
+	for _, arg := range interpreter.Args {
+		words = append(words, shellQuote(arg))
+	}
+	words = append(words, shellQuote(file))
+	script := "exec " + strings.Join(words, " ")
+	if limits := c.limits(); limits != "" {
+		script = limits + " && " + script
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir}
+	// the output is capped while the snippet runs, and the processes it leaves behind holding the pipes cannot keep Wait from returning
+	stdout, stderr := &cappedBuffer{max: c.MaxOutputBytes}, &cappedBuffer{max: c.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = codeWaitDelay
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("snippet timed out after %s", c.Timeout)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the snippet itself exited successfully
+		err = nil
+	}
+	result := CodeResult{
+		Stdout: c.truncate(stdout.String()),
+		Stderr: c.truncate(stderr.String()),
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+	var decoded any
+	if json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &decoded) == nil {
+		result.JSON = decoded
+	}
+	return result, nil
+}
+
+// Time given to the processes started by a snippet to release its output once it exits or times out
+const codeWaitDelay = time.Second
+
+// Private struct type buffering the output of a snippet up to one byte past a maximum size (0 means no limit), discarding the rest, so that the truncation is noticed without holding a runaway output in memory
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 {
+		if room := b.max + 1 - b.Len(); room < len(p) {
+			b.Buffer.Write(p[:max(room, 0)])
+			return len(p), nil
+		}
+	}
+	return b.Buffer.Write(p)
+}
+
+func (c *CodeInterpreter) truncate(s string) string {
+	if c.MaxOutputBytes > 0 && len(s) > c.MaxOutputBytes {
+		return s[:c.MaxOutputBytes] + "\n[output truncated]"
+	}
+	return s
+}
+
+// Helper method to expose the code interpreter as a tool definition that can be passed to an agent.
+func (c *CodeInterpreter) AsTool() ToolDefinition[CodeParams] {
+	languages := make([]string, 0, len(c.Interpreters))
+	for lang := range c.Interpreters {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return ToolDefinition[CodeParams]{
+		Name:        "CodeInterpreter",
+		Description: fmt.Sprintf("Execute a short code snippet in a sandbox and get back its standard output and error. Useful for calculations and data manipulation. Provide the language (`language` parameter - string, one of: %s) and the code (`code` parameter - string). Print the result to standard output, as JSON if structured.", strings.Join(languages, ", ")),
+		Fn:          c.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyModerate,
+	}
+}
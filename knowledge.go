@@ -0,0 +1,253 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Struct type representing a knowledge base: documents split into chunks, embedded and stored in a vector store, which agents search with the SearchKnowledgeBase tool
+type KnowledgeBase struct {
+	Embedder Embedder
+	Store    VectorStore
+	// Optional chunker splitting the documents added to the knowledge base (defaults to an AutoChunker with chunks of DefaultChunkSize bytes)
+	Chunker Chunker
+	// Number of chunks retrieved by a search (defaults to 5)
+	TopK int
+	// Minimum similarity for a chunk to be retrieved
+	MinScore float32
+	// Optional reranker scoring the retrieved chunks by their relevance to the query before they reach the agent
+	Reranker Reranker
+	// Optional keyword index searched along with the store, whose results are merged with the ones of the store by reciprocal rank fusion (see `EnableKeywordSearch`)
+	Keywords *BM25Index
+	// Number of chunks retrieved from the store and the keyword index for the fusion and the reranker to choose from (defaults to four times TopK)
+	Candidates int
+}
+
+// Constructor function for a new KnowledgeBase, given the embedder of its chunks and the vector store holding them
+func NewKnowledgeBase(embedder Embedder, store VectorStore) *KnowledgeBase {
+	return &KnowledgeBase{Embedder: embedder, Store: store}
+}
+
+// Method to add documents to the knowledge base, replacing the chunks previously added for the same documents
+func (k *KnowledgeBase) AddDocuments(ctx context.Context, documents ...Document) error {
+	_, err := k.addDocuments(ctx, documents)
+	return err
+}
+
+// Private method adding documents to the knowledge base, returning the number of chunks added
+func (k *KnowledgeBase) addDocuments(ctx context.Context, documents []Document) (int, error) {
+	chunker := k.Chunker
+	if chunker == nil {
+		chunker = NewAutoChunker(DefaultChunkSize, DefaultChunkSize/10)
+	}
+	chunks := ChunkDocuments(chunker, documents)
+	records, err := EmbedChunks(ctx, k.Embedder, chunks)
+	if err != nil {
+		return 0, fmt.Errorf("error while embedding the chunks: %w", err)
+	}
+	ids := make([]string, len(documents))
+	for i, document := range documents {
+		ids[i] = document.ID
+	}
+	if err := k.deleteDocuments(ctx, ids...); err != nil {
+		return 0, err
+	}
+	if err := k.Store.Add(ctx, records...); err != nil {
+		return 0, err
+	}
+	if k.Keywords != nil {
+		k.Keywords.Add(chunks...)
+	}
+	return len(records), nil
+}
+
+// Private method deleting the chunks of the given documents from the store and from the keyword index
+func (k *KnowledgeBase) deleteDocuments(ctx context.Context, documentIDs ...string) error {
+	if k.Keywords != nil {
+		k.Keywords.Delete(documentIDs...)
+	}
+	return k.Store.Delete(ctx, documentIDs...)
+}
+
+// Method to enable hybrid search, building a keyword index of the chunks of the store, which must implement ChunkLister. The chunks added to the knowledge base afterwards are indexed as well.
+func (k *KnowledgeBase) EnableKeywordSearch(ctx context.Context) error {
+	lister, ok := k.Store.(ChunkLister)
+	if !ok {
+		return fmt.Errorf("keyword search is not supported by the store %T, which cannot list its chunks", k.Store)
+	}
+	chunks, err := lister.Chunks(ctx)
+	if err != nil {
+		return fmt.Errorf("error while listing the chunks of the store: %w", err)
+	}
+	keywords := NewBM25Index()
+	keywords.Add(chunks...)
+	k.Keywords = keywords
+	return nil
+}
+
+// Method to search the knowledge base, returning the chunks most relevant to a query (only the ones whose metadata matches the filter, if any).
+//
+// With a keyword index, the chunks most similar to the query and the ones best matching its terms are merged by reciprocal rank fusion, and scored by it. With a reranker, more candidates are retrieved and only the ones the reranker finds the most relevant are returned, scored by their relevance.
+func (k *KnowledgeBase) Search(ctx context.Context, text string, filter map[string]any) ([]ScoredChunk, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("no query provided")
+	}
+	embeddings, err := k.Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(embeddings))
+	}
+	query := VectorQuery{TopK: k.TopK, MinScore: k.MinScore, Filter: filter}
+	if k.Reranker == nil && k.Keywords == nil {
+		return k.Store.Query(ctx, embeddings[0], query)
+	}
+	topK := query.topK()
+	query.TopK = k.Candidates
+	if query.TopK < topK {
+		query.TopK = topK * 4
+	}
+	chunks, err := k.Store.Query(ctx, embeddings[0], query)
+	if err != nil {
+		return nil, err
+	}
+	if k.Keywords != nil {
+		chunks = ReciprocalRankFusion(DefaultRRFConstant, chunks, k.Keywords.Search(text, query))
+	}
+	if k.Reranker != nil {
+		if chunks, err = k.Reranker.Rerank(ctx, text, chunks); err != nil {
+			return nil, err
+		}
+	}
+	if len(chunks) > topK {
+		chunks = chunks[:topK]
+	}
+	return chunks, nil
+}
+
+// Struct type representing the result of the SearchKnowledgeBase tool: the chunks retrieved for a query, shown to the agent with their sources
+type KnowledgeSearchResult struct {
+	Query  string        `json:"query"`
+	Chunks []ScoredChunk `json:"chunks"`
+	// Number of the first chunk, when the chunks are numbered after the sources of previous tool calls (defaults to 1)
+	First int `json:"first,omitempty"`
+}
+
+// Method returning the retrieved chunks as the agent sees them: every chunk is numbered and preceded by its source, so that the agent can cite it
+func (r KnowledgeSearchResult) String() string {
+	if len(r.Chunks) == 0 {
+		return fmt.Sprintf("No results in the knowledge base for %q", r.Query)
+	}
+	first := max(r.First, 1)
+	var text strings.Builder
+	fmt.Fprintf(&text, "Results from the knowledge base for %q (cite them by number, e.g. [%d]):\n", r.Query, first)
+	for i, chunk := range r.Chunks {
+		fmt.Fprintf(&text, "\n[%d] %s (score %.2f)\n%s\n", first+i, chunkSource(chunk.Chunk), chunk.Score, chunk.Text)
+	}
+	return text.String()
+}
+
+// Method numbering the retrieved chunks from first, returning them as the sources the answer can cite
+func (r KnowledgeSearchResult) Cite(first int) (any, []Citation) {
+	r.First = first
+	citations := make([]Citation, len(r.Chunks))
+	for i, chunk := range r.Chunks {
+		source, _ := chunk.Metadata["source"].(string)
+		if source == "" {
+			source = chunk.DocumentID
+		}
+		title, _ := chunk.Metadata["title"].(string)
+		citations[i] = Citation{
+			Number:     first + i,
+			Source:     source,
+			Title:      title,
+			DocumentID: chunk.DocumentID,
+			ChunkID:    chunk.ID,
+			Start:      chunk.Start,
+			End:        chunk.End,
+			StartLine:  chunk.StartLine,
+			EndLine:    chunk.EndLine,
+			Page:       metadataInt(chunk.Metadata, "page"),
+			Score:      chunk.Score,
+		}
+	}
+	return r, citations
+}
+
+// Private function describing where a chunk comes from: its source path, its lines or page, and its heading
+func chunkSource(chunk Chunk) string {
+	source, _ := chunk.Metadata["source"].(string)
+	if source == "" {
+		source = chunk.DocumentID
+	}
+	if page, ok := chunk.Metadata["page"]; ok {
+		source += fmt.Sprintf(", page %v", page)
+	} else if chunk.StartLine > 0 {
+		source += fmt.Sprintf(", lines %d-%d", chunk.StartLine, chunk.EndLine)
+	}
+	if heading, ok := chunk.Metadata["heading"].(string); ok && heading != "" {
+		source += " — " + heading
+	}
+	return source
+}
+
+// Struct type representing the parameters of the SearchKnowledgeBase tool
+type SearchKnowledgeBaseParams struct {
+	Query  string `json:"query" description:"What to search for, phrased as a question or as the text of the passage you are looking for"`
+	Source string `json:"source" description:"Optional path of the document to search in, when you only want results from a given document"`
+}
+
+// Struct type representing a tool with which an agent searches a knowledge base, getting the most relevant chunks along with their sources as the tool result
+type SearchKnowledgeBaseTool struct {
+	KnowledgeBase *KnowledgeBase
+}
+
+// Constructor function for a new SearchKnowledgeBaseTool
+func NewSearchKnowledgeBaseTool(knowledgeBase *KnowledgeBase) *SearchKnowledgeBaseTool {
+	return &SearchKnowledgeBaseTool{KnowledgeBase: knowledgeBase}
+}
+
+// Method to search the knowledge base
+func (t *SearchKnowledgeBaseTool) Execute(params SearchKnowledgeBaseParams) (any, error) {
+	if t.KnowledgeBase == nil {
+		return nil, errors.New("no knowledge base is configured")
+	}
+	var filter map[string]any
+	if params.Source != "" {
+		filter = map[string]any{"source": params.Source}
+	}
+	chunks, err := t.KnowledgeBase.Search(context.Background(), params.Query, filter)
+	if err != nil {
+		return nil, err
+	}
+	return KnowledgeSearchResult{Query: params.Query, Chunks: chunks}, nil
+}
+
+// Helper method to expose the SearchKnowledgeBase tool as a tool definition that can be passed to an agent.
+func (t *SearchKnowledgeBaseTool) AsTool() ToolDefinition[SearchKnowledgeBaseParams] {
+	return ToolDefinition[SearchKnowledgeBaseParams]{
+		Name:        "SearchKnowledgeBase",
+		Description: "Search the knowledge base (the documentation and files indexed for you) for the passages most relevant to a query, by meaning and by keywords, returned with their sources and numbered so that you can cite them in your answer (e.g. [2]), by providing the query (`query` parameter - string) and optionally the path of the document to search in (`source` parameter - string). Search before answering questions the knowledge base may cover, and rephrase the query if the results are not relevant.",
+		Fn:          t.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+}
+
+// Helper method returning the tools of the agent, along with the SearchKnowledgeBase tool if the agent has a knowledge base and the tool is not among its tools already
+func (o *OpenAIReActAgent) tools() []Tool {
+	if o.KnowledgeBase == nil {
+		return o.Tools
+	}
+	for _, tool := range o.Tools {
+		if tool.GetMetadata().Name == "SearchKnowledgeBase" {
+			return o.Tools
+		}
+	}
+	tools := make([]Tool, 0, len(o.Tools)+1)
+	tools = append(tools, o.Tools...)
+	return append(tools, NewSearchKnowledgeBaseTool(o.KnowledgeBase).AsTool())
+}
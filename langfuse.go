@@ -0,0 +1,106 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Implementation of TraceExporter pushing run traces to Langfuse through its ingestion API: every run becomes a Langfuse trace, with a generation for each LLM request (with its token usage), a span for each tool call and the scores of the run.
+type LangfuseExporter struct {
+	// Base URL of the Langfuse instance (defaults to "https://cloud.langfuse.com")
+	Host      string
+	PublicKey string
+	SecretKey string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new LangfuseExporter for Langfuse Cloud, given the public and secret keys of the project
+func NewLangfuseExporter(publicKey, secretKey string) *LangfuseExporter {
+	return &LangfuseExporter{
+		Host:      "https://cloud.langfuse.com",
+		PublicKey: publicKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Private function wrapping the body of a Langfuse ingestion event
+func langfuseEvent(id, eventType string, body map[string]any) map[string]any {
+	return map[string]any{
+		"id":        traceUUID(id + "/" + eventType),
+		"type":      eventType,
+		"timestamp": time.Now().UTC(),
+		"body":      body,
+	}
+}
+
+// Push a trace to Langfuse
+func (e *LangfuseExporter) Export(ctx context.Context, trace *Trace) error {
+	traceBody := map[string]any{
+		"id":        trace.ID,
+		"name":      "gopheract run",
+		"sessionId": trace.SessionID,
+		"timestamp": trace.StartedAt,
+		"input":     trace.Prompt,
+		"output":    trace.Answer,
+		"metadata":  map[string]any{"model": trace.Model, "error": trace.Error},
+	}
+	batch := []map[string]any{langfuseEvent(trace.ID, "trace-create", traceBody)}
+	for i, entry := range trace.Entries {
+		id := traceUUID(fmt.Sprintf("%s/%d", trace.ID, i))
+		body := map[string]any{
+			"id":        id,
+			"traceId":   trace.ID,
+			"startTime": entryStart(entry),
+			"endTime":   entry.CreatedAt,
+			"metadata":  map[string]any{"step": entry.Step},
+		}
+		switch entry.Kind {
+		case TraceEntryCompletion:
+			body["name"] = string(entry.Phase)
+			body["model"] = trace.Model
+			body["input"] = json.RawMessage(entry.Messages)
+			body["output"] = json.RawMessage(entry.Completion)
+			if entry.Usage != nil {
+				body["usage"] = map[string]any{
+					"input":  entry.Usage.PromptTokens,
+					"output": entry.Usage.CompletionTokens,
+					"total":  entry.Usage.TotalTokens,
+					"unit":   "TOKENS",
+				}
+			}
+			batch = append(batch, langfuseEvent(id, "generation-create", body))
+		case TraceEntryToolCall:
+			body["name"] = entry.Tool
+			body["input"] = entry.Args
+			body["output"] = entry.Result
+			body["metadata"] = map[string]any{"step": entry.Step, "call_id": entry.CallID, "reused": entry.Reused}
+			if entry.Error != "" {
+				body["level"] = "ERROR"
+				body["statusMessage"] = entry.Error
+			}
+			batch = append(batch, langfuseEvent(id, "span-create", body))
+		}
+	}
+	for name, value := range trace.Scores {
+		id := traceUUID(trace.ID + "/score/" + name)
+		batch = append(batch, langfuseEvent(id, "score-create", map[string]any{
+			"id":      id,
+			"traceId": trace.ID,
+			"name":    name,
+			"value":   value,
+		}))
+	}
+	host := e.Host
+	if host == "" {
+		host = "https://cloud.langfuse.com"
+	}
+	return postJSON(ctx, e.Client, strings.TrimSuffix(host, "/")+"/api/public/ingestion", map[string]any{"batch": batch}, func(req *http.Request) {
+		req.SetBasicAuth(e.PublicKey, e.SecretKey)
+	})
+}
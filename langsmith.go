@@ -0,0 +1,126 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Implementation of TraceExporter pushing run traces to LangSmith through its runs API: every run becomes a chain run, with a child LLM run for each request to the model (with its token usage), a child tool run for each tool call, and the scores of the run sent as feedback.
+type LangSmithExporter struct {
+	// Base URL of the LangSmith API (defaults to "https://api.smith.langchain.com")
+	Endpoint string
+	APIKey   string
+	// Name of the project the runs are logged to (defaults to "default")
+	Project string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new LangSmithExporter, given the API key and the project the runs are logged to
+func NewLangSmithExporter(apiKey, project string) *LangSmithExporter {
+	return &LangSmithExporter{
+		Endpoint: "https://api.smith.langchain.com",
+		APIKey:   apiKey,
+		Project:  project,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Private function returning the segment of the dotted order of a LangSmith run, which sorts runs by start time
+func langSmithDottedOrder(start time.Time, id string) string {
+	start = start.UTC()
+	return fmt.Sprintf("%s%06dZ%s", start.Format("20060102T150405"), start.Nanosecond()/1000, id)
+}
+
+// Push a trace to LangSmith
+func (e *LangSmithExporter) Export(ctx context.Context, trace *Trace) error {
+	project := e.Project
+	if project == "" {
+		project = "default"
+	}
+	rootID := traceUUID(trace.ID)
+	rootOrder := langSmithDottedOrder(trace.StartedAt, rootID)
+	root := map[string]any{
+		"id":           rootID,
+		"trace_id":     rootID,
+		"dotted_order": rootOrder,
+		"name":         "gopheract run",
+		"run_type":     "chain",
+		"start_time":   trace.StartedAt,
+		"end_time":     trace.FinishedAt,
+		"inputs":       map[string]any{"prompt": trace.Prompt},
+		"outputs":      map[string]any{"answer": trace.Answer},
+		"session_name": project,
+		"extra":        map[string]any{"metadata": map[string]any{"session_id": trace.SessionID, "model": trace.Model, "run_id": trace.ID}},
+	}
+	if trace.Error != "" {
+		root["error"] = trace.Error
+	}
+	runs := []map[string]any{root}
+	for i, entry := range trace.Entries {
+		id := traceUUID(fmt.Sprintf("%s/%d", trace.ID, i))
+		run := map[string]any{
+			"id":            id,
+			"trace_id":      rootID,
+			"parent_run_id": rootID,
+			"dotted_order":  rootOrder + "." + langSmithDottedOrder(entryStart(entry), id),
+			"start_time":    entryStart(entry),
+			"end_time":      entry.CreatedAt,
+			"session_name":  project,
+			"extra":         map[string]any{"metadata": map[string]any{"step": entry.Step}},
+		}
+		switch entry.Kind {
+		case TraceEntryCompletion:
+			outputs := map[string]any{"completion": json.RawMessage(entry.Completion)}
+			if entry.Usage != nil {
+				outputs["usage_metadata"] = map[string]any{
+					"input_tokens":  entry.Usage.PromptTokens,
+					"output_tokens": entry.Usage.CompletionTokens,
+					"total_tokens":  entry.Usage.TotalTokens,
+				}
+			}
+			run["name"] = string(entry.Phase)
+			run["run_type"] = "llm"
+			run["inputs"] = map[string]any{"messages": json.RawMessage(entry.Messages)}
+			run["outputs"] = outputs
+			run["extra"] = map[string]any{"metadata": map[string]any{"step": entry.Step, "ls_provider": "openai", "ls_model_name": trace.Model}}
+		case TraceEntryToolCall:
+			run["name"] = entry.Tool
+			run["run_type"] = "tool"
+			run["inputs"] = entry.Args
+			run["outputs"] = map[string]any{"result": entry.Result}
+			run["extra"] = map[string]any{"metadata": map[string]any{"step": entry.Step, "call_id": entry.CallID, "reused": entry.Reused}}
+			if entry.Error != "" {
+				run["error"] = entry.Error
+			}
+		}
+		runs = append(runs, run)
+	}
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.smith.langchain.com"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	authenticate := func(req *http.Request) {
+		req.Header.Set("x-api-key", e.APIKey)
+	}
+	if err := postJSON(ctx, e.Client, endpoint+"/runs/batch", map[string]any{"post": runs}, authenticate); err != nil {
+		return err
+	}
+	for name, value := range trace.Scores {
+		feedback := map[string]any{
+			"id":     traceUUID(trace.ID + "/score/" + name),
+			"run_id": rootID,
+			"key":    name,
+			"score":  value,
+		}
+		if err := postJSON(ctx, e.Client, endpoint+"/feedback", feedback, authenticate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
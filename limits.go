@@ -0,0 +1,75 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Error returned by the runs started beyond the limits of nested runs
+var ErrRunLimitExceeded = errors.New("the nested runs exceeded their limits")
+
+// Struct type representing the limits of the runs nested within a run (sub-agents, agents continuing the conversation and agents run by tools), so that a misconfigured team cannot spawn agents forever
+type RunLimits struct {
+	// Maximum nesting of the runs under the top-level run (defaults to 8)
+	MaxDepth int
+	// Maximum number of nested runs started under the top-level run, at any depth (defaults to 100)
+	MaxNestedRuns int
+}
+
+// Limits of the nested runs applied when an agent has none
+var DefaultRunLimits = RunLimits{MaxDepth: 8, MaxNestedRuns: 100}
+
+// Private struct type representing the position of a run in the tree of nested runs started by a top-level run
+type runLineage struct {
+	// limits of the top-level run, applied to the whole tree
+	limits RunLimits
+	depth  int
+	// names of the named agents of the run and of its ancestors, from the top-level run
+	path []string
+	// nested runs started in the tree so far
+	runs *atomic.Int64
+}
+
+// Key of the context value holding the lineage of a run
+type runLineageKey struct{}
+
+// Private method registering a run of the agent in the tree of runs of the context (starting a new tree if the context carries none), returning the context of the run.
+//
+// It fails if the run is nested deeper than allowed, if the tree has started too many nested runs, or if the agent is already running among the ancestors of the run (a cycle), which is detected for named agents only.
+func (o *OpenAIReActAgent) enterRun(ctx context.Context) (context.Context, error) {
+	parent, ok := ctx.Value(runLineageKey{}).(*runLineage)
+	if !ok {
+		limits := DefaultRunLimits
+		if o.Limits != nil {
+			if o.Limits.MaxDepth > 0 {
+				limits.MaxDepth = o.Limits.MaxDepth
+			}
+			if o.Limits.MaxNestedRuns > 0 {
+				limits.MaxNestedRuns = o.Limits.MaxNestedRuns
+			}
+		}
+		root := &runLineage{limits: limits, runs: &atomic.Int64{}}
+		if o.Name != "" {
+			root.path = []string{o.Name}
+		}
+		return context.WithValue(ctx, runLineageKey{}, root), nil
+	}
+	lineage := &runLineage{limits: parent.limits, depth: parent.depth + 1, path: parent.path, runs: parent.runs}
+	if lineage.depth > lineage.limits.MaxDepth {
+		return nil, fmt.Errorf("%w: the runs are nested more than %d levels deep", ErrRunLimitExceeded, lineage.limits.MaxDepth)
+	}
+	if o.Name != "" {
+		if slices.Contains(parent.path, o.Name) {
+			return nil, fmt.Errorf("%w: cycle between the agents %s -> %s", ErrRunLimitExceeded, strings.Join(parent.path, " -> "), o.Name)
+		}
+		lineage.path = append(slices.Clone(parent.path), o.Name)
+	}
+	if runs := lineage.runs.Add(1); runs > int64(lineage.limits.MaxNestedRuns) {
+		return nil, fmt.Errorf("%w: more than %d nested runs were started", ErrRunLimitExceeded, lineage.limits.MaxNestedRuns)
+	}
+	return context.WithValue(ctx, runLineageKey{}, lineage), nil
+}
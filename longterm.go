@@ -0,0 +1,216 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct type representing a memory stored in the long-term memory of an agent
+type MemoryRecord struct {
+	SessionID string    `json:"session_id"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Struct type representing a memory retrieved from the long-term memory, along with its similarity to the query
+type ScoredMemory struct {
+	MemoryRecord
+	Score float32 `json:"score"`
+}
+
+// Struct type representing a semantic, long-term memory: past observations and answers are stored as embeddings, and the ones most relevant to the task at hand are recalled into the prompt, so that agents remember facts from earlier sessions.
+type LongTermMemory struct {
+	Embedder Embedder
+	// Number of memories recalled for each query
+	TopK int
+	// Minimum similarity for a memory to be recalled
+	MinScore float32
+	// Optional chunker splitting the texts to remember (e.g. long tool results) into several memories, which are recalled separately
+	Chunker Chunker
+	mu      sync.RWMutex
+	records []MemoryRecord
+}
+
+// Constructor function for a new, empty LongTermMemory, given an embedder and the number of memories to recall.
+func NewLongTermMemory(embedder Embedder, topK int) *LongTermMemory {
+	return &LongTermMemory{
+		Embedder: embedder,
+		TopK:     topK,
+	}
+}
+
+// Method to store texts in the long-term memory, on behalf of a session
+func (m *LongTermMemory) Remember(ctx context.Context, sessionID string, texts ...string) error {
+	texts = slicesWithoutBlanks(texts)
+	if m.Chunker != nil {
+		chunks := []string{}
+		for _, text := range texts {
+			for _, chunk := range m.Chunker.Chunk(Document{ID: sessionID, Text: text}) {
+				chunks = append(chunks, chunk.Text)
+			}
+		}
+		texts = chunks
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	embeddings, err := m.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(embeddings) != len(texts) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+	now := time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, text := range texts {
+		m.records = append(m.records, MemoryRecord{
+			SessionID: sessionID,
+			Text:      text,
+			Embedding: embeddings[i],
+			CreatedAt: now,
+		})
+	}
+	return nil
+}
+
+// Method to retrieve the memories most relevant to a query, from the most to the least similar
+func (m *LongTermMemory) Recall(ctx context.Context, query string) ([]ScoredMemory, error) {
+	m.mu.RLock()
+	empty := len(m.records) == 0
+	m.mu.RUnlock()
+	if empty || strings.TrimSpace(query) == "" {
+		return []ScoredMemory{}, nil
+	}
+	embeddings, err := m.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(embeddings))
+	}
+	m.mu.RLock()
+	scored := make([]ScoredMemory, 0, len(m.records))
+	for _, record := range m.records {
+		score := cosineSimilarity(embeddings[0], record.Embedding)
+		if score >= m.MinScore {
+			scored = append(scored, ScoredMemory{MemoryRecord: record, Score: score})
+		}
+	}
+	m.mu.RUnlock()
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if m.TopK > 0 && len(scored) > m.TopK {
+		scored = scored[:m.TopK]
+	}
+	return scored, nil
+}
+
+// Method to save the long-term memory to a JSON file
+func (m *LongTermMemory) Save(path string) error {
+	m.mu.RLock()
+	data, err := json.Marshal(m.records)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Method to load the long-term memory from a JSON file written by `Save`, replacing its content. A missing file leaves the memory empty.
+func (m *LongTermMemory) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	records := []MemoryRecord{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = records
+	return nil
+}
+
+// Private function computing the cosine similarity between two vectors
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// Private function dropping the blank strings from a slice
+func slicesWithoutBlanks(texts []string) []string {
+	kept := make([]string, 0, len(texts))
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			kept = append(kept, text)
+		}
+	}
+	return kept
+}
+
+// Private function returning the latest request of the user in the chat history, skipping tool results and summaries
+func latestUserRequest(history []*ChatMessage) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		message := history[i]
+		if message.Role != "user" || len(toolResultNames(message)) > 0 || strings.HasPrefix(message.Content, compactedHistoryPrefix) {
+			continue
+		}
+		return message.Content
+	}
+	return ""
+}
+
+// Helper method returning the memories relevant to the latest user request, formatted as a system message (nil if there are none)
+func (o *OpenAIReActAgent) recallMemories() (*ChatMessage, error) {
+	if o.LongTermMemory == nil {
+		return nil, nil
+	}
+	history, err := o.History()
+	if err != nil {
+		return nil, err
+	}
+	memories, err := o.LongTermMemory.Recall(context.Background(), latestUserRequest(history))
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+	lines := make([]string, 0, len(memories))
+	for _, memory := range memories {
+		lines = append(lines, "- "+memory.Text)
+	}
+	return NewChatMessage("system", "Relevant memories from earlier work:\n"+strings.Join(lines, "\n")), nil
+}
+
+// Helper method storing a text in the long-term memory of the agent, if one is configured
+func (o *OpenAIReActAgent) remember(texts ...string) error {
+	if o.LongTermMemory == nil {
+		return nil
+	}
+	return o.LongTermMemory.Remember(context.Background(), o.SessionID, texts...)
+}
@@ -0,0 +1,520 @@
+package gopheract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version of the Model Context Protocol spoken by MCPClient
+const MCPProtocolVersion = "2025-06-18"
+
+// Struct type representing a JSON-RPC message exchanged with an MCP server: a request (or a notification, without id), or a response
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *MCPError       `json:"error,omitempty"`
+}
+
+// Struct type representing the error of a JSON-RPC request to an MCP server
+type MCPError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *MCPError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// Interface of the transports of the MCP clients
+type mcpTransport interface {
+	// sends a request and waits for its response, or sends a notification (a message without id) and returns a nil response
+	send(ctx context.Context, message mcpMessage) (*mcpMessage, error)
+	close() error
+}
+
+// Struct type representing a client of an MCP (Model Context Protocol) server, whose tools can be mounted into an agent.
+//
+// The client speaks JSON-RPC either over the standard input and output of a spawned process (`NewStdioMCPClient`) or over streamable HTTP (`NewHTTPMCPClient`), and must be initialized with `Initialize` before listing or calling the tools.
+type MCPClient struct {
+	// Name of the server, prefixing the names of its tools when they are mounted into an agent
+	Name string
+	// Maximum duration of a tool call executed by the agent (0 means no limit)
+	Timeout   time.Duration
+	transport mcpTransport
+	nextID    atomic.Int64
+}
+
+// Constructor function for a new MCPClient spawning the server with a command, whose environment is the one of the current process extended with `env`.
+//
+// The server is stopped when the client is closed.
+func NewStdioMCPClient(name, command string, args []string, env map[string]string) (*MCPClient, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the MCP server %s: %w", name, err)
+	}
+	transport := &mcpStdioTransport{cmd: cmd, stdin: stdin, pending: map[string]chan *mcpMessage{}, done: make(chan struct{})}
+	go transport.read(stdout)
+	return &MCPClient{Name: name, Timeout: 5 * time.Minute, transport: transport}, nil
+}
+
+// Constructor function for a new MCPClient reaching the server at a URL over streamable HTTP, sending the given headers (e.g. `Authorization`) with every request
+func NewHTTPMCPClient(name, url string, headers map[string]string) *MCPClient {
+	transport := &mcpHTTPTransport{url: url, headers: headers, client: &http.Client{}}
+	return &MCPClient{Name: name, Timeout: 5 * time.Minute, transport: transport}
+}
+
+// Helper method sending a request to the server and decoding its result
+func (c *MCPClient) call(ctx context.Context, method string, params any, result any) error {
+	id, err := json.Marshal(c.nextID.Add(1))
+	if err != nil {
+		return err
+	}
+	response, err := c.transport.send(ctx, mcpMessage{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("MCP server %s: %w", c.Name, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("MCP server %s: %w", c.Name, response.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// Method performing the initialization handshake with the server
+func (c *MCPClient) Initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": MCPProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "gopheract"},
+	}
+	if err := c.call(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+	_, err := c.transport.send(ctx, mcpMessage{JSONRPC: "2.0", Method: "notifications/initialized"})
+	return err
+}
+
+// Method listing the tools of the server
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	tools := []MCPTool{}
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// Method calling a tool of the server, returning the text of its result (tool errors reported by the server are returned as errors)
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			URI      string `json:"uri"`
+			Resource struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+	parts := []string{}
+	for _, content := range result.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource.Text != "" {
+				parts = append(parts, content.Resource.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource: %s]", content.Resource.URI))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource: %s]", content.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s: %s]", content.Type, content.MimeType))
+		}
+	}
+	if len(parts) == 0 && len(result.StructuredContent) > 0 {
+		parts = append(parts, string(result.StructuredContent))
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", fmt.Errorf("tool %s of MCP server %s failed: %s", name, c.Name, text)
+	}
+	return text, nil
+}
+
+// Method returning the tools of the server, to be mounted into an agent
+func (c *MCPClient) Tools(ctx context.Context) ([]Tool, error) {
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]Tool, 0, len(listed))
+	for _, tool := range listed {
+		tools = append(tools, &MCPServerTool{Client: c, Tool: tool})
+	}
+	return tools, nil
+}
+
+// Method closing the connection to the server, stopping it if it was spawned by the client
+func (c *MCPClient) Close() error {
+	return c.transport.close()
+}
+
+// Struct type representing a tool of an MCP server, implementing the `Tool` interface.
+//
+// Its name is the one of the server tool, prefixed with the name of the server and two underscores (e.g. `github__create_issue`), so that the tools of different servers do not clash.
+type MCPServerTool struct {
+	Client *MCPClient
+	Tool   MCPTool
+}
+
+// Helper method to get the metadata of the tool, with the parameters described by its input schema
+func (t *MCPServerTool) GetMetadata() ToolMetadata {
+	return ToolMetadata{
+		Name:               t.Client.Name + "__" + t.Tool.Name,
+		Description:        t.Tool.Description,
+		ParametersMetadata: paramsFromSchema(t.Tool.InputSchema),
+		Latency:            ToolLatencyModerate,
+	}
+}
+
+// Method returning the JSON schema of the parameters of the tool, as declared by the server
+func (t *MCPServerTool) InputSchema() map[string]any {
+	if t.Tool.InputSchema == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return t.Tool.InputSchema
+}
+
+// Method returning whether the server declares that the tool does not modify its environment (with the `readOnlyHint` annotation)
+func (t *MCPServerTool) ReadOnly() bool {
+	readOnly, _ := t.Tool.Annotations["readOnlyHint"].(bool)
+	return readOnly
+}
+
+// Method to execute the tool, calling it on the server
+func (t *MCPServerTool) Execute(args map[string]any) (any, error) {
+	ctx := context.Background()
+	if t.Client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Client.Timeout)
+		defer cancel()
+	}
+	return t.Client.CallTool(ctx, t.Tool.Name, args)
+}
+
+// Private function describing the properties of a JSON schema as parameters metadata, the optional ones being marked with `omitempty`
+func paramsFromSchema(schema map[string]any) []ToolParamsMetadata {
+	properties, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]any)
+	params := make([]ToolParamsMetadata, 0, len(properties))
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		property, _ := properties[name].(map[string]any)
+		jsonDef := name
+		if !slices.Contains(required, any(name)) {
+			jsonDef += ",omitempty"
+		}
+		description, _ := property["description"].(string)
+		params = append(params, ToolParamsMetadata{JsonDef: jsonDef, Description: description, Type: typeNameForSchema(property)})
+	}
+	return params
+}
+
+// Private function mapping a JSON schema to the string representation of the matching Go type (the inverse of `jsonSchemaForTypeName`)
+func typeNameForSchema(schema map[string]any) string {
+	switch schema["type"] {
+	case "string":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		return "[]" + typeNameForSchema(items)
+	case "object":
+		return "map[string]any"
+	default:
+		return "any"
+	}
+}
+
+// Struct type representing the transport of an MCP client over the standard input and output of a spawned server, with one JSON-RPC message per line
+type mcpStdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// serializes the writes to the standard input of the server
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	// channels of the requests waiting for their response, by id
+	pending map[string]chan *mcpMessage
+	// closed when the output of the server ends, with the reason in err
+	done chan struct{}
+	err  error
+}
+
+// Helper method reading the messages of the server until its output ends, dispatching the responses to the pending requests
+func (t *mcpStdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var message mcpMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		if message.Method != "" {
+			t.reply(message)
+			continue
+		}
+		t.mu.Lock()
+		response, ok := t.pending[string(message.ID)]
+		delete(t.pending, string(message.ID))
+		t.mu.Unlock()
+		if ok {
+			response <- &message
+		}
+	}
+	t.err = scanner.Err()
+	if t.err == nil {
+		t.err = errors.New("the server exited")
+	}
+	close(t.done)
+}
+
+// Helper method answering the requests of the server: pings are acknowledged, and the other requests are not supported by the client
+func (t *mcpStdioTransport) reply(request mcpMessage) {
+	if len(request.ID) == 0 {
+		return
+	}
+	response := mcpMessage{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage("{}")}
+	if request.Method != "ping" {
+		response = mcpMessage{JSONRPC: "2.0", ID: request.ID, Error: &MCPError{Code: -32601, Message: "method not found: " + request.Method}}
+	}
+	_ = t.write(response)
+}
+
+// Helper method writing a message to the standard input of the server
+func (t *mcpStdioTransport) write(message mcpMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *mcpStdioTransport) send(ctx context.Context, message mcpMessage) (*mcpMessage, error) {
+	if len(message.ID) == 0 {
+		return nil, t.write(message)
+	}
+	response := make(chan *mcpMessage, 1)
+	t.mu.Lock()
+	t.pending[string(message.ID)] = response
+	t.mu.Unlock()
+	forget := func() {
+		t.mu.Lock()
+		delete(t.pending, string(message.ID))
+		t.mu.Unlock()
+	}
+	if err := t.write(message); err != nil {
+		forget()
+		return nil, err
+	}
+	select {
+	case result := <-response:
+		return result, nil
+	case <-t.done:
+		forget()
+		return nil, t.err
+	case <-ctx.Done():
+		forget()
+		return nil, ctx.Err()
+	}
+}
+
+// Closing the standard input asks the server to exit, and it is killed if it does not within 5 seconds
+func (t *mcpStdioTransport) close() error {
+	err := t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(5 * time.Second):
+		_ = t.cmd.Process.Kill()
+	}
+	// the exit status of the server is irrelevant once it is closed
+	_ = t.cmd.Wait()
+	return err
+}
+
+// Struct type representing the transport of an MCP client over streamable HTTP, where every message is POSTed to the URL of the server and the responses come either as JSON or as a stream of server-sent events
+type mcpHTTPTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	mu      sync.Mutex
+	// session assigned by the server on initialization, sent back with every request
+	sessionID string
+}
+
+// Helper method building a request to the server, with the headers of the client and of the session
+func (t *mcpHTTPTransport) request(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("MCP-Protocol-Version", MCPProtocolVersion)
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *mcpHTTPTransport) send(ctx context.Context, message mcpMessage) (*mcpMessage, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.request(ctx, http.MethodPost, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if len(message.ID) == 0 {
+		return nil, nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readMCPEventStream(resp.Body, message.ID)
+	}
+	var response mcpMessage
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &response, nil
+}
+
+// Sessions are terminated explicitly, although servers might not support it
+func (t *mcpHTTPTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := t.request(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Private function reading a stream of server-sent events until the response to a request, skipping the other messages of the server
+func readMCPEventStream(body io.Reader, id json.RawMessage) (*mcpMessage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	data := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+		var message mcpMessage
+		err := json.Unmarshal([]byte(strings.Join(data, "\n")), &message)
+		data = data[:0]
+		if err == nil && message.Method == "" && bytes.Equal(message.ID, id) {
+			return &message, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("the stream ended without a response")
+}
@@ -0,0 +1,111 @@
+package gopheract
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Struct type holding the Prometheus metrics of agents and LLMs: runs, iterations, LLM and tool latencies, tool errors and tokens.
+//
+// The metrics are registered on a `prometheus.Registerer`, so they can be exposed with `promhttp.HandlerFor` (or `promhttp.Handler` when using the default registry).
+type Metrics struct {
+	RunsStarted   prometheus.Counter
+	RunsCompleted *prometheus.CounterVec
+	RunIterations prometheus.Histogram
+	LLMLatency    *prometheus.HistogramVec
+	ToolLatency   *prometheus.HistogramVec
+	ToolErrors    *prometheus.CounterVec
+	Tokens        *prometheus.CounterVec
+}
+
+// Constructor function for a new Metrics, registering the metrics on the given registerer (e.g. `prometheus.DefaultRegisterer`).
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		RunsStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gopheract_runs_started_total",
+			Help: "Number of agent runs started.",
+		}),
+		RunsCompleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gopheract_runs_completed_total",
+			Help: "Number of agent runs completed, by status (success or error).",
+		}, []string{"status"}),
+		RunIterations: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gopheract_run_iterations",
+			Help:    "Number of think-act-observe iterations per run.",
+			Buckets: []float64{1, 2, 3, 5, 8, 13, 21, 34, 55},
+		}),
+		LLMLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gopheract_llm_request_duration_seconds",
+			Help:    "Latency of the LLM requests, by model and status.",
+			Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32, 64},
+		}, []string{"model", "status"}),
+		ToolLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gopheract_tool_call_duration_seconds",
+			Help:    "Latency of the tool calls, by tool.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tool"}),
+		ToolErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gopheract_tool_errors_total",
+			Help: "Number of tool calls that returned an error, by tool.",
+		}, []string{"tool"}),
+		Tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gopheract_llm_tokens_total",
+			Help: "Number of tokens used by the LLM requests, by model and type (prompt or completion).",
+		}, []string{"model", "type"}),
+	}
+	for _, collector := range []prometheus.Collector{m.RunsStarted, m.RunsCompleted, m.RunIterations, m.LLMLatency, m.ToolLatency, m.ToolErrors, m.Tokens} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Private function returning the status label of an operation
+func metricsStatus(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// Private method recording the start of a run (no-op on nil Metrics)
+func (m *Metrics) runStarted() {
+	if m == nil {
+		return
+	}
+	m.RunsStarted.Inc()
+}
+
+// Private method recording the end of a run and its number of iterations (no-op on nil Metrics)
+func (m *Metrics) runCompleted(iterations int, err error) {
+	if m == nil {
+		return
+	}
+	m.RunsCompleted.WithLabelValues(metricsStatus(err)).Inc()
+	m.RunIterations.Observe(float64(iterations))
+}
+
+// Private method recording an LLM request with its token usage (no-op on nil Metrics)
+func (m *Metrics) llmRequest(model string, duration time.Duration, usage TokenUsage, err error) {
+	if m == nil {
+		return
+	}
+	m.LLMLatency.WithLabelValues(model, metricsStatus(err)).Observe(duration.Seconds())
+	if err == nil {
+		m.Tokens.WithLabelValues(model, "prompt").Add(float64(usage.PromptTokens))
+		m.Tokens.WithLabelValues(model, "completion").Add(float64(usage.CompletionTokens))
+	}
+}
+
+// Private method recording the execution of a tool (no-op on nil Metrics)
+func (m *Metrics) toolCall(name string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.ToolLatency.WithLabelValues(name).Observe(duration.Seconds())
+	if err != nil {
+		m.ToolErrors.WithLabelValues(name).Inc()
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/openai/openai-go/v2"
@@ -24,6 +26,24 @@ type OpenAILLM struct {
 
 	// OpenAI API client
 	Client *openai.Client
+
+	// Optional callback receiving the token usage of every request
+	OnUsage func(TokenUsage)
+
+	// Optional structured logger (nothing is logged if nil)
+	Logger *slog.Logger
+
+	// Optional Prometheus metrics recording the latency and token usage of the requests
+	Metrics *Metrics
+
+	// Optional debug mode dumping every request and its raw response to a directory
+	Debug *DebugDumper
+
+	// Optional rate limiter the requests wait for, shared with other LLMs (e.g. by the runs of an AgentPool)
+	RateLimiter *RateLimiter
+
+	// context of the run the requests belong to, aborting them when cancelled (defaults to context.Background())
+	ctx context.Context
 }
 
 // Constructor function for a new OpenAILLM (provide an API key and the model identifier)
@@ -47,18 +67,59 @@ func (o *OpenAILLM) StructuredChat(chatHistory any, responseFormat any) (string,
 	if !ok {
 		return "", errors.New("response format doesn't conform whith the one expected for OpenAI")
 	}
-	ctx := context.Background()
+	ctx := o.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := o.RateLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	start := time.Now()
 	chat, err := o.Client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Messages:       typedChatHistory,
 		Model:          o.Model,
 		ResponseFormat: resFmt,
 	})
+	if o.Debug != nil {
+		o.dumpRequest(typedChatHistory, resFmt, chat, time.Since(start), err)
+	}
 	if err != nil {
+		o.Metrics.llmRequest(o.Model, time.Since(start), TokenUsage{}, err)
+		if o.Logger != nil {
+			o.Logger.Warn("llm request failed", "model", o.Model, "duration", time.Since(start), "error", err)
+		}
 		return "", err
 	}
+	usage := TokenUsage{
+		PromptTokens:     chat.Usage.PromptTokens,
+		CompletionTokens: chat.Usage.CompletionTokens,
+		TotalTokens:      chat.Usage.TotalTokens,
+	}
+	o.Metrics.llmRequest(o.Model, time.Since(start), usage, nil)
+	if o.Logger != nil {
+		o.Logger.Debug("llm request", "model", o.Model, "duration", time.Since(start), "prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens)
+	}
+	if o.OnUsage != nil {
+		o.OnUsage(usage)
+	}
 	return chat.Choices[0].Message.Content, nil
 }
 
+// Helper method that dumps a request and its response in debug mode, logging (but otherwise ignoring) the failures
+func (o *OpenAILLM) dumpRequest(messages []openai.ChatCompletionMessageParamUnion, responseFormat openai.ChatCompletionNewParamsResponseFormatUnion, chat *openai.ChatCompletion, duration time.Duration, requestErr error) {
+	schemaName := "response"
+	if responseFormat.OfJSONSchema != nil {
+		schemaName = responseFormat.OfJSONSchema.JSONSchema.Name
+	}
+	rawResponse := ""
+	if chat != nil {
+		rawResponse = chat.RawJSON()
+	}
+	if err := o.Debug.dump(o.Model, schemaName, messages, responseFormat, rawResponse, duration, requestErr); err != nil && o.Logger != nil {
+		o.Logger.Warn("failed to write the debug dump", "error", err)
+	}
+}
+
 // Struct type representing the thinking part of the ReAct agent
 type Thought struct {
 	Thought string `json:"thought" jsonschema_description:"Thought about the path forward, based on the chat history"`
@@ -105,29 +166,86 @@ func (t *ToolCall) ArgsToMap() (map[string]any, error) {
 
 // Struct type representing the action part of a ReAct Agent
 //
-// The agent can take two type of actions:
+// The agent can take three type of actions:
 // (1) `_done`, in which case the Action payload will have a non-null `StopReason` field;
-// (2) `tool_call`, in which case the Action payload will have a non-null `ToolCall` field
+// (2) `tool_call`, in which case the Action payload will have a non-null `ToolCall` field;
+// (3) `tool_calls`, in which case the Action payload will have a non-empty `ToolCalls` field, with several tools to call in the same step
 type Action struct {
-	ActionType string      `json:"type" jsonschema:"enum=_done,enum=tool_call" jsonschema_description:"Type of the action to perform based on the chat history. Use '_done' if you think the conversation should stop, and 'tool_call' if you want to call a tool"`
+	ActionType string      `json:"type" jsonschema:"enum=_done,enum=tool_call,enum=tool_calls" jsonschema_description:"Type of the action to perform based on the chat history. Use '_done' if you think the conversation should stop, 'tool_call' if you want to call a tool, and 'tool_calls' if you want to call several independent tools at once"`
 	StopReason *StopReason `json:"stop_reason" jsonschema_description:"Reason why the conversation should stop. Only present when type is '_done'"`
 	ToolCall   *ToolCall   `json:"tool_call" jsonschema_description:"Tool to call with its arguments. Only present when type is 'tool_call'"`
+	ToolCalls  []ToolCall  `json:"tool_calls" jsonschema_description:"Tools to call with their arguments. Only non-empty when type is 'tool_calls'"`
 }
 
+// Type representing the phase of the agent loop a message originates from
+type MessagePhase string
+
+const (
+	// System prompt and user requests
+	MessagePhasePrompt MessagePhase = "prompt"
+	// Thoughts of the agent
+	MessagePhaseThought MessagePhase = "thought"
+	// Tool calls requested by the agent
+	MessagePhaseAction MessagePhase = "action"
+	// Results of the tool calls
+	MessagePhaseTool MessagePhase = "tool"
+	// Observations of the agent
+	MessagePhaseObservation MessagePhase = "observation"
+	// Recurring reminders
+	MessagePhaseReminder MessagePhase = "reminder"
+	// Answers sent back for rework by the verifier, with its feedback
+	MessagePhaseVerification MessagePhase = "verification"
+)
+
 // Helper struct type to represent a message within the chat history
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Time the message was created at
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	// Phase of the agent loop the message originates from (empty for messages created outside of the loop)
+	Phase MessagePhase `json:"phase,omitempty"`
+	// Identifier of the tool call the message refers to, for action and tool messages
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Whether the message is pinned: pinned messages are never trimmed nor compacted
+	Pinned bool `json:"pinned,omitempty"`
+	// Images attached to the message (only sent for user messages, to vision-capable models)
+	Images []ImageContent `json:"images,omitempty"`
+}
+
+// Struct type representing an image attached to a chat message, either as base64-encoded data or as a URL
+type ImageContent struct {
+	MimeType string `json:"mime_type,omitempty"`
+	// Base64-encoded image data
+	Data string `json:"data,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Method returning the URL of the image sent to the model: a data URL for base64-encoded images
+func (i ImageContent) ImageURL() string {
+	if i.Data == "" {
+		return i.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", i.MimeType, i.Data)
 }
 
 // Constructor function for a new chat message
 func NewChatMessage(role, content string) *ChatMessage {
 	return &ChatMessage{
-		Role:    role,
-		Content: content,
+		Role:      role,
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
 	}
 }
 
+// Constructor function for a new chat message originating from a phase of the agent loop (`toolCallID` is only set for action and tool messages)
+func NewPhaseMessage(role string, phase MessagePhase, toolCallID, content string) *ChatMessage {
+	message := NewChatMessage(role, content)
+	message.Phase = phase
+	message.ToolCallID = toolCallID
+	return message
+}
+
 // Struct type representing metadata for tool parameters, used when passing the tool defintion to the agent's system prompt.
 type ToolParamsMetadata struct {
 	JsonDef     string
@@ -140,11 +258,45 @@ func (tp *ToolParamsMetadata) ToString() string {
 	return fmt.Sprintf("JSON Definition of the parameter: %s; Description: %s; Type: %s", tp.JsonDef, tp.Description, tp.Type)
 }
 
+// Type representing the estimated cost category of a tool call (e.g. API fees or resource usage)
+type ToolCost string
+
+const (
+	ToolCostLow    ToolCost = "low"
+	ToolCostMedium ToolCost = "medium"
+	ToolCostHigh   ToolCost = "high"
+)
+
+// Type representing the estimated latency category of a tool call
+type ToolLatency string
+
+const (
+	ToolLatencyFast     ToolLatency = "fast"
+	ToolLatencyModerate ToolLatency = "moderate"
+	ToolLatencySlow     ToolLatency = "slow"
+)
+
 // Type struct representing metadata related to a tool defintion
 type ToolMetadata struct {
 	Name               string
 	Description        string
 	ParametersMetadata []ToolParamsMetadata
+	// Estimated cost category of a call to the tool (empty if unknown)
+	Cost ToolCost
+	// Estimated latency category of a call to the tool (empty if unknown)
+	Latency ToolLatency
+}
+
+// Helper method to convert the cost and latency annotations of a tool into a string, used when rendering the tool into the system prompt
+func (tm *ToolMetadata) CostToString() string {
+	cost, latency := string(tm.Cost), string(tm.Latency)
+	if cost == "" {
+		cost = "unknown"
+	}
+	if latency == "" {
+		latency = "unknown"
+	}
+	return fmt.Sprintf("cost: %s; latency: %s", cost, latency)
 }
 
 // Base interface that a tool definition should implement
@@ -162,6 +314,10 @@ type ToolDefinition[T any] struct {
 	Fn          func(T) (any, error)
 	Name        string
 	Description string
+	// Estimated cost category of a call to the tool (optional)
+	Cost ToolCost
+	// Estimated latency category of a call to the tool (optional)
+	Latency ToolLatency
 }
 
 // Helper method to get the metadata from the tool definition.
@@ -186,6 +342,8 @@ func (t ToolDefinition[T]) GetMetadata() ToolMetadata {
 		Name:               t.Name,
 		Description:        t.Description,
 		ParametersMetadata: paramMeta,
+		Cost:               t.Cost,
+		Latency:            t.Latency,
 	}
 }
 
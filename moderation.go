@@ -0,0 +1,111 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// Default model of the moderation guardrails
+const DefaultModerationModel = "omni-moderation-latest"
+
+// Implementation of Guardrail classifying contents with the OpenAI moderation endpoint, for deployments that must filter abusive prompts and answers (attach it to the Prompt and Answer stages of the guardrails of the agent).
+//
+// A content violates the guardrail when one of the checked categories scores at least its threshold or, for the categories without a threshold, when the endpoint flags it.
+type ModerationGuardrail struct {
+	// The OpenAI moderation model to use (defaults to DefaultModerationModel)
+	Model string
+	// Optional categories checked, e.g. "harassment" or "self-harm/intent" (defaults to all the categories of the endpoint)
+	Categories []string
+	// Optional scores (between 0 and 1) from which the categories are violations, by category; the "*" key applies to the categories without their own threshold
+	Thresholds map[string]float64
+	// Action taken on the violations: block (the default) or require approval
+	Action GuardrailAction
+
+	// OpenAI API client
+	Client *openai.Client
+}
+
+// Constructor function for a new ModerationGuardrail blocking the contents flagged by the moderation endpoint in any category (provide an API key)
+func NewModerationGuardrail(apiKey string) *ModerationGuardrail {
+	client := openai.NewClient(option.WithAPIKey(apiKey))
+	return &ModerationGuardrail{
+		Model:  DefaultModerationModel,
+		Action: GuardrailBlock,
+		Client: &client,
+	}
+}
+
+// Name of the guardrail
+func (m *ModerationGuardrail) Name() string {
+	return "moderation"
+}
+
+// Private method returning the threshold of a category, and whether it has one
+func (m *ModerationGuardrail) threshold(category string) (float64, bool) {
+	if threshold, ok := m.Thresholds[category]; ok {
+		return threshold, true
+	}
+	threshold, ok := m.Thresholds["*"]
+	return threshold, ok
+}
+
+// Check a content with the moderation endpoint, reporting the categories it violates with their scores
+func (m *ModerationGuardrail) Check(ctx context.Context, content string) (GuardrailVerdict, error) {
+	if strings.TrimSpace(content) == "" {
+		return GuardrailVerdict{Action: GuardrailAllow}, nil
+	}
+	model := m.Model
+	if model == "" {
+		model = DefaultModerationModel
+	}
+	response, err := m.Client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(content)},
+		Model: model,
+	})
+	if err != nil {
+		return GuardrailVerdict{}, err
+	}
+	if len(response.Results) == 0 {
+		return GuardrailVerdict{}, fmt.Errorf("the moderation endpoint returned no result")
+	}
+	// the categories are decoded by name, so that the ones added to the endpoint are checked too
+	var flags map[string]bool
+	var scores map[string]float64
+	if err := json.Unmarshal([]byte(response.Results[0].Categories.RawJSON()), &flags); err != nil {
+		return GuardrailVerdict{}, fmt.Errorf("invalid moderation categories: %w", err)
+	}
+	if err := json.Unmarshal([]byte(response.Results[0].CategoryScores.RawJSON()), &scores); err != nil {
+		return GuardrailVerdict{}, fmt.Errorf("invalid moderation scores: %w", err)
+	}
+	violations := []string{}
+	for category, score := range scores {
+		if len(m.Categories) > 0 && !slices.Contains(m.Categories, category) {
+			continue
+		}
+		threshold, ok := m.threshold(category)
+		if (ok && score >= threshold) || (!ok && flags[category]) {
+			violations = append(violations, category)
+		}
+	}
+	if len(violations) == 0 {
+		return GuardrailVerdict{Action: GuardrailAllow}, nil
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return scores[violations[i]] > scores[violations[j]]
+	})
+	for i, category := range violations {
+		violations[i] = fmt.Sprintf("%s (%.2f)", category, scores[category])
+	}
+	action := m.Action
+	if action == "" {
+		action = GuardrailBlock
+	}
+	return GuardrailVerdict{Action: action, Reason: "flagged for " + strings.Join(violations, ", ")}, nil
+}
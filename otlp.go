@@ -0,0 +1,150 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Base interface for the exporters of the events of a run, which receive the whole stream once the run is over
+type EventExporter interface {
+	ExportEvents(ctx context.Context, sessionID, runID string, events []AgentEvent) error
+}
+
+// Implementation of EventExporter sending the events of a run as OTLP log records (one per event, named after its type, e.g. "gopheract.thought") over OTLP/HTTP with JSON encoding, so that any platform ingesting OTLP can rebuild the timeline of the run.
+//
+// The records of a run share a trace ID derived from the run identifier, and carry the session and run identifiers as attributes.
+type OTLPLogExporter struct {
+	// URL of the OTLP logs endpoint (e.g. "http://localhost:4318/v1/logs")
+	Endpoint string
+	// Headers added to every request (e.g. authentication headers)
+	Headers map[string]string
+	// Name of the service reported in the resource of the records (defaults to "gopheract")
+	ServiceName string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new OTLPLogExporter, given the URL of the OTLP logs endpoint
+func NewOTLPLogExporter(endpoint string) *OTLPLogExporter {
+	return &OTLPLogExporter{
+		Endpoint:    endpoint,
+		Headers:     map[string]string{},
+		ServiceName: "gopheract",
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Private struct type representing an attribute of an OTLP log record
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// Private function converting a value to an OTLP attribute value (values other than strings, integers, floats and booleans are serialized as JSON strings)
+func otlpValue(value any) map[string]any {
+	switch v := value.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	case time.Duration:
+		return map[string]any{"intValue": strconv.FormatInt(v.Milliseconds(), 10)}
+	}
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return map[string]any{"stringValue": err.Error()}
+	}
+	return map[string]any{"stringValue": string(serialized)}
+}
+
+// Private function converting key-value pairs to OTLP attributes
+func otlpAttributes(keyValues ...any) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(keyValues)/2)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		attributes = append(attributes, otlpAttribute{Key: keyValues[i].(string), Value: otlpValue(keyValues[i+1])})
+	}
+	return attributes
+}
+
+// Private function mapping an event to the name, severity, body and attributes of its log record
+func otlpEvent(event AgentEvent) (name string, severity int, body any, attributes []otlpAttribute) {
+	severity = 9 // INFO
+	switch e := event.(type) {
+	case ProgressEvent:
+		return "gopheract.progress", severity, "", otlpAttributes("gopheract.max_steps", e.MaxSteps, "gopheract.elapsed_ms", e.Elapsed)
+	case ThoughtEvent:
+		return "gopheract.thought", severity, e.Thought, otlpAttributes("gopheract.duration_ms", e.Duration)
+	case ActionEvent:
+		return "gopheract.action", severity, e.Action, otlpAttributes("gopheract.action.type", e.Action.ActionType, "gopheract.duration_ms", e.Duration)
+	case ToolStartEvent:
+		return "gopheract.tool_start", severity, e.ToolCall.Args, otlpAttributes("gen_ai.tool.call.id", e.CallID, "gen_ai.tool.name", e.ToolCall.Name)
+	case ToolEndEvent:
+		return "gopheract.tool_end", severity, e.Result, otlpAttributes("gen_ai.tool.call.id", e.CallID, "gen_ai.tool.name", e.Tool, "gopheract.reused", e.Reused, "gopheract.duration_ms", e.Duration)
+	case ObservationEvent:
+		return "gopheract.observation", severity, e.Observation, otlpAttributes("gopheract.duration_ms", e.Duration)
+	case GuardrailEvent:
+		return "gopheract.guardrail", 13, e.Verdict.Reason, otlpAttributes("gopheract.guardrail.stage", string(e.Stage), "gopheract.guardrail.name", e.Guardrail, "gopheract.guardrail.action", string(e.Verdict.Action), "gopheract.guardrail.blocked", e.Blocked()) // WARN
+	case StopEvent:
+		return "gopheract.stop", severity, e.Reason, nil
+	case ErrorEvent:
+		return "gopheract.error", 17, e.Err.Error(), nil // ERROR
+	case UsageEvent:
+		return "gopheract.usage", severity, "", otlpAttributes("gopheract.phase", e.Phase, "gen_ai.usage.input_tokens", e.Usage.PromptTokens, "gen_ai.usage.output_tokens", e.Usage.CompletionTokens)
+	}
+	return "gopheract.event", severity, "", nil
+}
+
+// Send the events of a run to the OTLP logs endpoint
+func (e *OTLPLogExporter) ExportEvents(ctx context.Context, sessionID, runID string, events []AgentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	serviceName := e.ServiceName
+	if serviceName == "" {
+		serviceName = "gopheract"
+	}
+	traceID := strings.ReplaceAll(traceUUID(runID), "-", "")
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	records := make([]map[string]any, 0, len(events))
+	for _, event := range events {
+		name, severity, body, attributes := otlpEvent(event)
+		attributes = append(attributes, otlpAttributes("event.name", name, "session.id", sessionID, "gopheract.run_id", runID, "gopheract.step", event.EventStep())...)
+		record := map[string]any{
+			"timeUnixNano":         strconv.FormatInt(event.EventTime().UnixNano(), 10),
+			"observedTimeUnixNano": observed,
+			"severityNumber":       severity,
+			"severityText":         map[int]string{9: "INFO", 13: "WARN", 17: "ERROR"}[severity],
+			"eventName":            name,
+			"attributes":           attributes,
+			"traceId":              traceID,
+		}
+		if body != "" {
+			record["body"] = otlpValue(body)
+		}
+		records = append(records, record)
+	}
+	payload := map[string]any{
+		"resourceLogs": []map[string]any{{
+			"resource": map[string]any{"attributes": otlpAttributes("service.name", serviceName)},
+			"scopeLogs": []map[string]any{{
+				"scope":      map[string]any{"name": "github.com/AstraBert/gopheract"},
+				"logRecords": records,
+			}},
+		}},
+	}
+	return postJSON(ctx, e.Client, e.Endpoint, payload, func(req *http.Request) {
+		for key, value := range e.Headers {
+			req.Header.Set(key, value)
+		}
+	})
+}
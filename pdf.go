@@ -0,0 +1,784 @@
+package gopheract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Loader of PDF files, extracting the text of their pages (one document per page).
+//
+// Only the text drawn on the pages is extracted: scanned pages (images) come out empty and are skipped, and encrypted files are not supported.
+type PDFLoader struct{}
+
+// Load the pages of a PDF file as documents, skipping the pages without text
+func (PDFLoader) Load(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: %s is not a PDF file", ErrUnsupportedDocument, path)
+	}
+	if pdfEncryptPattern.Match(data) {
+		return nil, fmt.Errorf("%w: %s is encrypted", ErrUnsupportedDocument, path)
+	}
+	file := parsePDF(data)
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages found in %s", path)
+	}
+	title := file.title()
+	documents := []Document{}
+	for i, page := range pages {
+		text := tidyText(file.pageText(page))
+		if text == "" {
+			continue
+		}
+		metadata := documentMetadata(path, "pdf")
+		metadata["page"] = i + 1
+		metadata["pages"] = len(pages)
+		if title != "" {
+			metadata["title"] = title
+		}
+		documents = append(documents, Document{ID: fmt.Sprintf("%s#page=%d", path, i+1), Text: text, Metadata: metadata})
+	}
+	return documents, nil
+}
+
+// Limits protecting the parser from malformed or malicious files: the size of a decoded stream, the number of objects of an object stream, the number of pages and the nesting of the dictionaries and arrays
+const (
+	maxPDFStreamSize    = 64 << 20
+	maxPDFStreamObjects = 1 << 20
+	maxPDFPages         = 100000
+	maxPDFNesting       = 256
+)
+
+// Patterns of the indirect objects of a PDF file, of the references to its catalog and information dictionary, and of its encryption dictionary
+var (
+	pdfObjectPattern  = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfRootPattern    = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+	pdfInfoPattern    = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfEncryptPattern = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+)
+
+// Private struct type representing a reference to an indirect object of a PDF file
+type pdfRef struct{ num int }
+
+// Private types of the names (e.g. /Font) and keywords (operators, in content streams) of a PDF file
+type (
+	pdfName    string
+	pdfKeyword string
+)
+
+// Private struct type representing a stream of a PDF file, with its dictionary and its raw (encoded) data
+type pdfStream struct {
+	dict map[string]any
+	raw  []byte
+}
+
+// Private method returning the decoded data of a stream (nil if it uses an unsupported filter)
+func (s *pdfStream) decode() []byte {
+	var filters []any
+	switch filter := s.dict["Filter"].(type) {
+	case pdfName:
+		filters = []any{filter}
+	case []any:
+		filters = filter
+	}
+	data := s.raw
+	for _, filter := range filters {
+		switch filter {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil
+			}
+			// the data read before an error is kept, since truncated streams are common (and the data beyond the limit is dropped)
+			data, _ = io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+		default:
+			return nil
+		}
+	}
+	return data
+}
+
+// Private struct type representing a lexer of the objects of a PDF file or content stream
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// number of dictionaries and arrays the lexer is in
+	depth int
+}
+
+// Private function returning whether a byte is whitespace in a PDF file
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+// Private function returning whether a byte is a delimiter in a PDF file
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// Private method skipping the whitespace and comments
+func (l *pdfLexer) skip() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// Private method reading the next object: a dictionary (map[string]any), an array ([]any), a string, a number (float64), a boolean, nil, a pdfName, a pdfRef or a pdfKeyword. It returns io.EOF at the end of the data.
+func (l *pdfLexer) next() (any, error) {
+	l.skip()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+	if c := l.data[l.pos]; c == '[' || (c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<') {
+		if l.depth >= maxPDFNesting {
+			return nil, errors.New("objects nested too deeply")
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+	}
+	switch c := l.data[l.pos]; {
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := map[string]any{}
+		for {
+			l.skip()
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, errors.New("malformed dictionary")
+			}
+			value, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			dict[string(name)] = value
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		digits := strings.Map(func(r rune) rune {
+			if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return r
+			}
+			return -1
+		}, string(l.data[l.pos+1:l.pos+end]))
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		l.pos += end + 1
+		decoded, err := hex.DecodeString(digits)
+		return string(decoded), err
+	case c == '[':
+		l.pos++
+		array := []any{}
+		for {
+			l.skip()
+			if l.pos >= len(l.data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return array, nil
+			}
+			value, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	case c == '(':
+		return l.literal()
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(l.data[start:l.pos]), nil
+	case isPDFDelimiter(c):
+		// stray delimiters are returned as keywords, which the callers ignore
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+	if integer, err := strconv.Atoi(token); err == nil {
+		if ref, ok := l.reference(integer); ok {
+			return ref, nil
+		}
+		return float64(integer), nil
+	}
+	if number, err := strconv.ParseFloat(token, 64); err == nil && strings.Trim(token, "+-.0123456789") == "" {
+		return number, nil
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(token), nil
+}
+
+// Private method reading the rest of a reference ("<generation> R") after its object number, leaving the lexer in place if there is none
+func (l *pdfLexer) reference(num int) (pdfRef, bool) {
+	start := l.pos
+	l.skip()
+	generation := l.pos
+	for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		l.pos++
+	}
+	if l.pos > generation {
+		l.skip()
+		if l.pos < len(l.data) && l.data[l.pos] == 'R' && (l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+			l.pos++
+			return pdfRef{num}, true
+		}
+	}
+	l.pos = start
+	return pdfRef{}, false
+}
+
+// Private method reading a literal string, with its escape sequences and balanced parentheses
+func (l *pdfLexer) literal() (any, error) {
+	l.pos++
+	out := []byte{}
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(out), nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			escaped := l.data[l.pos]
+			l.pos++
+			switch escaped {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// escaped line breaks continue the string on the next line
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				value := int(escaped - '0')
+				for digits := 1; digits < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; digits++ {
+					value = value*8 + int(l.data[l.pos]-'0')
+					l.pos++
+				}
+				out = append(out, byte(value))
+			default:
+				out = append(out, escaped)
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Private struct type representing the indirect objects of a PDF file, by object number
+type pdfFile struct {
+	data    []byte
+	objects map[int]any
+}
+
+// Private function reading the indirect objects of a PDF file, including the ones stored in object streams. The objects are found by scanning the file rather than through its cross-reference table, so that damaged files can be read too.
+func parsePDF(data []byte) *pdfFile {
+	file := &pdfFile{data: data, objects: map[int]any{}}
+	for _, match := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[match[2]:match[3]]))
+		if err != nil {
+			continue
+		}
+		lexer := &pdfLexer{data: data, pos: match[1]}
+		value, err := lexer.next()
+		if err != nil {
+			continue
+		}
+		if dict, ok := value.(map[string]any); ok {
+			lexer.skip()
+			if bytes.HasPrefix(data[lexer.pos:], []byte("stream")) {
+				start := lexer.pos + len("stream")
+				if start < len(data) && data[start] == '\r' {
+					start++
+				}
+				if start < len(data) && data[start] == '\n' {
+					start++
+				}
+				end := bytes.Index(data[start:], []byte("endstream"))
+				if end < 0 {
+					continue
+				}
+				value = &pdfStream{dict: dict, raw: data[start : start+end]}
+			}
+		}
+		// the objects redefined by incremental updates come later in the file
+		file.objects[num] = value
+	}
+	for _, object := range file.objects {
+		if stream, ok := object.(*pdfStream); ok && stream.dict["Type"] == pdfName("ObjStm") {
+			file.readObjectStream(stream)
+		}
+	}
+	return file
+}
+
+// Private method reading the objects stored in an object stream, unless they are defined elsewhere in the file
+func (f *pdfFile) readObjectStream(stream *pdfStream) {
+	count, _ := stream.dict["N"].(float64)
+	first, _ := stream.dict["First"].(float64)
+	if count < 0 || count > maxPDFStreamObjects || first < 0 {
+		return
+	}
+	data := stream.decode()
+	if data == nil || first > float64(len(data)) {
+		return
+	}
+	header := &pdfLexer{data: data[:int(first)]}
+	for range int(count) {
+		num, err := header.next()
+		if err != nil {
+			return
+		}
+		offset, err := header.next()
+		if err != nil {
+			return
+		}
+		number, okNum := num.(float64)
+		position, okOffset := offset.(float64)
+		if !okNum || !okOffset {
+			return
+		}
+		if position < 0 || first+position >= float64(len(data)) {
+			continue
+		}
+		if _, ok := f.objects[int(number)]; ok {
+			continue
+		}
+		lexer := &pdfLexer{data: data, pos: int(first) + int(position)}
+		if value, err := lexer.next(); err == nil {
+			f.objects[int(number)] = value
+		}
+	}
+}
+
+// Private method resolving the references to indirect objects
+func (f *pdfFile) resolve(value any) any {
+	for range 32 {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = f.objects[ref.num]
+	}
+	return nil
+}
+
+// Private method returning the dictionary a value refers to (or the dictionary of the stream it refers to), nil if it is not one
+func (f *pdfFile) dict(value any) map[string]any {
+	switch value := f.resolve(value).(type) {
+	case map[string]any:
+		return value
+	case *pdfStream:
+		return value.dict
+	}
+	return nil
+}
+
+// Private struct type representing a page of a PDF file, with the resources it inherits from the page tree
+type pdfPage struct {
+	dict      map[string]any
+	resources map[string]any
+}
+
+// Private method returning the pages of the file, in order
+func (f *pdfFile) pages() []pdfPage {
+	var catalog map[string]any
+	if matches := pdfRootPattern.FindAllSubmatch(f.data, -1); len(matches) > 0 {
+		num, _ := strconv.Atoi(string(matches[len(matches)-1][1]))
+		catalog = f.dict(pdfRef{num})
+	}
+	if catalog == nil {
+		for _, object := range f.objects {
+			if dict := f.dict(object); dict != nil && dict["Type"] == pdfName("Catalog") {
+				catalog = dict
+				break
+			}
+		}
+	}
+	pages := []pdfPage{}
+	if catalog != nil {
+		f.walk(catalog["Pages"], nil, &pages, map[int]bool{}, 0)
+	}
+	return pages
+}
+
+// Private method collecting the pages of a node of the page tree, visiting every object once so that trees whose nodes refer to each other terminate
+func (f *pdfFile) walk(node any, resources map[string]any, pages *[]pdfPage, visited map[int]bool, depth int) {
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref.num] {
+			return
+		}
+		visited[ref.num] = true
+	}
+	dict := f.dict(node)
+	if dict == nil || depth > 64 || len(*pages) >= maxPDFPages {
+		return
+	}
+	if own := f.dict(dict["Resources"]); own != nil {
+		resources = own
+	}
+	if kids, ok := f.resolve(dict["Kids"]).([]any); ok {
+		for _, kid := range kids {
+			f.walk(kid, resources, pages, visited, depth+1)
+		}
+		return
+	}
+	*pages = append(*pages, pdfPage{dict: dict, resources: resources})
+}
+
+// Private method returning the title of the file, from its information dictionary
+func (f *pdfFile) title() string {
+	matches := pdfInfoPattern.FindAllSubmatch(f.data, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	num, _ := strconv.Atoi(string(matches[len(matches)-1][1]))
+	title, _ := f.resolve(f.dict(pdfRef{num})["Title"]).(string)
+	if rest, ok := strings.CutPrefix(title, "\xfe\xff"); ok {
+		return strings.TrimSpace(utf16Text(rest))
+	}
+	return strings.TrimSpace(latin1Text(title))
+}
+
+// Private method extracting the text of a page
+func (f *pdfFile) pageText(page pdfPage) string {
+	contents := f.resolve(page.dict["Contents"])
+	streams, ok := contents.([]any)
+	if !ok {
+		streams = []any{contents}
+	}
+	var content []byte
+	for _, stream := range streams {
+		if stream, ok := f.resolve(stream).(*pdfStream); ok {
+			content = append(content, stream.decode()...)
+			content = append(content, '\n')
+		}
+	}
+	fonts := map[string]*pdfFont{}
+	for name, font := range f.dict(page.resources["Font"]) {
+		fonts[name] = f.font(font)
+	}
+	return pdfContentText(content, fonts)
+}
+
+// Private struct type representing how the strings shown with a font map to text
+type pdfFont struct {
+	// text of the character codes, from the ToUnicode map of the font (if any)
+	toUnicode map[string]string
+	// length of the character codes, in bytes
+	codeLength int
+	// whether the font is a composite (Type0) font, whose codes cannot be read without a ToUnicode map
+	composite bool
+}
+
+// Private method reading a font of the file
+func (f *pdfFile) font(value any) *pdfFont {
+	font := &pdfFont{codeLength: 1}
+	dict := f.dict(value)
+	if dict == nil {
+		return font
+	}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.composite = true
+		font.codeLength = 2
+	}
+	if stream, ok := f.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		var codeLength int
+		font.toUnicode, codeLength = parseToUnicode(stream.decode())
+		if codeLength > 0 {
+			font.codeLength = codeLength
+		}
+	}
+	return font
+}
+
+// Private method returning the text of a string shown with the font (nil fonts read the string as Latin-1)
+func (font *pdfFont) text(shown string) string {
+	if font == nil || font.toUnicode == nil {
+		if font != nil && font.composite {
+			return ""
+		}
+		return latin1Text(shown)
+	}
+	var text strings.Builder
+	for i := 0; i < len(shown); i += font.codeLength {
+		code := shown[i:min(i+font.codeLength, len(shown))]
+		if mapped, ok := font.toUnicode[code]; ok {
+			text.WriteString(mapped)
+		} else if !font.composite {
+			text.WriteString(latin1Text(code))
+		}
+	}
+	return text.String()
+}
+
+// Private function parsing a ToUnicode map, returning the text of the character codes and their length in bytes
+func parseToUnicode(data []byte) (map[string]string, int) {
+	toUnicode := map[string]string{}
+	codeLength := 0
+	lexer := &pdfLexer{data: data}
+	operands := []any{}
+	for {
+		value, err := lexer.next()
+		if err != nil {
+			break
+		}
+		keyword, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+		switch keyword {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				code, okCode := operands[i].(string)
+				text, okText := operands[i+1].(string)
+				if okCode && okText && code != "" {
+					toUnicode[code] = utf16Text(text)
+					codeLength = len(code)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, okLow := operands[i].(string)
+				high, okHigh := operands[i+1].(string)
+				if !okLow || !okHigh || low == "" || len(low) != len(high) || len(low) > 4 {
+					continue
+				}
+				codeLength = len(low)
+				first, last := pdfCode(low), pdfCode(high)
+				for code := first; code <= last && code-first < 65536; code++ {
+					key := pdfCodeString(code, len(low))
+					switch target := operands[i+2].(type) {
+					case string:
+						units := utf16Units(target)
+						if len(units) > 0 {
+							units[len(units)-1] += uint16(code - first)
+							toUnicode[key] = string(utf16.Decode(units))
+						}
+					case []any:
+						if index := int(code - first); index < len(target) {
+							if text, ok := target[index].(string); ok {
+								toUnicode[key] = utf16Text(text)
+							}
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return toUnicode, codeLength
+}
+
+// Private function returning the integer value of a character code
+func pdfCode(code string) uint32 {
+	var value uint32
+	for i := 0; i < len(code); i++ {
+		value = value<<8 | uint32(code[i])
+	}
+	return value
+}
+
+// Private function returning the bytes of a character code of the given length
+func pdfCodeString(value uint32, length int) string {
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = byte(value)
+		value >>= 8
+	}
+	return string(code)
+}
+
+// Private function returning the UTF-16 code units of big-endian bytes
+func utf16Units(data string) []uint16 {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+	return units
+}
+
+// Private function decoding big-endian UTF-16 text
+func utf16Text(data string) string {
+	return string(utf16.Decode(utf16Units(data)))
+}
+
+// Private function decoding Latin-1 text
+func latin1Text(data string) string {
+	runes := make([]rune, len(data))
+	for i := 0; i < len(data); i++ {
+		runes[i] = rune(data[i])
+	}
+	return string(runes)
+}
+
+// Pattern of the end of the data of an inline image
+var pdfInlineImageEndPattern = regexp.MustCompile(`\sEI(\s|$)`)
+
+// Private function extracting the text shown by a content stream, breaking the lines where the text moves to another line
+func pdfContentText(content []byte, fonts map[string]*pdfFont) string {
+	var text strings.Builder
+	last := byte('\n')
+	write := func(s string) {
+		if s != "" {
+			text.WriteString(s)
+			last = s[len(s)-1]
+		}
+	}
+	space := func() {
+		if last != ' ' && last != '\n' {
+			write(" ")
+		}
+	}
+	newline := func() {
+		if last != '\n' {
+			write("\n")
+		}
+	}
+	var font *pdfFont
+	show := func(value any) {
+		if shown, ok := value.(string); ok {
+			write(font.text(shown))
+		}
+	}
+	lineY, hasLine := 0.0, false
+	lexer := &pdfLexer{data: content}
+	operands := []any{}
+	for {
+		value, err := lexer.next()
+		if err != nil {
+			break
+		}
+		operator, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+		var lastOperand any
+		if len(operands) > 0 {
+			lastOperand = operands[len(operands)-1]
+		}
+		switch operator {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			show(lastOperand)
+		case "'", "\"":
+			newline()
+			show(lastOperand)
+		case "TJ":
+			items, _ := lastOperand.([]any)
+			for _, item := range items {
+				switch item := item.(type) {
+				case string:
+					show(item)
+				case float64:
+					// large negative adjustments move the text by about a word
+					if item < -200 {
+						space()
+					}
+				}
+			}
+		case "Td", "TD":
+			if ty, _ := lastOperand.(float64); ty != 0 {
+				newline()
+			} else {
+				space()
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			if y, ok := lastOperand.(float64); ok && len(operands) >= 6 {
+				if hasLine && y == lineY {
+					space()
+				} else {
+					newline()
+				}
+				lineY, hasLine = y, true
+			}
+		case "ET":
+			space()
+		case "ID":
+			// the binary data of inline images is skipped
+			end := pdfInlineImageEndPattern.FindIndex(content[lexer.pos:])
+			if end == nil {
+				return text.String()
+			}
+			lexer.pos += end[1]
+		}
+		operands = operands[:0]
+	}
+	return text.String()
+}
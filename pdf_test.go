@@ -0,0 +1,214 @@
+package gopheract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function assembling a PDF file from the bodies of its objects, numbered from 1, with a catalog at object 1
+func buildPDF(objects ...string) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n")
+	for i, object := range objects {
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	out.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return out.Bytes()
+}
+
+// Helper function returning a stream object with the given dictionary entries and data
+func pdfStreamObject(entries string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", entries, len(data), data)
+}
+
+// Helper function compressing data with zlib, as the FlateDecode filter expects it
+func flate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	writer := zlib.NewWriter(&out)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestPDFLexer(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{"integer", "42", float64(42)},
+		{"real", "-3.5", -3.5},
+		{"reference", "12 0 R", pdfRef{12}},
+		{"integers", "12 0 obj", float64(12)},
+		{"name", "/FlateDecode", pdfName("FlateDecode")},
+		{"literal string", `(a \(nested\) \n string)`, "a (nested) \n string"},
+		{"balanced parentheses", "(a (b) c)", "a (b) c"},
+		{"octal escape", `(\101\102)`, "AB"},
+		{"hex string", "<48 65 6c6C6f>", "Hello"},
+		{"odd hex string", "<414>", "A@"},
+		{"booleans and null", "[true false null]", []any{true, false, nil}},
+		{"array", "[1 /Two (three) 4 0 R]", []any{float64(1), pdfName("Two"), "three", pdfRef{4}}},
+		{"dictionary", "<< /Type /Page /Kids [1 0 R] >>", map[string]any{"Type": pdfName("Page"), "Kids": []any{pdfRef{1}}}},
+		{"comment", "% comment\n7", float64(7)},
+		{"keyword", "BT", pdfKeyword("BT")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lexer := &pdfLexer{data: []byte(test.input)}
+			got, err := lexer.next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPDFLexerMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unterminated array", "[1 2"},
+		{"unterminated dictionary", "<< /Type /Page"},
+		{"unterminated literal", "(abc"},
+		{"unterminated hex string", "<4142"},
+		{"dictionary key that is not a name", "<< 1 2 >>"},
+		{"deeply nested arrays", strings.Repeat("[", 100000)},
+		{"deeply nested dictionaries", strings.Repeat("<< /A ", 100000)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lexer := &pdfLexer{data: []byte(test.input)}
+			if _, err := lexer.next(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestPDFTextExtraction(t *testing.T) {
+	content := []byte("BT /F1 12 Tf 72 712 Td (Hello, PDF world) Tj ET")
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		pdfStreamObject("/Filter /FlateDecode", flate(t, content)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	file := parsePDF(data)
+	pages := file.pages()
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if text := tidyText(file.pageText(pages[0])); !strings.Contains(text, "Hello, PDF world") {
+		t.Errorf("got text %q", text)
+	}
+}
+
+func TestPDFObjectStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries string
+		data    string
+		want    bool
+	}{
+		{"valid", "/N 1 /First 4", "6 0 (in the stream)", true},
+		{"negative first", "/N 1 /First -3", "6 0 (in the stream)", false},
+		{"first beyond the data", "/N 1 /First 1000", "6 0 (in the stream)", false},
+		{"huge first", "/N 1 /First 1e300", "6 0 (in the stream)", false},
+		{"negative offset", "/N 1 /First 4", "6 -9 (in the stream)", false},
+		{"offset beyond the data", "/N 1 /First 4", "6 900 (in the stream)", false},
+		{"negative count", "/N -1 /First 4", "6 0 (in the stream)", false},
+		{"huge count", "/N 1e12 /First 4", "6 0 (in the stream)", false},
+		{"count beyond the header", "/N 50 /First 4", "6 0 (in the stream)", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := buildPDF("<< /Type /Catalog >>", pdfStreamObject("/Type /ObjStm "+test.entries, []byte(test.data)))
+			file := parsePDF(data)
+			_, found := file.objects[6]
+			if found != test.want {
+				t.Errorf("object found: %v, want %v", found, test.want)
+			}
+		})
+	}
+}
+
+func TestPDFPageTreeCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		kids string
+		want int
+	}{
+		{"duplicate kids", "[2 0 R 2 0 R 2 0 R 2 0 R]", 0},
+		{"kids pointing to themselves and a page", "[2 0 R 3 0 R 3 0 R]", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids "+test.kids+" >>",
+				"<< /Type /Page /Parent 2 0 R >>",
+			)
+			done := make(chan int)
+			go func() { done <- len(parsePDF(data).pages()) }()
+			select {
+			case pages := <-done:
+				if pages != test.want {
+					t.Errorf("got %d pages, want %d", pages, test.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the page tree walk did not terminate")
+			}
+		})
+	}
+}
+
+func TestPDFDecompressionLimit(t *testing.T) {
+	stream := &pdfStream{dict: map[string]any{"Filter": pdfName("FlateDecode")}, raw: flate(t, make([]byte, maxPDFStreamSize+4096))}
+	if decoded := stream.decode(); len(decoded) != maxPDFStreamSize {
+		t.Errorf("got %d bytes, want the %d bytes of the limit", len(decoded), maxPDFStreamSize)
+	}
+}
+
+func TestPDFLoaderMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"header only", []byte("%PDF-1.7\n")},
+		{"truncated object", []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R")},
+		{"truncated stream", []byte("%PDF-1.7\n1 0 obj\n<< /Length 10 >>\nstream\nabc")},
+		{"invalid flate data", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [3 0 R] >>", "<< /Type /Page /Contents 4 0 R >>", pdfStreamObject("/Filter /FlateDecode", []byte("not zlib")))},
+		{"reference loop", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "3 0 R", "2 0 R")},
+		{"garbage", append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("<<[( obj 1 0 R >>]/"), 1000)...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.pdf")
+			if err := os.WriteFile(path, test.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			// malformed files fail or come out empty, but never panic
+			documents, err := PDFLoader{}.Load(path)
+			if err == nil && len(documents) > 0 {
+				t.Errorf("got %d documents from a malformed file", len(documents))
+			}
+		})
+	}
+}
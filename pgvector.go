@@ -0,0 +1,228 @@
+package gopheract
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pattern of the table names accepted by the PgVectorStore, which are written in its queries
+var pgTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Implementation of VectorStore persisting the chunks and their embeddings to a Postgres database with the pgvector extension, for deployments that already run Postgres.
+//
+// The store works on a `*sql.DB` opened by the caller, so that the library does not depend on a specific Postgres driver (e.g. `github.com/jackc/pgx/v5/stdlib` or `github.com/lib/pq`): vectors are passed to the queries as text and cast to the vector type.
+type PgVectorStore struct {
+	DB *sql.DB
+	// Name of the table storing the chunks (defaults to "gopheract_chunks")
+	Table string
+	// Number of dimensions of the embeddings, which the table is created for (zero creates a column of any dimensions, which cannot be indexed)
+	Dimensions int
+	// Maximum number of records upserted by a single statement (defaults to 100)
+	BatchSize int
+}
+
+// Constructor function for a new PgVectorStore, creating its table if it does not exist yet (see `Migrate`)
+func NewPgVectorStore(ctx context.Context, db *sql.DB, table string, dimensions int) (*PgVectorStore, error) {
+	store := &PgVectorStore{DB: db, Table: table, Dimensions: dimensions}
+	if err := store.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Private method returning the name of the table of the store
+func (s *PgVectorStore) table() (string, error) {
+	table := s.Table
+	if table == "" {
+		table = "gopheract_chunks"
+	}
+	if !pgTablePattern.MatchString(table) {
+		return "", fmt.Errorf("invalid table name: %s", table)
+	}
+	return table, nil
+}
+
+// Method to create the vector extension, the table of the store and its indexes if they do not exist yet: an index on the documents of the chunks, and an HNSW index for cosine similarity when the dimensions are known
+func (s *PgVectorStore) Migrate(ctx context.Context) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	vectorType := "vector"
+	if s.Dimensions > 0 {
+		vectorType = fmt.Sprintf("vector(%d)", s.Dimensions)
+	}
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	text TEXT NOT NULL,
+	start_offset INTEGER NOT NULL DEFAULT 0,
+	end_offset INTEGER NOT NULL DEFAULT 0,
+	start_line INTEGER NOT NULL DEFAULT 0,
+	end_line INTEGER NOT NULL DEFAULT 0,
+	metadata JSONB NOT NULL DEFAULT '{}',
+	embedding %s NOT NULL
+)`, table, vectorType),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_document_idx ON %s (document_id)", table, table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_metadata_idx ON %s USING gin (metadata)", table, table),
+	}
+	if s.Dimensions > 0 {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING hnsw (embedding vector_cosine_ops)", table, table))
+	}
+	for _, statement := range statements {
+		if _, err := s.DB.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error while migrating the pgvector schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Private function formatting a vector as a pgvector literal
+func pgVector(vector []float32) string {
+	values := make([]string, len(vector))
+	for i, value := range vector {
+		values[i] = strconv.FormatFloat(float64(value), 'g', -1, 32)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+// Add records to the store in batches, within a transaction, replacing the records with the same chunk identifiers
+func (s *PgVectorStore) Add(ctx context.Context, records ...VectorRecord) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	// a statement cannot upsert the same row twice, so only the last record of every chunk is kept
+	last := make(map[string]int, len(records))
+	for i, record := range records {
+		last[record.ID] = i
+	}
+	unique := make([]VectorRecord, 0, len(last))
+	for i, record := range records {
+		if last[record.ID] == i {
+			unique = append(unique, record)
+		}
+	}
+	records = unique
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for start := 0; start < len(records); start += batchSize {
+		batch := records[start:min(start+batchSize, len(records))]
+		rows := make([]string, 0, len(batch))
+		args := make([]any, 0, len(batch)*9)
+		for _, record := range batch {
+			metadata, err := json.Marshal(record.Metadata)
+			if err != nil {
+				return fmt.Errorf("invalid metadata for chunk %s: %w", record.ID, err)
+			}
+			if record.Metadata == nil {
+				metadata = []byte("{}")
+			}
+			n := len(args)
+			rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d::vector)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9))
+			args = append(args, record.ID, record.DocumentID, record.Text, record.Start, record.End, record.StartLine, record.EndLine, string(metadata), pgVector(record.Embedding))
+		}
+		statement := fmt.Sprintf(`INSERT INTO %s (id, document_id, text, start_offset, end_offset, start_line, end_line, metadata, embedding) VALUES %s
+ON CONFLICT (id) DO UPDATE SET document_id = EXCLUDED.document_id, text = EXCLUDED.text, start_offset = EXCLUDED.start_offset, end_offset = EXCLUDED.end_offset,
+	start_line = EXCLUDED.start_line, end_line = EXCLUDED.end_line, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`, table, strings.Join(rows, ", "))
+		if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+			return fmt.Errorf("error while adding the chunks: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Retrieve the chunks most similar to an embedding, by cosine similarity. The filter of the query is matched with JSONB containment, so its values must have the same JSON types as the metadata.
+func (s *PgVectorStore) Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error) {
+	table, err := s.table()
+	if err != nil {
+		return nil, err
+	}
+	filter := []byte("{}")
+	if len(query.Filter) > 0 {
+		if filter, err = json.Marshal(query.Filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT id, document_id, text, start_offset, end_offset, start_line, end_line, metadata, 1 - (embedding <=> $1::vector) AS score
+FROM %s
+WHERE metadata @> $2::jsonb AND 1 - (embedding <=> $1::vector) >= $3
+ORDER BY embedding <=> $1::vector
+LIMIT $4`, table), pgVector(embedding), string(filter), query.MinScore, query.topK())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scored := []ScoredChunk{}
+	for rows.Next() {
+		var chunk ScoredChunk
+		var metadata []byte
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.Text, &chunk.Start, &chunk.End, &chunk.StartLine, &chunk.EndLine, &metadata, &chunk.Score); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &chunk.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for chunk %s: %w", chunk.ID, err)
+		}
+		scored = append(scored, chunk)
+	}
+	return scored, rows.Err()
+}
+
+// List the chunks of the store
+func (s *PgVectorStore) Chunks(ctx context.Context) ([]Chunk, error) {
+	table, err := s.table()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf("SELECT id, document_id, text, start_offset, end_offset, start_line, end_line, metadata FROM %s", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	chunks := []Chunk{}
+	for rows.Next() {
+		var chunk Chunk
+		var metadata []byte
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.Text, &chunk.Start, &chunk.End, &chunk.StartLine, &chunk.EndLine, &metadata); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &chunk.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for chunk %s: %w", chunk.ID, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// Delete the records of the chunks of the given documents
+func (s *PgVectorStore) Delete(ctx context.Context, documentIDs ...string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, len(documentIDs))
+	args := make([]any, len(documentIDs))
+	for i, id := range documentIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	_, err = s.DB.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE document_id IN (%s)", table, strings.Join(placeholders, ", ")), args...)
+	return err
+}
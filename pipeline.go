@@ -0,0 +1,128 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Type representing a function that builds the arguments of a pipeline step, given the arguments the pipeline was called with and the output of the previous step (nil for the first step).
+type PipelineMapping func(input map[string]any, previous any) (map[string]any, error)
+
+// Struct type representing a step of a pipeline: a tool and the mapping that builds its arguments.
+type PipelineStep struct {
+	Tool Tool
+	// Mapping used to build the arguments of the tool (if nil, the arguments of the pipeline are passed to the first step, and the output of the previous step is passed to the following ones, which must then be a map)
+	Map PipelineMapping
+}
+
+// Struct type representing a composite tool that chains existing tools, feeding the output of each step into the next one.
+//
+// A Pipeline implements the `Tool` interface, so that common multi-step operations (e.g. fetch → extract → save) can be exposed to the agent as a single, reliable call.
+type Pipeline struct {
+	Name        string
+	Description string
+	// Parameters accepted by the pipeline (if empty, the ones of the first step are used)
+	ParametersMetadata []ToolParamsMetadata
+	Steps              []PipelineStep
+}
+
+// Constructor function for a new, empty Pipeline, given its name and description.
+func NewPipeline(name, description string) *Pipeline {
+	return &Pipeline{
+		Name:        name,
+		Description: description,
+	}
+}
+
+// Method to append a step to the pipeline, returning the pipeline itself so that calls can be chained.
+func (p *Pipeline) Then(tool Tool, mapping PipelineMapping) *Pipeline {
+	p.Steps = append(p.Steps, PipelineStep{Tool: tool, Map: mapping})
+	return p
+}
+
+// Helper function returning a mapping that passes the output of the previous step as the `param` argument of the next one, along with the arguments the pipeline was called with.
+func OutputAs(param string) PipelineMapping {
+	return func(input map[string]any, previous any) (map[string]any, error) {
+		args := make(map[string]any, len(input)+1)
+		for k, v := range input {
+			args[k] = v
+		}
+		args[param] = previous
+		return args, nil
+	}
+}
+
+// Helper method to get the metadata of the pipeline.
+//
+// The cost and latency of the pipeline are the highest among the ones of its steps.
+func (p *Pipeline) GetMetadata() ToolMetadata {
+	params := p.ParametersMetadata
+	if len(params) == 0 && len(p.Steps) > 0 {
+		params = p.Steps[0].Tool.GetMetadata().ParametersMetadata
+	}
+	costs := []ToolCost{ToolCostLow, ToolCostMedium, ToolCostHigh}
+	latencies := []ToolLatency{ToolLatencyFast, ToolLatencyModerate, ToolLatencySlow}
+	var cost ToolCost
+	var latency ToolLatency
+	steps := make([]string, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		meta := step.Tool.GetMetadata()
+		steps = append(steps, meta.Name)
+		if slices.Index(costs, meta.Cost) > slices.Index(costs, cost) {
+			cost = meta.Cost
+		}
+		if slices.Index(latencies, meta.Latency) > slices.Index(latencies, latency) {
+			latency = meta.Latency
+		}
+	}
+	return ToolMetadata{
+		Name:               p.Name,
+		Description:        fmt.Sprintf("%s (runs, in order: %s)", p.Description, strings.Join(steps, " → ")),
+		ParametersMetadata: params,
+		Cost:               cost,
+		Latency:            latency,
+	}
+}
+
+// Method returning the JSON schema of the parameters of the pipeline (the ones of its first step, unless specified).
+func (p *Pipeline) InputSchema() map[string]any {
+	if len(p.ParametersMetadata) == 0 && len(p.Steps) > 0 {
+		return ToolInputSchema(p.Steps[0].Tool)
+	}
+	return schemaFromMetadata(p.ParametersMetadata)
+}
+
+// Method to execute the pipeline, running each step in order and returning the output of the last one.
+func (p *Pipeline) Execute(args map[string]any) (any, error) {
+	if len(p.Steps) == 0 {
+		return nil, errors.New("the pipeline has no steps")
+	}
+	var previous any
+	for i, step := range p.Steps {
+		var stepArgs map[string]any
+		switch {
+		case step.Map != nil:
+			mapped, err := step.Map(args, previous)
+			if err != nil {
+				return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Tool.GetMetadata().Name, err)
+			}
+			stepArgs = mapped
+		case i == 0:
+			stepArgs = args
+		default:
+			mapped, ok := previous.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("step %d (%s): the output of the previous step is not a map and no mapping was provided", i+1, step.Tool.GetMetadata().Name)
+			}
+			stepArgs = mapped
+		}
+		output, err := step.Tool.Execute(stepArgs)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Tool.GetMetadata().Name, err)
+		}
+		previous = output
+	}
+	return previous, nil
+}
@@ -0,0 +1,90 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status of a step of the plan of the agent
+type PlanStepStatus string
+
+const (
+	PlanStepPending    PlanStepStatus = "pending"
+	PlanStepInProgress PlanStepStatus = "in_progress"
+	PlanStepCompleted  PlanStepStatus = "completed"
+)
+
+// Priority of a step of the plan of the agent
+type PlanStepPriority string
+
+const (
+	PlanStepHigh   PlanStepPriority = "high"
+	PlanStepMedium PlanStepPriority = "medium"
+	PlanStepLow    PlanStepPriority = "low"
+)
+
+// Struct type representing a step of the plan of the agent
+type PlanStep struct {
+	Content  string           `json:"content"`
+	Status   PlanStepStatus   `json:"status"`
+	Priority PlanStepPriority `json:"priority"`
+}
+
+// Struct type representing the parameters of the UpdatePlan tool
+type UpdatePlanParams struct {
+	Steps []PlanStep `json:"steps" description:"Complete list of the steps of the plan, each an object with a 'content' (string), a 'status' ('pending', 'in_progress' or 'completed') and a 'priority' ('high', 'medium' or 'low')"`
+}
+
+// Struct type representing a tool with which the agent publishes its plan for a task as a checklist, and updates the status of its steps as it works through them, so that frontends can render the progress of the task.
+//
+// The `OnUpdate` callback is provided by the host application, and receives the whole plan every time it changes.
+type UpdatePlanTool struct {
+	OnUpdate func(steps []PlanStep)
+}
+
+// Constructor function for a new UpdatePlanTool, given the callback receiving the plan every time it changes.
+func NewUpdatePlanTool(onUpdate func(steps []PlanStep)) *UpdatePlanTool {
+	return &UpdatePlanTool{OnUpdate: onUpdate}
+}
+
+// Method to publish the plan of the agent (steps without a status are pending, and steps without a priority have medium priority).
+func (p *UpdatePlanTool) Execute(params UpdatePlanParams) (any, error) {
+	if len(params.Steps) == 0 {
+		return nil, errors.New("no steps provided")
+	}
+	completed := 0
+	for i := range params.Steps {
+		step := &params.Steps[i]
+		switch step.Status {
+		case "":
+			step.Status = PlanStepPending
+		case PlanStepCompleted:
+			completed += 1
+		case PlanStepPending, PlanStepInProgress:
+		default:
+			return nil, fmt.Errorf("invalid status for step %d: %s", i+1, step.Status)
+		}
+		switch step.Priority {
+		case "":
+			step.Priority = PlanStepMedium
+		case PlanStepHigh, PlanStepMedium, PlanStepLow:
+		default:
+			return nil, fmt.Errorf("invalid priority for step %d: %s", i+1, step.Priority)
+		}
+	}
+	if p.OnUpdate != nil {
+		p.OnUpdate(params.Steps)
+	}
+	return fmt.Sprintf("Plan updated: %d of %d steps completed", completed, len(params.Steps)), nil
+}
+
+// Helper method to expose the UpdatePlan tool as a tool definition that can be passed to an agent.
+func (p *UpdatePlanTool) AsTool() ToolDefinition[UpdatePlanParams] {
+	return ToolDefinition[UpdatePlanParams]{
+		Name:        "UpdatePlan",
+		Description: "For tasks with several steps, publish your plan as a checklist before starting, and call again with the complete updated list whenever a step starts or is completed, by providing the steps (`steps` parameter - list of objects with `content`, `status` and `priority`). Keep at most one step in progress at a time.",
+		Fn:          p.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyFast,
+	}
+}
@@ -0,0 +1,193 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Error returned by `AgentPool.Run` when the queue of the pool is full
+var ErrPoolFull = errors.New("the agent pool is full")
+
+// Error returned by `AgentPool.Run` when the token budget of the pool is exhausted
+var ErrBudgetExhausted = errors.New("the token budget of the agent pool is exhausted")
+
+// Struct type representing a rate limiter spacing out the LLM requests evenly, shared by the LLMs it is set on
+type RateLimiter struct {
+	mu sync.Mutex
+	// interval between two requests
+	interval time.Duration
+	// time the next request can be sent at
+	next time.Time
+}
+
+// Constructor function for a new RateLimiter allowing the given number of requests per minute
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	return &RateLimiter{interval: time.Minute / time.Duration(max(requestsPerMinute, 1))}
+}
+
+// Method waiting until a request can be sent, or the context is cancelled (no-op on nil RateLimiter)
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.interval)
+	r.mu.Unlock()
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Struct type representing the state of an agent pool
+type AgentPoolStats struct {
+	// Runs in progress
+	Running int
+	// Runs waiting for a free slot
+	Queued int
+	// Tokens consumed by the runs of the pool
+	Usage TokenUsage
+}
+
+// Struct type representing a pool running a bounded number of agents at once, queuing the excess runs, with a rate limit and a token budget shared by all its runs, so that servers and batches neither spawn unbounded runs nor hammer the API.
+type AgentPool struct {
+	// Maximum number of runs at once (zero means no limit)
+	MaxConcurrent int
+	// Maximum number of runs waiting for a free slot, beyond which runs fail with ErrPoolFull (zero means no limit)
+	MaxQueued int
+	// Optional rate limiter the LLM requests of the runs wait for
+	RateLimiter *RateLimiter
+	// Maximum number of tokens consumed by all the runs of the pool, after which they fail with ErrBudgetExhausted (zero means no limit)
+	MaxTokens int64
+	mu        sync.Mutex
+	slots     chan struct{}
+	stats     AgentPoolStats
+}
+
+// Constructor function for a new AgentPool running at most maxConcurrent agents at once (zero means no limit)
+func NewAgentPool(maxConcurrent int) *AgentPool {
+	return &AgentPool{MaxConcurrent: maxConcurrent}
+}
+
+// Method returning the state of the pool
+func (p *AgentPool) Stats() AgentPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Method to run an agent on a prompt once a slot of the pool is free, like `RunEventsContext`.
+//
+// The agent is not modified: the run works on a shallow copy of it, sharing its memory and tools. A QueuedEvent is emitted if the run has to wait for a slot. The LLM requests of the run wait for the rate limiter of the pool, and the run is stopped with ErrBudgetExhausted once the runs of the pool have consumed its token budget.
+func (p *AgentPool) Run(ctx context.Context, agent *OpenAIReActAgent, prompt string, images []ImageContent, handler func(AgentEvent)) error {
+	release, err := p.acquire(ctx, handler)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if p.exhausted() {
+		return ErrBudgetExhausted
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var exceeded atomic.Bool
+	llm := agent.Llm
+	pooled := *llm
+	if p.RateLimiter != nil {
+		pooled.RateLimiter = p.RateLimiter
+	}
+	pooled.OnUsage = func(usage TokenUsage) {
+		p.mu.Lock()
+		p.stats.Usage = p.stats.Usage.Add(usage)
+		p.mu.Unlock()
+		if llm.OnUsage != nil {
+			llm.OnUsage(usage)
+		}
+		if p.exhausted() && exceeded.CompareAndSwap(false, true) {
+			cancel()
+		}
+	}
+	// the run works on a copy of the agent, so that concurrent runs of the same agent never see the LLM of each other
+	run := *agent
+	run.Llm = &pooled
+	err = run.RunEventsContext(ctx, prompt, images, handler)
+	if exceeded.Load() {
+		return ErrBudgetExhausted
+	}
+	return err
+}
+
+// Private method waiting for a free slot, returning the function releasing it
+func (p *AgentPool) acquire(ctx context.Context, handler func(AgentEvent)) (func(), error) {
+	p.mu.Lock()
+	if p.MaxConcurrent > 0 && p.slots == nil {
+		p.slots = make(chan struct{}, p.MaxConcurrent)
+	}
+	slots := p.slots
+	p.mu.Unlock()
+	release := func() {
+		p.mu.Lock()
+		p.stats.Running--
+		p.mu.Unlock()
+		if slots != nil {
+			<-slots
+		}
+	}
+	if slots == nil {
+		p.mu.Lock()
+		p.stats.Running++
+		p.mu.Unlock()
+		return release, nil
+	}
+	select {
+	case slots <- struct{}{}:
+	default:
+		p.mu.Lock()
+		if p.MaxQueued > 0 && p.stats.Queued >= p.MaxQueued {
+			p.mu.Unlock()
+			return nil, ErrPoolFull
+		}
+		p.stats.Queued++
+		position := p.stats.Queued
+		p.mu.Unlock()
+		handler(QueuedEvent{EventInfo: EventInfo{Time: time.Now().UTC()}, Position: position})
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.stats.Queued--
+			p.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+		p.stats.Queued--
+		p.mu.Unlock()
+	}
+	p.mu.Lock()
+	p.stats.Running++
+	p.mu.Unlock()
+	return release, nil
+}
+
+// Private method returning whether the runs of the pool have consumed its token budget
+func (p *AgentPool) exhausted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.MaxTokens > 0 && p.stats.Usage.TotalTokens >= p.MaxTokens
+}
@@ -0,0 +1,159 @@
+package gopheract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Base interface for the stores holding durable facts about the user and their work (preferences, environment, conventions), injected into the system prompt of future runs
+type ProfileStore interface {
+	// List the known facts
+	Facts() ([]string, error)
+	// Add facts to the profile (facts already known are ignored)
+	AddFacts(facts ...string) error
+}
+
+// Struct type representing the facts extracted from a run
+type ExtractedFacts struct {
+	Facts []string `json:"facts" jsonschema_description:"New durable facts about the user, their preferences, their project or their environment, worth remembering in future conversations (e.g. 'The project uses Go 1.22 and Postgres'). Empty if there are none."`
+}
+
+// Implementation of ProfileStore keeping the facts in memory
+type InMemoryProfileStore struct {
+	mu    sync.RWMutex
+	facts []string
+}
+
+// Constructor function for a new, empty InMemoryProfileStore
+func NewInMemoryProfileStore() *InMemoryProfileStore {
+	return &InMemoryProfileStore{}
+}
+
+// List the known facts
+func (p *InMemoryProfileStore) Facts() ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Clone(p.facts), nil
+}
+
+// Add facts to the profile
+func (p *InMemoryProfileStore) AddFacts(facts ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.facts = mergeFacts(p.facts, facts)
+	return nil
+}
+
+// Implementation of ProfileStore persisting the facts to a JSON file
+type FileProfileStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Constructor function for a new FileProfileStore, given the path of the JSON file (created on the first write)
+func NewFileProfileStore(path string) *FileProfileStore {
+	return &FileProfileStore{Path: path}
+}
+
+// Private method reading the facts from the file
+func (p *FileProfileStore) read() ([]string, error) {
+	data, err := os.ReadFile(p.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	facts := []string{}
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// List the known facts
+func (p *FileProfileStore) Facts() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read()
+}
+
+// Add facts to the profile, rewriting the file
+func (p *FileProfileStore) AddFacts(facts ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	known, err := p.read()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mergeFacts(known, facts), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.Path, data, 0o600)
+}
+
+// Private function adding new facts to the known ones, skipping blanks and duplicates (case-insensitively)
+func mergeFacts(known, facts []string) []string {
+	for _, fact := range facts {
+		fact = strings.TrimSpace(fact)
+		if fact == "" || slices.ContainsFunc(known, func(k string) bool { return strings.EqualFold(k, fact) }) {
+			continue
+		}
+		known = append(known, fact)
+	}
+	return known
+}
+
+// Private function rendering the known facts as a section of the system prompt
+func profilePromptSection(facts []string) string {
+	if len(facts) == 0 {
+		return ""
+	}
+	return "\n\n## What you know about the user\n\n- " + strings.Join(facts, "\n- ")
+}
+
+// Private function returning the messages of the latest run in the chat history, starting from the user request
+func currentRunMessages(history []*ChatMessage) []*ChatMessage {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Phase == MessagePhasePrompt && history[i].Role == "user" {
+			return history[i:]
+		}
+	}
+	return history
+}
+
+// Helper method extracting durable facts from the messages of a run and adding them to the profile of the agent
+func (o *OpenAIReActAgent) extractFacts(runMessages []*ChatMessage) error {
+	known, err := o.Profile.Facts()
+	if err != nil {
+		return err
+	}
+	var transcript strings.Builder
+	for _, message := range o.redact(runMessages) {
+		if message.Role == "system" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "[%s]: %s\n\n", message.Role, message.Content)
+	}
+	extractionHistory := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You extract durable facts about the user from a conversation between them and an AI agent: preferences, conventions, details of their project and environment that will still be true in future conversations. Do not extract facts about the task at hand only, and do not repeat the facts that are already known.\n\nAlready known facts:\n- " + strings.Join(known, "\n- ")),
+		openai.UserMessage(transcript.String()),
+	}
+	response, err := OpenAILLMStructuredPredict[ExtractedFacts](o.Llm, extractionHistory, "facts", "Durable facts extracted from the conversation")
+	if err != nil {
+		return err
+	}
+	extracted, ok := response.(ExtractedFacts)
+	if !ok {
+		return errors.New("error while extracting facts: unexpected structured output")
+	}
+	return o.Profile.AddFacts(extracted.Facts...)
+}
@@ -0,0 +1,134 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Implementation of VectorStore backed by a collection of a Qdrant server, through its REST API.
+//
+// Every chunk is stored as a point identified by a UUID derived from the chunk identifier, with the chunk as its payload: the metadata of the chunk is under the "metadata" key of the payload, so that queries can filter on it.
+type QdrantStore struct {
+	// Base URL of the Qdrant server (defaults to "http://localhost:6333")
+	URL string
+	// Optional API key of the server
+	APIKey     string
+	Collection string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new QdrantStore using a collection of a Qdrant server, which can be created with `EnsureCollection`
+func NewQdrantStore(url, apiKey, collection string) *QdrantStore {
+	return &QdrantStore{
+		URL:        url,
+		APIKey:     apiKey,
+		Collection: collection,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Private method sending a request to the API of the collection of the store (path is relative to the collection)
+func (s *QdrantStore) request(ctx context.Context, method, path string, payload, result any) error {
+	base := s.URL
+	if base == "" {
+		base = "http://localhost:6333"
+	}
+	endpoint := strings.TrimSuffix(base, "/") + "/collections/" + url.PathEscape(s.Collection) + path
+	return apiRequest(ctx, s.Client, method, endpoint, payload, result, func(req *http.Request) {
+		if s.APIKey != "" {
+			req.Header.Set("api-key", s.APIKey)
+		}
+	})
+}
+
+// Method to create the collection of the store for embeddings of the given dimensions (compared by cosine similarity) if it does not exist yet, along with the index of the documents of the chunks
+func (s *QdrantStore) EnsureCollection(ctx context.Context, dimensions int) error {
+	err := s.request(ctx, http.MethodGet, "", nil, nil)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.status != http.StatusNotFound {
+		return err
+	}
+	collection := map[string]any{"vectors": map[string]any{"size": dimensions, "distance": "Cosine"}}
+	if err := s.request(ctx, http.MethodPut, "", collection, nil); err != nil {
+		return fmt.Errorf("error while creating the collection %s: %w", s.Collection, err)
+	}
+	index := map[string]any{"field_name": "document_id", "field_schema": "keyword"}
+	return s.request(ctx, http.MethodPut, "/index?wait=true", index, nil)
+}
+
+// Method to delete the collection of the store, with all its points
+func (s *QdrantStore) DeleteCollection(ctx context.Context) error {
+	return s.request(ctx, http.MethodDelete, "", nil, nil)
+}
+
+// Add records to the collection, replacing the points of the same chunks
+func (s *QdrantStore) Add(ctx context.Context, records ...VectorRecord) error {
+	for start := 0; start < len(records); start += 100 {
+		batch := records[start:min(start+100, len(records))]
+		points := make([]map[string]any, len(batch))
+		for i, record := range batch {
+			points[i] = map[string]any{
+				"id":      traceUUID(record.ID),
+				"vector":  record.Embedding,
+				"payload": record.Chunk,
+			}
+		}
+		if err := s.request(ctx, http.MethodPut, "/points?wait=true", map[string]any{"points": points}, nil); err != nil {
+			return fmt.Errorf("error while adding the chunks: %w", err)
+		}
+	}
+	return nil
+}
+
+// Retrieve the chunks most similar to an embedding. Every value of the filter of the query must match the metadata value with the same key, or one of its elements if it is a list.
+func (s *QdrantStore) Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error) {
+	search := map[string]any{
+		"vector":       embedding,
+		"limit":        query.topK(),
+		"with_payload": true,
+	}
+	if query.MinScore != 0 {
+		search["score_threshold"] = query.MinScore
+	}
+	if len(query.Filter) > 0 {
+		conditions := make([]map[string]any, 0, len(query.Filter))
+		for key, value := range query.Filter {
+			conditions = append(conditions, map[string]any{"key": "metadata." + key, "match": map[string]any{"value": value}})
+		}
+		search["filter"] = map[string]any{"must": conditions}
+	}
+	var response struct {
+		Result []struct {
+			Score   float32         `json:"score"`
+			Payload json.RawMessage `json:"payload"`
+		} `json:"result"`
+	}
+	if err := s.request(ctx, http.MethodPost, "/points/search", search, &response); err != nil {
+		return nil, err
+	}
+	scored := make([]ScoredChunk, 0, len(response.Result))
+	for _, point := range response.Result {
+		chunk := ScoredChunk{Score: point.Score}
+		if err := json.Unmarshal(point.Payload, &chunk.Chunk); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		scored = append(scored, chunk)
+	}
+	return scored, nil
+}
+
+// Delete the points of the chunks of the given documents
+func (s *QdrantStore) Delete(ctx context.Context, documentIDs ...string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	filter := map[string]any{"must": []map[string]any{{"key": "document_id", "match": map[string]any{"any": documentIDs}}}}
+	return s.request(ctx, http.MethodPost, "/points/delete?wait=true", map[string]any{"filter": filter}, nil)
+}
@@ -0,0 +1,385 @@
+package gopheract
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Base interface for the filters redacting sensitive data (API keys, secrets, emails...) from messages before they are sent to the LLM provider
+type RedactionFilter interface {
+	// Name of the filter, used in redaction reports
+	Name() string
+	// Redact a text, returning the redacted text and the number of redacted occurrences
+	Redact(text string) (string, int)
+}
+
+// Interface that the redaction filters can implement to report where their matches are, so that a redactor can replace them with placeholders it is able to restore
+type RedactionMatcher interface {
+	// Return the byte offsets of the start and the end of every match in a text
+	Matches(text string) [][]int
+}
+
+// Implementation of RedactionFilter (and RedactionMatcher) replacing the matches of a regular expression
+type RegexRedactionFilter struct {
+	FilterName string
+	Pattern    *regexp.Regexp
+	// Text replacing the matches (defaults to "[REDACTED:<name>]")
+	Replacement string
+	// Optional check of the matches, the ones failing it being left untouched (e.g. the checksum of card numbers)
+	Validate func(match string) bool
+}
+
+// Constructor function for a new RegexRedactionFilter, given its name and the regular expression matching the data to redact
+func NewRegexRedactionFilter(name, pattern string) (*RegexRedactionFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for redaction filter %s: %w", name, err)
+	}
+	return &RegexRedactionFilter{FilterName: name, Pattern: re}, nil
+}
+
+// Name of the filter
+func (f *RegexRedactionFilter) Name() string {
+	return f.FilterName
+}
+
+// Redact the matches of the regular expression in a text
+func (f *RegexRedactionFilter) Redact(text string) (string, int) {
+	replacement := f.Replacement
+	if replacement == "" {
+		replacement = fmt.Sprintf("[REDACTED:%s]", f.FilterName)
+	}
+	count := 0
+	redacted := f.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if f.Validate != nil && !f.Validate(match) {
+			return match
+		}
+		count++
+		return replacement
+	})
+	return redacted, count
+}
+
+// Return the byte offsets of the matches of the regular expression that pass the validation
+func (f *RegexRedactionFilter) Matches(text string) [][]int {
+	matches := f.Pattern.FindAllStringIndex(text, -1)
+	if f.Validate == nil {
+		return matches
+	}
+	valid := matches[:0]
+	for _, match := range matches {
+		if f.Validate(text[match[0]:match[1]]) {
+			valid = append(valid, match)
+		}
+	}
+	return valid
+}
+
+// Private function checking the Luhn checksum of a card number, ignoring its separators
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			continue
+		}
+		digit := int(number[i] - '0')
+		if digits%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// Pattern of the dates a phone number match can start with, e.g. 2026-10-16 or 16/10/2026
+var phoneDatePattern = regexp.MustCompile(`^(?:\d{4}[\-/.]\d{1,2}[\-/.]\d{1,2}|\d{1,2}[\-/.]\d{1,2}[\-/.]\d{4})\b`)
+
+// Private function checking that a phone number match is not a date nor a timestamp: it has as many digits as the international format allows, and the groups of digits after the country and area codes are separated consistently (a date followed by a time, such as 2026-10-16 13, mixes separators)
+func phoneValid(number string) bool {
+	if phoneDatePattern.MatchString(number) {
+		return false
+	}
+	digits := 0
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 9 || digits > 15 {
+		return false
+	}
+	rest := number
+	if strings.HasPrefix(rest, "+") {
+		rest = strings.TrimLeft(rest[1:], "0123456789")
+		rest = strings.TrimLeft(rest, " -")
+	}
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			rest = strings.TrimLeft(rest[end+1:], " -")
+		}
+	}
+	separator := rune(0)
+	for _, r := range rest {
+		if r >= '0' && r <= '9' {
+			continue
+		}
+		if separator != 0 && r != separator {
+			return false
+		}
+		separator = r
+	}
+	return true
+}
+
+// Function returning the built-in detectors for common secrets and personal data: OpenAI, Anthropic, AWS, GitHub, Slack and Google API keys, bearer tokens, private keys, generic `key=value` secrets, email addresses, card numbers and phone numbers
+func DefaultRedactionFilters() []RedactionFilter {
+	patterns := []struct{ name, pattern string }{
+		{"private_key", `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+		{"anthropic_key", `\bsk-ant-[A-Za-z0-9_\-]{20,}`},
+		{"openai_key", `\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_\-]{20,}`},
+		{"aws_access_key", `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+		{"github_token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`},
+		{"slack_token", `\bxox[abposr]-[A-Za-z0-9\-]{10,}`},
+		{"google_api_key", `\bAIza[0-9A-Za-z_\-]{35}`},
+		{"bearer_token", `(?i)\bbearer\s+[A-Za-z0-9_\-\.=]{16,}`},
+		{"secret", `(?i)\b(?:api[_-]?key|secret|password|passwd|token)\s*[:=]\s*["']?[^\s"']{8,}`},
+		{"email", `\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`},
+	}
+	filters := make([]RedactionFilter, 0, len(patterns)+2)
+	for _, p := range patterns {
+		filters = append(filters, &RegexRedactionFilter{FilterName: p.name, Pattern: regexp.MustCompile(p.pattern)})
+	}
+	// card numbers come before phone numbers, which they would otherwise match
+	filters = append(filters,
+		&RegexRedactionFilter{FilterName: "credit_card", Pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`), Validate: luhnValid},
+		&RegexRedactionFilter{FilterName: "phone", Pattern: regexp.MustCompile(`(?:\+\d{1,3}[ \-]?)?(?:\(\d{1,4}\)[ \-]?|\b\d{1,4}[ \-])(?:\d{2,4}[ \-]){1,3}\d{2,4}\b`), Validate: phoneValid},
+	)
+	return filters
+}
+
+// Struct type representing what was redacted from a batch of messages: the number of redacted occurrences, by filter name
+type RedactionReport map[string]int
+
+// Pattern of the placeholders replacing the redacted values that can be restored, e.g. [EMAIL_1]
+var placeholderPattern = regexp.MustCompile(`\[[A-Z][A-Z0-9_]*_\d+\]`)
+
+// Struct type applying redaction filters to the messages sent to the LLM provider.
+//
+// Redaction only affects what is sent to the provider: the messages in the memory of the agent are left untouched. With `Restore`, every redacted value is replaced with a numbered placeholder (e.g. [EMAIL_1], the same one every time the value occurs), and the agent puts the values back locally in the arguments of the tool calls and in the final answers, so that the model can work with personal data it never sees.
+//
+// The placeholders of an agent are scoped to its run, and forgotten when the run ends, so that the placeholders of a session never restore the values of another session sharing the redactor. The methods of the redactor use a scope of their own, which `Forget` clears.
+type Redactor struct {
+	Filters []RedactionFilter
+	// Optional callback receiving a report every time something is redacted
+	OnRedact func(RedactionReport)
+	// Whether the matches of the filters implementing RedactionMatcher are replaced with placeholders that are restored locally, instead of fixed markers
+	Restore bool
+	// Optional names of the tools whose arguments keep the placeholders, e.g. the tools sending data to third parties (only with Restore)
+	RedactedTools []string
+
+	mu sync.Mutex
+	// placeholders, by scope
+	vaults map[string]*redactionVault
+}
+
+// Private struct type holding the placeholders of a scope: the placeholders by redacted value, the redacted values by placeholder and the number of placeholders created by filter name
+type redactionVault struct {
+	placeholders map[string]string
+	values       map[string]string
+	counts       map[string]int
+}
+
+// Constructor function for a new Redactor with the built-in filters, followed by the given ones
+func NewRedactor(filters ...RedactionFilter) *Redactor {
+	return &Redactor{Filters: append(DefaultRedactionFilters(), filters...)}
+}
+
+// Method redacting a text, returning the redacted text and what was redacted
+func (r *Redactor) RedactText(text string) (string, RedactionReport) {
+	return r.redactText("", text)
+}
+
+// Private method redacting a text with the placeholders of a scope
+func (r *Redactor) redactText(scope, text string) (string, RedactionReport) {
+	report := RedactionReport{}
+	for _, filter := range r.Filters {
+		var count int
+		if matcher, ok := filter.(RedactionMatcher); ok && r.Restore {
+			text, count = r.replaceMatches(scope, text, filter.Name(), matcher.Matches(text))
+		} else {
+			text, count = filter.Redact(text)
+		}
+		if count > 0 {
+			report[filter.Name()] += count
+		}
+	}
+	return text, report
+}
+
+// Private method replacing the matches of a filter with their placeholders in a scope, returning the redacted text and the number of matches
+func (r *Redactor) replaceMatches(scope, text, name string, matches [][]int) (string, int) {
+	if len(matches) == 0 {
+		return text, 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.vaults == nil {
+		r.vaults = map[string]*redactionVault{}
+	}
+	vault, ok := r.vaults[scope]
+	if !ok {
+		vault = &redactionVault{placeholders: map[string]string{}, values: map[string]string{}, counts: map[string]int{}}
+		r.vaults[scope] = vault
+	}
+	var redacted strings.Builder
+	last := 0
+	for _, match := range matches {
+		value := text[match[0]:match[1]]
+		placeholder, ok := vault.placeholders[value]
+		if !ok {
+			vault.counts[name]++
+			placeholder = fmt.Sprintf("[%s_%d]", strings.ToUpper(name), vault.counts[name])
+			vault.placeholders[value] = placeholder
+			vault.values[placeholder] = value
+		}
+		redacted.WriteString(text[last:match[0]])
+		redacted.WriteString(placeholder)
+		last = match[1]
+	}
+	redacted.WriteString(text[last:])
+	return redacted.String(), len(matches)
+}
+
+// Method putting back the values replaced with placeholders by `RedactText` and `RedactMessages` in a text (placeholders that the redactor did not create are left untouched)
+func (r *Redactor) RestoreText(text string) string {
+	return r.restoreText("", text)
+}
+
+// Private method putting back the values replaced with the placeholders of a scope in a text
+func (r *Redactor) restoreText(scope, text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	vault, ok := r.vaults[scope]
+	if !ok {
+		return text
+	}
+	return vault.restore(text, map[string]bool{})
+}
+
+// Private method putting back the values of the placeholders in a text, in one pass: the values matched by a filter can hold the placeholders of the filters applied before it, which are restored as well, but never the placeholders being restored (a value can hold its own placeholder, when it was in the redacted text)
+func (v *redactionVault) restore(text string, restoring map[string]bool) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := v.values[placeholder]
+		if !ok || restoring[placeholder] {
+			return placeholder
+		}
+		restoring[placeholder] = true
+		defer delete(restoring, placeholder)
+		return v.restore(value, restoring)
+	})
+}
+
+// Private method putting back the redacted values of a scope in the strings of a JSON value (as decoded into `any`)
+func (r *Redactor) restoreJSONValue(scope string, value any) any {
+	switch v := value.(type) {
+	case string:
+		return r.restoreText(scope, v)
+	case []any:
+		for i := range v {
+			v[i] = r.restoreJSONValue(scope, v[i])
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = r.restoreJSONValue(scope, v[key])
+		}
+	}
+	return value
+}
+
+// Method forgetting the placeholders created by `RedactText` and `RedactMessages`, after which they are not restored anymore
+func (r *Redactor) Forget() {
+	r.forget("")
+}
+
+// Private method forgetting the placeholders of a scope
+func (r *Redactor) forget(scope string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.vaults, scope)
+}
+
+// Method redacting a batch of messages, returning redacted copies of the messages (the input ones are left untouched) and what was redacted.
+//
+// The `OnRedact` callback is called if anything was redacted.
+func (r *Redactor) RedactMessages(messages []*ChatMessage) ([]*ChatMessage, RedactionReport) {
+	return r.redactMessages("", messages)
+}
+
+// Private method redacting a batch of messages with the placeholders of a scope
+func (r *Redactor) redactMessages(scope string, messages []*ChatMessage) ([]*ChatMessage, RedactionReport) {
+	redacted := make([]*ChatMessage, 0, len(messages))
+	report := RedactionReport{}
+	for _, message := range messages {
+		content, messageReport := r.redactText(scope, message.Content)
+		if len(messageReport) == 0 {
+			redacted = append(redacted, message)
+			continue
+		}
+		clone := *message
+		clone.Content = content
+		redacted = append(redacted, &clone)
+		for name, count := range messageReport {
+			report[name] += count
+		}
+	}
+	if len(report) > 0 && r.OnRedact != nil {
+		r.OnRedact(report)
+	}
+	return redacted, report
+}
+
+// Helper method redacting messages with the redactor of the agent, if one is configured
+func (o *OpenAIReActAgent) redact(messages []*ChatMessage) []*ChatMessage {
+	if o.Redactor == nil {
+		return messages
+	}
+	redacted, report := o.Redactor.redactMessages(o.redactionScope, messages)
+	if len(report) > 0 {
+		o.logger().Info("redacted sensitive data", "redactions", map[string]int(report))
+	}
+	return redacted
+}
+
+// Helper method scoping the placeholders of the redactor of the agent to a run (or to a compaction outside of runs), returning the function forgetting them when it ends. Nested scopes, such as the runs of the sub-agents copied from the agent during a run, share the placeholders of the outer one.
+func (o *OpenAIReActAgent) beginRedactionScope(scope string) func() {
+	if o.Redactor == nil || o.redactionScope != "" {
+		return func() {}
+	}
+	o.redactionScope = scope
+	return func() {
+		o.Redactor.forget(scope)
+		o.redactionScope = ""
+	}
+}
+
+// Helper method putting back the values redacted by the redactor of the agent in the arguments of a tool call, unless the tool keeps the placeholders
+func (o *OpenAIReActAgent) restoreArgs(tool string, args map[string]any) map[string]any {
+	if o.Redactor == nil || !o.Redactor.Restore || slices.Contains(o.Redactor.RedactedTools, tool) {
+		return args
+	}
+	return o.Redactor.restoreJSONValue(o.redactionScope, args).(map[string]any)
+}
+
+// Helper method putting back the values redacted by the redactor of the agent in a text
+func (o *OpenAIReActAgent) restore(text string) string {
+	if o.Redactor == nil || !o.Redactor.Restore {
+		return text
+	}
+	return o.Redactor.restoreText(o.redactionScope, text)
+}
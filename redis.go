@@ -0,0 +1,213 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Error returned by `RedisStore.AppendAt` when the history of the session was modified by another process in the meantime
+var ErrSessionConflict = errors.New("the session history was modified concurrently")
+
+// Implementation of HistoryStore keeping the history of each session in a Redis list, so that any replica of a horizontally scaled deployment can continue a session.
+//
+// Retention is handled by Redis itself, through the expiration of the sessions (and the eviction policy of the server).
+type RedisStore struct {
+	Client redis.UniversalClient
+	// Prefix of the keys holding the sessions (defaults to "gopheract:session:")
+	Prefix string
+	// Expiration of the sessions, refreshed every time they are written (zero means no expiration)
+	TTL time.Duration
+}
+
+// Constructor function for a new RedisStore, given a Redis client and the expiration of the sessions.
+func NewRedisStore(client redis.UniversalClient, ttl time.Duration) *RedisStore {
+	return &RedisStore{
+		Client: client,
+		Prefix: "gopheract:session:",
+		TTL:    ttl,
+	}
+}
+
+// Private method returning the key of the list holding the history of a session
+func (s *RedisStore) key(sessionID string) string {
+	return s.Prefix + sessionID
+}
+
+// Private function serializing messages to be pushed to a Redis list
+func encodeMessages(messages []*ChatMessage) ([]any, error) {
+	values := make([]any, 0, len(messages))
+	for _, message := range messages {
+		encoded, err := json.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, encoded)
+	}
+	return values, nil
+}
+
+// Private function deserializing messages read from a Redis list
+func decodeMessages(values []string) ([]*ChatMessage, error) {
+	messages := make([]*ChatMessage, 0, len(values))
+	for _, value := range values {
+		var message ChatMessage
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, nil
+}
+
+// Private method queueing the commands that append messages to a session and refresh its expiration
+func (s *RedisStore) push(ctx context.Context, pipe redis.Pipeliner, key string, values []any) {
+	pipe.RPush(ctx, key, values...)
+	if s.TTL > 0 {
+		pipe.Expire(ctx, key, s.TTL)
+	}
+}
+
+// Append messages to the history of a session
+func (s *RedisStore) Append(sessionID string, messages ...*ChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	values, err := encodeMessages(messages)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.push(ctx, pipe, s.key(sessionID), values)
+		return nil
+	})
+	return err
+}
+
+// Append messages to the history of a session only if it still holds `expected` messages, returning ErrSessionConflict otherwise.
+//
+// This allows a replica to detect that another one continued the session since it last listed the history, instead of interleaving messages.
+func (s *RedisStore) AppendAt(sessionID string, expected int, messages ...*ChatMessage) error {
+	values, err := encodeMessages(messages)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key := s.key(sessionID)
+	err = s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		length, err := tx.LLen(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if int(length) != expected {
+			return ErrSessionConflict
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			s.push(ctx, pipe, key, values)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrSessionConflict
+	}
+	return err
+}
+
+// List the messages in the history of a session
+func (s *RedisStore) List(sessionID string) ([]*ChatMessage, error) {
+	values, err := s.Client.LRange(context.Background(), s.key(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(values)
+}
+
+// Trim the history of a session, keeping only the newest `keep` messages along with the pinned ones.
+//
+// The list is rewritten in a transaction, which is retried if the session is modified concurrently.
+func (s *RedisStore) Trim(sessionID string, keep int) error {
+	ctx := context.Background()
+	key := s.key(sessionID)
+	for {
+		err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+			values, err := tx.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			messages, err := decodeMessages(values)
+			if err != nil {
+				return err
+			}
+			kept := trimMessages(messages, keep)
+			if len(kept) == len(messages) {
+				return nil
+			}
+			encoded, err := encodeMessages(kept)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, key)
+				if len(encoded) > 0 {
+					s.push(ctx, pipe, key, encoded)
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+}
+
+// Clear the history of a session
+func (s *RedisStore) Clear(sessionID string) error {
+	return s.Client.Del(context.Background(), s.key(sessionID)).Err()
+}
+
+// Replace the history of a session with the given messages, in a single MULTI/EXEC transaction
+func (s *RedisStore) Replace(sessionID string, messages ...*ChatMessage) error {
+	values, err := encodeMessages(messages)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key := s.key(sessionID)
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(values) > 0 {
+			s.push(ctx, pipe, key, values)
+		}
+		return nil
+	})
+	return err
+}
+
+// List the persisted sessions. Redis does not track when sessions were created or updated, so only their identifier and number of messages are reported.
+func (s *RedisStore) ListSessions() ([]SessionInfo, error) {
+	ctx := context.Background()
+	sessions := []SessionInfo{}
+	iter := s.Client.Scan(ctx, 0, s.Prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		count, err := s.Client.LLen(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:           strings.TrimPrefix(key, s.Prefix),
+			MessageCount: int(count),
+		})
+	}
+	return sessions, iter.Err()
+}
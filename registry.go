@@ -0,0 +1,81 @@
+package gopheract
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Struct type representing a registry of tools, keyed by name.
+//
+// The registry keeps the registration order, so that tools are always listed (and rendered into prompts or exported) in the same order.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	names []string
+	tools map[string]Tool
+}
+
+// Constructor function for a new ToolRegistry, optionally pre-populated with tools.
+func NewToolRegistry(tools ...Tool) (*ToolRegistry, error) {
+	r := &ToolRegistry{tools: map[string]Tool{}}
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Method to add a tool to the registry. An error is returned if a tool with the same name is already registered.
+func (r *ToolRegistry) Register(tool Tool) error {
+	name := tool.GetMetadata().Name
+	if name == "" {
+		return fmt.Errorf("cannot register a tool without a name")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tools == nil {
+		r.tools = map[string]Tool{}
+	}
+	if _, ok := r.tools[name]; ok {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+	r.names = append(r.names, name)
+	r.tools[name] = tool
+	return nil
+}
+
+// Method to remove a tool from the registry, returning whether it was registered.
+func (r *ToolRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; !ok {
+		return false
+	}
+	delete(r.tools, name)
+	for i, n := range r.names {
+		if n == name {
+			r.names = append(r.names[:i], r.names[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Method to retrieve a tool by name.
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Method returning the registered tools, in registration order. The result can be passed as the `Tools` of an agent.
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.names))
+	for _, name := range r.names {
+		tools = append(tools, r.tools[name])
+	}
+	return tools
+}
@@ -0,0 +1,40 @@
+package gopheract
+
+// Struct type representing a system reminder re-injected into the chat history periodically (e.g. safety rules or coding conventions), so that it stays fresh in long sessions
+type Reminder struct {
+	Content string
+	// Number of turns of the session (prompts of the user) between two injections of the reminder (the reminder is injected at the start of turns Every, 2*Every, ...)
+	Every int
+}
+
+// Private function returning the reminders due at the start of a turn, given the chat history of the session including the prompt of the turn: the turns are counted over the whole session, from the last injection of each reminder
+func dueReminders(reminders []Reminder, history []*ChatMessage) []*ChatMessage {
+	due := []*ChatMessage{}
+	for _, reminder := range reminders {
+		if reminder.Every <= 0 {
+			continue
+		}
+		turns := 0
+		for i := len(history) - 1; i >= 0; i-- {
+			message := history[i]
+			if message.Phase == MessagePhaseReminder && message.Content == reminder.Content {
+				break
+			}
+			if message.Role == "user" && message.Phase == MessagePhasePrompt {
+				turns++
+			}
+		}
+		if turns >= reminder.Every {
+			due = append(due, NewPhaseMessage("system", MessagePhaseReminder, "", reminder.Content))
+		}
+	}
+	return due
+}
+
+// Method appending pinned messages to the chat history of the current session: pinned messages are never trimmed nor compacted.
+func (o *OpenAIReActAgent) Pin(messages ...*ChatMessage) error {
+	for _, message := range messages {
+		message.Pinned = true
+	}
+	return o.AppendHistory(messages...)
+}
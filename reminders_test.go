@@ -0,0 +1,28 @@
+package gopheract
+
+import "testing"
+
+func TestDueReminders(t *testing.T) {
+	reminder := Reminder{Content: "be careful", Every: 2}
+	prompt := func() *ChatMessage { return NewPhaseMessage("user", MessagePhasePrompt, "", "prompt") }
+	injected := func() *ChatMessage { return NewPhaseMessage("system", MessagePhaseReminder, "", reminder.Content) }
+	tests := []struct {
+		name    string
+		history []*ChatMessage
+		want    bool
+	}{
+		{"first turn", []*ChatMessage{prompt()}, false},
+		{"second turn", []*ChatMessage{prompt(), NewChatMessage("assistant", "answer"), prompt()}, true},
+		{"turn after the reminder", []*ChatMessage{prompt(), prompt(), injected(), prompt()}, false},
+		{"second turn after the reminder", []*ChatMessage{prompt(), prompt(), injected(), prompt(), prompt()}, true},
+		{"steps of a single turn", []*ChatMessage{prompt(), NewChatMessage("assistant", "thought"), NewChatMessage("user", "observation")}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			due := dueReminders([]Reminder{reminder, {Content: "disabled"}}, test.history)
+			if got := len(due) == 1; got != test.want {
+				t.Errorf("got %d due reminders, want due: %v", len(due), test.want)
+			}
+		})
+	}
+}
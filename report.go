@@ -0,0 +1,97 @@
+package gopheract
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct type representing the summary of a run: tokens consumed (overall and by phase), tool calls, estimated cost and wall time.
+//
+// It is built from the events of the run.
+type RunReport struct {
+	SessionID string
+	Model     string
+	// Number of think-act-observe iterations
+	Steps int
+	// Number of tool calls requested by the model
+	ToolCalls int
+	Usage     TokenUsage
+	// Tokens consumed by phase: "thought", "action", "observation", plus "verification", "compaction" and "facts" for the history compaction and the extraction of facts
+	UsageByPhase map[string]TokenUsage
+	// Estimated cost of the run in USD, from the prices of the model (zero if they are unknown)
+	EstimatedCost float64
+	Duration      time.Duration
+	// Error the run failed with, if any
+	Err error
+	mu  sync.Mutex
+}
+
+// Private function returning a new report for a run of the given session and model
+func newRunReport(sessionID, model string) *RunReport {
+	return &RunReport{
+		SessionID:    sessionID,
+		Model:        model,
+		UsageByPhase: map[string]TokenUsage{},
+	}
+}
+
+// Private method accounting an event of the run in the report (no-op on nil RunReport)
+func (r *RunReport) record(event AgentEvent) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps = max(r.Steps, event.EventStep())
+	switch e := event.(type) {
+	case ToolStartEvent:
+		r.ToolCalls++
+	case UsageEvent:
+		r.Usage = r.Usage.Add(e.Usage)
+		r.UsageByPhase[e.Phase] = r.UsageByPhase[e.Phase].Add(e.Usage)
+	}
+}
+
+// Method returning a human-readable summary of the run
+func (r *RunReport) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var summary strings.Builder
+	status := "completed"
+	if r.Err != nil {
+		status = "failed"
+	}
+	fmt.Fprintf(&summary, "Run %s in %s: %d steps, %d tool calls, %d tokens (%d prompt, %d completion)", status, r.Duration.Round(time.Millisecond), r.Steps, r.ToolCalls, r.Usage.TotalTokens, r.Usage.PromptTokens, r.Usage.CompletionTokens)
+	if r.EstimatedCost > 0 {
+		fmt.Fprintf(&summary, ", estimated cost $%.4f", r.EstimatedCost)
+	}
+	phases := make([]string, 0, len(r.UsageByPhase))
+	for phase := range r.UsageByPhase {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for i, phase := range phases {
+		if i == 0 {
+			summary.WriteString("\nTokens by phase: ")
+		} else {
+			summary.WriteString(", ")
+		}
+		fmt.Fprintf(&summary, "%s %d", phase, r.UsageByPhase[phase].TotalTokens)
+	}
+	return summary.String()
+}
+
+// Helper method that completes the report of a run and sends it to the `OnRunReport` callback
+func (o *OpenAIReActAgent) finishRunReport(report *RunReport, duration time.Duration, runErr error) {
+	report.mu.Lock()
+	report.Duration = duration
+	report.Err = runErr
+	if info, ok := o.modelInfo(); ok {
+		report.EstimatedCost = info.Cost(report.Usage)
+	}
+	report.mu.Unlock()
+	o.OnRunReport(report)
+}
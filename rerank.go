@@ -0,0 +1,175 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Maximum length of every passage shown to the LLM reranker, in bytes
+const rerankerPassageLimit = 2000
+
+// Base interface for the rerankers, scoring the chunks retrieved for a query by their relevance to it, which is more accurate than the similarity of their embeddings
+type Reranker interface {
+	// Rerank the chunks retrieved for a query, returning them from the most to the least relevant with their relevance as their score
+	Rerank(ctx context.Context, query string, chunks []ScoredChunk) ([]ScoredChunk, error)
+}
+
+// Private function sorting reranked chunks by their new scores, from the most to the least relevant
+func sortByScore(chunks []ScoredChunk) []ScoredChunk {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+	return chunks
+}
+
+// Struct type representing the relevance of a passage to a query, as judged by the LLM reranker
+type PassageRelevance struct {
+	Passage int `json:"passage" jsonschema_description:"Number of the passage"`
+	Score   int `json:"score" jsonschema_description:"Relevance of the passage to the query, from 0 (unrelated) to 10 (answers it directly)"`
+}
+
+// Struct type representing the relevance of the passages retrieved for a query, as judged by the LLM reranker
+type PassageRelevances struct {
+	Passages []PassageRelevance `json:"passages" jsonschema_description:"Relevance of every passage to the query"`
+}
+
+// Implementation of Reranker asking an LLM to grade the relevance of every chunk to the query, from 0 to 10 (scores are normalized between 0 and 1)
+type LLMReranker struct {
+	Llm *OpenAILLM
+}
+
+// Constructor function for a new LLMReranker using the given LLM
+func NewLLMReranker(llm *OpenAILLM) *LLMReranker {
+	return &LLMReranker{Llm: llm}
+}
+
+// Rerank the chunks with a single request to the LLM. Chunks the LLM does not grade are ranked last.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, chunks []ScoredChunk) ([]ScoredChunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+	if r.Llm == nil {
+		return nil, errors.New("no LLM is configured for the reranker")
+	}
+	var passages strings.Builder
+	fmt.Fprintf(&passages, "## Query\n\n%s\n\n## Passages\n", query)
+	for i, chunk := range chunks {
+		text := chunk.Text
+		if len(text) > rerankerPassageLimit {
+			text = text[:rerankerPassageLimit] + "\n[truncated]"
+		}
+		fmt.Fprintf(&passages, "\n### Passage %d (%s)\n\n%s\n", i+1, chunkSource(chunk.Chunk), text)
+	}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You grade how relevant passages retrieved from a knowledge base are to a query, from 0 (unrelated) to 10 (answers it directly). Grade every passage on its own content, not on how similar its wording is to the query."),
+		openai.UserMessage(passages.String()),
+	}
+	llm := *r.Llm
+	llm.ctx = ctx
+	response, err := OpenAILLMStructuredPredict[PassageRelevances](&llm, history, "relevance", "Relevance of the passages to the query")
+	if err != nil {
+		return nil, err
+	}
+	relevances, ok := response.(PassageRelevances)
+	if !ok {
+		return nil, errors.New("error while reranking the chunks: unexpected structured output")
+	}
+	reranked := make([]ScoredChunk, len(chunks))
+	for i, chunk := range chunks {
+		reranked[i] = ScoredChunk{Chunk: chunk.Chunk, Score: -1}
+	}
+	for _, relevance := range relevances.Passages {
+		if relevance.Passage >= 1 && relevance.Passage <= len(chunks) {
+			reranked[relevance.Passage-1].Score = float32(min(max(relevance.Score, 0), 10)) / 10
+		}
+	}
+	return sortByScore(reranked), nil
+}
+
+// Implementation of Reranker backed by a rerank API in the format shared by Cohere and Voyage AI, which returns the relevance score of every document along with its index
+type APIReranker struct {
+	// URL of the rerank endpoint (e.g. "https://api.cohere.com/v2/rerank")
+	URL    string
+	APIKey string
+	// Reranking model to use (e.g. "rerank-v3.5" or "rerank-2")
+	Model string
+	// Name of the request field limiting the number of results ("top_n" for Cohere, "top_k" for Voyage AI; empty returns every document)
+	LimitField string
+	// Maximum number of chunks returned (zero returns every chunk)
+	Limit int
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new APIReranker using the Cohere rerank API (provide an API key and a model, e.g. "rerank-v3.5")
+func NewCohereReranker(apiKey, model string) *APIReranker {
+	return &APIReranker{
+		URL:        "https://api.cohere.com/v2/rerank",
+		APIKey:     apiKey,
+		Model:      model,
+		LimitField: "top_n",
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Constructor function for a new APIReranker using the Voyage AI rerank API (provide an API key and a model, e.g. "rerank-2")
+func NewVoyageReranker(apiKey, model string) *APIReranker {
+	return &APIReranker{
+		URL:        "https://api.voyageai.com/v1/rerank",
+		APIKey:     apiKey,
+		Model:      model,
+		LimitField: "top_k",
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Rerank the chunks with a request to the rerank API
+func (r *APIReranker) Rerank(ctx context.Context, query string, chunks []ScoredChunk) ([]ScoredChunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+	if r.URL == "" {
+		return nil, errors.New("no URL is configured for the reranker")
+	}
+	documents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		documents[i] = chunk.Text
+	}
+	payload := map[string]any{"model": r.Model, "query": query, "documents": documents}
+	if r.LimitField != "" && r.Limit > 0 {
+		payload[r.LimitField] = r.Limit
+	}
+	// Cohere returns the scores under "results", Voyage AI under "data"
+	var response struct {
+		Results []rerankResult `json:"results"`
+		Data    []rerankResult `json:"data"`
+	}
+	err := apiRequest(ctx, r.Client, http.MethodPost, r.URL, payload, &response, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while reranking the chunks: %w", err)
+	}
+	results := append(response.Results, response.Data...)
+	reranked := make([]ScoredChunk, 0, len(results))
+	for _, result := range results {
+		if result.Index < 0 || result.Index >= len(chunks) {
+			return nil, fmt.Errorf("error while reranking the chunks: invalid document index %d", result.Index)
+		}
+		reranked = append(reranked, ScoredChunk{Chunk: chunks[result.Index].Chunk, Score: result.RelevanceScore})
+	}
+	return sortByScore(reranked), nil
+}
+
+// Private struct type representing the score of a document returned by a rerank API
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float32 `json:"relevance_score"`
+}
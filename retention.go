@@ -0,0 +1,53 @@
+package gopheract
+
+import (
+	"sort"
+	"time"
+)
+
+// Struct type representing the retention policy of a history store. Zero values mean no limit.
+type RetentionPolicy struct {
+	// Maximum number of sessions kept (the least recently updated ones are pruned first)
+	MaxSessions int
+	// Maximum time since the last update of a session
+	MaxAge time.Duration
+	// Maximum total size of the stored messages, in bytes
+	MaxBytes int64
+}
+
+// Method returning whether the policy sets no limit
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxSessions <= 0 && p.MaxAge <= 0 && p.MaxBytes <= 0
+}
+
+// Interface implemented by the history stores that can prune sessions according to their retention policy.
+//
+// Stores implementing it prune automatically every time a new session is created; `Prune` can also be called explicitly (e.g. on startup).
+type Pruner interface {
+	// Remove the sessions exceeding the retention policy, returning their identifiers
+	Prune() ([]string, error)
+}
+
+// Private function selecting the sessions exceeding a retention policy, keeping the most recently updated ones (and always keeping the session `keep`)
+func sessionsToPrune(sessions []SessionInfo, policy RetentionPolicy, now time.Time, keep string) []string {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	pruned := []string{}
+	kept := 0
+	var size int64
+	for _, session := range sessions {
+		if session.ID != keep {
+			expired := policy.MaxAge > 0 && now.Sub(session.UpdatedAt) > policy.MaxAge
+			tooMany := policy.MaxSessions > 0 && kept >= policy.MaxSessions
+			tooLarge := policy.MaxBytes > 0 && size+session.Size > policy.MaxBytes
+			if expired || tooMany || tooLarge {
+				pruned = append(pruned, session.ID)
+				continue
+			}
+		}
+		kept++
+		size += session.Size
+	}
+	return pruned
+}
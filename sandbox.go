@@ -0,0 +1,148 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Struct type representing the parameters of the sandboxed bash tool.
+//
+// The fields mirror the ones of a raw bash tool, so that the sandboxed version can be used as a drop-in replacement.
+type SandboxParams struct {
+	Command   string   `json:"command" description:"Main bash command to execute"`
+	Arguments []string `json:"arguments" description:"Arguments for the bash command"`
+}
+
+// Struct type representing an ephemeral container sandbox (Docker or Podman) in which commands are executed.
+//
+// Every command runs in a fresh container that is removed once the command exits. The workspace directory is mounted inside the container, and resource limits and network policy are applied to each run.
+type ContainerSandbox struct {
+	// Container runtime binary to use (`docker` or `podman`)
+	Runtime string
+	// Image used to create the container
+	Image string
+	// Host directory mounted as the workspace of the container
+	Workspace string
+	// Path at which the workspace is mounted inside the container
+	MountPath string
+	// Whether to mount the workspace as read-only
+	ReadOnly bool
+	// Network mode for the container (`none` disables networking altogether)
+	Network string
+	// Memory limit for the container, in the format accepted by the runtime (e.g. `512m`)
+	Memory string
+	// CPU limit for the container (e.g. `1.5`)
+	CPUs string
+	// Maximum number of processes in the container (0 means no limit)
+	PidsLimit int
+	// Maximum execution time for a command (0 means no limit)
+	Timeout time.Duration
+}
+
+// Constructor function for a new ContainerSandbox based on Docker, with networking disabled and conservative resource limits. Takes, as arguments, the image to use and the host workspace directory to mount.
+func NewContainerSandbox(image, workspace string) *ContainerSandbox {
+	return &ContainerSandbox{
+		Runtime:   "docker",
+		Image:     image,
+		Workspace: workspace,
+		MountPath: "/workspace",
+		Network:   "none",
+		Memory:    "512m",
+		CPUs:      "1",
+		PidsLimit: 256,
+		Timeout:   2 * time.Minute,
+	}
+}
+
+// Helper method to build the arguments passed to the container runtime in order to execute a command within the sandbox.
+func (c *ContainerSandbox) BuildArgs(params SandboxParams) ([]string, error) {
+	if c.Image == "" {
+		return nil, errors.New("no image provided for the sandbox")
+	}
+	if params.Command == "" {
+		return nil, errors.New("no command provided")
+	}
+	args := []string{"run", "--rm", "-i", "--cap-drop", "ALL", "--security-opt", "no-new-privileges"}
+	if c.Network != "" {
+		args = append(args, "--network", c.Network)
+	}
+	if c.Memory != "" {
+		args = append(args, "--memory", c.Memory)
+	}
+	if c.CPUs != "" {
+		args = append(args, "--cpus", c.CPUs)
+	}
+	if c.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(c.PidsLimit))
+	}
+	if c.Workspace != "" {
+		workspace, err := filepath.Abs(c.Workspace)
+		if err != nil {
+			return nil, err
+		}
+		mountPath := c.mountPath()
+		mount := fmt.Sprintf("%s:%s", workspace, mountPath)
+		if c.ReadOnly {
+			mount += ":ro"
+		}
+		args = append(args, "-v", mount, "-w", mountPath)
+	}
+	args = append(args, c.Image, params.Command)
+	args = append(args, params.Arguments...)
+	return args, nil
+}
+
+// Method to execute a command within the sandbox, returning its combined standard output and standard error.
+func (c *ContainerSandbox) Execute(params SandboxParams) (any, error) {
+	args, err := c.BuildArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, c.runtimeName(), args...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("command timed out after %s", c.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, string(output))
+	}
+	return string(output), nil
+}
+
+// Helper method to expose the sandbox as a tool definition that can be passed to an agent.
+//
+// The tool is named `Bash`, so that it can replace a raw bash tool without changes to the system prompt.
+func (c *ContainerSandbox) AsTool() ToolDefinition[SandboxParams] {
+	return ToolDefinition[SandboxParams]{
+		Name:        "Bash",
+		Description: fmt.Sprintf("Execute a bash command inside an isolated %s container (image: %s, workspace mounted at %s) by providing the main command (`command` parameter - string) and the arguments for it (`arguments` parameter - list of strings)", c.runtimeName(), c.Image, c.mountPath()),
+		Fn:          c.Execute,
+		Cost:        ToolCostLow,
+		Latency:     ToolLatencyModerate,
+	}
+}
+
+func (c *ContainerSandbox) runtimeName() string {
+	if c.Runtime == "" {
+		return "docker"
+	}
+	return c.Runtime
+}
+
+func (c *ContainerSandbox) mountPath() string {
+	if c.MountPath == "" {
+		return "/workspace"
+	}
+	return c.MountPath
+}
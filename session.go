@@ -0,0 +1,182 @@
+package gopheract
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Struct type representing the tokens consumed by LLM requests
+type TokenUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// Method returning the sum of two token usages
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// Error returned by `Session.Begin` when a turn of the session is already in progress
+var ErrSessionBusy = errors.New("a turn of the session is already in progress")
+
+// Struct type representing a conversation with an agent, with its own chat history and token accounting
+type Session struct {
+	ID        string
+	CreatedAt time.Time
+	// Agent working on the history of the session
+	Agent *OpenAIReActAgent
+	mu    sync.Mutex
+	usage TokenUsage
+	// time the session was last opened or ended a turn
+	lastActive time.Time
+	running    bool
+}
+
+// Method marking the start of a turn of the session, so that its agent is not used by two turns at once and the session is not closed as idle. It fails with ErrSessionBusy if a turn is already in progress.
+func (s *Session) Begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return ErrSessionBusy
+	}
+	s.running = true
+	s.lastActive = time.Now()
+	return nil
+}
+
+// Method marking the end of the turn of the session started with `Begin`
+func (s *Session) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.lastActive = time.Now()
+}
+
+// Method returning whether the session has been inactive (no turn in progress, neither opened nor used) for at least the given duration
+func (s *Session) IdleFor(d time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.running && time.Since(s.lastActive) >= d
+}
+
+// Method returning the tokens consumed by the LLM requests of the session
+func (s *Session) Usage() TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
+// Method returning the (estimated) number of tokens in the chat history of the session, as counted by the context policy of the agent
+func (s *Session) HistoryTokens() (int, error) {
+	history, err := s.Agent.History()
+	if err != nil {
+		return 0, err
+	}
+	policy := s.Agent.ContextPolicy
+	if policy == nil {
+		policy = &ContextWindowPolicy{}
+	}
+	return policy.CountTokens(history), nil
+}
+
+// Struct type managing several sessions with the same agent configuration, for hosts (e.g. ACP or HTTP servers) that serve many conversations at once.
+//
+// Every session gets its own copy of the base agent, working on the session's history in the shared memory store and accounting for its own token usage.
+type SessionManager struct {
+	Base     *OpenAIReActAgent
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// Constructor function for a new SessionManager, given the agent whose configuration (LLM, tools, memory store, policies) is shared by the sessions
+func NewSessionManager(base *OpenAIReActAgent) *SessionManager {
+	return &SessionManager{
+		Base:     base,
+		sessions: map[string]*Session{},
+	}
+}
+
+// Method returning the session with the given identifier, creating it if needed. The history of sessions persisted by the memory store is picked up again.
+func (m *SessionManager) Open(sessionID string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions == nil {
+		m.sessions = map[string]*Session{}
+	}
+	if session, ok := m.sessions[sessionID]; ok {
+		session.mu.Lock()
+		session.lastActive = time.Now()
+		session.mu.Unlock()
+		return session
+	}
+	session := &Session{ID: sessionID, CreatedAt: time.Now().UTC(), lastActive: time.Now()}
+	agent := *m.Base
+	agent.SessionID = sessionID
+	if m.Base.Llm != nil {
+		llm := *m.Base.Llm
+		baseOnUsage := llm.OnUsage
+		llm.OnUsage = func(usage TokenUsage) {
+			session.mu.Lock()
+			session.usage = session.usage.Add(usage)
+			session.mu.Unlock()
+			if baseOnUsage != nil {
+				baseOnUsage(usage)
+			}
+		}
+		agent.Llm = &llm
+	}
+	session.Agent = &agent
+	m.sessions[sessionID] = session
+	return session
+}
+
+// Method returning the session with the given identifier, if it is open
+func (m *SessionManager) Get(sessionID string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[sessionID]
+	return session, ok
+}
+
+// Method closing a session. Its history is kept in the memory store, so that it can be opened again.
+func (m *SessionManager) Close(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, sessionID)
+}
+
+// Method closing the sessions that have been idle for at least the given duration (see `Session.IdleFor`), returning their identifiers. Their history is kept in the memory store, so that they can be opened again.
+func (m *SessionManager) CloseIdle(maxIdle time.Duration) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	closed := []string{}
+	for id, session := range m.sessions {
+		if session.IdleFor(maxIdle) {
+			delete(m.sessions, id)
+			closed = append(closed, id)
+		}
+	}
+	sort.Strings(closed)
+	return closed
+}
+
+// Method returning the open sessions, from the oldest to the newest
+func (m *SessionManager) Sessions() []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions
+}
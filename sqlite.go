@@ -0,0 +1,370 @@
+package gopheract
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Interface that history stores can implement to also persist metadata about runs and tool calls.
+//
+// When the memory of an agent implements it, the agent records the start and end of each run and every tool call it executes.
+type RunRecorder interface {
+	// Record the start of a run for a session, returning the identifier of the run
+	StartRun(sessionID, prompt string) (string, error)
+	// Record the end of a run, along with the error that terminated it (if any)
+	FinishRun(runID string, runErr error) error
+	// Record a tool call executed within a run
+	RecordToolCall(runID string, call ToolCallRecord) error
+}
+
+// Struct type representing a tool call executed by the agent, as persisted by a RunRecorder
+type ToolCallRecord struct {
+	// Identifier of the tool call within the run
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Args      map[string]any `json:"args"`
+	Result    any            `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Struct type representing summary information about a persisted session
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MessageCount int       `json:"message_count"`
+	// Size of the stored messages, in bytes, including the runs and tool calls recorded for the session (when reported by the store)
+	Size int64 `json:"size,omitempty"`
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+	role TEXT NOT NULL,
+	content TEXT NOT NULL,
+	phase TEXT NOT NULL DEFAULT '',
+	tool_call_id TEXT NOT NULL DEFAULT '',
+	pinned INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_session_idx ON messages(session_id, id);
+CREATE TABLE IF NOT EXISTS runs (
+	id TEXT PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	prompt TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT,
+	started_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS tool_calls (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	call_id TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	args TEXT NOT NULL,
+	result TEXT,
+	error TEXT,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_session_idx ON runs(session_id);
+CREATE INDEX IF NOT EXISTS tool_calls_run_idx ON tool_calls(run_id);
+`
+
+// Columns added to the tables after their first version, created when opening databases written by older versions of the store
+var sqliteAddedColumns = []struct{ table, column, definition string }{
+	{"messages", "phase", "TEXT NOT NULL DEFAULT ''"},
+	{"messages", "tool_call_id", "TEXT NOT NULL DEFAULT ''"},
+	{"messages", "pinned", "INTEGER NOT NULL DEFAULT 0"},
+	{"tool_calls", "call_id", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// Private function adding the columns missing from tables created by older versions of the store
+func migrateSQLiteSchema(db *sql.DB) error {
+	for _, added := range sqliteAddedColumns {
+		var exists int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, added.table, added.column).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", added.table, added.column, added.definition)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Implementation of HistoryStore (and RunRecorder) persisting sessions, messages, runs and tool calls to a SQLite database.
+//
+// The store works on a `*sql.DB` opened by the caller, so that the library does not depend on a specific SQLite driver (e.g. `github.com/mattn/go-sqlite3` or `modernc.org/sqlite`).
+type SQLiteStore struct {
+	DB *sql.DB
+	// Retention policy applied every time a new session is created
+	Retention RetentionPolicy
+}
+
+// Constructor function for a new SQLiteStore, creating the tables if they do not exist yet.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("error while creating the sqlite schema: %w", err)
+	}
+	if err := migrateSQLiteSchema(db); err != nil {
+		return nil, fmt.Errorf("error while migrating the sqlite schema: %w", err)
+	}
+	return &SQLiteStore{DB: db}, nil
+}
+
+// Append messages to the history of a session, creating the session if needed
+func (s *SQLiteStore) Append(sessionID string, messages ...*ChatMessage) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	var existing int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sessions WHERE id = ?`, sessionID).Scan(&existing); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO sessions (id, created_at, updated_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET updated_at = excluded.updated_at`, sessionID, now, now); err != nil {
+		return err
+	}
+	if err := insertMessages(tx, sessionID, messages, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if existing == 0 && !s.Retention.IsZero() {
+		_, err := s.prune(sessionID)
+		return err
+	}
+	return nil
+}
+
+// Replace the history of a session with the given messages in a single transaction, keeping the creation time of the session
+func (s *SQLiteStore) Replace(sessionID string, messages ...*ChatMessage) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	var existing int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sessions WHERE id = ?`, sessionID).Scan(&existing); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO sessions (id, created_at, updated_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET updated_at = excluded.updated_at`, sessionID, now, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	if err := insertMessages(tx, sessionID, messages, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if existing == 0 && !s.Retention.IsZero() {
+		_, err := s.prune(sessionID)
+		return err
+	}
+	return nil
+}
+
+// Private function inserting messages into the history of a session within a transaction, dating the ones without a creation time with `now`
+func insertMessages(tx *sql.Tx, sessionID string, messages []*ChatMessage, now time.Time) error {
+	for _, message := range messages {
+		createdAt := message.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		if _, err := tx.Exec(`INSERT INTO messages (session_id, role, content, phase, tool_call_id, pinned, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`, sessionID, message.Role, message.Content, message.Phase, message.ToolCallID, message.Pinned, createdAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List the messages in the history of a session
+func (s *SQLiteStore) List(sessionID string) ([]*ChatMessage, error) {
+	rows, err := s.DB.Query(`SELECT role, content, phase, tool_call_id, pinned, created_at FROM messages WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []*ChatMessage{}
+	for rows.Next() {
+		var message ChatMessage
+		if err := rows.Scan(&message.Role, &message.Content, &message.Phase, &message.ToolCallID, &message.Pinned, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, rows.Err()
+}
+
+// Trim the history of a session, keeping only the newest `keep` messages along with the pinned ones
+func (s *SQLiteStore) Trim(sessionID string, keep int) error {
+	if keep < 0 {
+		keep = 0
+	}
+	_, err := s.DB.Exec(`DELETE FROM messages WHERE session_id = ? AND pinned = 0 AND id NOT IN (SELECT id FROM messages WHERE session_id = ? AND pinned = 0 ORDER BY id DESC LIMIT ?)`, sessionID, sessionID, keep)
+	return err
+}
+
+// Clear the history of a session, removing the session altogether along with its runs and the tool calls executed within them
+func (s *SQLiteStore) Clear(sessionID string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// the tool calls hold the full arguments and results, so they are removed explicitly (foreign keys may not be enforced)
+	if _, err := tx.Exec(`DELETE FROM tool_calls WHERE run_id IN (SELECT id FROM runs WHERE session_id = ?)`, sessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM runs WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create the session `targetID` with the first `at` messages of the history of `sourceID`, copying them within the database
+func (s *SQLiteStore) Fork(sourceID, targetID string, at int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var sourceCount, targetCount int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ?`, sourceID).Scan(&sourceCount); err != nil {
+		return err
+	}
+	if at < 0 || at > sourceCount {
+		return fmt.Errorf("cannot fork session %s at message %d: the session has %d messages", sourceID, at, sourceCount)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ?`, targetID).Scan(&targetCount); err != nil {
+		return err
+	}
+	if targetCount > 0 {
+		return fmt.Errorf("cannot fork into session %s: the session already exists", targetID)
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`INSERT INTO sessions (id, created_at, updated_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET updated_at = excluded.updated_at`, targetID, now, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO messages (session_id, role, content, phase, tool_call_id, pinned, created_at) SELECT ?, role, content, phase, tool_call_id, pinned, created_at FROM messages WHERE session_id = ? ORDER BY id LIMIT ?`, targetID, sourceID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Query summarizing the persisted sessions, whose size counts the runs and tool calls recorded for them along with their messages
+const sqliteSessionsQuery = `
+SELECT s.id, s.created_at, s.updated_at,
+	(SELECT COUNT(*) FROM messages m WHERE m.session_id = s.id),
+	(SELECT COALESCE(SUM(LENGTH(m.content)), 0) FROM messages m WHERE m.session_id = s.id)
+	+ (SELECT COALESCE(SUM(LENGTH(r.prompt) + COALESCE(LENGTH(r.error), 0)), 0) FROM runs r WHERE r.session_id = s.id)
+	+ (SELECT COALESCE(SUM(LENGTH(t.args) + COALESCE(LENGTH(t.result), 0) + COALESCE(LENGTH(t.error), 0)), 0) FROM tool_calls t JOIN runs r ON r.id = t.run_id WHERE r.session_id = s.id)
+FROM sessions s
+ORDER BY s.updated_at DESC`
+
+// List the persisted sessions, from the most recently updated
+func (s *SQLiteStore) ListSessions() ([]SessionInfo, error) {
+	rows, err := s.DB.Query(sqliteSessionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var info SessionInfo
+		if err := rows.Scan(&info.ID, &info.CreatedAt, &info.UpdatedAt, &info.MessageCount, &info.Size); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions, rows.Err()
+}
+
+// Remove the sessions exceeding the retention policy of the store, returning their identifiers
+func (s *SQLiteStore) Prune() ([]string, error) {
+	return s.prune("")
+}
+
+// Private method removing the sessions exceeding the retention policy, except for the session `keep`
+func (s *SQLiteStore) prune(keep string) ([]string, error) {
+	sessions, err := s.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	pruned := sessionsToPrune(sessions, s.Retention, time.Now().UTC(), keep)
+	for _, sessionID := range pruned {
+		if err := s.Clear(sessionID); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+// Record the start of a run for a session
+func (s *SQLiteStore) StartRun(sessionID, prompt string) (string, error) {
+	runID := fmt.Sprintf("run_%d", time.Now().UnixNano())
+	_, err := s.DB.Exec(`INSERT INTO runs (id, session_id, prompt, status, started_at) VALUES (?, ?, ?, 'running', ?)`, runID, sessionID, prompt, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return runID, nil
+}
+
+// Record the end of a run
+func (s *SQLiteStore) FinishRun(runID string, runErr error) error {
+	status, errMsg := "completed", sql.NullString{}
+	if runErr != nil {
+		status = "failed"
+		errMsg = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := s.DB.Exec(`UPDATE runs SET status = ?, error = ?, finished_at = ? WHERE id = ?`, status, errMsg, time.Now().UTC(), runID)
+	return err
+}
+
+// Record a tool call executed within a run
+func (s *SQLiteStore) RecordToolCall(runID string, call ToolCallRecord) error {
+	args, err := json.Marshal(call.Args)
+	if err != nil {
+		return err
+	}
+	var result sql.NullString
+	if call.Result != nil {
+		serialized, err := json.Marshal(call.Result)
+		if err != nil {
+			serialized = []byte(fmt.Sprintf("%q", fmt.Sprint(call.Result)))
+		}
+		result = sql.NullString{String: string(serialized), Valid: true}
+	}
+	errMsg := sql.NullString{String: call.Error, Valid: call.Error != ""}
+	createdAt := call.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	_, err = s.DB.Exec(`INSERT INTO tool_calls (run_id, call_id, name, args, result, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`, runID, call.ID, call.Name, string(args), result, errMsg, createdAt)
+	return err
+}
@@ -0,0 +1,206 @@
+package gopheract
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const sqliteVectorSchema = `
+CREATE TABLE IF NOT EXISTS vector_records (
+	id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	text TEXT NOT NULL,
+	start_offset INTEGER NOT NULL DEFAULT 0,
+	end_offset INTEGER NOT NULL DEFAULT 0,
+	start_line INTEGER NOT NULL DEFAULT 0,
+	end_line INTEGER NOT NULL DEFAULT 0,
+	metadata TEXT NOT NULL DEFAULT '{}',
+	embedding BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS vector_records_document_idx ON vector_records(document_id);
+CREATE TABLE IF NOT EXISTS ingested_files (
+	path TEXT PRIMARY KEY,
+	hash TEXT NOT NULL,
+	document_ids TEXT NOT NULL,
+	ingested_at TIMESTAMP NOT NULL
+);
+`
+
+// Implementation of VectorStore (along with ChunkLister and IngestionIndex) persisting the chunks and their embeddings to a SQLite database, so that a knowledge base fits in a single file.
+//
+// The store works on a `*sql.DB` opened by the caller, like SQLiteStore. SQLite has no vector index, so queries compare the embedding to every record (which is fast enough for tens of thousands of chunks).
+type SQLiteVectorStore struct {
+	DB *sql.DB
+}
+
+// Constructor function for a new SQLiteVectorStore, creating the tables if they do not exist yet.
+func NewSQLiteVectorStore(db *sql.DB) (*SQLiteVectorStore, error) {
+	if _, err := db.Exec(sqliteVectorSchema); err != nil {
+		return nil, fmt.Errorf("error while creating the sqlite schema: %w", err)
+	}
+	return &SQLiteVectorStore{DB: db}, nil
+}
+
+// Private function encoding an embedding as a blob of little-endian float32 values
+func encodeEmbedding(embedding []float32) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+// Private function decoding an embedding encoded by encodeEmbedding
+func decodeEmbedding(data []byte) []float32 {
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return embedding
+}
+
+// Add records to the store, within a transaction, replacing the records with the same chunk identifiers
+func (s *SQLiteVectorStore) Add(ctx context.Context, records ...VectorRecord) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, record := range records {
+		metadata, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid metadata for chunk %s: %w", record.ID, err)
+		}
+		if record.Metadata == nil {
+			metadata = []byte("{}")
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO vector_records (id, document_id, text, start_offset, end_offset, start_line, end_line, metadata, embedding) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			record.ID, record.DocumentID, record.Text, record.Start, record.End, record.StartLine, record.EndLine, string(metadata), encodeEmbedding(record.Embedding))
+		if err != nil {
+			return fmt.Errorf("error while adding the chunks: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Retrieve the chunks most similar to an embedding, by cosine similarity
+func (s *SQLiteVectorStore) Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, document_id, text, start_offset, end_offset, start_line, end_line, metadata, embedding FROM vector_records`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scored := []ScoredChunk{}
+	for rows.Next() {
+		var chunk ScoredChunk
+		var metadata string
+		var vector []byte
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.Text, &chunk.Start, &chunk.End, &chunk.StartLine, &chunk.EndLine, &metadata, &vector); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &chunk.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for chunk %s: %w", chunk.ID, err)
+		}
+		if !query.matches(chunk.Metadata) {
+			continue
+		}
+		chunk.Score = cosineSimilarity(embedding, decodeEmbedding(vector))
+		if chunk.Score >= query.MinScore {
+			scored = append(scored, chunk)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > query.topK() {
+		scored = scored[:query.topK()]
+	}
+	return scored, nil
+}
+
+// List the chunks of the store
+func (s *SQLiteVectorStore) Chunks(ctx context.Context) ([]Chunk, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, document_id, text, start_offset, end_offset, start_line, end_line, metadata FROM vector_records`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	chunks := []Chunk{}
+	for rows.Next() {
+		var chunk Chunk
+		var metadata string
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.Text, &chunk.Start, &chunk.End, &chunk.StartLine, &chunk.EndLine, &metadata); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &chunk.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for chunk %s: %w", chunk.ID, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// Delete the records of the chunks of the given documents
+func (s *SQLiteVectorStore) Delete(ctx context.Context, documentIDs ...string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(documentIDs)), ", ")
+	args := make([]any, len(documentIDs))
+	for i, id := range documentIDs {
+		args[i] = id
+	}
+	_, err := s.DB.ExecContext(ctx, fmt.Sprintf("DELETE FROM vector_records WHERE document_id IN (%s)", placeholders), args...)
+	return err
+}
+
+// List the files ingested into the store, by path
+func (s *SQLiteVectorStore) IngestedFiles(ctx context.Context) (map[string]IngestedFile, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT path, hash, document_ids, ingested_at FROM ingested_files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := map[string]IngestedFile{}
+	for rows.Next() {
+		var file IngestedFile
+		var documentIDs string
+		if err := rows.Scan(&file.Path, &file.Hash, &documentIDs, &file.IngestedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(documentIDs), &file.DocumentIDs); err != nil {
+			return nil, fmt.Errorf("invalid documents for file %s: %w", file.Path, err)
+		}
+		files[file.Path] = file
+	}
+	return files, rows.Err()
+}
+
+// Record a file ingested into the store, replacing the previous record of the same path
+func (s *SQLiteVectorStore) RecordIngestedFile(ctx context.Context, file IngestedFile) error {
+	documentIDs, err := json.Marshal(file.DocumentIDs)
+	if err != nil {
+		return err
+	}
+	if file.IngestedAt.IsZero() {
+		file.IngestedAt = time.Now()
+	}
+	_, err = s.DB.ExecContext(ctx, `INSERT OR REPLACE INTO ingested_files (path, hash, document_ids, ingested_at) VALUES (?, ?, ?, ?)`, file.Path, file.Hash, string(documentIDs), file.IngestedAt.UTC())
+	return err
+}
+
+// Forget a file ingested into the store (its chunks must be deleted separately)
+func (s *SQLiteVectorStore) ForgetIngestedFile(ctx context.Context, path string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM ingested_files WHERE path = ?`, path)
+	return err
+}
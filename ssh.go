@@ -0,0 +1,193 @@
+package gopheract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Struct type representing the parameters of the SSH remote execution tool
+type SSHParams struct {
+	Host    string `json:"host" description:"Name of the remote host to run the command on (one of the allowed hosts)"`
+	Command string `json:"command" description:"Command to execute on the remote host"`
+}
+
+// Struct type representing a remote host the SSH tool is allowed to connect to.
+type SSHHost struct {
+	// Address of the host, in the `host:port` format (the port defaults to 22 if omitted)
+	Address string
+	// User to log in as
+	User string
+	// Path to a private key used to authenticate (optional if the SSH agent is enabled)
+	KeyPath string
+	// Passphrase for the private key, if encrypted
+	KeyPassphrase string
+	// Working directory in which commands are executed on the host (optional)
+	WorkDir string
+}
+
+// Struct type representing a tool that runs commands on remote hosts over SSH.
+//
+// Only the hosts listed in the `Hosts` allowlist (keyed by the name the agent uses to refer to them) can be reached. Host keys are verified against a known_hosts file.
+type SSHTool struct {
+	// Allowlist of the reachable hosts, keyed by name
+	Hosts map[string]SSHHost
+	// Whether to authenticate using the keys loaded in the running SSH agent (via `SSH_AUTH_SOCK`)
+	UseAgent bool
+	// Path to the known_hosts file used for host key verification (defaults to ~/.ssh/known_hosts)
+	KnownHostsPath string
+	// Maximum time allowed to connect to a host and execute a command (0 means no limit)
+	Timeout time.Duration
+}
+
+// Constructor function for a new SSHTool, given the allowlist of reachable hosts. Authentication through the SSH agent is enabled by default.
+func NewSSHTool(hosts map[string]SSHHost) *SSHTool {
+	return &SSHTool{
+		Hosts:    hosts,
+		UseAgent: true,
+		Timeout:  2 * time.Minute,
+	}
+}
+
+func (s *SSHTool) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	path := s.KnownHostsPath
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return knownhosts.New(path)
+}
+
+func (s *SSHTool) authMethods(host SSHHost) ([]ssh.AuthMethod, func(), error) {
+	methods := []ssh.AuthMethod{}
+	cleanup := func() {}
+	if host.KeyPath != "" {
+		key, err := os.ReadFile(host.KeyPath)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		var signer ssh.Signer
+		if host.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(host.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, cleanup, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if s.UseAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				return nil, cleanup, err
+			}
+			cleanup = func() { conn.Close() }
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, cleanup, errors.New("no authentication method available: provide a key path or enable the SSH agent")
+	}
+	return methods, cleanup, nil
+}
+
+// Method to execute a command on one of the allowed hosts, returning its combined standard output and standard error.
+func (s *SSHTool) Execute(params SSHParams) (any, error) {
+	host, ok := s.Hosts[params.Host]
+	if !ok {
+		return nil, fmt.Errorf("host %s is not in the list of allowed hosts", params.Host)
+	}
+	if params.Command == "" {
+		return nil, errors.New("no command provided")
+	}
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	auth, cleanup, err := s.authMethods(host)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+	address := host.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            host.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         s.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	command := params.Command
+	if host.WorkDir != "" {
+		command = fmt.Sprintf("cd %s && %s", shellQuote(host.WorkDir), command)
+	}
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timer := time.NewTimer(s.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err = <-done:
+	case <-timeout:
+		return nil, fmt.Errorf("command timed out after %s", s.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, output.String())
+	}
+	return output.String(), nil
+}
+
+// Helper method to expose the SSH tool as a tool definition that can be passed to an agent.
+func (s *SSHTool) AsTool() ToolDefinition[SSHParams] {
+	names := make([]string, 0, len(s.Hosts))
+	for name := range s.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return ToolDefinition[SSHParams]{
+		Name:        "SSH",
+		Description: fmt.Sprintf("Execute a command on a remote host over SSH, by providing the host name (`host` parameter - string, one of: %s) and the command to run (`command` parameter - string)", strings.Join(names, ", ")),
+		Fn:          s.Execute,
+		Cost:        ToolCostMedium,
+		Latency:     ToolLatencyModerate,
+	}
+}
+
+// Private function to quote a string for safe use as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,230 @@
+package gopheract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Struct type representing an agent specialized in a kind of task, to which a supervisor routes requests
+type Specialist struct {
+	// Name the router refers to the specialist by (e.g. "coder", "researcher" or "reviewer")
+	Name string
+	// Description of what the specialist is good at, shown to the router
+	Description string
+	Agent       *OpenAIReActAgent
+}
+
+// Struct type representing the assignment of a task to a specialist, chosen by the router of a supervisor
+type SupervisorAssignment struct {
+	Specialist string `json:"specialist" jsonschema_description:"Name of the specialist the task is assigned to, one of the available specialists"`
+	Task       string `json:"task" jsonschema_description:"Self-contained instructions for the specialist, with all the context it needs from the request"`
+}
+
+// Struct type representing the routing of a request by the router of a supervisor
+type SupervisorRoute struct {
+	Assignments []SupervisorAssignment `json:"assignments" jsonschema_description:"Tasks to assign to the specialists, in the order they must be carried out (later tasks see the results of the earlier ones)"`
+}
+
+// Struct type representing the response of a supervisor, aggregating the results of its specialists
+type SupervisorAnswer struct {
+	Answer string `json:"answer" jsonschema_description:"Single response to the request, combining the results of the specialists"`
+}
+
+// Struct type representing a task carried out by a specialist on behalf of a supervisor
+type SupervisorStep struct {
+	Specialist string
+	Task       string
+	// Final answer of the specialist (empty if it failed)
+	Answer string
+	// Error the run of the specialist failed with, if any
+	Err error
+}
+
+// Struct type representing the outcome of a request handled by a supervisor
+type SupervisorResult struct {
+	Answer string
+	// Tasks carried out by the specialists, in order
+	Steps []SupervisorStep
+}
+
+// Struct type representing a supervisor, which routes requests (or the sub-tasks they are split into) to named specialist agents with an LLM-based router, and aggregates their results into one response.
+//
+// The specialists run one after another, each seeing the results of the previous ones, so that e.g. a reviewer can check the work of a coder.
+type Supervisor struct {
+	// LLM routing the requests and aggregating the results
+	Llm         *OpenAILLM
+	Specialists []Specialist
+	// Whether the router can split a request into sub-tasks for several specialists (otherwise the whole request goes to the most suitable one)
+	Decompose bool
+	// Maximum number of sub-tasks a request is split into (defaults to 5)
+	MaxAssignments int
+	// Optional callback receiving the events of the runs of the specialists, along with their name
+	OnEvent func(specialist string, event AgentEvent)
+}
+
+// Constructor function for a new Supervisor, given the LLM routing the requests and the specialists
+func NewSupervisor(llm *OpenAILLM, specialists ...Specialist) *Supervisor {
+	return &Supervisor{Llm: llm, Specialists: specialists}
+}
+
+// Method to handle a request: route it to the specialists, run them in order and aggregate their results into one response.
+//
+// It fails if every specialist fails, or if the context is cancelled.
+func (s *Supervisor) Run(ctx context.Context, prompt string) (*SupervisorResult, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	llm := *s.Llm
+	llm.ctx = ctx
+	assignments, err := s.route(&llm, prompt)
+	if err != nil {
+		return nil, err
+	}
+	result := &SupervisorResult{}
+	failed := 0
+	for _, assignment := range assignments {
+		step := s.runStep(ctx, assignment, result.Steps)
+		if step.Err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			failed++
+		}
+		result.Steps = append(result.Steps, step)
+	}
+	if failed == len(result.Steps) {
+		return nil, fmt.Errorf("every specialist failed: %w", result.Steps[0].Err)
+	}
+	if len(result.Steps) == 1 {
+		result.Answer = result.Steps[0].Answer
+		return result, nil
+	}
+	result.Answer, err = s.aggregate(&llm, prompt, result.Steps)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Private method checking that the supervisor has an LLM and specialists with distinct names
+func (s *Supervisor) validate() error {
+	if s.Llm == nil {
+		return errors.New("no LLM is configured for the supervisor")
+	}
+	if len(s.Specialists) == 0 {
+		return errors.New("no specialists are configured for the supervisor")
+	}
+	names := map[string]bool{}
+	for _, specialist := range s.Specialists {
+		if specialist.Name == "" || specialist.Agent == nil {
+			return errors.New("every specialist needs a name and an agent")
+		}
+		if names[specialist.Name] {
+			return fmt.Errorf("duplicate specialist %s", specialist.Name)
+		}
+		names[specialist.Name] = true
+	}
+	return nil
+}
+
+// Private method asking the router which specialists to assign the request to (the only specialist gets the whole request without asking)
+func (s *Supervisor) route(llm *OpenAILLM, prompt string) ([]SupervisorAssignment, error) {
+	if len(s.Specialists) == 1 {
+		return []SupervisorAssignment{{Specialist: s.Specialists[0].Name, Task: prompt}}, nil
+	}
+	var instructions strings.Builder
+	instructions.WriteString("You are the supervisor of a team of specialist agents, and you route the requests of the user to them. The specialists are:\n\n")
+	for _, specialist := range s.Specialists {
+		fmt.Fprintf(&instructions, "- %s: %s\n", specialist.Name, specialist.Description)
+	}
+	maxAssignments := s.MaxAssignments
+	if maxAssignments <= 0 {
+		maxAssignments = 5
+	}
+	if s.Decompose {
+		fmt.Fprintf(&instructions, "\nSplit the request into at most %d sub-tasks, in the order they must be carried out, and assign each of them to the most suitable specialist. Do not split requests that a single specialist can handle.", maxAssignments)
+	} else {
+		instructions.WriteString("\nAssign the whole request to the single most suitable specialist.")
+	}
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(instructions.String()),
+		openai.UserMessage(prompt),
+	}
+	response, err := OpenAILLMStructuredPredict[SupervisorRoute](llm, history, "route", "Assignment of the request to the specialists")
+	if err != nil {
+		return nil, err
+	}
+	route, ok := response.(SupervisorRoute)
+	if !ok || len(route.Assignments) == 0 {
+		return nil, errors.New("error while routing the request: unexpected structured output")
+	}
+	if !s.Decompose {
+		// the whole request goes to the chosen specialist, in the words of the user
+		route.Assignments = []SupervisorAssignment{{Specialist: route.Assignments[0].Specialist, Task: prompt}}
+	}
+	if len(route.Assignments) > maxAssignments {
+		route.Assignments = route.Assignments[:maxAssignments]
+	}
+	for _, assignment := range route.Assignments {
+		if !slices.ContainsFunc(s.Specialists, func(specialist Specialist) bool { return specialist.Name == assignment.Specialist }) {
+			return nil, fmt.Errorf("the router chose an unknown specialist %s", assignment.Specialist)
+		}
+	}
+	return route.Assignments, nil
+}
+
+// Private method running a specialist on its task, with the results of the previous steps as context
+func (s *Supervisor) runStep(ctx context.Context, assignment SupervisorAssignment, previous []SupervisorStep) SupervisorStep {
+	step := SupervisorStep{Specialist: assignment.Specialist, Task: assignment.Task}
+	index := slices.IndexFunc(s.Specialists, func(specialist Specialist) bool { return specialist.Name == assignment.Specialist })
+	prompt := assignment.Task + supervisorStepsSection("Results of the previous sub-tasks", previous)
+	step.Err = s.Specialists[index].Agent.RunEventsContext(ctx, prompt, nil, func(event AgentEvent) {
+		if e, ok := event.(StopEvent); ok {
+			step.Answer = e.Reason
+		}
+		if s.OnEvent != nil {
+			s.OnEvent(assignment.Specialist, event)
+		}
+	})
+	return step
+}
+
+// Private method combining the results of the specialists into one response to the request
+func (s *Supervisor) aggregate(llm *OpenAILLM, prompt string, steps []SupervisorStep) (string, error) {
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are the supervisor of a team of specialist agents. Given the request of the user and the results of the sub-tasks your specialists carried out, write a single, complete response to the request. Mention the sub-tasks that failed, if they matter."),
+		openai.UserMessage("## Request\n\n" + prompt + supervisorStepsSection("Results of the specialists", steps)),
+	}
+	response, err := OpenAILLMStructuredPredict[SupervisorAnswer](llm, history, "answer", "Response to the request, combining the results of the specialists")
+	if err != nil {
+		return "", err
+	}
+	answer, ok := response.(SupervisorAnswer)
+	if !ok {
+		return "", errors.New("error while aggregating the results: unexpected structured output")
+	}
+	return answer.Answer, nil
+}
+
+// Private function rendering the results of steps as a markdown section with the given title (empty without steps)
+func supervisorStepsSection(title string, steps []SupervisorStep) string {
+	if len(steps) == 0 {
+		return ""
+	}
+	var section strings.Builder
+	fmt.Fprintf(&section, "\n\n## %s\n", title)
+	for _, step := range steps {
+		fmt.Fprintf(&section, "\n### %s: %s\n\n", step.Specialist, step.Task)
+		if step.Err != nil {
+			fmt.Fprintf(&section, "Failed: %v\n", step.Err)
+		} else {
+			section.WriteString(step.Answer + "\n")
+		}
+	}
+	return section.String()
+}
@@ -0,0 +1,156 @@
+package gopheract
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Name of the Task tool, removed from the tools of the sub-agents once the maximum depth is reached
+const TaskToolName = "Task"
+
+// Instructions prepended to the prompt of the sub-agents
+const taskInstructions = "You are a sub-agent working on a task delegated by another agent, which only sees your final answer: make it complete and self-contained, with the results, the files changed and anything left undone.\n\n## Task\n\n"
+
+// Struct type representing the parameters of the Task tool
+type TaskParams struct {
+	Description string   `json:"description" description:"Short (3 to 5 words) description of the task"`
+	Prompt      string   `json:"prompt" description:"Complete instructions for the sub-agent, with all the context it needs, since it does not see the conversation"`
+	Tools       []string `json:"tools" description:"Names of the tools the sub-agent can use (leave empty for all the tools)"`
+}
+
+// Struct type representing a tool that delegates a self-contained sub-task to a child agent, which works with its own chat history and returns only its final answer, keeping the context of the parent small on big multi-part jobs.
+//
+// The child agents are copies of `Agent` (same model, system prompt, tools and approvals), running on an in-memory history.
+type TaskTool struct {
+	// Agent the sub-agents are copied from when they are spawned, usually the one the tool is given to
+	Agent *OpenAIReActAgent
+	// Maximum nesting of sub-agents: with 1 (the default), sub-agents cannot spawn sub-agents of their own
+	MaxDepth int
+	// Maximum number of steps of every sub-agent (zero keeps the one of `Agent`)
+	MaxSteps int
+	// Maximum number of tokens every sub-agent can consume, after which it is stopped (zero means no limit)
+	MaxTokens int64
+	// Optional callback receiving the events of the sub-agents, along with the description of their task (e.g. to show their progress)
+	OnEvent func(task string, event AgentEvent)
+	// nesting of the sub-agents spawned by the tool (zero for the tool of the top-level agent)
+	depth int
+}
+
+// Constructor function for a new TaskTool spawning copies of the given agent, with a maximum depth of 1
+func NewTaskTool(agent *OpenAIReActAgent) *TaskTool {
+	return &TaskTool{Agent: agent, MaxDepth: 1}
+}
+
+// Method to run a sub-agent on a task until it answers, returning its final answer
+func (t *TaskTool) Execute(params TaskParams) (any, error) {
+	if t.Agent == nil {
+		return nil, errors.New("no agent is configured for the sub-agents")
+	}
+	if strings.TrimSpace(params.Prompt) == "" {
+		return nil, errors.New("no prompt provided")
+	}
+	child := *t.Agent
+	tools, err := t.childTools(&child, params.Tools)
+	if err != nil {
+		return nil, err
+	}
+	child.Tools = tools
+	child.Memory = NewInMemoryStore()
+	child.SessionID = taskSessionID(t.Agent.SessionID)
+	// the tokens of the sub-agent are accounted to the run of its parent, which reports on them
+	child.OnRunReport = nil
+	child.Notifier = nil
+	child.ExtractFacts = false
+	// the sub-agent is a copy of its parent rather than another agent, so it is left unnamed to avoid being taken for a cycle: its nesting is bounded by the depth instead
+	child.Name = ""
+	if t.MaxSteps > 0 {
+		child.MaxSteps = t.MaxSteps
+	}
+	// the sub-agent is cancelled along with the run of its parent, whose requests carry its context
+	parent := context.Background()
+	if child.Llm != nil && child.Llm.ctx != nil {
+		parent = child.Llm.ctx
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var answer string
+	var used int64
+	exceeded := false
+	err = child.RunEventsContext(ctx, taskInstructions+params.Prompt, nil, func(event AgentEvent) {
+		switch e := event.(type) {
+		case StopEvent:
+			answer = e.Reason
+		case UsageEvent:
+			used += e.Usage.TotalTokens
+			if t.MaxTokens > 0 && used > t.MaxTokens && !exceeded {
+				exceeded = true
+				cancel()
+			}
+		}
+		if t.OnEvent != nil {
+			t.OnEvent(params.Description, event)
+		}
+	})
+	switch {
+	case exceeded:
+		return nil, fmt.Errorf("the sub-agent was stopped after exceeding its budget of %d tokens", t.MaxTokens)
+	case err != nil:
+		return nil, fmt.Errorf("the sub-agent failed: %w", err)
+	}
+	return answer, nil
+}
+
+// Private method returning the tools of a sub-agent: the requested ones among the tools of the parent, with the Task tool bound to the sub-agent (or removed once the maximum depth is reached)
+func (t *TaskTool) childTools(child *OpenAIReActAgent, names []string) ([]Tool, error) {
+	available := make([]string, 0, len(child.Tools))
+	for _, tool := range child.Tools {
+		available = append(available, tool.GetMetadata().Name)
+	}
+	for _, name := range names {
+		if !slices.Contains(available, name) {
+			return nil, fmt.Errorf("unknown tool %s (available tools: %s)", name, strings.Join(available, ", "))
+		}
+	}
+	maxDepth := max(t.MaxDepth, 1)
+	tools := make([]Tool, 0, len(child.Tools))
+	for _, tool := range child.Tools {
+		name := tool.GetMetadata().Name
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+		if name == TaskToolName {
+			if t.depth+1 >= maxDepth {
+				continue
+			}
+			nested := *t
+			nested.Agent = child
+			nested.depth = t.depth + 1
+			tool = nested.AsTool()
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// Private function returning the identifier of the session of a sub-agent, derived from the one of its parent
+func taskSessionID(parent string) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s/task_%s", parent, hex.EncodeToString(b[:]))
+}
+
+// Helper method to expose the Task tool as a tool definition that can be passed to an agent.
+func (t *TaskTool) AsTool() ToolDefinition[TaskParams] {
+	return ToolDefinition[TaskParams]{
+		Name:        TaskToolName,
+		Description: "Delegate a self-contained sub-task to a sub-agent, which works with its own context and returns only its final answer, to keep your context small on big multi-part jobs, by providing a short description of the task (`description` parameter - string), complete instructions with all the context it needs, since it does not see the conversation (`prompt` parameter - string) and, optionally, the names of the tools it can use (`tools` parameter - list of strings, defaults to all of your tools). Returns the final answer of the sub-agent.",
+		Fn:          t.Execute,
+		Cost:        ToolCostHigh,
+		Latency:     ToolLatencySlow,
+	}
+}
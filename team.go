@@ -0,0 +1,283 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Topologies of the orchestration of a team
+const (
+	TeamSupervisor = "supervisor"
+	TeamSwarm      = "swarm"
+	TeamFanOut     = "fanout"
+	TeamDebate     = "debate"
+)
+
+// Struct type representing the definition of an agent of a team
+type TeamAgentConfig struct {
+	// Name of the agent, unique within the team (e.g. "coder" or "critic")
+	Name string `json:"name" yaml:"name"`
+	// Description of the agent: what it is good at for supervisors and swarms, its role in debates
+	Description string `json:"description" yaml:"description"`
+	// Model of the agent (defaults to the one of the team)
+	Model string `json:"model" yaml:"model"`
+	// Instructions appended to the system prompt of the agent
+	Instructions string `json:"instructions" yaml:"instructions"`
+	// Names of the tools of the agent (defaults to all the tools, an empty list gives none)
+	Tools []string `json:"tools" yaml:"tools"`
+	// Maximum number of steps of the runs of the agent (defaults to the one of the base agent)
+	MaxSteps int `json:"maxSteps" yaml:"maxSteps"`
+	// Prompt replacing the request for the agent, in fan-outs
+	Prompt string `json:"prompt" yaml:"prompt"`
+}
+
+// Struct type representing the orchestration of the agents of a team
+type TeamOrchestration struct {
+	// Topology of the team: "supervisor" (the default), "swarm", "fanout" or "debate"
+	Type string `json:"type" yaml:"type"`
+	// Whether the supervisor can split requests into sub-tasks for several agents
+	Decompose bool `json:"decompose" yaml:"decompose"`
+	// Maximum number of sub-tasks a supervisor splits a request into
+	MaxAssignments int `json:"maxAssignments" yaml:"maxAssignments"`
+	// Maximum number of handoffs in the conversations of a swarm
+	MaxHandoffs int `json:"maxHandoffs" yaml:"maxHandoffs"`
+	// Reducer merging the answers of a fan-out: "concat" (the default), "vote" or "judge"
+	Reducer string `json:"reducer" yaml:"reducer"`
+	// Criteria the judge of a fan-out picks the best answer on
+	Criteria string `json:"criteria" yaml:"criteria"`
+	// Maximum number of agents of a fan-out running at once
+	MaxConcurrency int `json:"maxConcurrency" yaml:"maxConcurrency"`
+	// Number of rounds of a debate
+	Rounds int `json:"rounds" yaml:"rounds"`
+}
+
+// Struct type representing the declarative definition of a team of agents and of their orchestration, loaded from a YAML or JSON file so that teams can be versioned alongside a repository
+type TeamConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// Model of the agents and of the orchestrator (defaults to the one of the base agent)
+	Model         string            `json:"model" yaml:"model"`
+	Orchestration TeamOrchestration `json:"orchestration" yaml:"orchestration"`
+	// Whether the agents share a blackboard, injected into their prompts and editable with the Blackboard tool
+	Blackboard bool              `json:"blackboard" yaml:"blackboard"`
+	Agents     []TeamAgentConfig `json:"agents" yaml:"agents"`
+}
+
+// Function loading the definition of a team from a file, decoded as JSON for .json files and as YAML otherwise, and validating it
+func LoadTeamConfig(path string) (*TeamConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &TeamConfig{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, config)
+	} else {
+		err = yaml.Unmarshal(content, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid team file %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid team file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Method checking that the definition of a team is consistent: agents with distinct names, a known topology and reducer, and enough agents for a debate
+func (c *TeamConfig) Validate() error {
+	if len(c.Agents) == 0 {
+		return errors.New("no agents are defined")
+	}
+	names := map[string]bool{}
+	for _, agent := range c.Agents {
+		if agent.Name == "" {
+			return errors.New("every agent needs a name")
+		}
+		if names[agent.Name] {
+			return fmt.Errorf("duplicate agent %s", agent.Name)
+		}
+		names[agent.Name] = true
+	}
+	switch c.Orchestration.Type {
+	case "", TeamSupervisor, TeamSwarm, TeamFanOut:
+	case TeamDebate:
+		if len(c.Agents) < 2 {
+			return errors.New("a debate needs at least two agents")
+		}
+	default:
+		return fmt.Errorf("invalid orchestration type %s (expected supervisor, swarm, fanout or debate)", c.Orchestration.Type)
+	}
+	if !slices.Contains([]string{"", "concat", "vote", "judge"}, c.Orchestration.Reducer) {
+		return fmt.Errorf("invalid reducer %s (expected concat, vote or judge)", c.Orchestration.Reducer)
+	}
+	return nil
+}
+
+// Method building the team defined by the configuration, whose agents are copies of the base agent (same provider, tools, approvals and system prompt) with the settings of their definition.
+//
+// The tools of the agents are looked up among the ones of the base agent.
+func (c *TeamConfig) Build(base *OpenAIReActAgent) (*Team, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if base == nil || base.Llm == nil {
+		return nil, errors.New("no base agent is configured for the team")
+	}
+	team := &Team{Name: c.Name, Orchestration: c.Orchestration, Llm: withModel(base.Llm, c.Model)}
+	if c.Blackboard {
+		team.Blackboard = NewBlackboard()
+	}
+	for _, definition := range c.Agents {
+		agent, err := c.buildAgent(base, definition, team.Blackboard)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", definition.Name, err)
+		}
+		team.Members = append(team.Members, Specialist{Name: definition.Name, Description: definition.Description, Agent: agent})
+		team.prompts = append(team.prompts, definition.Prompt)
+	}
+	return team, nil
+}
+
+// Private method building an agent of the team from its definition, as a copy of the base agent with an in-memory history
+func (c *TeamConfig) buildAgent(base *OpenAIReActAgent, definition TeamAgentConfig, board *Blackboard) (*OpenAIReActAgent, error) {
+	agent := *base
+	agent.Name = definition.Name
+	agent.Memory = NewInMemoryStore()
+	agent.SessionID = fmt.Sprintf("%s/%s", base.SessionID, definition.Name)
+	model := definition.Model
+	if model == "" {
+		model = c.Model
+	}
+	agent.Llm = withModel(base.Llm, model)
+	if definition.MaxSteps > 0 {
+		agent.MaxSteps = definition.MaxSteps
+	}
+	if definition.Tools != nil {
+		agent.Tools = make([]Tool, 0, len(definition.Tools))
+		for _, name := range definition.Tools {
+			index := slices.IndexFunc(base.Tools, func(tool Tool) bool { return tool.GetMetadata().Name == name })
+			if index < 0 {
+				return nil, fmt.Errorf("unknown tool %s", name)
+			}
+			agent.Tools = append(agent.Tools, base.Tools[index])
+		}
+	} else {
+		agent.Tools = slices.Clone(base.Tools)
+	}
+	if board != nil {
+		agent.Blackboard = board
+		agent.Tools = append(agent.Tools, NewBlackboardTool(board, definition.Name).AsTool())
+	}
+	if definition.Instructions != "" {
+		tmpl, err := withInstructions(agent.SystemPromptTemplate, definition.Instructions)
+		if err != nil {
+			return nil, err
+		}
+		agent.SystemPromptTemplate = tmpl
+	}
+	return &agent, nil
+}
+
+// Private function returning a copy of an LLM using another model (the LLM itself if no model is given)
+func withModel(llm *OpenAILLM, model string) *OpenAILLM {
+	if model == "" {
+		return llm
+	}
+	copied := *llm
+	copied.Model = model
+	return &copied
+}
+
+// Private function returning a system prompt template with instructions appended under their own heading (the instructions are inserted as a value, so that they are not parsed as a template)
+func withInstructions(base *template.Template, instructions string) (*template.Template, error) {
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Funcs(template.FuncMap{"instructions": func() string { return instructions }}).
+		New("withInstructions").
+		Parse(fmt.Sprintf("{{template %q .}}\n\n## Additional Instructions\n\n{{instructions}}", base.Name()))
+}
+
+// Struct type representing a team of agents built from a TeamConfig, run with the orchestration of its definition
+type Team struct {
+	Name          string
+	Orchestration TeamOrchestration
+	Members       []Specialist
+	// LLM of the orchestrator: router of supervisors, judge of fan-outs and synthesizer of debates
+	Llm *OpenAILLM
+	// Blackboard shared by the agents, if enabled
+	Blackboard *Blackboard
+	// Optional callback receiving the events of the runs of the agents, along with their name
+	OnEvent func(agent string, event AgentEvent)
+	// prompts of the fan-out branches of the agents, in order
+	prompts []string
+}
+
+// Method to run the team on a request, returning its final answer
+func (t *Team) Run(ctx context.Context, prompt string) (string, error) {
+	switch t.Orchestration.Type {
+	case "", TeamSupervisor:
+		supervisor := NewSupervisor(t.Llm, t.Members...)
+		supervisor.Decompose = t.Orchestration.Decompose
+		supervisor.MaxAssignments = t.Orchestration.MaxAssignments
+		supervisor.OnEvent = t.OnEvent
+		result, err := supervisor.Run(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return result.Answer, nil
+	case TeamSwarm:
+		swarm := NewSwarm(t.Members...)
+		swarm.MaxHandoffs = t.Orchestration.MaxHandoffs
+		swarm.OnEvent = t.OnEvent
+		result, err := swarm.Run(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return result.Answer, nil
+	case TeamFanOut:
+		branches := make([]FanOutBranch, 0, len(t.Members))
+		for i, member := range t.Members {
+			branches = append(branches, FanOutBranch{Name: member.Name, Agent: member.Agent, Prompt: t.prompts[i]})
+		}
+		var reducer FanOutReducer = ConcatReducer{}
+		switch t.Orchestration.Reducer {
+		case "vote":
+			reducer = MajorityVoteReducer{}
+		case "judge":
+			reducer = JudgeReducer{Llm: t.Llm, Criteria: t.Orchestration.Criteria}
+		}
+		fanOut := NewFanOut(reducer, branches...)
+		fanOut.MaxConcurrency = t.Orchestration.MaxConcurrency
+		fanOut.OnEvent = t.OnEvent
+		result, err := fanOut.Run(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return result.Answer, nil
+	case TeamDebate:
+		debate := NewDebate(t.Members...)
+		if t.Orchestration.Rounds > 0 {
+			debate.Rounds = t.Orchestration.Rounds
+		}
+		debate.Llm = t.Llm
+		debate.OnEvent = t.OnEvent
+		result, err := debate.Run(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return result.Answer, nil
+	default:
+		return "", fmt.Errorf("invalid orchestration type %s", t.Orchestration.Type)
+	}
+}
@@ -0,0 +1,134 @@
+package gopheract
+
+import (
+	"unicode/utf8"
+)
+
+// Base interface for the token counters used to fit the chat history into the context window of a model.
+//
+// It is compatible with tiktoken-based tokenizers (e.g. `github.com/pkoukk/tiktoken-go`), that can be plugged in through `TokenCounterFunc`.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// Function type implementing TokenCounter
+type TokenCounterFunc func(text string) int
+
+// Count the tokens in a text
+func (f TokenCounterFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// Implementation of TokenCounter that estimates the number of tokens from the length of the text, assuming about 4 characters per token (as with OpenAI's `cl100k_base` and `o200k_base` encodings on English text).
+type EstimateTokenCounter struct{}
+
+// Estimate the tokens in a text
+func (EstimateTokenCounter) CountTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Number of tokens added to every message by the chat format, on top of its content
+const messageTokenOverhead = 4
+
+// Struct type representing a policy that fits the chat history into the context window of a model before each LLM call.
+//
+// The system messages at the beginning of the history and the pinned messages are always kept; the oldest messages among the others are dropped until the history fits, and if the newest message alone does not fit, its content is trimmed from the start.
+type ContextWindowPolicy struct {
+	// Counter used to count the tokens of the messages (defaults to EstimateTokenCounter)
+	Counter TokenCounter
+	// Size of the context window of the model, in tokens
+	MaxTokens int
+	// Tokens reserved for the response of the model
+	ReservedTokens int
+}
+
+// Constructor function for a new ContextWindowPolicy with the default token estimator.
+func NewContextWindowPolicy(maxTokens, reservedTokens int) *ContextWindowPolicy {
+	return &ContextWindowPolicy{
+		Counter:        EstimateTokenCounter{},
+		MaxTokens:      maxTokens,
+		ReservedTokens: reservedTokens,
+	}
+}
+
+// Private method returning the token counter of the policy
+func (p *ContextWindowPolicy) counter() TokenCounter {
+	if p.Counter == nil {
+		return EstimateTokenCounter{}
+	}
+	return p.Counter
+}
+
+// Method counting the tokens of a slice of messages, including the overhead of the chat format
+func (p *ContextWindowPolicy) CountTokens(messages []*ChatMessage) int {
+	counter := p.counter()
+	total := 0
+	for _, message := range messages {
+		total += counter.CountTokens(message.Content) + messageTokenOverhead
+	}
+	return total
+}
+
+// Method returning the messages that fit into the context window, leaving the input slice untouched.
+func (p *ContextWindowPolicy) Fit(messages []*ChatMessage) []*ChatMessage {
+	budget := p.MaxTokens - p.ReservedTokens
+	if p.MaxTokens <= 0 || p.CountTokens(messages) <= budget {
+		return messages
+	}
+	// the leading system messages and the pinned messages are always kept
+	kept := make([]bool, len(messages))
+	droppable := []int{}
+	for i, message := range messages {
+		if message.Pinned || (message.Role == "system" && len(droppable) == 0) {
+			kept[i] = true
+			budget -= p.CountTokens(messages[i : i+1])
+		} else {
+			droppable = append(droppable, i)
+		}
+	}
+	used := 0
+	for _, i := range droppable {
+		used += p.CountTokens(messages[i : i+1])
+	}
+	for len(droppable) > 1 && used > budget {
+		used -= p.CountTokens(messages[droppable[0] : droppable[0]+1])
+		droppable = droppable[1:]
+	}
+	for _, i := range droppable {
+		kept[i] = true
+	}
+	fitted := make([]*ChatMessage, 0, len(messages))
+	for i, message := range messages {
+		if !kept[i] {
+			continue
+		}
+		if len(droppable) == 1 && i == droppable[0] && used > budget {
+			message = p.trim(message, budget-messageTokenOverhead)
+		}
+		fitted = append(fitted, message)
+	}
+	return fitted
+}
+
+// Private method trimming the content of a message from the start, so that it fits into `budget` tokens
+func (p *ContextWindowPolicy) trim(message *ChatMessage, budget int) *ChatMessage {
+	trimmed := *message
+	if budget <= 0 {
+		trimmed.Content = ""
+		return &trimmed
+	}
+	counter := p.counter()
+	runes := []rune(message.Content)
+	// binary search for the longest suffix of the content that fits
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if counter.CountTokens(string(runes[len(runes)-mid:])) <= budget {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	trimmed.Content = string(runes[len(runes)-low:])
+	return &trimmed
+}
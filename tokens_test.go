@@ -0,0 +1,128 @@
+package gopheract
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestContextWindowPolicyFit(t *testing.T) {
+	// one token per character, so that every message costs its length plus the overhead of the chat format
+	counter := TokenCounterFunc(utf8.RuneCountInString)
+	pinned := func(role, content string) *ChatMessage {
+		message := NewChatMessage(role, content)
+		message.Pinned = true
+		return message
+	}
+	tests := []struct {
+		name     string
+		max      int
+		reserved int
+		messages []*ChatMessage
+		want     []string
+	}{
+		{
+			name:     "history that fits",
+			max:      100,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), NewChatMessage("user", "hello")},
+			want:     []string{"sys", "hello"},
+		},
+		{
+			name:     "no limit",
+			messages: []*ChatMessage{NewChatMessage("user", "a very long message that no window would hold")},
+			want:     []string{"a very long message that no window would hold"},
+		},
+		{
+			name:     "oldest messages dropped",
+			max:      30,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), NewChatMessage("user", "aaaaaaaaaa"), NewChatMessage("assistant", "bbbbbbbbbb"), NewChatMessage("user", "cc")},
+			want:     []string{"sys", "bbbbbbbbbb", "cc"},
+		},
+		{
+			name:     "tokens reserved for the response",
+			max:      40,
+			reserved: 10,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), NewChatMessage("user", "aaaaaaaaaa"), NewChatMessage("assistant", "bbbbbbbbbb"), NewChatMessage("user", "cc")},
+			want:     []string{"sys", "bbbbbbbbbb", "cc"},
+		},
+		{
+			name:     "pinned messages kept",
+			max:      30,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), pinned("user", "pppp"), NewChatMessage("user", "aaaaaaaaaa"), NewChatMessage("assistant", "bbbbbbbbbb")},
+			want:     []string{"sys", "pppp", "bbbbbbbbbb"},
+		},
+		{
+			name:     "later system messages dropped",
+			max:      20,
+			messages: []*ChatMessage{NewChatMessage("user", "aaaaaaaaaa"), NewChatMessage("system", "reminder"), NewChatMessage("user", "cc")},
+			want:     []string{"reminder", "cc"},
+		},
+		{
+			name:     "newest message trimmed from the start",
+			max:      20,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), NewChatMessage("user", "0123456789abcdefghij")},
+			want:     []string{"sys", "bcdefghij"},
+		},
+		{
+			name:     "older messages dropped before trimming the newest",
+			max:      20,
+			messages: []*ChatMessage{NewChatMessage("system", "sys"), NewChatMessage("user", "aaaaaaaaaa"), NewChatMessage("user", "0123456789abcdefghij")},
+			want:     []string{"sys", "bcdefghij"},
+		},
+		{
+			name:     "no room left for the newest message",
+			max:      10,
+			messages: []*ChatMessage{NewChatMessage("system", "system prompt"), NewChatMessage("user", "hello")},
+			want:     []string{"system prompt", ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := &ContextWindowPolicy{Counter: counter, MaxTokens: test.max, ReservedTokens: test.reserved}
+			original := make([]string, len(test.messages))
+			for i, message := range test.messages {
+				original[i] = message.Content
+			}
+			fitted := policy.Fit(test.messages)
+			if len(fitted) != len(test.want) {
+				t.Fatalf("got %d messages, want %d", len(fitted), len(test.want))
+			}
+			for i, message := range fitted {
+				if message.Content != test.want[i] {
+					t.Errorf("message %d: got %q, want %q", i, message.Content, test.want[i])
+				}
+			}
+			for i, message := range test.messages {
+				if message.Content != original[i] {
+					t.Errorf("message %d of the input was modified", i)
+				}
+			}
+		})
+	}
+}
+
+func TestContextWindowPolicyTrim(t *testing.T) {
+	policy := NewContextWindowPolicy(100, 0)
+	tests := []struct {
+		name    string
+		content string
+		budget  int
+		want    string
+	}{
+		{"content that fits", "short", 10, "short"},
+		{"suffix kept", "0123456789abcdefghij", 2, "cdefghij"},
+		{"multibyte runes kept whole", "ééééééééééééé", 1, "éééé"},
+		{"no budget", "content", 0, ""},
+		{"negative budget", "content", -3, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trimmed := policy.trim(NewChatMessage("user", test.content), test.budget)
+			if trimmed.Content != test.want {
+				t.Errorf("got %q, want %q", trimmed.Content, test.want)
+			}
+			if !utf8.ValidString(trimmed.Content) {
+				t.Errorf("trimmed content %q is not valid UTF-8", trimmed.Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package gopheract
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind of a trace entry
+type TraceEntryKind string
+
+const (
+	// Request to the LLM, with the messages sent and the structured completion received (for the action phase, the action chosen by the model)
+	TraceEntryCompletion TraceEntryKind = "completion"
+	// Tool call executed (or reused) by the agent, with its result
+	TraceEntryToolCall TraceEntryKind = "tool_call"
+)
+
+// Struct type representing an entry of a run trace
+type TraceEntry struct {
+	Kind TraceEntryKind `json:"kind"`
+	// Index of the think-act-observe iteration the entry belongs to (starting from 1)
+	Step      int          `json:"step"`
+	Phase     MessagePhase `json:"phase,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Duration  Duration     `json:"duration,omitzero"`
+	// Messages sent to the LLM (completions only), in the format of the provider
+	Messages json.RawMessage `json:"messages,omitempty"`
+	// Structured output of the LLM (completions only)
+	Completion json.RawMessage `json:"completion,omitempty"`
+	// Tokens consumed by the request to the LLM (completions only)
+	Usage *TokenUsage `json:"usage,omitempty"`
+	// Identifier, name, arguments and result of the tool call (tool calls only)
+	CallID string         `json:"call_id,omitempty"`
+	Tool   string         `json:"tool,omitempty"`
+	Args   map[string]any `json:"args,omitempty"`
+	Result any            `json:"result,omitempty"`
+	// Whether the result of the tool call was reused from an identical call in the same run
+	Reused bool   `json:"reused,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Struct type representing the trace of a run: the prompt, then every completion (including the actions chosen by the model) and tool call with its result, in order.
+type Trace struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Answer     string    `json:"answer,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Tokens consumed by all the LLM requests of the run (including the compaction of the history and the extraction of facts)
+	Usage   TokenUsage   `json:"usage"`
+	Entries []TraceEntry `json:"entries"`
+	// Scores of the run (e.g. from evaluations or user feedback), sent along with the trace by the exporters
+	Scores map[string]float64 `json:"scores,omitempty"`
+	mu     sync.Mutex
+	// current iteration of the run
+	step int
+	// usage of the latest LLM request, attached to the next completion entry
+	pendingUsage *TokenUsage
+}
+
+// Duration serialized as a string (e.g. "1.5s") in JSON
+type Duration time.Duration
+
+// Serialize the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Deserialize the duration from a string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Private method setting the current iteration of the run (no-op on nil Trace)
+func (t *Trace) setStep(step int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.step = step
+}
+
+// Private method accounting the token usage of an LLM request of the run (no-op on nil Trace). The usage of the requests of the think, act and observe phases is attached to their completion entry.
+func (t *Trace) addUsage(phase string, usage TokenUsage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Usage = t.Usage.Add(usage)
+	switch MessagePhase(phase) {
+	case MessagePhaseThought, MessagePhaseAction, MessagePhaseObservation:
+		t.pendingUsage = &usage
+	}
+}
+
+// Method to set a score of the run (e.g. the outcome of an evaluation), exported along with the trace
+func (t *Trace) SetScore(name string, value float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Scores == nil {
+		t.Scores = map[string]float64{}
+	}
+	t.Scores[name] = value
+}
+
+// Private method appending an entry to the current iteration of the trace (no-op on nil Trace)
+func (t *Trace) add(entry TraceEntry) {
+	if t == nil {
+		return
+	}
+	entry.CreatedAt = time.Now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry.Step = t.step
+	if entry.Kind == TraceEntryCompletion {
+		entry.Usage, t.pendingUsage = t.pendingUsage, nil
+	}
+	t.Entries = append(t.Entries, entry)
+}
+
+// Private method recording a request to the LLM (no-op on nil Trace)
+func (t *Trace) addCompletion(phase MessagePhase, messages, completion any, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	serializedMessages, _ := json.Marshal(messages)
+	serializedCompletion, _ := json.Marshal(completion)
+	t.add(TraceEntry{
+		Kind:       TraceEntryCompletion,
+		Phase:      phase,
+		Duration:   Duration(duration),
+		Messages:   serializedMessages,
+		Completion: serializedCompletion,
+	})
+}
+
+// Load a trace from a JSON file written by a TraceRecorder
+func LoadTrace(path string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var trace Trace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// Base interface for the exporters pushing run traces to an observability platform
+type TraceExporter interface {
+	Export(ctx context.Context, trace *Trace) error
+}
+
+// Struct type representing a recorder writing the trace of every run of an agent to a JSON file (one per run) under a directory, as the basis for replay, debugging and evaluation tooling.
+type TraceRecorder struct {
+	Dir string
+	// Exporters the traces are pushed to once written (e.g. LangfuseExporter or LangSmithExporter)
+	Exporters []TraceExporter
+}
+
+// Constructor function for a new TraceRecorder, creating the directory if it does not exist yet.
+func NewTraceRecorder(dir string) (*TraceRecorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &TraceRecorder{Dir: dir}, nil
+}
+
+// Start the trace of a run
+func (r *TraceRecorder) Start(sessionID, model, prompt string) *Trace {
+	now := time.Now().UTC()
+	return &Trace{
+		ID:        fmt.Sprintf("run_%d", now.UnixNano()),
+		SessionID: sessionID,
+		Model:     model,
+		Prompt:    prompt,
+		StartedAt: now,
+		Entries:   []TraceEntry{},
+	}
+}
+
+// Finish the trace of a run and write it to the directory of the recorder, returning the path of the file
+func (r *TraceRecorder) Finish(trace *Trace, runErr error) (string, error) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.FinishedAt = time.Now().UTC()
+	if runErr != nil {
+		trace.Error = runErr.Error()
+	}
+	data, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.Dir, trace.ID+".json")
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// Push a trace to all the exporters of the recorder
+func (r *TraceRecorder) Export(ctx context.Context, trace *Trace) error {
+	errs := []error{}
+	for _, exporter := range r.Exporters {
+		errs = append(errs, exporter.Export(ctx, trace))
+	}
+	return errors.Join(errs...)
+}
+
+// Private function deriving a stable UUID from a name (e.g. the identifier of a trace and of one of its entries), so that exporting a trace twice does not duplicate it
+func traceUUID(name string) string {
+	sum := sha1.Sum([]byte(name))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Private function returning the time at which a trace entry started, given that entries are recorded when they end
+func entryStart(entry TraceEntry) time.Time {
+	return entry.CreatedAt.Add(-time.Duration(entry.Duration))
+}
+
+// Private function sending a JSON payload to the HTTP API of an observability platform
+func postJSON(ctx context.Context, client *http.Client, url string, payload any, authenticate func(*http.Request)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authenticate(req)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request to %s failed with status %d: %s", url, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Replay a recorded run through an event handler, as `RunEvents` would have streamed it, without contacting the LLM provider nor executing any tool, so that frontends can render it step by step.
+//
+// Usage events are not replayed, since the trace does not record token usage. If the recorded run failed, its error is sent as an ErrorEvent and returned once the recorded steps have been replayed.
+func ReplayTrace(trace *Trace, handler func(AgentEvent)) error {
+	calls, step := 0, 0
+	// the tool calls of older traces are identified within the run only
+	prefix := ""
+	for _, entry := range trace.Entries {
+		if entry.Kind == TraceEntryToolCall && strings.HasPrefix(entry.CallID, trace.ID+"/") {
+			prefix = trace.ID
+			break
+		}
+	}
+	for _, entry := range trace.Entries {
+		step = entry.Step
+		info := EventInfo{Time: entry.CreatedAt, Step: step}
+		if entry.Kind == TraceEntryToolCall {
+			if entry.Error == "" {
+				handler(ToolEndEvent{EventInfo: info, CallID: entry.CallID, Tool: entry.Tool, Result: entry.Result, Reused: entry.Reused, Duration: time.Duration(entry.Duration)})
+			}
+			continue
+		}
+		switch entry.Phase {
+		case MessagePhaseThought:
+			var thought Thought
+			if err := json.Unmarshal(entry.Completion, &thought); err != nil {
+				return fmt.Errorf("invalid thought at step %d: %w", entry.Step, err)
+			}
+			handler(ThoughtEvent{EventInfo: info, Thought: thought.Thought, Duration: time.Duration(entry.Duration)})
+		case MessagePhaseAction:
+			var action Action
+			if err := json.Unmarshal(entry.Completion, &action); err != nil {
+				return fmt.Errorf("invalid action at step %d: %w", entry.Step, err)
+			}
+			handler(ActionEvent{EventInfo: info, Action: action, Duration: time.Duration(entry.Duration)})
+			switch action.ActionType {
+			case "_done":
+				if action.StopReason != nil {
+					handler(StopEvent{EventInfo: info, Reason: action.StopReason.Reason})
+				}
+			case "tool_call", "tool_calls":
+				toolCalls := action.ToolCalls
+				if action.ActionType == "tool_call" && action.ToolCall != nil {
+					toolCalls = []ToolCall{*action.ToolCall}
+				}
+				// tool calls are numbered in the order they are requested within the run, as in `RunEvents`
+				for _, toolCall := range toolCalls {
+					calls++
+					handler(ToolStartEvent{EventInfo: info, CallID: toolCallID(prefix, calls), ToolCall: toolCall})
+				}
+			}
+		case MessagePhaseObservation:
+			var observation Observation
+			if err := json.Unmarshal(entry.Completion, &observation); err != nil {
+				return fmt.Errorf("invalid observation at step %d: %w", entry.Step, err)
+			}
+			handler(ObservationEvent{EventInfo: info, Observation: observation.Observation, Duration: time.Duration(entry.Duration)})
+		}
+	}
+	if trace.Error != "" {
+		err := errors.New(trace.Error)
+		handler(ErrorEvent{EventInfo: EventInfo{Time: trace.FinishedAt, Step: step}, Err: err})
+		return err
+	}
+	return nil
+}
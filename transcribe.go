@@ -0,0 +1,72 @@
+package gopheract
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// Base interface for the speech-to-text models, turning audio input into text the agent can be prompted with
+type Transcriber interface {
+	// Transcribe audio data, given its MIME type (e.g. "audio/wav")
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// Implementation of Transcriber for the OpenAI transcription models (e.g. "whisper-1" or "gpt-4o-transcribe")
+type OpenAITranscriber struct {
+	// The OpenAI transcription model to use
+	Model openai.AudioModel
+	// Optional language of the audio, in ISO-639-1 format (e.g. "en"), improving accuracy and latency
+	Language string
+
+	// OpenAI API client
+	Client *openai.Client
+}
+
+// Constructor function for a new OpenAITranscriber (provide an API key and the model identifier, e.g. "whisper-1")
+func NewOpenAITranscriber(apiKey, model string) *OpenAITranscriber {
+	client := openai.NewClient(option.WithAPIKey(apiKey))
+	return &OpenAITranscriber{
+		Model:  model,
+		Client: &client,
+	}
+}
+
+// Private function returning the file extension of an audio MIME type, from which the API detects the format of the audio
+func audioExtension(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch mimeType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return "m4a"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/flac", "audio/x-flac":
+		return "flac"
+	case "audio/ogg":
+		return "ogg"
+	case "audio/webm":
+		return "webm"
+	}
+	return "wav"
+}
+
+// Transcribe audio data with the OpenAI transcription API
+func (o *OpenAITranscriber) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	params := openai.AudioTranscriptionNewParams{
+		File:  openai.File(bytes.NewReader(audio), "audio."+audioExtension(mimeType), mimeType),
+		Model: o.Model,
+	}
+	if o.Language != "" {
+		params.Language = openai.String(o.Language)
+	}
+	transcription, err := o.Client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	return transcription.Text, nil
+}
@@ -0,0 +1,95 @@
+package gopheract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Format of the messages reporting the tool calls of the agent in the chat history
+const actionMessageFormat = "Calling tool %s with arguments: %s"
+
+// Function extracting the tool name and the serialized arguments from a message reporting a tool call
+func ParseActionMessage(content string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(content, "Calling tool ")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, " with arguments: ")
+}
+
+// Function rendering a chat history as a transcript, either as a readable Markdown log (`format` is "markdown") or as JSONL, one message per line (`format` is "jsonl").
+func FormatTranscript(messages []*ChatMessage, format string) ([]byte, error) {
+	switch format {
+	case "markdown", "md":
+		return transcriptMarkdown(messages), nil
+	case "jsonl":
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, message := range messages {
+			if err := encoder.Encode(message); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported transcript format: %s", format)
+	}
+}
+
+// Private function rendering a chat history as a Markdown log
+func transcriptMarkdown(messages []*ChatMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Transcript\n")
+	for _, message := range messages {
+		heading, body := "", message.Content
+		switch {
+		case message.Phase == MessagePhasePrompt && message.Role == "system":
+			heading = "## System prompt"
+			body = "<details>\n<summary>Show</summary>\n\n" + message.Content + "\n\n</details>"
+		case message.Phase == MessagePhasePrompt:
+			heading = "## Request"
+		case message.Phase == MessagePhaseThought:
+			heading = "### Thought"
+		case message.Phase == MessagePhaseAction:
+			heading = "### Tool call"
+			if name, args, ok := ParseActionMessage(message.Content); ok {
+				var indented bytes.Buffer
+				if json.Indent(&indented, []byte(args), "", "  ") == nil {
+					args = indented.String()
+				}
+				heading = fmt.Sprintf("### Tool call: `%s`", name)
+				body = "```json\n" + args + "\n```"
+			}
+		case message.Phase == MessagePhaseTool:
+			heading = "### Tool result"
+			body = "```\n" + message.Content + "\n```"
+		case message.Phase == MessagePhaseObservation:
+			heading = "### Observation"
+		case message.Phase == MessagePhaseReminder:
+			heading = "### Reminder"
+		case message.Phase == MessagePhaseVerification:
+			heading = "### Verification"
+		default:
+			heading = fmt.Sprintf("### %s", message.Role)
+		}
+		if message.ToolCallID != "" {
+			heading += fmt.Sprintf(" (`%s`)", message.ToolCallID)
+		}
+		if !message.CreatedAt.IsZero() {
+			heading += " · " + message.CreatedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(&buf, "\n%s\n\n%s\n", heading, body)
+	}
+	return buf.Bytes()
+}
+
+// Method exporting the chat history of the current session as a Markdown log (`format` is "markdown") or as JSONL (`format` is "jsonl"), to share runs or build evaluation datasets.
+func (o *OpenAIReActAgent) ExportTranscript(format string) ([]byte, error) {
+	history, err := o.History()
+	if err != nil {
+		return nil, err
+	}
+	return FormatTranscript(history, format)
+}
@@ -0,0 +1,322 @@
+package gopheract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Struct type representing a chunk stored in a vector store, along with its embedding
+type VectorRecord struct {
+	Chunk
+	Embedding []float32 `json:"embedding"`
+}
+
+// Struct type representing a chunk retrieved from a vector store, along with its similarity to the query
+type ScoredChunk struct {
+	Chunk
+	Score float32 `json:"score"`
+}
+
+// Struct type representing the options of a query to a vector store
+type VectorQuery struct {
+	// Number of chunks to retrieve (defaults to 5)
+	TopK int
+	// Minimum similarity for a chunk to be retrieved
+	MinScore float32
+	// Optional values the metadata of the chunks must have to be retrieved (e.g. {"format": "markdown"})
+	Filter map[string]any
+}
+
+// Private method returning the number of chunks to retrieve
+func (q VectorQuery) topK() int {
+	if q.TopK <= 0 {
+		return 5
+	}
+	return q.TopK
+}
+
+// Private method returning whether the metadata of a chunk matches the filter of the query. Values are compared by their text, so that numbers match whether they were decoded from JSON or not.
+func (q VectorQuery) matches(metadata map[string]any) bool {
+	for key, value := range q.Filter {
+		actual, ok := metadata[key]
+		if !ok || fmt.Sprint(actual) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Base interface for the vector stores, storing the embeddings of chunks and retrieving the chunks most similar to a query
+type VectorStore interface {
+	// Add records to the store, replacing the records with the same chunk identifiers
+	Add(ctx context.Context, records ...VectorRecord) error
+	// Retrieve the chunks most similar to an embedding, from the most to the least similar
+	Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error)
+	// Delete the records of the chunks of the given documents
+	Delete(ctx context.Context, documentIDs ...string) error
+}
+
+// Interface that the vector stores keeping their records in memory implement, so that they can be saved to disk and loaded back
+type PersistentVectorStore interface {
+	VectorStore
+	// Save the records of the store to a file
+	Save(path string) error
+	// Load the records of the store from a file written by `Save`, replacing them
+	Load(path string) error
+}
+
+// Interface that the vector stores buffering their changes implement, so that the changes are written out at the end of a batch of changes (e.g. every few files of an ingestion)
+type FlushableVectorStore interface {
+	VectorStore
+	// Write out the changes made since the last flush
+	Flush() error
+}
+
+// Interface that the vector stores can implement to list the chunks they hold, so that a keyword index can be built from them
+type ChunkLister interface {
+	// List the chunks of the store, without their embeddings
+	Chunks(ctx context.Context) ([]Chunk, error)
+}
+
+// Function embedding chunks, returning the records to add to a vector store
+func EmbedChunks(ctx context.Context, embedder Embedder, chunks []Chunk) ([]VectorRecord, error) {
+	if len(chunks) == 0 {
+		return []VectorRecord{}, nil
+	}
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	embeddings, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(chunks) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(chunks), len(embeddings))
+	}
+	records := make([]VectorRecord, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = VectorRecord{Chunk: chunk, Embedding: embeddings[i]}
+	}
+	return records, nil
+}
+
+// Implementation of VectorStore keeping its records in memory and comparing them by cosine similarity, so that retrieval works without any infrastructure. It can be saved to a JSON file and loaded back, automatically if it has a path.
+//
+// The changes of a store with a path are written to its file by `Flush` and `Close`, rather than on every change, since the whole file is rewritten every time.
+type InMemoryVectorStore struct {
+	// Optional path of the file the store is saved to when flushed
+	Path    string
+	mu      sync.RWMutex
+	records []VectorRecord
+	// whether the records changed since they were last written to the file
+	dirty bool
+}
+
+// Constructor function for a new, empty InMemoryVectorStore
+func NewInMemoryVectorStore() *InMemoryVectorStore {
+	return &InMemoryVectorStore{}
+}
+
+// Constructor function for an InMemoryVectorStore saved to a file when flushed or closed, loaded from the file if it exists
+func OpenInMemoryVectorStore(path string) (*InMemoryVectorStore, error) {
+	store := &InMemoryVectorStore{Path: path}
+	if err := store.Load(path); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Method returning the number of records in the store
+func (s *InMemoryVectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Add records to the store, replacing the records with the same chunk identifiers
+func (s *InMemoryVectorStore) Add(ctx context.Context, records ...VectorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	indexes := make(map[string]int, len(s.records))
+	for i, record := range s.records {
+		indexes[record.ID] = i
+	}
+	for _, record := range records {
+		if index, ok := indexes[record.ID]; ok {
+			s.records[index] = record
+			continue
+		}
+		indexes[record.ID] = len(s.records)
+		s.records = append(s.records, record)
+	}
+	s.dirty = true
+	return nil
+}
+
+// Retrieve the chunks most similar to an embedding
+func (s *InMemoryVectorStore) Query(ctx context.Context, embedding []float32, query VectorQuery) ([]ScoredChunk, error) {
+	s.mu.RLock()
+	scored := []ScoredChunk{}
+	for _, record := range s.records {
+		if !query.matches(record.Metadata) {
+			continue
+		}
+		score := cosineSimilarity(embedding, record.Embedding)
+		if score >= query.MinScore {
+			scored = append(scored, ScoredChunk{Chunk: record.Chunk, Score: score})
+		}
+	}
+	s.mu.RUnlock()
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > query.topK() {
+		scored = scored[:query.topK()]
+	}
+	return scored, nil
+}
+
+// List the chunks of the store
+func (s *InMemoryVectorStore) Chunks(ctx context.Context) ([]Chunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	chunks := make([]Chunk, len(s.records))
+	for i, record := range s.records {
+		chunks[i] = record.Chunk
+	}
+	return chunks, nil
+}
+
+// Delete the records of the chunks of the given documents
+func (s *InMemoryVectorStore) Delete(ctx context.Context, documentIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = slices.DeleteFunc(s.records, func(record VectorRecord) bool {
+		return slices.Contains(documentIDs, record.DocumentID)
+	})
+	s.dirty = true
+	return nil
+}
+
+// Method to write the changes of the store to its file, if it has one and they were not written yet
+func (s *InMemoryVectorStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Path == "" || !s.dirty {
+		return nil
+	}
+	if err := writeVectorRecords(s.Path, s.records); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Method to close the store, writing its pending changes to its file
+func (s *InMemoryVectorStore) Close() error {
+	return s.Flush()
+}
+
+// Method to save the records of the store to a JSON file
+func (s *InMemoryVectorStore) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return writeVectorRecords(path, s.records)
+}
+
+// Method to load the records of the store from a JSON file written by `Save`, replacing them. A missing file leaves the store empty.
+func (s *InMemoryVectorStore) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	records := []VectorRecord{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("error while loading the vector store %s: %w", path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+	return nil
+}
+
+// Private function writing vector records to a JSON file, replacing it atomically so that a crash never leaves a truncated store behind
+func writeVectorRecords(path string, records []VectorRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".vectors-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Private function sending a JSON request to an HTTP API (e.g. of a vector database) and decoding its JSON response into result (unless it is nil)
+func apiRequest(ctx context.Context, client *http.Client, method, url string, payload, result any, authenticate func(*http.Request)) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authenticate(req)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &apiError{url: url, status: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Private struct type representing an error returned by an HTTP API
+type apiError struct {
+	url     string
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("request to %s failed with status %d: %s", e.url, e.status, e.message)
+}
@@ -0,0 +1,48 @@
+package gopheract
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInMemoryVectorStoreFlush(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	store, err := OpenInMemoryVectorStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(id, documentID string) VectorRecord {
+		return VectorRecord{Chunk: Chunk{ID: id, DocumentID: documentID, Text: id}, Embedding: []float32{1, 0}}
+	}
+	if err := store.Add(ctx, record("a#0", "a"), record("b#0", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	// the changes are buffered until the store is flushed
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the store was written before being flushed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenInMemoryVectorStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 1 {
+		t.Errorf("got %d records after reopening the store, want 1", reopened.Len())
+	}
+	// the temporary file is renamed over the store
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files next to the store, want 1", len(entries))
+	}
+}
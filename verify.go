@@ -0,0 +1,104 @@
+package gopheract
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Maximum length of every tool result shown to the verifier, in bytes
+const verifierToolResultLimit = 2000
+
+// Default instructions of the verifier
+const verifierPrompt = "You are a strict reviewer checking the final answer of an AI agent before it is returned to the user. Check that the answer fully addresses the request, and that it is supported by the results of the tools the agent called: reject answers that are incomplete, that claim work the tool results do not show, or that contradict them. Approve answers that are correct and complete, even if they could be worded better."
+
+// Struct type representing the verdict of a verifier on the final answer of an agent
+type Verdict struct {
+	Approved bool   `json:"approved" jsonschema_description:"Whether the answer is correct, complete and supported by the tool results"`
+	Feedback string `json:"feedback" jsonschema_description:"What is wrong or missing in the answer and what the agent should do to fix it (empty if approved)"`
+}
+
+// Struct type representing a verification stage, in which a model checks the final answer of an agent against the request and the results of the tools called during the run, and sends the agent back to work when it rejects it
+type Verifier struct {
+	// Optional LLM checking the answers (defaults to the one of the agent)
+	Llm *OpenAILLM
+	// Optional instructions of the verifier, replacing the default critic prompt
+	Prompt string
+	// Maximum number of times an answer is sent back for rework in a run (defaults to 1), after which the last answer is returned even if rejected
+	MaxReworks int
+}
+
+// Constructor function for a new Verifier using the given LLM (nil for the one of the agent), sending answers back for rework up to maxReworks times per run
+func NewVerifier(llm *OpenAILLM, maxReworks int) *Verifier {
+	return &Verifier{Llm: llm, MaxReworks: maxReworks}
+}
+
+// Private method returning the maximum number of reworks of a run
+func (v *Verifier) maxReworks() int {
+	if v.MaxReworks <= 0 {
+		return 1
+	}
+	return v.MaxReworks
+}
+
+// Method asking the verifier whether the answer to a request is correct, given the tool results of the run (the usage of its LLM is reported to the callback of the given LLM, which is the one of the run)
+func (v *Verifier) Verify(runLlm *OpenAILLM, request string, toolResults []*ChatMessage, answer string) (Verdict, error) {
+	llm := *runLlm
+	if v.Llm != nil {
+		llm = *v.Llm
+		llm.ctx = runLlm.ctx
+		llm.OnUsage = runLlm.OnUsage
+	}
+	instructions := v.Prompt
+	if instructions == "" {
+		instructions = verifierPrompt
+	}
+	var review strings.Builder
+	fmt.Fprintf(&review, "## Request\n\n%s\n\n## Tool results\n", request)
+	if len(toolResults) == 0 {
+		review.WriteString("\nThe agent did not call any tool.\n")
+	}
+	for _, result := range toolResults {
+		content := result.Content
+		if len(content) > verifierToolResultLimit {
+			content = content[:verifierToolResultLimit] + "\n[truncated]"
+		}
+		fmt.Fprintf(&review, "\n```\n%s\n```\n", content)
+	}
+	fmt.Fprintf(&review, "\n## Answer\n\n%s", answer)
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(instructions),
+		openai.UserMessage(review.String()),
+	}
+	response, err := OpenAILLMStructuredPredict[Verdict](&llm, history, "verdict", "Verdict on the final answer of the agent")
+	if err != nil {
+		return Verdict{}, err
+	}
+	verdict, ok := response.(Verdict)
+	if !ok {
+		return Verdict{}, errors.New("error while verifying the answer: unexpected structured output")
+	}
+	return verdict, nil
+}
+
+// Helper method asking the verifier of the agent whether the answer of the current run is correct, given the tool results of the run
+func (o *OpenAIReActAgent) verify(prompt, answer string) (Verdict, error) {
+	history, err := o.History()
+	if err != nil {
+		return Verdict{}, err
+	}
+	toolResults := []*ChatMessage{}
+	for _, message := range currentRunMessages(history) {
+		if message.Phase == MessagePhaseTool {
+			toolResults = append(toolResults, message)
+		}
+	}
+	return o.Verifier.Verify(o.Llm, prompt, o.redact(toolResults), answer)
+}
+
+// Private function returning the message sending a rejected answer back for rework, with the feedback of the verifier
+func reworkMessage(answer string, verdict Verdict) *ChatMessage {
+	return NewPhaseMessage("user", MessagePhaseVerification, "", fmt.Sprintf("A reviewer rejected your answer, do not return it as it is: address the feedback, using the tools if needed, then answer again.\n\n## Rejected answer\n\n%s\n\n## Feedback\n\n%s", answer, verdict.Feedback))
+}
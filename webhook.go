@@ -0,0 +1,110 @@
+package gopheract
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Types of the notifications sent by a WebhookNotifier
+const (
+	WebhookRunStarted        = "run.started"
+	WebhookApprovalRequested = "tool.approval_requested"
+	WebhookRunCompleted      = "run.completed"
+	WebhookRunFailed         = "run.failed"
+)
+
+// Struct type representing a notification sent to the webhooks, as a JSON object.
+//
+// The Text field summarizes the notification, so that it can be posted as is to chat incoming webhooks (e.g. Slack).
+type WebhookPayload struct {
+	Type      string         `json:"type"`
+	Time      time.Time      `json:"time"`
+	SessionID string         `json:"session_id,omitempty"`
+	RunID     string         `json:"run_id,omitempty"`
+	Text      string         `json:"text"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// Struct type representing a notifier POSTing the lifecycle of the runs (start, file changes awaiting approval, completion and failure) to webhook URLs, so that long unattended runs can notify a chat or an orchestrator.
+//
+// When a secret is set, every request carries the HMAC-SHA256 of its body, hex-encoded, in the `X-Gopheract-Signature` header (as "sha256=<hex>"), so that receivers can authenticate it.
+type WebhookNotifier struct {
+	URLs []string
+	// Secret used to sign the requests (they are not signed if empty)
+	Secret string
+	// HTTP client used to perform the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Constructor function for a new WebhookNotifier, given the secret used to sign the requests and the URLs they are sent to
+func NewWebhookNotifier(secret string, urls ...string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URLs:   urls,
+		Secret: secret,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Method returning the signature of a request body, as sent in the `X-Gopheract-Signature` header
+func (n *WebhookNotifier) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(n.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send a notification to every webhook URL (no-op on nil WebhookNotifier), returning the joined errors of the failed deliveries
+func (n *WebhookNotifier) Notify(ctx context.Context, payload WebhookPayload) error {
+	if n == nil {
+		return nil
+	}
+	if payload.Time.IsZero() {
+		payload.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	authenticate := func(req *http.Request) {
+		req.Header.Set("X-Gopheract-Event", payload.Type)
+		if n.Secret != "" {
+			req.Header.Set("X-Gopheract-Signature", n.Sign(body))
+		}
+	}
+	var errs []error
+	for _, url := range n.URLs {
+		errs = append(errs, postJSON(ctx, n.Client, url, json.RawMessage(body), authenticate))
+	}
+	return errors.Join(errs...)
+}
+
+// Method wrapping an approval callback (e.g. `FileSystem.Approve`) so that a notification is sent whenever a change awaits approval, before asking for it (returns the callback unchanged on nil WebhookNotifier)
+func (n *WebhookNotifier) NotifyApprovals(approve func(change FileChange) (bool, error)) func(change FileChange) (bool, error) {
+	if n == nil {
+		return approve
+	}
+	return func(change FileChange) (bool, error) {
+		// failing to notify does not prevent the approval
+		_ = n.Notify(context.Background(), WebhookPayload{
+			Type: WebhookApprovalRequested,
+			Text: fmt.Sprintf("A change to %s is awaiting approval", change.Path),
+			Data: map[string]any{"path": change.Path, "diff": change.Diff},
+		})
+		return approve(change)
+	}
+}
+
+// Helper method that sends a notification about a run of the agent, logging (but otherwise ignoring) the failed deliveries
+func (o *OpenAIReActAgent) notify(logger *slog.Logger, payload WebhookPayload) {
+	payload.SessionID = o.SessionID
+	if err := o.Notifier.Notify(context.Background(), payload); err != nil {
+		logger.Warn("failed to send the webhook notification", "type", payload.Type, "error", err)
+	}
+}
@@ -0,0 +1,321 @@
+package gopheract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Struct type representing a node of a workflow: an agent, a tool or a Go function, run on the output of the node it is reached from
+type WorkflowNode struct {
+	// Name of the node, unique within the workflow
+	Name string
+	// Function doing the work of the node, given its input
+	Run func(ctx context.Context, input any) (any, error)
+	// Number of times the node is run again when it fails (zero means no retries)
+	Retries int
+	// Delay between the attempts of the node
+	RetryDelay time.Duration
+}
+
+// Struct type representing an edge of a workflow, passing the output of a node to another one
+type WorkflowEdge struct {
+	From string
+	To   string
+	// Optional condition on the output of the source node: the edge is followed only if it holds (e.g. to branch on the verdict of a reviewer)
+	When func(output any) (bool, error)
+	// Optional mapping of the output of the source node to the input of the target node
+	Map func(output any) (any, error)
+}
+
+// Function returning an edge whose source node outputs a T, followed when the condition on the output holds (always, if the condition is nil).
+//
+// The workflow fails if the output is not a T, so that the edges check the types flowing between the nodes.
+func Edge[T any](from, to string, when func(output T) bool) WorkflowEdge {
+	return WorkflowEdge{
+		From: from,
+		To:   to,
+		When: func(output any) (bool, error) {
+			typed, err := workflowValue[T](output)
+			if err != nil {
+				return false, fmt.Errorf("output of node %s: %w", from, err)
+			}
+			return when == nil || when(typed), nil
+		},
+	}
+}
+
+// Function returning an edge converting the output of its source node (a From) to the input of its target node (a To)
+func MapEdge[From, To any](from, to string, mapping func(output From) (To, error)) WorkflowEdge {
+	return WorkflowEdge{
+		From: from,
+		To:   to,
+		Map: func(output any) (any, error) {
+			typed, err := workflowValue[From](output)
+			if err != nil {
+				return nil, fmt.Errorf("output of node %s: %w", from, err)
+			}
+			return mapping(typed)
+		},
+	}
+}
+
+// Private function converting a value flowing through a workflow to a T (nil converts to the zero value)
+func workflowValue[T any](value any) (T, error) {
+	var zero T
+	if value == nil {
+		return zero, nil
+	}
+	typed, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("expected a %s, got a %T", reflect.TypeFor[T](), value)
+	}
+	return typed, nil
+}
+
+// Function returning a node running a Go function, whose input must be an In
+func FuncNode[In, Out any](name string, fn func(ctx context.Context, input In) (Out, error)) WorkflowNode {
+	return WorkflowNode{
+		Name: name,
+		Run: func(ctx context.Context, input any) (any, error) {
+			typed, err := workflowValue[In](input)
+			if err != nil {
+				return nil, fmt.Errorf("input: %w", err)
+			}
+			return fn(ctx, typed)
+		},
+	}
+}
+
+// Function returning a node executing a tool, whose input must be the map of its arguments
+func ToolNode(name string, tool Tool) WorkflowNode {
+	return WorkflowNode{
+		Name: name,
+		Run: func(ctx context.Context, input any) (any, error) {
+			args, err := workflowValue[map[string]any](input)
+			if err != nil {
+				return nil, fmt.Errorf("input: %w", err)
+			}
+			if args == nil {
+				args = map[string]any{}
+			}
+			return tool.Execute(args)
+		},
+	}
+}
+
+// Function returning a node running an agent until it answers, on a copy with an in-memory history. Its input is the prompt (inputs other than strings are passed as JSON) and its output the final answer.
+//
+// The events of the run are passed to the `OnEvent` callback of the workflow.
+func AgentNode(name string, agent *OpenAIReActAgent) WorkflowNode {
+	return WorkflowNode{
+		Name: name,
+		Run: func(ctx context.Context, input any) (any, error) {
+			prompt, ok := input.(string)
+			if !ok {
+				encoded, err := json.Marshal(input)
+				if err != nil {
+					return nil, fmt.Errorf("input: %w", err)
+				}
+				prompt = string(encoded)
+			}
+			onEvent, _ := ctx.Value(workflowEventsKey{}).(func(string, AgentEvent))
+			node := *agent
+			node.Memory = NewInMemoryStore()
+			node.SessionID = fmt.Sprintf("%s/%s", agent.SessionID, name)
+			var answer string
+			err := node.RunEventsContext(ctx, prompt, nil, func(event AgentEvent) {
+				if e, ok := event.(StopEvent); ok {
+					answer = e.Reason
+				}
+				if onEvent != nil {
+					onEvent(name, event)
+				}
+			})
+			return answer, err
+		},
+	}
+}
+
+// Key of the context value holding the callback receiving the events of the agent nodes
+type workflowEventsKey struct{}
+
+// Struct type representing the outcome of a workflow
+type WorkflowResult struct {
+	// Output of the last node that ran
+	Output any
+	// Outputs of the nodes that ran, by name
+	Outputs map[string]any
+	// Names of the nodes that ran, in order
+	Order []string
+}
+
+// Struct type representing a workflow: a directed acyclic graph of nodes (agents, tools or Go functions) connected by edges, with conditional branching and retries, giving deterministic structure to tasks around the agents.
+//
+// The nodes without incoming edges receive the input of the workflow. The nodes run one at a time in topological order: a node runs if at least one of its incoming edges is followed, and it is skipped (along with its outgoing edges) otherwise. A node reached by a single edge receives the output carried by that edge, and a node reached by several edges (a join) receives a map of their outputs by source node.
+type Workflow struct {
+	Name  string
+	Nodes []WorkflowNode
+	Edges []WorkflowEdge
+	// Optional callback receiving the events of the runs of the agent nodes, along with the name of the node
+	OnEvent func(node string, event AgentEvent)
+}
+
+// Constructor function for a new, empty Workflow
+func NewWorkflow(name string) *Workflow {
+	return &Workflow{Name: name}
+}
+
+// Method to add a node to the workflow, returning the workflow itself so that calls can be chained
+func (w *Workflow) AddNode(node WorkflowNode) *Workflow {
+	w.Nodes = append(w.Nodes, node)
+	return w
+}
+
+// Method to add an edge to the workflow, returning the workflow itself so that calls can be chained
+func (w *Workflow) AddEdge(edge WorkflowEdge) *Workflow {
+	w.Edges = append(w.Edges, edge)
+	return w
+}
+
+// Method to run the workflow on an input.
+//
+// It fails as soon as a node fails after its retries, or an edge cannot be evaluated.
+func (w *Workflow) Run(ctx context.Context, input any) (*WorkflowResult, error) {
+	order, err := w.sort()
+	if err != nil {
+		return nil, err
+	}
+	if w.OnEvent != nil {
+		ctx = context.WithValue(ctx, workflowEventsKey{}, w.OnEvent)
+	}
+	result := &WorkflowResult{Outputs: map[string]any{}}
+	// outputs carried by the edges followed so far, by target node and source node
+	carried := map[string]map[string]any{}
+	for _, node := range order {
+		nodeInput := input
+		if slices.ContainsFunc(w.Edges, func(edge WorkflowEdge) bool { return edge.To == node.Name }) {
+			inputs := carried[node.Name]
+			if len(inputs) == 0 {
+				continue
+			}
+			nodeInput = any(inputs)
+			if len(inputs) == 1 {
+				for _, value := range inputs {
+					nodeInput = value
+				}
+			}
+		}
+		output, err := w.runNode(ctx, node, nodeInput)
+		if err != nil {
+			return nil, err
+		}
+		result.Outputs[node.Name] = output
+		result.Order = append(result.Order, node.Name)
+		result.Output = output
+		for _, edge := range w.Edges {
+			if edge.From != node.Name {
+				continue
+			}
+			value, followed, err := edge.follow(output)
+			if err != nil {
+				return nil, fmt.Errorf("edge from %s to %s: %w", edge.From, edge.To, err)
+			}
+			if !followed {
+				continue
+			}
+			if carried[edge.To] == nil {
+				carried[edge.To] = map[string]any{}
+			}
+			carried[edge.To][edge.From] = value
+		}
+	}
+	return result, nil
+}
+
+// Private method returning the value an edge carries to its target node, and whether it is followed
+func (e WorkflowEdge) follow(output any) (any, bool, error) {
+	if e.When != nil {
+		ok, err := e.When(output)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+	}
+	if e.Map == nil {
+		return output, true, nil
+	}
+	value, err := e.Map(output)
+	return value, err == nil, err
+}
+
+// Private method running a node on its input, retrying it when it fails
+func (w *Workflow) runNode(ctx context.Context, node WorkflowNode, input any) (any, error) {
+	var err error
+	for attempt := 0; attempt <= node.Retries; attempt++ {
+		if attempt > 0 && node.RetryDelay > 0 {
+			select {
+			case <-time.After(node.RetryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		var output any
+		output, err = node.Run(ctx, input)
+		if err == nil {
+			return output, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if node.Retries > 0 {
+		return nil, fmt.Errorf("node %s failed after %d attempts: %w", node.Name, node.Retries+1, err)
+	}
+	return nil, fmt.Errorf("node %s failed: %w", node.Name, err)
+}
+
+// Private method checking the nodes and edges of the workflow and returning the nodes in topological order (nodes added earlier come first among the ones that are ready)
+func (w *Workflow) sort() ([]WorkflowNode, error) {
+	if len(w.Nodes) == 0 {
+		return nil, errors.New("the workflow has no nodes")
+	}
+	indegree := map[string]int{}
+	for _, node := range w.Nodes {
+		if node.Name == "" || node.Run == nil {
+			return nil, errors.New("every node needs a name and a function")
+		}
+		if _, ok := indegree[node.Name]; ok {
+			return nil, fmt.Errorf("duplicate node %s", node.Name)
+		}
+		indegree[node.Name] = 0
+	}
+	for _, edge := range w.Edges {
+		for _, name := range []string{edge.From, edge.To} {
+			if _, ok := indegree[name]; !ok {
+				return nil, fmt.Errorf("edge from %s to %s: unknown node %s", edge.From, edge.To, name)
+			}
+		}
+		indegree[edge.To]++
+	}
+	order := make([]WorkflowNode, 0, len(w.Nodes))
+	done := map[string]bool{}
+	for len(order) < len(w.Nodes) {
+		index := slices.IndexFunc(w.Nodes, func(node WorkflowNode) bool { return !done[node.Name] && indegree[node.Name] == 0 })
+		if index < 0 {
+			return nil, errors.New("the workflow has a cycle")
+		}
+		node := w.Nodes[index]
+		done[node.Name] = true
+		order = append(order, node)
+		for _, edge := range w.Edges {
+			if edge.From == node.Name {
+				indegree[edge.To]--
+			}
+		}
+	}
+	return order, nil
+}